@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// route is parsed from handler doc comment annotations:
+//
+//	@Summary <text>
+//	@Description <text>
+//	@Tags <tag>[,<tag>]
+//	@Param <name> <query|header> <type> [required] ["description"]
+//	@Body <type> [content type]
+//	@Success <code> <type> ["description"]
+//	@Produce <content type>
+//	@Security none
+//	@Server <url>
+//	@Router <path> [<method>]
+//
+// Types are Go type expressions resolved in scope of the handler function,
+// so function local types like form or resp can be used. For multipart
+// form data body the type is name of the uploaded file field. Responses
+// are JSON unless @Produce is set, @Server overrides API base URL for
+// routes mounted outside of it.
+type route struct {
+	path        string
+	method      string
+	summary     string
+	description string
+	tags        []string
+	params      []param
+	body        string
+	bodyType    string
+	responses   []resp
+	produce     string
+	public      bool
+	server      string
+}
+
+type param struct {
+	name        string
+	in          string
+	typ         string
+	required    bool
+	description string
+}
+
+type resp struct {
+	code        string
+	typ         string
+	description string
+}
+
+var methods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true, "patch": true, "head": true, "options": true,
+}
+
+// parseAnnotations returns route parsed from doc comment or nil if
+// comment does not contain @Router annotation.
+func parseAnnotations(doc string) (*route, error) {
+	r := &route{}
+	var found bool
+
+	for _, line := range strings.Split(doc, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "@") {
+			continue
+		}
+		fields := strings.Fields(line)
+		tag, args := fields[0], fields[1:]
+		rest := strings.TrimSpace(strings.TrimPrefix(line, tag))
+		text, desc := splitDescription(rest)
+
+		switch strings.ToLower(tag) {
+		case "@summary":
+			r.summary = rest
+		case "@description":
+			r.description = strings.TrimSpace(r.description + " " + rest)
+		case "@tags":
+			for _, t := range strings.Split(rest, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					r.tags = append(r.tags, t)
+				}
+			}
+		case "@param":
+			args = strings.Fields(text)
+			if len(args) < 3 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			if args[1] != "query" && args[1] != "header" && args[1] != "path" {
+				return nil, fmt.Errorf("invalid parameter location %s", args[1])
+			}
+			p := param{name: args[0], in: args[1], typ: args[2], description: desc}
+			if len(args) > 3 && args[3] == "required" {
+				p.required = true
+			}
+			r.params = append(r.params, p)
+		case "@body":
+			if len(args) < 1 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			r.body = args[0]
+			if len(args) > 1 {
+				r.bodyType = args[1]
+			}
+		case "@success":
+			args = strings.Fields(text)
+			if len(args) < 2 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			if desc == "" {
+				desc = "successful operation"
+			}
+			r.responses = append(r.responses, resp{code: args[0], typ: strings.Join(args[1:], " "), description: desc})
+		case "@produce":
+			if len(args) != 1 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			r.produce = args[0]
+		case "@security":
+			r.public = rest == "none"
+		case "@server":
+			if len(args) != 1 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			r.server = args[0]
+		case "@router":
+			if len(args) < 1 {
+				return nil, fmt.Errorf("invalid annotation %q", line)
+			}
+			r.path, r.method = args[0], "get"
+			if len(args) > 1 {
+				r.method = strings.ToLower(strings.Trim(args[1], "[]"))
+			}
+			if !methods[r.method] {
+				return nil, fmt.Errorf("invalid method %s", r.method)
+			}
+			found = true
+		default:
+			return nil, fmt.Errorf("unknown annotation %s", tag)
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// splitDescription splits trailing quoted description from text.
+func splitDescription(text string) (string, string) {
+	if !strings.HasSuffix(text, `"`) {
+		return text, ""
+	}
+	i := strings.Index(text, `"`)
+	if i == len(text)-1 {
+		return text, ""
+	}
+	return strings.TrimSpace(text[:i]), text[i+1 : len(text)-1]
+}
@@ -0,0 +1,109 @@
+package main
+
+// OpenAPI 3 document types, only parts used by the generator are defined.
+type (
+	document struct {
+		OpenAPI    string                           `json:"openapi"`
+		Info       info                             `json:"info"`
+		Servers    []server                         `json:"servers"`
+		Paths      map[string]map[string]*operation `json:"paths"`
+		Components components                       `json:"components"`
+		Security   []map[string][]string            `json:"security"`
+	}
+
+	info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	}
+
+	server struct {
+		URL string `json:"url"`
+	}
+
+	components struct {
+		Schemas         map[string]*schema         `json:"schemas"`
+		SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+	}
+
+	securityScheme struct {
+		Type         string `json:"type"`
+		Description  string `json:"description,omitempty"`
+		Scheme       string `json:"scheme,omitempty"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+		Name         string `json:"name,omitempty"`
+		In           string `json:"in,omitempty"`
+	}
+
+	operation struct {
+		Summary     string                 `json:"summary,omitempty"`
+		Description string                 `json:"description,omitempty"`
+		OperationID string                 `json:"operationId"`
+		Tags        []string               `json:"tags,omitempty"`
+		Parameters  []*parameter           `json:"parameters,omitempty"`
+		RequestBody *requestBody           `json:"requestBody,omitempty"`
+		Responses   map[string]*response   `json:"responses"`
+		Security    *[]map[string][]string `json:"security,omitempty"` // empty for public operations
+		Servers     []server               `json:"servers,omitempty"`
+	}
+
+	parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *schema `json:"schema"`
+	}
+
+	requestBody struct {
+		Required bool                  `json:"required"`
+		Content  map[string]*mediaType `json:"content"`
+	}
+
+	response struct {
+		Description string                `json:"description"`
+		Content     map[string]*mediaType `json:"content,omitempty"`
+	}
+
+	mediaType struct {
+		Schema *schema `json:"schema"`
+	}
+
+	schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Properties           map[string]*schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Items                *schema            `json:"items,omitempty"`
+		AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	}
+)
+
+func newDocument() *document {
+	return &document{
+		OpenAPI: "3.0.3",
+		Info: info{
+			Title:       "Abstruse CI API",
+			Description: "HTTP API of Abstruse CI server.",
+		},
+		Servers: []server{{URL: "/api/v1"}},
+		Paths:   make(map[string]map[string]*operation),
+		Components: components{
+			SecuritySchemes: map[string]*securityScheme{
+				"bearer": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "JWT access token returned by login, API keys are accepted as bearer token too.",
+				},
+				"apiKey": {
+					Type: "apiKey",
+					Name: "X-API-Key",
+					In:   "header",
+				},
+			},
+		},
+		Security: []map[string][]string{{"bearer": {}}, {"apiKey": {}}},
+	}
+}
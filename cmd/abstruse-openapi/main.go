@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	root   = flag.String("root", ".", "module root directory")
+	dir    = flag.String("dir", "server/api", "directory with annotated http handlers")
+	routes = flag.String("routes", "server/api/api.go", "file with router mounting the handlers")
+	output = flag.String("o", "server/api/openapi/openapi_gen.go", "output file")
+)
+
+func main() {
+	flag.Parse()
+
+	g, err := newGenerator(*root)
+	if err != nil {
+		fatal(err)
+	}
+
+	doc, err := g.generate(filepath.Join(*root, *dir))
+	if err != nil {
+		fatal(err)
+	}
+	if err := g.checkRoutes(doc, filepath.Join(*root, *routes)); err != nil {
+		fatal(err)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := writeSpec(*output, data); err != nil {
+		fatal(err)
+	}
+}
+
+// generate walks directory and returns OpenAPI document built from
+// annotated handler functions.
+func (g *generator) generate(dir string) (doc *document, err error) {
+	doc = newDocument()
+	if doc.Info.Version, err = g.apiVersion(); err != nil {
+		return nil, err
+	}
+
+	render, err := g.loadDir(filepath.Join(dir, "render"))
+	if err != nil {
+		return nil, err
+	}
+	if ts, ok := render.types["Error"]; ok {
+		if _, err := g.namedSchema(render, fileOf(render, ts), ts); err != nil {
+			return nil, err
+		}
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		pkg, err := g.loadDir(path)
+		if err != nil || pkg == nil {
+			return err
+		}
+
+		for _, file := range pkg.files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Doc == nil || fn.Recv != nil {
+					continue
+				}
+				route, err := parseAnnotations(fn.Doc.Text())
+				if err != nil {
+					return fmt.Errorf("%s: %s: %v", pkg.path, fn.Name.Name, err)
+				}
+				if route == nil {
+					continue
+				}
+				s := &scope{pkg: pkg, file: file, fn: fn}
+				if err := g.addOperation(doc, s, route); err != nil {
+					return fmt.Errorf("%s: %s: %v", pkg.path, fn.Name.Name, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc, nil
+}
+
+func (g *generator) addOperation(doc *document, s *scope, r *route) (err error) {
+	op := &operation{
+		Summary:     r.summary,
+		Description: r.description,
+		OperationID: fmt.Sprintf("%s%s", s.pkg.name, strings.TrimPrefix(s.fn.Name.Name, "Handle")),
+		Tags:        r.tags,
+		Responses:   make(map[string]*response),
+	}
+
+	declared := make(map[string]bool)
+	for _, p := range r.params {
+		declared[p.name] = p.in == "path"
+	}
+	for _, name := range pathParams(r.path) {
+		if declared[name] {
+			continue
+		}
+		op.Parameters = append(op.Parameters, &parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   pathSchema(name),
+		})
+	}
+	for _, p := range r.params {
+		sch, err := g.schemaOf(s, p.typ)
+		if err != nil {
+			return err
+		}
+		op.Parameters = append(op.Parameters, &parameter{
+			Name:        p.name,
+			In:          p.in,
+			Required:    p.required || p.in == "path",
+			Description: p.description,
+			Schema:      sch,
+		})
+	}
+
+	if r.body != "" {
+		content := "application/json"
+		if r.bodyType != "" {
+			content = r.bodyType
+		}
+		var sch *schema
+		if content == "multipart/form-data" {
+			sch = &schema{Type: "object", Properties: map[string]*schema{
+				r.body: {Type: "string", Format: "binary"},
+			}}
+		} else if sch, err = g.schemaOf(s, r.body); err != nil {
+			return err
+		}
+		op.RequestBody = &requestBody{
+			Required: true,
+			Content:  map[string]*mediaType{content: {Schema: sch}},
+		}
+	}
+
+	produce := "application/json"
+	if r.produce != "" {
+		produce = r.produce
+	}
+	for _, resp := range r.responses {
+		sch, err := g.schemaOf(s, resp.typ)
+		if err != nil {
+			return err
+		}
+		op.Responses[resp.code] = &response{
+			Description: resp.description,
+			Content:     map[string]*mediaType{produce: {Schema: sch}},
+		}
+	}
+	op.Responses["default"] = &response{
+		Description: "error",
+		Content:     map[string]*mediaType{"application/json": {Schema: &schema{Ref: "#/components/schemas/Error"}}},
+	}
+
+	if r.public {
+		op.Security = &[]map[string][]string{}
+	}
+	if r.server != "" {
+		op.Servers = []server{{URL: r.server}}
+	}
+
+	item, ok := doc.Paths[r.path]
+	if !ok {
+		item = make(map[string]*operation)
+		doc.Paths[r.path] = item
+	}
+	if _, ok := item[r.method]; ok {
+		return fmt.Errorf("duplicate operation %s %s", strings.ToUpper(r.method), r.path)
+	}
+	item[r.method] = op
+	return nil
+}
+
+// pathSchema returns schema of path parameter, parameters named id or
+// ending with id are database identifiers.
+func pathSchema(name string) *schema {
+	if strings.HasSuffix(strings.ToLower(name), "id") {
+		return &schema{Type: "integer"}
+	}
+	return &schema{Type: "string"}
+}
+
+func pathParams(path string) []string {
+	var params []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, strings.Trim(part, "{}"))
+		}
+	}
+	sort.Strings(params)
+	return params
+}
+
+// apiVersion returns value of version.APIVersion constant.
+func (g *generator) apiVersion() (string, error) {
+	p, err := g.loadImport(g.module + "/internal/version")
+	if err != nil || p == nil {
+		return "", fmt.Errorf("version package not found")
+	}
+	for _, file := range p.files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if name.Name != "APIVersion" || i >= len(vs.Values) {
+						continue
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok {
+						return strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("APIVersion constant not found")
+}
+
+func writeSpec(path string, data []byte) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by abstruse-openapi. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package openapi\n\n")
+	fmt.Fprintf(&buf, "func init() {\n\tspec = []byte(%s)\n}\n", "`"+strings.ReplaceAll(string(data), "`", "` + \"`\" + `")+"`")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, src, 0644)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "abstruse-openapi: %v\n", err)
+	os.Exit(1)
+}
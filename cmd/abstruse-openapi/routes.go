@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mounted is route registered on the router with handler of package
+// with annotated handlers.
+type mounted struct {
+	method  string
+	path    string
+	handler string // e.g. user.HandleList
+	opID    string
+}
+
+// routeMethods are chi router methods registering routes.
+var routeMethods = map[string]string{
+	"Get": "get", "Post": "post", "Put": "put", "Delete": "delete", "Patch": "patch", "Head": "head", "Options": "options",
+}
+
+// checkRoutes compares routes mounted by Router methods in file with
+// annotated operations, each mounted route of handler packages must be
+// documented and each operation must be mounted.
+func (g *generator) checkRoutes(doc *document, file string) error {
+	f, err := parser.ParseFile(g.fset, file, nil, 0)
+	if err != nil {
+		return err
+	}
+	methods := make(map[string]*ast.BlockStmt)
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && fn.Body != nil {
+			methods[fn.Name.Name] = fn.Body
+		}
+	}
+	handlers := make(map[string]bool)
+	for path, p := range g.packages {
+		if p != nil && strings.HasPrefix(path, g.module+"/"+strings.Trim(*dir, "/")+"/") {
+			handlers[p.name] = true
+		}
+	}
+
+	var routes []mounted
+	var walk func(body *ast.BlockStmt, prefix string) error
+	walk = func(body *ast.BlockStmt, prefix string) (err error) {
+		ast.Inspect(body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || err != nil || len(call.Args) != 2 {
+				return err == nil
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			method, ok := routeMethods[sel.Sel.Name]
+			if !ok && sel.Sel.Name != "Mount" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok {
+				err = fmt.Errorf("%s: route path is not string literal", g.fset.Position(call.Pos()))
+				return false
+			}
+			path, _ := strconv.Unquote(lit.Value)
+			h, ok := call.Args[1].(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			hsel, ok := h.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			x, ok := hsel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if sel.Sel.Name == "Mount" {
+				if body, ok := methods[hsel.Sel.Name]; ok && x.Name == "r" {
+					err = walk(body, prefix+path)
+				}
+				return err == nil
+			}
+			if handlers[x.Name] {
+				routes = append(routes, mounted{
+					method:  method,
+					path:    prefix + path,
+					handler: x.Name + "." + hsel.Sel.Name,
+					opID:    x.Name + strings.TrimPrefix(hsel.Sel.Name, "Handle"),
+				})
+			}
+			return true
+		})
+		return err
+	}
+	body, ok := methods["Handler"]
+	if !ok {
+		return fmt.Errorf("%s: Handler method not found", file)
+	}
+	if err := walk(body, ""); err != nil {
+		return err
+	}
+
+	operations := make(map[string]*operation)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			server := doc.Servers[0].URL
+			if len(op.Servers) > 0 {
+				server = op.Servers[0].URL
+			}
+			operations[method+" "+routeKey(strings.TrimSuffix(server, "/")+path)] = op
+		}
+	}
+
+	var errs []string
+	seen := make(map[string]bool)
+	for _, r := range routes {
+		key := r.method + " " + routeKey(r.path)
+		seen[key] = true
+		op, ok := operations[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s %s: %s has no @Router annotation", strings.ToUpper(r.method), r.path, r.handler))
+		} else if op.OperationID != r.opID {
+			errs = append(errs, fmt.Sprintf("%s %s: mounted %s, annotated operation is %s", strings.ToUpper(r.method), r.path, r.handler, op.OperationID))
+		}
+	}
+	for key, op := range operations {
+		if !seen[key] {
+			errs = append(errs, fmt.Sprintf("%s: operation %s is not mounted", strings.ToUpper(key), op.OperationID))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("routes do not match annotations:\n\t%s", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// routeKey returns path with parameter names and wildcards replaced,
+// trailing slash is removed.
+func routeKey(path string) string {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for i, part := range parts {
+		if part == "*" || strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = "{}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
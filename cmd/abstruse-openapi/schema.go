@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// generator resolves Go types of the module to OpenAPI schemas.
+type generator struct {
+	root     string
+	module   string
+	fset     *token.FileSet
+	packages map[string]*pkg // by import path
+	schemas  map[string]*schema
+	names    map[string]string // schema name by qualified type name
+}
+
+type pkg struct {
+	path  string
+	name  string
+	files []*ast.File
+	types map[string]*ast.TypeSpec
+}
+
+// scope is handler function in which type expressions are resolved.
+type scope struct {
+	pkg  *pkg
+	file *ast.File
+	fn   *ast.FuncDecl
+}
+
+// knownTypes are types from outside of the module with custom JSON encoding.
+var knownTypes = map[string]*schema{
+	"time.Time":            {Type: "string", Format: "date-time"},
+	"time.Duration":        {Type: "integer", Format: "int64"},
+	"json.RawMessage":      {},
+	"sql.NullString":       {Type: "string"},
+	"multipart.FileHeader": {Type: "string", Format: "binary"},
+}
+
+func newGenerator(root string) (*generator, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	var module string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "module ") {
+			module = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+		}
+	}
+	if module == "" {
+		return nil, fmt.Errorf("module path not found in go.mod")
+	}
+
+	return &generator{
+		root:     root,
+		module:   module,
+		fset:     token.NewFileSet(),
+		packages: make(map[string]*pkg),
+		schemas:  make(map[string]*schema),
+		names:    make(map[string]string),
+	}, nil
+}
+
+// loadDir parses Go package in directory, nil is returned when directory
+// does not contain Go files.
+func (g *generator) loadDir(dir string) (*pkg, error) {
+	rel, err := filepath.Rel(g.root, dir)
+	if err != nil {
+		return nil, err
+	}
+	path := g.module
+	if rel != "." {
+		path = g.module + "/" + filepath.ToSlash(rel)
+	}
+	if p, ok := g.packages[path]; ok {
+		return p, nil
+	}
+
+	filter := func(fi os.FileInfo) bool { return !strings.HasSuffix(fi.Name(), "_test.go") }
+	pkgs, err := parser.ParseDir(g.fset, dir, filter, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var p *pkg
+	for name, astPkg := range pkgs {
+		p = &pkg{path: path, name: name, types: make(map[string]*ast.TypeSpec)}
+		for _, file := range astPkg.Files {
+			p.files = append(p.files, file)
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					p.types[ts.Name.Name] = ts
+				}
+			}
+		}
+	}
+	g.packages[path] = p
+	return p, nil
+}
+
+// loadImport returns package of the module by import path or nil for
+// packages outside of the module.
+func (g *generator) loadImport(path string) (*pkg, error) {
+	if path != g.module && !strings.HasPrefix(path, g.module+"/") {
+		return nil, nil
+	}
+	rel := strings.TrimPrefix(strings.TrimPrefix(path, g.module), "/")
+	return g.loadDir(filepath.Join(g.root, filepath.FromSlash(rel)))
+}
+
+// schemaOf returns schema of Go type expression resolved in scope.
+func (g *generator) schemaOf(s *scope, expr string) (*schema, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %s: %v", expr, err)
+	}
+	return g.schemaExpr(s, e)
+}
+
+func (g *generator) schemaExpr(s *scope, expr ast.Expr) (*schema, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if ts := s.localType(t.Name); ts != nil {
+			return g.schemaExpr(s, ts.Type)
+		}
+		if ts, ok := s.pkg.types[t.Name]; ok {
+			return g.namedSchema(s.pkg, s.fileOf(ts), ts)
+		}
+		return basicSchema(t.Name)
+	case *ast.StarExpr:
+		return g.schemaExpr(s, t.X)
+	case *ast.ParenExpr:
+		return g.schemaExpr(s, t.X)
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && id.Name == "byte" {
+			return &schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schemaExpr(s, t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		values, err := g.schemaExpr(s, t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.InterfaceType:
+		return &schema{}, nil
+	case *ast.StructType:
+		return g.structSchema(s, t)
+	case *ast.SelectorExpr:
+		return g.selectorSchema(s, t)
+	}
+	return nil, fmt.Errorf("unsupported type %T", expr)
+}
+
+func (g *generator) selectorSchema(s *scope, sel *ast.SelectorExpr) (*schema, error) {
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported type selector")
+	}
+	path := importPath(s.file, x.Name)
+	if path == "" {
+		return nil, fmt.Errorf("unknown package %s", x.Name)
+	}
+	name := fmt.Sprintf("%s.%s", filepath.Base(path), sel.Sel.Name)
+	if sch, ok := knownTypes[name]; ok {
+		copy := *sch
+		return &copy, nil
+	}
+
+	p, err := g.loadImport(path)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return &schema{Type: "object"}, nil
+	}
+	ts, ok := p.types[sel.Sel.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	return g.namedSchema(p, fileOf(p, ts), ts)
+}
+
+// namedSchema returns reference to component schema for package level
+// struct types, other named types are resolved to their underlying type.
+func (g *generator) namedSchema(p *pkg, file *ast.File, ts *ast.TypeSpec) (*schema, error) {
+	s := &scope{pkg: p, file: file}
+	if _, ok := ts.Type.(*ast.StructType); !ok {
+		return g.schemaExpr(s, ts.Type)
+	}
+
+	qualified := p.path + "." + ts.Name.Name
+	if name, ok := g.names[qualified]; ok {
+		return &schema{Ref: "#/components/schemas/" + name}, nil
+	}
+
+	name := strings.Title(ts.Name.Name)
+	if _, ok := g.schemas[name]; ok {
+		name = strings.Title(p.name) + name
+	}
+	g.names[qualified] = name
+	g.schemas[name] = &schema{} // placeholder for recursive types
+
+	sch, err := g.schemaExpr(s, ts.Type)
+	if err != nil {
+		return nil, err
+	}
+	g.schemas[name] = sch
+	return &schema{Ref: "#/components/schemas/" + name}, nil
+}
+
+func (g *generator) structSchema(s *scope, st *ast.StructType) (*schema, error) {
+	sch := &schema{Type: "object", Properties: make(map[string]*schema)}
+
+	for _, field := range st.Fields.List {
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			value, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(value)
+		}
+		jsonTag := tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name := strings.Split(jsonTag, ",")[0]
+		if len(field.Names) > 0 && !ast.IsExported(field.Names[0].Name) {
+			continue
+		}
+
+		if len(field.Names) == 0 && name == "" {
+			if err := g.embed(s, sch, field.Type); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		fieldSchema, err := g.schemaExpr(s, field.Type)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(jsonTag, ",string") {
+			fieldSchema = &schema{Type: "string"}
+		}
+		required := strings.Contains(tag.Get("valid"), "required")
+
+		names := []string{name}
+		if name == "" {
+			names = nil
+			for _, n := range field.Names {
+				names = append(names, n.Name)
+			}
+		}
+		for _, n := range names {
+			sch.Properties[n] = fieldSchema
+			if required {
+				sch.Required = append(sch.Required, n)
+			}
+		}
+	}
+
+	return sch, nil
+}
+
+// embed merges properties of embedded struct into schema.
+func (g *generator) embed(s *scope, sch *schema, expr ast.Expr) error {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	embedded, err := g.schemaExpr(s, expr)
+	if err != nil {
+		return err
+	}
+	if embedded.Ref != "" {
+		embedded = g.schemas[strings.TrimPrefix(embedded.Ref, "#/components/schemas/")]
+	}
+	for name, prop := range embedded.Properties {
+		if _, ok := sch.Properties[name]; !ok {
+			sch.Properties[name] = prop
+		}
+	}
+	sch.Required = append(sch.Required, embedded.Required...)
+	return nil
+}
+
+// localType returns type declared in the handler function body.
+func (s *scope) localType(name string) *ast.TypeSpec {
+	if s.fn == nil || s.fn.Body == nil {
+		return nil
+	}
+	var found *ast.TypeSpec
+	ast.Inspect(s.fn.Body, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == name && found == nil {
+			found = ts
+		}
+		return found == nil
+	})
+	return found
+}
+
+func (s *scope) fileOf(ts *ast.TypeSpec) *ast.File {
+	return fileOf(s.pkg, ts)
+}
+
+func fileOf(p *pkg, ts *ast.TypeSpec) *ast.File {
+	for _, file := range p.files {
+		if file.Pos() <= ts.Pos() && ts.End() <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
+// importPath returns import path of the package referenced by name in file.
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return path
+			}
+			continue
+		}
+		base := path[strings.LastIndex(path, "/")+1:]
+		if base == name || strings.TrimPrefix(base, "go-") == name {
+			return path
+		}
+	}
+	return ""
+}
+
+func basicSchema(name string) (*schema, error) {
+	switch name {
+	case "string":
+		return &schema{Type: "string"}, nil
+	case "bool":
+		return &schema{Type: "boolean"}, nil
+	case "int", "uint":
+		return &schema{Type: "integer"}, nil
+	case "int8", "int16", "int32", "uint8", "uint16", "uint32", "byte", "rune":
+		return &schema{Type: "integer", Format: "int32"}, nil
+	case "int64", "uint64":
+		return &schema{Type: "integer", Format: "int64"}, nil
+	case "float32":
+		return &schema{Type: "number", Format: "float"}, nil
+	case "float64":
+		return &schema{Type: "number", Format: "double"}, nil
+	case "error":
+		return &schema{Type: "string"}, nil
+	}
+	return nil, fmt.Errorf("unknown type %s", name)
+}
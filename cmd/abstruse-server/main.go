@@ -1,9 +1,16 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	_ "time/tzdata" // display time zones on hosts without tzdata
+
 	"github.com/bleenco/abstruse/server/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	if err := cmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
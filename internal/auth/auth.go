@@ -1,9 +1,6 @@
 package auth
 
-import (
-	"fmt"
-	"os"
-)
+import "errors"
 
 // JWT is exposed JWT authenticator with middlewares
 // to verify access tokens.
@@ -15,12 +12,11 @@ var (
 )
 
 // Init authentication constants from config.
-func Init(secret string) {
+func Init(secret string) error {
+	if secret == "" {
+		return errors.New("JWT secret must not be empty")
+	}
 	JWTSecret = []byte(secret)
 	JWT = NewJWTAuth("HS256")
-}
-
-func fatal(msg interface{}) {
-	fmt.Println(msg)
-	os.Exit(1)
+	return nil
 }
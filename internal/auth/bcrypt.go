@@ -1,17 +1,14 @@
 package auth
 
 import (
+	"strings"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Password defines password to be hashed.
-type Password struct {
-	Password string
-	Cost     int
-}
-
-// HashPassword generates encrypted password from password string
-func HashPassword(passwd Password) (string, error) {
+// hashBcrypt generates bcrypt hash, used where bcrypt format is required
+// like htpasswd files.
+func hashBcrypt(passwd Password) (string, error) {
 	if passwd.Cost == 0 {
 		passwd.Cost = bcrypt.DefaultCost
 	}
@@ -19,8 +16,11 @@ func HashPassword(passwd Password) (string, error) {
 	return string(bytes), err
 }
 
-// CheckPasswordHash compares password string with encrypted hash.
-func CheckPasswordHash(password, hash string) bool {
+func checkBcrypt(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
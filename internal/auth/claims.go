@@ -48,6 +48,10 @@ func (c *UserClaims) ParseClaims(claims jwt.MapClaims) error {
 	}
 	c.Role = role.(string)
 
+	if iat, ok := claims["iat"].(float64); ok {
+		c.IssuedAt = int64(iat)
+	}
+
 	return nil
 }
 
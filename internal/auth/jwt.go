@@ -15,6 +15,21 @@ var (
 	errorCtxKey = &contextKey{"Error"}
 )
 
+// DefaultJWTExpiry is lifetime of user access tokens when not set in
+// config.
+const DefaultJWTExpiry = time.Hour * 24 * 365
+
+var jwtExpiry = DefaultJWTExpiry
+
+// SetJWTExpiry sets lifetime of newly created user access tokens, zero
+// value is replaced with default.
+func SetJWTExpiry(expiry time.Duration) {
+	if expiry <= 0 {
+		expiry = DefaultJWTExpiry
+	}
+	jwtExpiry = expiry
+}
+
 // JWTAuth is JWT authenticator that provides middleware handlers
 // and encoding/decoding functions for JWT signing.
 type JWTAuth struct {
@@ -35,7 +50,7 @@ func NewJWTAuth(alg string) *JWTAuth {
 // CreateJWT returns an access token for provided user claims.
 func (a *JWTAuth) CreateJWT(c UserClaims) (string, error) {
 	c.IssuedAt = time.Now().Unix()
-	c.ExpiresAt = time.Now().Add(time.Hour * 24 * 365).Unix()
+	c.ExpiresAt = time.Now().Add(jwtExpiry).Unix()
 	c.Issuer = "Abstruse CI"
 	_, tokenString, err := a.encode(c)
 	return tokenString, err
@@ -0,0 +1,42 @@
+package auth
+
+import "strings"
+
+// Password defines password to be hashed. Cost is used
+// only for bcrypt hashes.
+type Password struct {
+	Password string
+	Cost     int
+}
+
+// HashPassword generates encrypted password from password string
+// using argon2id with configured parameters.
+func HashPassword(passwd Password) (string, error) {
+	return hashArgon2id(passwd.Password, argon2Params)
+}
+
+// CheckPasswordHash compares password string with encrypted hash.
+// Both argon2id and legacy bcrypt hashes are supported.
+func CheckPasswordHash(password, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return checkArgon2id(password, hash)
+	case isBcrypt(hash):
+		return checkBcrypt(password, hash)
+	default:
+		return false
+	}
+}
+
+// NeedsRehash returns true when hash was not generated with argon2id
+// or with parameters weaker than configured ones.
+func NeedsRehash(hash string) bool {
+	p, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.Memory < argon2Params.Memory ||
+		p.Iterations < argon2Params.Iterations ||
+		p.Parallelism < argon2Params.Parallelism ||
+		p.KeyLength < argon2Params.KeyLength
+}
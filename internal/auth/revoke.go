@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// revoked holds the time after which user tokens are valid.
+var revoked = struct {
+	sync.RWMutex
+	users map[uint]int64
+}{users: make(map[uint]int64)}
+
+// RevokeUserTokens invalidates user tokens issued before t.
+func RevokeUserTokens(id uint, t time.Time) {
+	revoked.Lock()
+	defer revoked.Unlock()
+	revoked.users[id] = t.Unix()
+}
+
+// Revoked returns true if token with claims has been revoked.
+func (c UserClaims) Revoked() bool {
+	revoked.RLock()
+	defer revoked.RUnlock()
+	t, ok := revoked.users[c.ID]
+	return ok && c.IssuedAt < t
+}
@@ -0,0 +1,68 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"google.golang.org/grpc/metadata"
+)
+
+type ctxKey int
+
+const ctxRequestID ctxKey = iota
+
+// Header is the HTTP header used to pass request ID.
+const Header = "X-Request-ID"
+
+// metadataKey is gRPC metadata key used to pass request ID to workers.
+const metadataKey = "x-request-id"
+
+// maxLength is maximum accepted length of request ID received from client.
+const maxLength = 128
+
+// New returns new request ID.
+func New() string {
+	return lib.ID()
+}
+
+// Valid returns true if request ID received from client can be used.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// WithContext returns copy of context with request ID.
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxRequestID, id)
+}
+
+// FromContext returns request ID from context or empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxRequestID).(string)
+	return id
+}
+
+// OutgoingContext returns copy of context with request ID attached
+// to outgoing gRPC metadata.
+func OutgoingContext(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, metadataKey, id)
+}
+
+// FromIncomingContext returns request ID from incoming gRPC metadata.
+func FromIncomingContext(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		return strings.Join(md.Get(metadataKey), "")
+	}
+	return ""
+}
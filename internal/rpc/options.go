@@ -0,0 +1,73 @@
+// Package rpc provides gRPC connection options shared by abstruse server
+// and workers.
+package rpc
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// DefaultMaxMsgSize is default maximum size of gRPC message, raised from
+// gRPC default of 4MB so large job log chunks fit into single message.
+const DefaultMaxMsgSize = 16 << 20
+
+// minPingInterval is minimum interval of keepalive pings from the other
+// side that is tolerated, gRPC clients never ping more often.
+const minPingInterval = 10 * time.Second
+
+// Options defines gRPC message size limits and keepalive parameters.
+type Options struct {
+	MaxRecvMsgSize      int
+	MaxSendMsgSize      int
+	KeepaliveTime       time.Duration // 0 disables keepalive pings
+	KeepaliveTimeout    time.Duration
+	PermitWithoutStream bool
+}
+
+// DialOptions returns client dial options.
+func DialOptions(opts Options) []grpc.DialOption {
+	dialOpts := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(msgSize(opts.MaxRecvMsgSize)),
+			grpc.MaxCallSendMsgSize(msgSize(opts.MaxSendMsgSize)),
+		),
+	}
+	if opts.KeepaliveTime > 0 {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.KeepaliveTime,
+			Timeout:             opts.KeepaliveTimeout,
+			PermitWithoutStream: opts.PermitWithoutStream,
+		}))
+	}
+	return dialOpts
+}
+
+// ServerOptions returns server options. Keepalive pings from clients are
+// permitted at any supported interval, so clients configured with shorter
+// keepalive time than the server are not disconnected.
+func ServerOptions(opts Options) []grpc.ServerOption {
+	serverOpts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(msgSize(opts.MaxRecvMsgSize)),
+		grpc.MaxSendMsgSize(msgSize(opts.MaxSendMsgSize)),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             minPingInterval,
+			PermitWithoutStream: true,
+		}),
+	}
+	if opts.KeepaliveTime > 0 {
+		serverOpts = append(serverOpts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    opts.KeepaliveTime,
+			Timeout: opts.KeepaliveTimeout,
+		}))
+	}
+	return serverOpts
+}
+
+func msgSize(size int) int {
+	if size <= 0 {
+		return DefaultMaxMsgSize
+	}
+	return size
+}
@@ -0,0 +1,224 @@
+// Package configfile reads JSON, YAML and TOML config files and reports
+// problems with them as FileError describing what is wrong with the file.
+package configfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bleenco/abstruse/pkg/fs"
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// Config file formats.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// extensions are file extensions of config formats in order default
+// config file is looked up in.
+var extensions = []string{".json", ".yml", ".yaml", ".toml"}
+
+var (
+	yamlLine = regexp.MustCompile(`^yaml: line (\d+): `)
+	tomlPos  = regexp.MustCompile(`^\((\d+), (\d+)\): `)
+)
+
+var (
+	// ErrNotExist is returned when config file does not exist and
+	// cannot be created.
+	ErrNotExist = errors.New("does not exist")
+
+	// ErrNotReadable is returned when config file exists but cannot
+	// be read, e.g. because of permissions or because it is a directory.
+	ErrNotReadable = errors.New("is not readable")
+
+	// ErrMalformed is returned when config file is not valid object of
+	// its format.
+	ErrMalformed = errors.New("is not valid config")
+)
+
+// FileError describes problem with config file, Err is one of ErrNotExist,
+// ErrNotReadable or ErrMalformed. Line and Column are set for
+// malformed files when position of the error is known.
+type FileError struct {
+	Path   string
+	Err    error
+	Line   int
+	Column int
+	Cause  error
+}
+
+func (e *FileError) Error() string {
+	msg := fmt.Sprintf("config file %s %v", e.Path, e.Err)
+	if e.Err == ErrMalformed {
+		msg = fmt.Sprintf("config file %s is not valid %s", e.Path, strings.ToUpper(Format(e.Path)))
+	}
+	if e.Line > 0 {
+		msg += fmt.Sprintf(" at line %d", e.Line)
+	}
+	if e.Column > 0 {
+		msg += fmt.Sprintf(", column %d", e.Column)
+	}
+	if e.Cause != nil {
+		msg += fmt.Sprintf(": %v", e.Cause)
+	}
+	return msg
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Format returns config format of the file detected from its extension,
+// files with other extensions are JSON.
+func Format(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// Default returns path of the default config file name in dir, existing
+// file of any supported format is used, JSON file otherwise.
+func Default(dir, name string) string {
+	for _, ext := range extensions {
+		if path := filepath.Join(dir, name+ext); fs.Exists(path) {
+			return path
+		}
+	}
+	return filepath.Join(dir, name+".json")
+}
+
+// Create writes settings to config file at path when file does not
+// exist yet, creating its parent directory when needed. File is written
+// in format of its extension.
+func Create(path string, settings map[string]interface{}) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil // existing or unreadable file is reported by Read
+	}
+	return Write(path, settings)
+}
+
+// Write writes settings to config file at path replacing existing file,
+// creating its parent directory when needed. File is written in format
+// of its extension.
+func Write(path string, settings map[string]interface{}) error {
+	dir := filepath.Dir(path)
+	if !fs.Exists(dir) {
+		if err := fs.MakeDir(dir); err != nil {
+			return &FileError{Path: path, Err: ErrNotExist, Cause: fmt.Errorf("cannot create directory %s: %v", dir, err)}
+		}
+	}
+	v := viper.New()
+	v.SetConfigType(Format(path))
+	if err := v.MergeConfigMap(settings); err != nil {
+		return &FileError{Path: path, Err: ErrNotExist, Cause: fmt.Errorf("cannot write file: %v", err)}
+	}
+	if err := v.WriteConfigAs(path); err != nil {
+		return &FileError{Path: path, Err: ErrNotExist, Cause: fmt.Errorf("cannot write file: %v", err)}
+	}
+	return nil
+}
+
+// Read reads config file at path and checks it contains object in format
+// of the file.
+func Read(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, &FileError{Path: path, Err: ErrNotExist}
+	}
+	if err != nil {
+		return nil, &FileError{Path: path, Err: ErrNotReadable, Cause: err}
+	}
+	if info.IsDir() {
+		return nil, &FileError{Path: path, Err: ErrNotReadable, Cause: errors.New("path is a directory")}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, &FileError{Path: path, Err: ErrNotReadable, Cause: err}
+	}
+
+	switch Format(path) {
+	case FormatYAML:
+		return data, checkYAML(path, data)
+	case FormatTOML:
+		return data, checkTOML(path, data)
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		ferr := &FileError{Path: path, Err: ErrMalformed, Cause: err}
+		var offset int64
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			offset = e.Offset
+		case *json.UnmarshalTypeError:
+			offset = e.Offset
+			ferr.Cause = errors.New("config must be JSON object")
+		}
+		if offset > 0 {
+			ferr.Line, ferr.Column = position(data, offset)
+		}
+		return nil, ferr
+	}
+
+	return data, nil
+}
+
+func checkYAML(path string, data []byte) error {
+	var v map[string]interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		ferr := &FileError{Path: path, Err: ErrMalformed, Cause: err}
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			ferr.Line, _ = strconv.Atoi(m[1])
+			ferr.Cause = errors.New(err.Error()[len(m[0]):])
+		}
+		if _, ok := err.(*yaml.TypeError); ok {
+			ferr.Cause = errors.New("config must be YAML mapping")
+		}
+		return ferr
+	}
+	return nil
+}
+
+func checkTOML(path string, data []byte) error {
+	if _, err := toml.LoadBytes(data); err != nil {
+		ferr := &FileError{Path: path, Err: ErrMalformed, Cause: err}
+		if m := tomlPos.FindStringSubmatch(err.Error()); m != nil {
+			ferr.Line, _ = strconv.Atoi(m[1])
+			ferr.Column, _ = strconv.Atoi(m[2])
+			ferr.Cause = errors.New(err.Error()[len(m[0]):])
+		}
+		return ferr
+	}
+	return nil
+}
+
+// position returns line and column of the byte at which decoding failed,
+// offset is number of bytes read before the error.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset-1]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
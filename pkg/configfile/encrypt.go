@@ -0,0 +1,100 @@
+package configfile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// EncryptedPrefix is prefix of config values encrypted with config key,
+// e.g. enc:v1:<base64 of nonce and AES-256-GCM ciphertext>.
+const EncryptedPrefix = "enc:v1:"
+
+// KeySize is size of config key in bytes.
+const KeySize = 32
+
+// ErrNoKey is returned when encrypted config value is read without
+// config key.
+var ErrNoKey = errors.New("config value is encrypted but config key is not set")
+
+// GenerateKey returns new random base64 encoded config key.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes base64 encoded config key.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("config key is not valid base64: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("config key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadKey reads base64 encoded config key from file.
+func ReadKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config key: %v", err)
+	}
+	return ParseKey(string(data))
+}
+
+// IsEncrypted reports whether config value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// Encrypt encrypts config value with key.
+func Encrypt(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts config value encrypted with key.
+func Decrypt(key []byte, value string) (string, error) {
+	if key == nil {
+		return "", ErrNoKey
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", errors.New("encrypted config value is malformed")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("encrypted config value cannot be decrypted with config key")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
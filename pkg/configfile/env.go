@@ -0,0 +1,73 @@
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// ExpandEnv replaces ${VAR} references in config value with values of
+// environment variables, $${ is kept as literal ${. Referenced variables
+// must be set, set but empty variables expand to empty string.
+func ExpandEnv(value string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		if i > 0 && value[i-1] == '$' {
+			b.WriteString(value[:i])
+			b.WriteString("{")
+			value = value[i+2:]
+			continue
+		}
+		end := strings.Index(value[i:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", value)
+		}
+		name := value[i+2 : i+end]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		b.WriteString(value[:i])
+		b.WriteString(env)
+		value = value[i+end+1:]
+	}
+}
+
+// DecodeHook returns viper decode option which expands environment
+// variables in string config values and decrypts values encrypted with
+// key before they are decoded, together with default viper hooks
+// decoding durations and comma separated lists. Key may be nil when
+// config has no encrypted values.
+func DecodeHook(key []byte) viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		expandEnvHook,
+		decryptHook(key),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+}
+
+func expandEnvHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	return ExpandEnv(data.(string))
+}
+
+func decryptHook(key []byte) mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || !IsEncrypted(data.(string)) {
+			return data, nil
+		}
+		return Decrypt(key, data.(string))
+	}
+}
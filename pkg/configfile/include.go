@@ -0,0 +1,94 @@
+package configfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// IncludeKey is config key listing additional files merged at load time.
+const IncludeKey = "include"
+
+// Expand returns config files with files they include following each
+// including file, so included values override values of file including
+// them. Include paths are resolved relative to directory of including
+// file and can include further files. Files in list that do not exist
+// are returned as they are, while missing included files are reported.
+func Expand(files []string) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		var err error
+		if expanded, err = expand(file, expanded, nil, false); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+func expand(path string, expanded, stack []string, included bool) ([]string, error) {
+	for _, p := range stack {
+		if p == path {
+			return nil, &FileError{Path: path, Err: ErrMalformed, Cause: fmt.Errorf("include cycle %s", cycle(stack, path))}
+		}
+	}
+	expanded = append(expanded, path)
+
+	data, err := Read(path)
+	if err != nil {
+		if !included && errors.Is(err, ErrNotExist) {
+			return expanded, nil
+		}
+		return nil, err
+	}
+	includes, err := Includes(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	stack = append(stack, path)
+	for _, include := range includes {
+		if expanded, err = expand(include, expanded, stack, true); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// Includes returns files included by config file read from data with
+// paths resolved relative to directory of config file.
+func Includes(path string, data []byte) ([]string, error) {
+	v := viper.New()
+	v.SetConfigType(Format(path))
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, &FileError{Path: path, Err: ErrMalformed, Cause: err}
+	}
+	if !v.IsSet(IncludeKey) {
+		return nil, nil
+	}
+	if _, ok := v.Get(IncludeKey).([]interface{}); !ok {
+		return nil, &FileError{Path: path, Err: ErrMalformed, Cause: fmt.Errorf("%s must be a list of files", IncludeKey)}
+	}
+
+	var includes []string
+	for _, include := range v.GetStringSlice(IncludeKey) {
+		if include == "" {
+			continue
+		}
+		includes = append(includes, Resolve(filepath.Dir(path), include))
+	}
+	return includes, nil
+}
+
+func cycle(stack []string, path string) string {
+	var s string
+	for i := len(stack) - 1; i >= 0; i-- {
+		s = stack[i] + " -> " + s
+		if stack[i] == path {
+			break
+		}
+	}
+	return s + path
+}
@@ -0,0 +1,18 @@
+package configfile
+
+import "path/filepath"
+
+// DataDir returns data directory root resolved relative to directory of
+// config file, directory of config file is used when dir is empty.
+func DataDir(path, dir string) string {
+	return Resolve(filepath.Dir(path), dir)
+}
+
+// Resolve returns path resolved relative to data directory root,
+// absolute paths are returned unchanged.
+func Resolve(root, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(root, path)
+}
@@ -0,0 +1,208 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule represents parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	loc                           *time.Location
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// star bit marks field specified with `*`.
+const star = 1 << 63
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses standard five field cron expression (minute, hour, day of
+// month, month, day of week) or one of predefined descriptors like @daily.
+// Schedule is evaluated in timezone, UTC is used when timezone is empty.
+func Parse(spec, timezone string) (*Schedule, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s", timezone)
+	}
+
+	spec = strings.TrimSpace(spec)
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", spec)
+	}
+
+	s := &Schedule{loc: loc}
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&s.minute, minutes}, {&s.hour, hours}, {&s.dom, doms}, {&s.month, months}, {&s.dow, dows},
+	} {
+		bits, err := parseField(fields[i], f.b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", spec, err)
+		}
+		*f.bits = bits
+	}
+	// 7 is alias for Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+
+	return s, nil
+}
+
+// Next returns next activation time after t, or zero time if there is none
+// within next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics where day of month and day of week are
+// combined with OR when both are restricted.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.dom&star != 0 || s.dow&star != 0 {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		r, err := parseRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= r
+	}
+	return bits, nil
+}
+
+func parseRange(expr string, b bounds) (uint64, error) {
+	var start, end, step uint = 0, 0, 1
+	var extra uint64
+
+	rangeAndStep := strings.Split(expr, "/")
+	if len(rangeAndStep) > 2 {
+		return 0, fmt.Errorf("invalid range %s", expr)
+	}
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	if len(lowAndHigh) > 2 {
+		return 0, fmt.Errorf("invalid range %s", expr)
+	}
+
+	if lowAndHigh[0] == "*" {
+		if len(lowAndHigh) > 1 {
+			return 0, fmt.Errorf("invalid range %s", expr)
+		}
+		start, end = b.min, b.max
+		extra = star
+	} else {
+		var err error
+		if start, err = parseValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		end = start
+		if len(lowAndHigh) == 2 {
+			if end, err = parseValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if len(rangeAndStep) == 2 {
+		s, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+		if err != nil || s == 0 {
+			return 0, fmt.Errorf("invalid step %s", expr)
+		}
+		step = uint(s)
+		if len(lowAndHigh) == 1 && extra == 0 {
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("invalid range %s", expr)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << i
+	}
+	return bits | extra, nil
+}
+
+func parseValue(value string, b bounds) (uint, error) {
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %s", value)
+	}
+	if uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d-%d]", n, b.min, b.max)
+	}
+	return uint(n), nil
+}
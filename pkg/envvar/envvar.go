@@ -0,0 +1,134 @@
+// Package envvar resolves environment variables of build jobs defined
+// in layers, where variables of later layers override variables of
+// earlier ones and values can reference already defined variables
+// with ${NAME}. References to variables which are not defined, like
+// ${HOME}, are left as they are. Values referencing secret variables
+// become secret too, so they are masked in logs.
+package envvar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	nameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	refRe  = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// Var is environment variable.
+type Var struct {
+	Key    string
+	Value  string
+	Secret bool
+}
+
+// Env is ordered set of resolved environment variables.
+type Env struct {
+	vars  []Var
+	index map[string]int
+}
+
+// New returns empty environment.
+func New() *Env {
+	return &Env{index: make(map[string]int)}
+}
+
+// Set interpolates value with variables defined so far and sets the
+// variable, overriding its previous value. Variable is secret when
+// marked as secret or when its value references secret variable.
+func (e *Env) Set(key, value string, secret bool) {
+	value, ref := e.Expand(value)
+	e.Put(Var{Key: key, Value: value, Secret: secret || ref})
+}
+
+// Put sets already resolved variable without interpolating its value.
+func (e *Env) Put(v Var) {
+	if i, ok := e.index[v.Key]; ok {
+		e.vars[i] = v
+		return
+	}
+	e.index[v.Key] = len(e.vars)
+	e.vars = append(e.vars, v)
+}
+
+// SetAll sets variables from KEY=value pairs in order, pairs without
+// = are ignored.
+func (e *Env) SetAll(pairs []string, secret bool) {
+	for _, pair := range pairs {
+		if key, value, ok := Split(pair); ok {
+			e.Set(key, value, secret)
+		}
+	}
+}
+
+// Expand replaces ${NAME} references of defined variables in value,
+// $$ is replaced with literal $. Reports whether value referenced
+// secret variable.
+func (e *Env) Expand(value string) (string, bool) {
+	var secret bool
+	value = refRe.ReplaceAllStringFunc(value, func(m string) string {
+		if m == "$$" {
+			return "$"
+		}
+		i, ok := e.index[m[2:len(m)-1]]
+		if !ok {
+			return m
+		}
+		secret = secret || e.vars[i].Secret
+		return e.vars[i].Value
+	})
+	return value, secret
+}
+
+// Get returns variable by key.
+func (e *Env) Get(key string) (Var, bool) {
+	i, ok := e.index[key]
+	if !ok {
+		return Var{}, false
+	}
+	return e.vars[i], true
+}
+
+// Vars returns variables in order they were first defined.
+func (e *Env) Vars() []Var {
+	return append([]Var(nil), e.vars...)
+}
+
+// Pairs returns variables as KEY=value pairs.
+func (e *Env) Pairs() []string {
+	var pairs []string
+	for _, v := range e.vars {
+		pairs = append(pairs, v.Key+"="+v.Value)
+	}
+	return pairs
+}
+
+// Clone returns copy of the environment, e.g. to resolve variables of
+// single step on top of job environment.
+func (e *Env) Clone() *Env {
+	c := New()
+	for _, v := range e.vars {
+		c.Put(v)
+	}
+	return c
+}
+
+// Split splits KEY=value pair.
+func Split(pair string) (string, string, bool) {
+	i := strings.Index(pair, "=")
+	if i < 1 {
+		return "", "", false
+	}
+	return pair[:i], pair[i+1:], true
+}
+
+// Validate checks KEY=value pair has valid variable name.
+func Validate(pair string) error {
+	key, _, ok := Split(pair)
+	if !ok || !nameRe.MatchString(key) {
+		return fmt.Errorf("invalid environment variable %q", pair)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package envvar
+
+import (
+	"reflect"
+	"testing"
+)
+
+// layers resolves environment of a step from global, repository,
+// matrix and step variables, in the order jobs are resolved.
+func layers(global, repo, matrix, step []string, secrets map[string]string) *Env {
+	env := New()
+	env.SetAll(global, false)
+	env.SetAll(repo, false)
+	for key, value := range secrets {
+		env.Put(Var{Key: key, Value: value, Secret: true})
+	}
+	env.SetAll(matrix, false)
+	env = env.Clone()
+	env.SetAll(step, false)
+	return env
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		name   string
+		global []string
+		repo   []string
+		matrix []string
+		step   []string
+		want   string
+	}{
+		{"global", []string{"V=global"}, nil, nil, nil, "global"},
+		{"repo overrides global", []string{"V=global"}, []string{"V=repo"}, nil, nil, "repo"},
+		{"matrix overrides repo", []string{"V=global"}, []string{"V=repo"}, []string{"V=matrix"}, nil, "matrix"},
+		{"step overrides matrix", []string{"V=global"}, []string{"V=repo"}, []string{"V=matrix"}, []string{"V=step"}, "step"},
+		{"step overrides global", []string{"V=global"}, nil, nil, []string{"V=step"}, "step"},
+		{"later pair in layer wins", []string{"V=first", "V=second"}, nil, nil, nil, "second"},
+		{"empty value overrides", []string{"V=global"}, nil, []string{"V="}, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := layers(tt.global, tt.repo, tt.matrix, tt.step, nil).Get("V")
+			if !ok {
+				t.Fatal("V is not set")
+			}
+			if v.Value != tt.want {
+				t.Errorf("V = %q, want %q", v.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		name   string
+		global []string
+		repo   []string
+		matrix []string
+		step   []string
+		key    string
+		want   string
+	}{
+		{"global from global", []string{"A=a", "B=${A}b"}, nil, nil, nil, "B", "ab"},
+		{"repo from global", []string{"HOST=db"}, []string{"URL=postgres://${HOST}:5432"}, nil, nil, "URL", "postgres://db:5432"},
+		{"matrix from repo", nil, []string{"GO=1.15"}, []string{"IMAGE=golang:${GO}"}, nil, "IMAGE", "golang:1.15"},
+		{"step from all layers", []string{"A=a"}, []string{"B=b"}, []string{"C=c"}, []string{"D=${A}${B}${C}"}, "D", "abc"},
+		{"reference to overridden value", []string{"V=global"}, []string{"V=repo"}, nil, []string{"W=${V}"}, "W", "repo"},
+		{"reference resolved when set", []string{"V=old", "W=${V}"}, nil, []string{"V=new"}, nil, "W", "old"},
+		{"later variable is not defined yet", []string{"A=${B}", "B=b"}, nil, nil, nil, "A", "${B}"},
+		{"undefined", nil, nil, nil, []string{"P=${HOME}/bin"}, "P", "${HOME}/bin"},
+		{"undefined self reference", nil, nil, nil, []string{"PATH=/opt/bin:${PATH}"}, "PATH", "/opt/bin:${PATH}"},
+		{"self reference extends previous value", []string{"PATH=/bin"}, nil, []string{"PATH=/opt/bin:${PATH}"}, nil, "PATH", "/opt/bin:/bin"},
+		{"self reference in same layer", []string{"X=a", "X=${X}b"}, nil, nil, nil, "X", "ab"},
+		{"escaped dollar", []string{"A=a"}, nil, nil, []string{"B=$${A}"}, "B", "${A}"},
+		{"unbraced reference", []string{"A=a"}, nil, nil, []string{"B=$A"}, "B", "$A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := layers(tt.global, tt.repo, tt.matrix, tt.step, nil).Get(tt.key)
+			if !ok {
+				t.Fatalf("%s is not set", tt.key)
+			}
+			if v.Value != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, v.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	secrets := map[string]string{"TOKEN": "s3cr3t"}
+	tests := []struct {
+		name   string
+		step   []string
+		key    string
+		want   string
+		secret bool
+	}{
+		{"secret", nil, "TOKEN", "s3cr3t", true},
+		{"interpolated secret", []string{"AUTH=Bearer ${TOKEN}"}, "AUTH", "Bearer s3cr3t", true},
+		{"interpolated interpolated secret", []string{"AUTH=Bearer ${TOKEN}", "HEADER=Authorization: ${AUTH}"}, "HEADER", "Authorization: Bearer s3cr3t", true},
+		{"plain variable", []string{"USER=abstruse"}, "USER", "abstruse", false},
+		{"escaped secret reference", []string{"LITERAL=$${TOKEN}"}, "LITERAL", "${TOKEN}", false},
+		{"override of secret", []string{"TOKEN=public"}, "TOKEN", "public", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := layers(nil, nil, nil, tt.step, secrets).Get(tt.key)
+			if !ok {
+				t.Fatalf("%s is not set", tt.key)
+			}
+			if v.Value != tt.want || v.Secret != tt.secret {
+				t.Errorf("%s = %q secret %v, want %q secret %v", tt.key, v.Value, v.Secret, tt.want, tt.secret)
+			}
+		})
+	}
+}
+
+func TestCloneIsolation(t *testing.T) {
+	env := New()
+	env.SetAll([]string{"A=a", "B=b"}, false)
+	step := env.Clone()
+	step.SetAll([]string{"A=step", "C=c"}, false)
+
+	if got, want := env.Pairs(), []string{"A=a", "B=b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("job env = %v, want %v", got, want)
+	}
+	if got, want := step.Pairs(), []string{"A=step", "B=b", "C=c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("step env = %v, want %v", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		pair  string
+		valid bool
+	}{
+		{"A=a", true},
+		{"_A1=", true},
+		{"A=b=c", true},
+		{"1A=a", false},
+		{"A-B=a", false},
+		{"=a", false},
+		{"A", false},
+	}
+
+	for _, tt := range tests {
+		if err := Validate(tt.pair); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q) = %v, want valid %v", tt.pair, err, tt.valid)
+		}
+	}
+}
@@ -15,6 +15,18 @@ import (
 	"github.com/drone/go-scm/scm/transport"
 )
 
+// maxChangePages is maximum number of pages of changed files listed.
+const maxChangePages = 10
+
+// baseTransport is transport of provider API requests.
+var baseTransport http.RoundTripper = http.DefaultTransport
+
+// SetTransport sets transport provider API requests are made with, e.g.
+// to use proxy. It should be called before any SCM instance is created.
+func SetTransport(t http.RoundTripper) {
+	baseTransport = t
+}
+
 // SCM represents source code management instance.
 type SCM struct {
 	provider string
@@ -51,7 +63,7 @@ func New(ctx context.Context, provider, url, token string) (SCM, error) {
 	}
 
 	scm.client.Client = &http.Client{
-		Transport: &transport.BearerToken{Token: scm.token},
+		Transport: &transport.BearerToken{Token: scm.token, Base: baseTransport},
 	}
 
 	return scm, err
@@ -109,6 +121,45 @@ func (s SCM) FindCommit(repo, ref string) (*scm.Commit, error) {
 	return commit, err
 }
 
+// ListChanges returns paths of files changed between before and after
+// commits, or changed by after commit when before is empty.
+func (s SCM) ListChanges(repo, before, after string) ([]string, error) {
+	return s.listChanges(func(opts scm.ListOptions) ([]*scm.Change, *scm.Response, error) {
+		if before == "" {
+			return s.client.Git.ListChanges(s.ctx, repo, after, opts)
+		}
+		return s.client.Git.CompareChanges(s.ctx, repo, before, after, opts)
+	})
+}
+
+// ListPullRequestChanges returns paths of files changed by pull request.
+func (s SCM) ListPullRequestChanges(repo string, number int) ([]string, error) {
+	return s.listChanges(func(opts scm.ListOptions) ([]*scm.Change, *scm.Response, error) {
+		return s.client.PullRequests.ListChanges(s.ctx, repo, number, opts)
+	})
+}
+
+// listChanges returns paths of changed files from all pages, error is
+// returned when there are more changes than maxChangePages pages.
+func (s SCM) listChanges(list func(scm.ListOptions) ([]*scm.Change, *scm.Response, error)) ([]string, error) {
+	var files []string
+	opts := scm.ListOptions{Page: 1, Size: 100}
+	for i := 0; i < maxChangePages; i++ {
+		changes, res, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			files = append(files, change.Path)
+		}
+		if res == nil || res.Page.Next == 0 {
+			return files, nil
+		}
+		opts.Page = res.Page.Next
+	}
+	return nil, fmt.Errorf("too many changed files")
+}
+
 // FindBranch finds a git branch by name.
 func (s SCM) FindBranch(repo, name string) (*scm.Reference, error) {
 	reference, _, err := s.client.Git.FindBranch(s.ctx, repo, name)
@@ -211,8 +262,8 @@ func (s SCM) DeleteHook(repo string, ID string) error {
 	return err
 }
 
-// CreateStatus sends build status to SCM provider.
-func (s SCM) CreateStatus(repo, sha, url string, state scm.State) error {
+// CreateStatus sends build status with context label to SCM provider.
+func (s SCM) CreateStatus(repo, sha, url, label string, state scm.State) (*scm.Response, error) {
 	var message string
 	switch state {
 	case scm.StateSuccess:
@@ -231,12 +282,12 @@ func (s SCM) CreateStatus(repo, sha, url string, state scm.State) error {
 
 	input := &scm.StatusInput{
 		State:  state,
-		Label:  "continuous-integration",
+		Label:  label,
 		Desc:   message,
 		Target: url,
 	}
-	_, _, err := s.client.Repositories.CreateStatus(s.ctx, repo, sha, input)
-	return err
+	_, res, err := s.client.Repositories.CreateStatus(s.ctx, repo, sha, input)
+	return res, err
 }
 
 // Client returns underlying scm client.
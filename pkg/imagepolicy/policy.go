@@ -0,0 +1,98 @@
+// Package imagepolicy decides which build images are allowed to run,
+// it is shared by server validating builds and workers running them.
+package imagepolicy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Policy defines default build image and image references allowed or
+// denied. Patterns are glob patterns matched against fully qualified
+// image reference, e.g. docker.io/library/golang:1.15, patterns ending
+// with slash match all images of the registry or namespace and patterns
+// without tag match all tags of the image. Denied images are not
+// allowed even when they match allowed pattern, all images are allowed
+// when allowlist is empty.
+type Policy struct {
+	Default string
+	Allow   []string
+	Deny    []string
+}
+
+// Image returns image or default image when image is not specified.
+func (p Policy) Image(image string) string {
+	if image == "" {
+		return p.Default
+	}
+	return image
+}
+
+// Check returns error when image is not allowed by policy.
+func (p Policy) Check(image string) error {
+	ref := Qualify(image)
+	for _, pattern := range p.Deny {
+		if match(pattern, ref) {
+			return fmt.Errorf("image %s is denied by image policy (%s)", image, pattern)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if match(pattern, ref) {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s is not in allowed images", image)
+}
+
+// Validate checks patterns of the policy.
+func (p Policy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid image pattern %q: %v", pattern, err)
+		}
+	}
+	if p.Default != "" {
+		return p.Check(p.Default)
+	}
+	return nil
+}
+
+// Qualify returns fully qualified image reference, images without
+// registry are expanded to Docker Hub references.
+func Qualify(image string) string {
+	if i := strings.Index(image, "/"); i != -1 {
+		host := image[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			return image
+		}
+		return "docker.io/" + image
+	}
+	return "docker.io/library/" + image
+}
+
+func match(pattern, ref string) bool {
+	pattern = Qualify(pattern)
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(ref, pattern)
+	}
+	if ok, _ := path.Match(pattern, ref); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, repository(ref))
+	return ok
+}
+
+// repository returns image reference without tag and digest.
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i != -1 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
@@ -0,0 +1,112 @@
+// Package junit parses JUnit XML test reports, which are produced by
+// most test runners either directly or with reporter plugins. Reports
+// with testsuites root element and with single testsuite are supported,
+// nested suites are flattened.
+package junit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Case status constants.
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// Suite is test suite with its test cases.
+type Suite struct {
+	Name   string  `xml:"name,attr"`
+	Time   float64 `xml:"time,attr"` // in seconds
+	Cases  []Case  `xml:"testcase"`
+	Suites []Suite `xml:"testsuite"`
+}
+
+// Case is single test case, at most one of failure, error and skipped
+// is set.
+type Case struct {
+	Name      string  `xml:"name,attr"`
+	Classname string  `xml:"classname,attr"`
+	Time      float64 `xml:"time,attr"` // in seconds
+	Failure   *Result `xml:"failure"`
+	Error     *Result `xml:"error"`
+	Skipped   *Result `xml:"skipped"`
+}
+
+// Result is failure, error or skip reason of test case.
+type Result struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Status returns status of test case.
+func (c Case) Status() string {
+	switch {
+	case c.Failure != nil:
+		return StatusFailed
+	case c.Error != nil:
+		return StatusError
+	case c.Skipped != nil:
+		return StatusSkipped
+	default:
+		return StatusPassed
+	}
+}
+
+// Parse parses JUnit XML report and returns its test suites.
+func Parse(data []byte) ([]Suite, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no test suites found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			var root struct {
+				Suites []Suite `xml:"testsuite"`
+			}
+			if err := dec.DecodeElement(&root, &start); err != nil {
+				return nil, err
+			}
+			return flatten(root.Suites), nil
+		case "testsuite":
+			var suite Suite
+			if err := dec.DecodeElement(&suite, &start); err != nil {
+				return nil, err
+			}
+			return flatten([]Suite{suite}), nil
+		default:
+			return nil, fmt.Errorf("unexpected root element %s, not JUnit report", start.Name.Local)
+		}
+	}
+}
+
+// flatten returns suites with nested suites following their parents,
+// suites without test cases are left out.
+func flatten(suites []Suite) []Suite {
+	var flat []Suite
+	for _, suite := range suites {
+		nested := suite.Suites
+		suite.Suites = nil
+		if len(suite.Cases) > 0 {
+			flat = append(flat, suite)
+		}
+		flat = append(flat, flatten(nested)...)
+	}
+	return flat
+}
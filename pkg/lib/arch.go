@@ -0,0 +1,34 @@
+package lib
+
+import "strings"
+
+// ArchAny is architecture matching workers of any architecture.
+const ArchAny = "any"
+
+// archAliases maps architecture names reported by uname and used by
+// container images to Go architecture names.
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+	"armv8":   "arm64",
+	"armv7l":  "arm",
+	"armv7":   "arm",
+	"armhf":   "arm",
+	"i386":    "386",
+	"i686":    "386",
+	"x86":     "386",
+}
+
+// NormalizeArch returns Go architecture name of arch, e.g. amd64 for
+// x86_64, empty when arch is empty or any.
+func NormalizeArch(arch string) string {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	if arch == ArchAny {
+		return ""
+	}
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
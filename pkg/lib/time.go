@@ -1,7 +1,10 @@
 package lib
 
 import (
+	"strings"
 	"time"
+
+	"go.uber.org/zap/zapcore"
 )
 
 // FormatTime returns DB compatible datetime string.
@@ -26,3 +29,33 @@ func ParseTime(str string) time.Time {
 func TimeNow() *time.Time {
 	return func(t time.Time) *time.Time { return &t }(time.Now())
 }
+
+// TimeEncoder returns zap encoder of log timestamps in format rfc3339,
+// rfc3339nano, iso8601, epoch (seconds), epochmillis or custom Go time
+// layout, e.g. "2006-01-02 15:04:05". Times are converted to loc unless
+// it is nil. Nil is returned for empty format, so encoder default is kept.
+func TimeEncoder(format string, loc *time.Location) zapcore.TimeEncoder {
+	var layout string
+	switch strings.ToLower(format) {
+	case "":
+		return nil
+	case "epoch":
+		return zapcore.EpochTimeEncoder
+	case "epochmillis":
+		return zapcore.EpochMillisTimeEncoder
+	case "rfc3339":
+		layout = time.RFC3339
+	case "rfc3339nano":
+		layout = time.RFC3339Nano
+	case "iso8601":
+		layout = "2006-01-02T15:04:05.000Z0700"
+	default:
+		layout = format
+	}
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		if loc != nil {
+			t = t.In(loc)
+		}
+		enc.AppendString(t.Format(layout))
+	}
+}
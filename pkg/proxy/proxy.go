@@ -0,0 +1,73 @@
+// Package proxy configures proxies of outbound HTTP connections.
+// Values not set in config are read from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables (or their lowercase versions).
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Config defines proxy settings.
+type Config struct {
+	HTTP    string `json:"http"`    // proxy for http requests
+	HTTPS   string `json:"https"`   // proxy for https requests
+	NoProxy string `json:"noproxy"` // comma separated hosts not proxied
+}
+
+// Set reports whether any proxy setting is set in config.
+func (c *Config) Set() bool {
+	return c != nil && (c.HTTP != "" || c.HTTPS != "" || c.NoProxy != "")
+}
+
+// resolve returns config merged with environment.
+func (c *Config) resolve() *httpproxy.Config {
+	cfg := httpproxy.FromEnvironment()
+	if c != nil {
+		if c.HTTP != "" {
+			cfg.HTTPProxy = c.HTTP
+		}
+		if c.HTTPS != "" {
+			cfg.HTTPSProxy = c.HTTPS
+		}
+		if c.NoProxy != "" {
+			cfg.NoProxy = c.NoProxy
+		}
+	}
+	return cfg
+}
+
+// Func returns function selecting proxy of request, suitable for
+// http.Transport.
+func (c *Config) Func() func(*http.Request) (*url.URL, error) {
+	fn := c.resolve().ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return fn(r.URL)
+	}
+}
+
+// Transport returns transport with default settings using proxy.
+func (c *Config) Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = c.Func()
+	return t
+}
+
+// Env returns proxy environment variables in both upper and lowercase,
+// as tools disagree on which one they read.
+func (c *Config) Env() []string {
+	cfg := c.resolve()
+	var env []string
+	add := func(key, value string) {
+		if value != "" {
+			env = append(env, key+"="+value, strings.ToLower(key)+"="+value)
+		}
+	}
+	add("HTTP_PROXY", cfg.HTTPProxy)
+	add("HTTPS_PROXY", cfg.HTTPSProxy)
+	add("NO_PROXY", cfg.NoProxy)
+	return env
+}
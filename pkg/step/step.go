@@ -0,0 +1,123 @@
+// Package step defines build commands sent from server to workers
+// together with conditions the worker evaluates before running them.
+package step
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxRetries is the maximum number of retries of a step.
+const MaxRetries = 10
+
+// DefaultShell is shell steps run with when shell is not set, failing
+// command or pipeline stops the step. Interactive shell loads profile
+// of the image, e.g. version managers.
+const DefaultShell = "bash -e -o pipefail -i"
+
+// Workspace is directory in container repository is cloned to.
+const Workspace = "/build"
+
+// Step status conditions.
+const (
+	OnSuccess = "success" // run when previous steps passed (default)
+	OnFailure = "failure" // run only when some previous step failed
+	Always    = "always"  // run regardless of previous steps
+)
+
+// Step is build command. Unconditional steps without environment are
+// encoded as plain command strings, so such jobs keep their format.
+// Env holds KEY=value pairs set for the step on top of job environment,
+// resolved by worker so secrets they reference are not stored with job.
+// Failed step is run again up to Retries times, waiting Backoff before
+// the first retry and twice as long before each next one.
+// Step runs with Shell in Workdir relative to workspace.
+type Step struct {
+	Run     string        `json:"run"`
+	When    string        `json:"when,omitempty"` // success, failure or always
+	Skip    bool          `json:"skip,omitempty"` // condition does not match the build
+	Env     []string      `json:"env,omitempty"`
+	Retries int           `json:"retries,omitempty"`
+	Backoff time.Duration `json:"backoff,omitempty"`
+	Shell   string        `json:"shell,omitempty"`   // DefaultShell when empty
+	Workdir string        `json:"workdir,omitempty"` // workspace when empty
+}
+
+// Runs reports whether step runs when previous steps of the job
+// finished with failed status.
+func (s Step) Runs(failed bool) bool {
+	if s.Skip {
+		return false
+	}
+	switch s.When {
+	case Always:
+		return true
+	case OnFailure:
+		return failed
+	default:
+		return !failed
+	}
+}
+
+// Wait returns time to wait before retry attempt, attempts start at 1.
+func (s Step) Wait(attempt int) time.Duration {
+	if attempt < 1 || s.Backoff <= 0 {
+		return 0
+	}
+	return s.Backoff << uint(attempt-1)
+}
+
+// Command returns command line step is executed with, shell is run
+// with -c and changes to working directory before running the step.
+func (s Step) Command() []string {
+	shell := s.Shell
+	if strings.TrimSpace(shell) == "" {
+		shell = DefaultShell
+	}
+	script := s.Run
+	if s.Workdir != "" {
+		dir := path.Join(Workspace, s.Workdir)
+		script = "cd '" + strings.ReplaceAll(dir, "'", `'\''`) + "' || exit 1\n" + script
+	}
+	return append(strings.Fields(shell), "-c", script)
+}
+
+// ValidWorkdir reports whether working directory is relative path
+// within workspace.
+func ValidWorkdir(dir string) bool {
+	if dir == "" {
+		return true
+	}
+	if path.IsAbs(dir) {
+		return false
+	}
+	clean := path.Clean(dir)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// MarshalJSON encodes unconditional step as plain command string.
+func (s Step) MarshalJSON() ([]byte, error) {
+	if (s.When == "" || s.When == OnSuccess) && !s.Skip && len(s.Env) == 0 && s.Retries == 0 && s.Shell == "" && s.Workdir == "" {
+		return json.Marshal(s.Run)
+	}
+	type plain Step
+	return json.Marshal(plain(s))
+}
+
+// UnmarshalJSON decodes step from plain command string or object.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	var run string
+	if err := json.Unmarshal(data, &run); err == nil {
+		*s = Step{Run: run}
+		return nil
+	}
+	type plain Step
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*s = Step(p)
+	return nil
+}
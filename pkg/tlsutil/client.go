@@ -0,0 +1,43 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// ClientConfig returns TLS config of outbound connections which trusts
+// system certificates together with CA certificates read from PEM file
+// and PEM data. Returns nil config when nothing is customized.
+func ClientConfig(caFile, caPEM string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caFile == "" && caPEM == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile == "" && caPEM == "" {
+		return cfg, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if caFile != "" {
+		data, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM encoded certificates found in %s", caFile)
+		}
+	}
+	if caPEM != "" && !pool.AppendCertsFromPEM([]byte(caPEM)) {
+		return nil, errors.New("no PEM encoded certificates found in CA data")
+	}
+	cfg.RootCAs = pool
+
+	return cfg, nil
+}
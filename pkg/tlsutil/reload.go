@@ -0,0 +1,110 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// CertReloader serves certificate loaded from certificate and key files
+// and reloads it when files change, so certificates can be rotated
+// without restarting the server.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Value // *tls.Certificate
+	watcher  *fsnotify.Watcher
+}
+
+// NewCertReloader loads certificate and key pair from files.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads certificate and key pair from files and replaces served
+// certificate, previous certificate is kept when pair cannot be loaded.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns current certificate, it is meant to be used
+// as tls.Config GetCertificate callback.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load().(*tls.Certificate), nil
+}
+
+// Watch watches directories of certificate and key files and reloads
+// certificate on changes until Close is called. Directories are watched
+// instead of files so atomic replacements by renaming files or swapping
+// symlinks, as done by Kubernetes secret volumes, are noticed.
+// Function fn is called after certificate is replaced or reload failed.
+func (r *CertReloader) Watch(fn func(error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, dir := range []string{filepath.Dir(r.certFile), filepath.Dir(r.keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	r.watcher = watcher
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Op == fsnotify.Chmod || !r.affects(ev.Name) {
+					continue
+				}
+				prev := r.cert.Load().(*tls.Certificate)
+				err := r.Reload()
+				if err == nil && bytes.Equal(prev.Certificate[0], r.cert.Load().(*tls.Certificate).Certificate[0]) {
+					continue
+				}
+				fn(err)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fn(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// affects returns true if change of the file may change certificate or
+// key, Kubernetes secret volumes swap symlinked ..data directory.
+func (r *CertReloader) affects(name string) bool {
+	base := filepath.Base(name)
+	return base == filepath.Base(r.certFile) ||
+		base == filepath.Base(r.keyFile) ||
+		strings.HasPrefix(base, "..")
+}
+
+// Close stops watching certificate files.
+func (r *CertReloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
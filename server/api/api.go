@@ -6,9 +6,12 @@ import (
 	"path"
 
 	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/api/apikey"
 	"github.com/bleenco/abstruse/server/api/badge"
 	"github.com/bleenco/abstruse/server/api/build"
+	"github.com/bleenco/abstruse/server/api/event"
 	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/openapi"
 	"github.com/bleenco/abstruse/server/api/provider"
 	"github.com/bleenco/abstruse/server/api/repo"
 	"github.com/bleenco/abstruse/server/api/setup"
@@ -24,19 +27,9 @@ import (
 	"github.com/bleenco/abstruse/server/ws"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/go-chi/cors"
 	"github.com/jkuri/statik/fs"
 )
 
-var corsOpts = cors.Options{
-	AllowedOrigins:   []string{"*"},
-	AllowedMethods:   []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"},
-	AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-	ExposedHeaders:   []string{"Link"},
-	AllowCredentials: true,
-	MaxAge:           300,
-}
-
 // New returns new API Router instance.
 func New(
 	config *config.Config,
@@ -52,39 +45,66 @@ func New(
 	workers core.WorkerRegistry,
 	scheduler core.Scheduler,
 	stats core.StatsService,
+	apiKeys core.APIKeyStore,
+	crons core.CronStore,
+	cron core.CronService,
+	loginAttempts core.LoginAttemptStore,
+	auditEvents core.AuditStore,
+	skippedBuilds core.SkippedBuildStore,
+	deliveries core.HookDeliveryStore,
+	audit core.AuditService,
+	oidc core.OIDCProvider,
 ) *Router {
 	return &Router{
-		Config:       config,
-		WS:           ws,
-		Users:        users,
-		Teams:        teams,
-		Permissions:  permissions,
-		Providers:    providers,
-		Builds:       builds,
-		Jobs:         jobs,
-		Repos:        repos,
-		EnvVariables: envVariables,
-		Workers:      workers,
-		Scheduler:    scheduler,
-		Stats:        stats,
+		Config:        config,
+		WS:            ws,
+		Users:         users,
+		Teams:         teams,
+		Permissions:   permissions,
+		Providers:     providers,
+		Builds:        builds,
+		Jobs:          jobs,
+		Repos:         repos,
+		EnvVariables:  envVariables,
+		Workers:       workers,
+		Scheduler:     scheduler,
+		Stats:         stats,
+		APIKeys:       apiKeys,
+		Crons:         crons,
+		Cron:          cron,
+		LoginAttempts: loginAttempts,
+		AuditEvents:   auditEvents,
+		SkippedBuilds: skippedBuilds,
+		Deliveries:    deliveries,
+		Audit:         audit,
+		OIDC:          oidc,
 	}
 }
 
 // Router is an API http.Handler.
 type Router struct {
-	Config       *config.Config
-	WS           *ws.Server
-	Users        core.UserStore
-	Teams        core.TeamStore
-	Permissions  core.PermissionStore
-	Providers    core.ProviderStore
-	Builds       core.BuildStore
-	Jobs         core.JobStore
-	Repos        core.RepositoryStore
-	EnvVariables core.EnvVariableStore
-	Workers      core.WorkerRegistry
-	Scheduler    core.Scheduler
-	Stats        core.StatsService
+	Config        *config.Config
+	WS            *ws.Server
+	Users         core.UserStore
+	Teams         core.TeamStore
+	Permissions   core.PermissionStore
+	Providers     core.ProviderStore
+	Builds        core.BuildStore
+	Jobs          core.JobStore
+	Repos         core.RepositoryStore
+	EnvVariables  core.EnvVariableStore
+	Workers       core.WorkerRegistry
+	Scheduler     core.Scheduler
+	Stats         core.StatsService
+	APIKeys       core.APIKeyStore
+	Crons         core.CronStore
+	Cron          core.CronService
+	LoginAttempts core.LoginAttemptStore
+	AuditEvents   core.AuditStore
+	SkippedBuilds core.SkippedBuildStore
+	Deliveries    core.HookDeliveryStore
+	Audit         core.AuditService
+	OIDC          core.OIDCProvider // nil when single sign-on is disabled
 }
 
 // Handler returns the http.Handler.
@@ -94,20 +114,17 @@ func (r Router) Handler() http.Handler {
 	router.Use(middleware.RequestID)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.NoCache)
-	router.Use(middleware.RealIP)
+	router.Use(middlewares.RealIP(r.Config.HTTP.Proxies()))
 	router.Use(middleware.Heartbeat("/ping"))
-	if r.Config.HTTP.Compress {
-		router.Use(middleware.Compress(5))
-	}
-
-	cors := cors.New(corsOpts)
-	router.Use(cors.Handler)
 
 	router.Mount("/api/v1", r.apiRouter())
 	router.Get("/ws", ws.UpstreamHandler(r.Config.Websocket.Addr))
+	router.Get("/openapi.json", openapi.HandleSpec())
 	router.Get("/badge/{token}", badge.HandleBadge(r.Builds))
+	router.Get("/api/badge/*", badge.HandleBranchBadge(r.Repos, r.Builds))
 	router.Mount("/uploads", r.fileServer())
-	router.Post("/webhooks", webhook.HandleHook(r.Repos, r.Builds, r.Scheduler, r.WS))
+	router.With(middlewares.RateLimit(r.Config.RateLimit.Webhooks)).
+		Post("/webhooks", webhook.HandleHook(r.Repos, r.Builds, r.SkippedBuilds, r.Deliveries, r.Scheduler, r.WS, r.Config))
 	router.NotFound(r.ui())
 
 	return router
@@ -121,7 +138,8 @@ func (r Router) apiRouter() *chi.Mux {
 	router.Mount("/workers", r.workersRouter())
 
 	router.Group(func(router chi.Router) {
-		router.Use(auth.JWT.Verifier(), middlewares.Authenticator)
+		router.Use(auth.JWT.Verifier(), middlewares.Authenticator(r.APIKeys))
+		router.Use(middlewares.RateLimit(r.Config.RateLimit.API))
 		router.Mount("/users", r.usersRouter())
 		router.Mount("/teams", r.teamsRouter())
 		router.Mount("/providers", r.providersRouter())
@@ -129,6 +147,10 @@ func (r Router) apiRouter() *chi.Mux {
 		router.Mount("/builds", r.buildsRouter())
 		router.Mount("/system", r.systemRouter())
 		router.Mount("/stats", r.statsRouter())
+		router.Mount("/keys", r.keysRouter())
+		router.Mount("/webhooks", r.webhooksRouter())
+		router.Get("/events", event.HandleStream(r.WS.App.Events, r.Repos, r.Builds))
+		router.Post("/validate", build.HandleValidate(r.Config))
 	})
 
 	return router
@@ -137,7 +159,13 @@ func (r Router) apiRouter() *chi.Mux {
 func (r Router) authRouter() *chi.Mux {
 	router := chi.NewRouter()
 
-	router.Post("/login", user.HandleLogin(r.Users))
+	router.Use(middlewares.RateLimit(r.Config.RateLimit.Auth))
+	router.Post("/login", user.HandleLogin(r.Users, r.LoginAttempts, r.Audit, r.Config))
+	router.Get("/oidc", user.HandleOIDC(r.OIDC))
+	if r.OIDC != nil {
+		router.Get("/oidc/login", user.HandleOIDCLogin(r.OIDC))
+		router.Get("/oidc/callback", user.HandleOIDCCallback(r.OIDC, r.Users, r.Audit, r.Config))
+	}
 
 	return router
 }
@@ -158,12 +186,15 @@ func (r Router) usersRouter() *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Get("/", user.HandleList(r.Users))
-	router.Post("/", user.HandleCreate(r.Users))
-	router.Put("/", user.HandleUpdate(r.Users))
+	router.With(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin)).Post("/", user.HandleCreate(r.Users, r.Audit))
+	router.With(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin)).Put("/", user.HandleUpdate(r.Users, r.Audit))
 	router.Get("/profile", user.HandleProfile(r.Users))
-	router.Put("/profile", user.HandleUpdateProfile(r.Users))
-	router.Put("/password", user.HandlePassword(r.Users))
-	router.Post("/avatar", user.HandleAvatar(r.Config.HTTP.UploadDir))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite))
+		router.Put("/profile", user.HandleUpdateProfile(r.Users))
+		router.Put("/password", user.HandlePassword(r.Users, r.Audit))
+		router.Post("/avatar", user.HandleAvatar(r.Config.HTTP.UploadDir))
+	})
 
 	return router
 }
@@ -173,8 +204,11 @@ func (r Router) teamsRouter() *chi.Mux {
 
 	router.Get("/", team.HandleList(r.Teams))
 	router.Get("/{id}", team.HandleFind(r.Teams))
-	router.Post("/", team.HandleCreate(r.Teams, r.Users, r.Permissions))
-	router.Put("/", team.HandleUpdate(r.Teams, r.Users, r.Permissions))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin))
+		router.Post("/", team.HandleCreate(r.Teams, r.Users, r.Permissions, r.Audit))
+		router.Put("/", team.HandleUpdate(r.Teams, r.Users, r.Permissions, r.Audit))
+	})
 
 	return router
 }
@@ -183,11 +217,14 @@ func (r Router) providersRouter() *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Get("/", provider.HandleListUser(r.Providers))
-	router.Post("/", provider.HandleCreate(r.Providers))
-	router.Put("/", provider.HandleUpdate(r.Providers, r.Users))
 	router.Get("/{id}", provider.HandleFind(r.Providers, r.Users))
-	router.Delete("/{id}", provider.HandleDelete(r.Providers, r.Users))
-	router.Put("/sync", provider.HandleSync(r.Providers))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin, core.RoleMaintainer))
+		router.Post("/", provider.HandleCreate(r.Providers))
+		router.Put("/", provider.HandleUpdate(r.Providers, r.Users))
+		router.Delete("/{id}", provider.HandleDelete(r.Providers, r.Users))
+		router.Put("/sync", provider.HandleSync(r.Providers))
+	})
 
 	return router
 }
@@ -196,15 +233,37 @@ func (r Router) reposRouter() *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Get("/", repo.HandleList(r.Repos))
-	router.Get("/{id}", repo.HandleFind(r.Repos))
-	router.Put("/{id}/active", repo.HandleActive(r.Repos))
+	router.Get("/{id}", repo.HandleFind(r.Repos, r.Scheduler))
 	router.Get("/{id}/hooks", repo.HandleListHooks(r.Repos))
-	router.Put("/{id}/hooks", repo.HandleCreateHooks(r.Repos))
 	router.Get("/{id}/config", repo.HandleConfig(r.Repos))
 	router.Get("/{id}/envs", repo.HandleListEnv(r.EnvVariables, r.Repos))
-	router.Put("/{id}/envs", repo.HandleCreateEnv(r.EnvVariables, r.Repos))
-	router.Post("/{id}/envs", repo.HandleUpdateEnv(r.EnvVariables, r.Repos))
-	router.Delete("/{id}/envs/{envid}", repo.HandleDeleteEnv(r.EnvVariables, r.Repos))
+	router.Get("/{id}/crons", repo.HandleListCrons(r.Crons, r.Repos))
+	router.Get("/{id}/skipped", repo.HandleListSkipped(r.SkippedBuilds, r.Repos))
+	router.With(middlewares.Scope(core.ScopeTrigger), middlewares.Authorize(core.RoleAdmin, core.RoleMaintainer)).
+		Post("/{id}/builds", build.HandleCreate(r.Builds, r.Repos, r.Scheduler, r.WS))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin, core.RoleMaintainer))
+		router.Put("/{id}/active", repo.HandleActive(r.Repos))
+		router.Put("/{id}/hooks", repo.HandleCreateHooks(r.Repos))
+		router.Post("/{id}/hooks/secrets", repo.HandleAddHookSecret(r.Repos, r.Audit))
+		router.Put("/{id}/hooks/secrets/promote", repo.HandlePromoteHookSecret(r.Repos, r.Audit))
+		router.Delete("/{id}/hooks/secrets", repo.HandleRemoveHookSecret(r.Repos, r.Audit))
+		router.Put("/{id}/envs", repo.HandleCreateEnv(r.EnvVariables, r.Repos, r.Audit))
+		router.Post("/{id}/envs", repo.HandleUpdateEnv(r.EnvVariables, r.Repos, r.Audit))
+		router.Delete("/{id}/envs/{envid}", repo.HandleDeleteEnv(r.EnvVariables, r.Repos, r.Audit))
+		router.Put("/{id}/notifications", repo.HandleNotifications(r.Repos))
+		router.Put("/{id}/registry", repo.HandleRegistryAuth(r.Repos, r.Audit))
+		router.Get("/{id}/cloneauth", repo.HandleCloneAuth(r.Repos))
+		router.Put("/{id}/cloneauth", repo.HandleSetCloneAuth(r.Repos, r.Audit))
+		router.Delete("/{id}/cloneauth", repo.HandleRemoveCloneAuth(r.Repos, r.Audit))
+		router.Put("/{id}/maxbuilds", repo.HandleMaxBuilds(r.Repos))
+		router.Put("/{id}/autocancel", repo.HandleAutoCancel(r.Repos))
+		router.Put("/{id}/badge", repo.HandlePublicBadge(r.Repos))
+		router.Put("/{id}/retention", repo.HandleRetention(r.Repos))
+		router.Put("/{id}/filter", repo.HandleHookFilter(r.Repos))
+		router.Put("/{id}/crons", repo.HandleCreateCron(r.Cron, r.Repos))
+		router.Delete("/{id}/crons/{cronid}", repo.HandleDeleteCron(r.Crons, r.Repos))
+	})
 
 	return router
 }
@@ -212,14 +271,37 @@ func (r Router) reposRouter() *chi.Mux {
 func (r Router) buildsRouter() *chi.Mux {
 	router := chi.NewRouter()
 
-	router.Get("/", build.HandleList(r.Builds))
-	router.Get("/{id}", build.HandleFind(r.Builds))
-	router.Put("/trigger", build.HandleTrigger(r.Builds, r.Scheduler, r.WS))
-	router.Put("/restart", build.HandleRestart(r.Builds, r.Repos, r.Scheduler))
-	router.Put("/stop", build.HandleStop(r.Builds, r.Repos, r.Scheduler))
-	router.Get("/job/{id}", build.HandleFindJob(r.Jobs, r.Scheduler))
-	router.Put("/job/restart", build.HandleRestartJob(r.Jobs, r.Repos, r.Scheduler))
-	router.Put("/job/stop", build.HandleStopJob(r.Jobs, r.Repos, r.Scheduler))
+	router.Get("/", build.HandleList(r.Builds, r.Config))
+	router.Get("/search", build.HandleSearch(r.Jobs))
+	router.Get("/{id}", build.HandleFind(r.Builds, r.Config))
+	router.Get("/{id}/metadata", build.HandleMetadata(r.Builds))
+	router.Get("/{id}/tests", build.HandleTests(r.Builds))
+	router.Get("/job/{id}", build.HandleFindJob(r.Jobs, r.Scheduler, r.Config))
+	router.With(middlewares.Scope(core.ScopeTrigger), middlewares.Authorize(core.RoleAdmin, core.RoleMaintainer)).
+		Put("/trigger", build.HandleTrigger(r.Builds, r.Repos, r.Scheduler, r.WS))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin, core.RoleMaintainer))
+		router.Put("/restart", build.HandleRestart(r.Builds, r.Repos, r.Scheduler))
+		router.Put("/stop", build.HandleStop(r.Builds, r.Repos, r.Scheduler, r.Audit))
+		router.Post("/{id}/cancel", build.HandleCancel(r.Builds, r.Repos, r.Scheduler, r.Audit))
+		router.Post("/{id}/restart", build.HandleRebuild(r.Builds, r.Repos, r.Scheduler, r.WS))
+		router.Put("/job/restart", build.HandleRestartJob(r.Jobs, r.Repos, r.Scheduler))
+		router.Put("/job/stop", build.HandleStopJob(r.Jobs, r.Repos, r.Scheduler))
+	})
+
+	return router
+}
+
+func (r Router) keysRouter() *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Use(middlewares.Authorize(core.RoleAdmin))
+	router.Get("/", apikey.HandleList(r.APIKeys))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite))
+		router.Post("/", apikey.HandleCreate(r.APIKeys, r.Users, r.Audit))
+		router.Delete("/{id}", apikey.HandleDelete(r.APIKeys, r.Audit))
+	})
 
 	return router
 }
@@ -232,6 +314,13 @@ func (r Router) workersRouter() *chi.Mux {
 		router.Use(auth.JWT.Verifier(), middlewares.WorkerAuthenticator)
 		router.Post("/auth", worker.HandleAuth(r.Workers, r.Config, r.WS.App))
 	})
+	router.Group(func(router chi.Router) {
+		router.Use(auth.JWT.Verifier(), middlewares.Authenticator(r.APIKeys))
+		router.Use(middlewares.RateLimit(r.Config.RateLimit.API), middlewares.Authorize(core.RoleAdmin))
+		router.Get("/{id}/drain", worker.HandleDrainStatus(r.Scheduler))
+		router.With(middlewares.Scope(core.ScopeWrite)).Put("/{id}/drain", worker.HandleDrain(r.Scheduler, r.Audit))
+		router.With(middlewares.Scope(core.ScopeWrite)).Delete("/{id}/drain", worker.HandleUndrain(r.Scheduler, r.Audit))
+	})
 
 	return router
 }
@@ -240,6 +329,25 @@ func (r Router) systemRouter() *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Get("/version", system.HandleVersion())
+	router.Get("/maintenance", system.HandleMaintenance(r.Scheduler))
+	router.With(middlewares.Authorize(core.RoleAdmin)).Get("/audit", system.HandleAudit(r.AuditEvents))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin))
+		router.Put("/maintenance", system.HandleStartMaintenance(r.Scheduler, r.Audit))
+		router.Delete("/maintenance", system.HandleStopMaintenance(r.Scheduler, r.Audit))
+	})
+
+	return router
+}
+
+func (r Router) webhooksRouter() *chi.Mux {
+	router := chi.NewRouter()
+
+	router.Use(middlewares.Authorize(core.RoleAdmin))
+	router.Get("/", webhook.HandleListDeliveries(r.Deliveries))
+	router.Get("/{id}", webhook.HandleFindDelivery(r.Deliveries))
+	router.With(middlewares.Scope(core.ScopeWrite)).
+		Post("/{id}/replay", webhook.HandleReplayDelivery(r.Repos, r.Builds, r.SkippedBuilds, r.Deliveries, r.Scheduler, r.WS, r.Config, r.Audit))
 
 	return router
 }
@@ -249,8 +357,12 @@ func (r Router) statsRouter() *chi.Mux {
 
 	router.Get("/", stats.HandleStats(r.Stats))
 	router.Get("/jobs", stats.HandleJobs(r.Jobs))
-	router.Put("/scheduler/resume", stats.HandleResume(r.Users, r.Scheduler))
-	router.Put("/scheduler/pause", stats.HandlePause(r.Users, r.Scheduler))
+	router.With(middlewares.Authorize(core.RoleAdmin)).Get("/storage", stats.HandleStorage(r.Builds))
+	router.Group(func(router chi.Router) {
+		router.Use(middlewares.Scope(core.ScopeWrite), middlewares.Authorize(core.RoleAdmin))
+		router.Put("/scheduler/resume", stats.HandleResume(r.Users, r.Scheduler))
+		router.Put("/scheduler/pause", stats.HandlePause(r.Users, r.Scheduler))
+	})
 
 	return router
 }
@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/testutil"
+)
+
+func TestLogin(t *testing.T) {
+	s := testutil.NewTestServer(t)
+	if err := s.Users.Create(&core.User{Email: "user@example.com", Password: "secret123", Name: "User", Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		status   int
+	}{
+		{"valid credentials", "user@example.com", "secret123", http.StatusOK},
+		{"invalid password", "user@example.com", "secret", http.StatusUnauthorized},
+		{"unknown email", "other@example.com", "secret123", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := request(t, s, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": tt.email, "password": tt.password})
+			if res.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", res.StatusCode, tt.status, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Token string `json:"token"`
+			}
+			if err := json.Unmarshal(body, &resp); err != nil || resp.Token == "" {
+				t.Errorf("response %s has no token", body)
+			}
+		})
+	}
+}
+
+func TestListRepos(t *testing.T) {
+	s := testutil.NewTestServer(t)
+	if err := s.Users.Create(&core.User{Email: "user@example.com", Password: "secret123", Name: "User", Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bleenco/abstruse", "bleenco/ng-terminal"} {
+		if err := s.Repos.Create(core.Repository{FullName: name, Active: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, body := request(t, s, http.MethodGet, "/api/v1/repos?limit=10&offset=0", "", nil)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	res, body = request(t, s, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": "user@example.com", "password": "secret123"})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("login status = %d: %s", res.StatusCode, body)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &login); err != nil {
+		t.Fatal(err)
+	}
+
+	res, body = request(t, s, http.MethodGet, "/api/v1/repos?limit=10&offset=0&keyword=abstruse", login.Token, nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d: %s", res.StatusCode, body)
+	}
+	var list struct {
+		Count int               `json:"count"`
+		Data  []core.Repository `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 1 || len(list.Data) != 1 || list.Data[0].FullName != "bleenco/abstruse" {
+		t.Errorf("repositories = %s, want bleenco/abstruse only", body)
+	}
+}
+
+// request sends JSON encoded body to test server, authenticated with token
+// when set, and returns response with its body.
+func request(t *testing.T, s *testutil.TestServer, method, path, token string, body interface{}) (*http.Response, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(res.Body); err != nil {
+		t.Fatal(err)
+	}
+	return res, out.Bytes()
+}
@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+var scopesRegexp = regexp.MustCompile(`^(read|write|trigger)(:[0-9]+)?(\s*,\s*(read|write|trigger)(:[0-9]+)?)*$`)
+
+// HandleCreate returns an http.HandlerFunc that writes JSON encoded
+// result about creating API key to the http response body.
+// Plain key is returned only once.
+//
+// @Summary Create API key
+// @Description Plain key is returned only once.
+// @Tags keys
+// @Body form
+// @Success 200 resp
+// @Router /keys [post]
+func HandleCreate(keys core.APIKeyStore, users core.UserStore, audit core.AuditService) http.HandlerFunc {
+	type form struct {
+		Name   string `json:"name" valid:"stringlength(3|255),required"`
+		Scopes string `json:"scopes" valid:"required"`
+		UserID uint   `json:"userID"`
+	}
+
+	type resp struct {
+		*core.APIKey
+		Key string `json:"key"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f form
+		defer r.Body.Close()
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
+			render.ValidationError(w, err)
+			return
+		}
+
+		if !scopesRegexp.MatchString(f.Scopes) {
+			render.BadRequestError(w, "invalid scopes")
+			return
+		}
+
+		if f.UserID == 0 {
+			f.UserID = claims.ID
+		}
+		if _, err := users.Find(f.UserID); err != nil {
+			render.ResourceNotFoundError(w, "user", err.Error())
+			return
+		}
+
+		key := &core.APIKey{
+			Name:   f.Name,
+			Scopes: f.Scopes,
+			UserID: f.UserID,
+		}
+
+		plain, err := keys.Create(key)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		event := middlewares.AuditEvent(r, core.AuditAPIKeyCreate, fmt.Sprintf("apikey:%d", key.ID))
+		event.Details = fmt.Sprintf("name: %s, user: %d, scopes: %s", key.Name, key.UserID, key.Scopes)
+		audit.Record(event)
+
+		render.JSON(w, http.StatusOK, resp{key, plain})
+	}
+}
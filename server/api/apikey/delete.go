@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleDelete returns an http.HandlerFunc that writes JSON encoded
+// result about revoking API key to the http response body.
+//
+// @Summary Revoke API key
+// @Tags keys
+// @Success 200 render.Empty
+// @Router /keys/{id} [delete]
+func HandleDelete(keys core.APIKeyStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		key, err := keys.Find(uint(id))
+		if err != nil {
+			render.ResourceNotFoundError(w, "apikey", err.Error())
+			return
+		}
+
+		if err := keys.Delete(key); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		event := middlewares.AuditEvent(r, core.AuditAPIKeyDelete, fmt.Sprintf("apikey:%d", key.ID))
+		event.Details = fmt.Sprintf("name: %s, user: %d", key.Name, key.UserID)
+		audit.Record(event)
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
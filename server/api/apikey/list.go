@@ -0,0 +1,27 @@
+package apikey
+
+import (
+	"net/http"
+
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// HandleList returns an http.HandlerFunc that writes JSON encoded
+// list of API keys to the http response body.
+//
+// @Summary List API keys
+// @Tags keys
+// @Success 200 []core.APIKey
+// @Router /keys [get]
+func HandleList(keys core.APIKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := keys.List()
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, list)
+	}
+}
@@ -2,6 +2,7 @@ package badge
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/bleenco/abstruse/server/api/render"
 	"github.com/bleenco/abstruse/server/core"
@@ -11,6 +12,15 @@ import (
 
 // HandleBadge returns an http.HandlerFunc that writes SVG status build
 // icon to the http response body.
+//
+// @Summary Get build status badge
+// @Tags badges
+// @Param branch query string "branch, default branch of repository when empty"
+// @Produce image/svg+xml
+// @Success 200 string "SVG badge"
+// @Security none
+// @Server /
+// @Router /badge/{token} [get]
 func HandleBadge(builds core.BuildStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := chi.URLParam(r, "token")
@@ -20,22 +30,79 @@ func HandleBadge(builds core.BuildStore) http.HandlerFunc {
 		if err != nil {
 			status = core.BuildStatusUnknown
 		}
-		color := "#555555"
-		if status == core.BuildStatusPassing {
-			color = "#48bb78"
-		} else if status == core.BuildStatusFailing {
-			color = "#e74c3c"
-		} else if status == core.BuildStatusRunning {
-			color = "#ecc94b"
+
+		writeBadge(w, status)
+	}
+}
+
+// HandleBranchBadge returns an http.HandlerFunc that writes SVG status
+// icon of the last finished build on the branch to the http response body.
+// Path is in {repo}/{branch}.svg form where repo is repository full name,
+// badge is served only for repositories with public badge enabled.
+//
+// @Summary Get public branch status badge
+// @Tags badges
+// @Param path path string "{repo}/{branch}.svg, repo is repository full name"
+// @Produce image/svg+xml
+// @Success 200 string "SVG badge"
+// @Security none
+// @Server /
+// @Router /api/badge/{path} [get]
+func HandleBranchBadge(repos core.RepositoryStore, builds core.BuildStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := chi.URLParam(r, "*")
+		if !strings.HasSuffix(path, ".svg") {
+			render.ResourceNotFoundError(w, "badge", "badge not found")
+			return
 		}
 
-		svg, err := badge.RenderBytes("build", status, badge.Color(color))
-		if err != nil {
-			render.InternalServerError(w, err.Error())
+		repo, branch := findRepo(repos, strings.TrimSuffix(path, ".svg"))
+		if repo == nil || !repo.PublicBadge {
+			render.ResourceNotFoundError(w, "badge", "badge not found")
 			return
 		}
 
-		w.Header().Set("Content-Type", "image/svg+xml")
-		w.Write(svg)
+		status := core.BuildStatusUnknown
+		if build, err := builds.FindLatest(repo.ID, branch); err == nil {
+			status = build.Status()
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Del("Expires")
+		w.Header().Del("Pragma")
+		writeBadge(w, status)
 	}
 }
+
+// findRepo splits path into repository full name and branch, both can
+// contain slashes so shortest matching repository name is used.
+func findRepo(repos core.RepositoryStore, path string) (*core.Repository, string) {
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		repo, err := repos.FindName(strings.Join(parts[:i], "/"))
+		if err == nil {
+			return repo, strings.Join(parts[i:], "/")
+		}
+	}
+	return nil, ""
+}
+
+func writeBadge(w http.ResponseWriter, status string) {
+	color := "#555555"
+	if status == core.BuildStatusPassing {
+		color = "#48bb78"
+	} else if status == core.BuildStatusFailing {
+		color = "#e74c3c"
+	} else if status == core.BuildStatusRunning {
+		color = "#ecc94b"
+	}
+
+	svg, err := badge.RenderBytes("build", status, badge.Color(color))
+	if err != nil {
+		render.InternalServerError(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(svg)
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleCancel returns an http.HandlerFunc that writes JSON encoded
+// cancelled build to the http response body.
+//
+// @Summary Cancel build
+// @Tags builds
+// @Success 200 resp
+// @Router /builds/{id}/cancel [post]
+func HandleCancel(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
+	type resp struct {
+		*core.Build
+		Status string `json:"status"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		build, err := builds.Find(uint(id))
+		if err != nil {
+			render.ResourceNotFoundError(w, "build", err.Error())
+			return
+		}
+
+		if perms := repos.GetPermissions(build.RepositoryID, claims.ID); !perms.Exec {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		build, err = scheduler.CancelBuild(build.ID)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditBuildCancel, fmt.Sprintf("build:%d", build.ID)))
+
+		render.JSON(w, http.StatusOK, resp{build, build.Status()})
+	}
+}
@@ -0,0 +1,106 @@
+package build
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bleenco/abstruse/internal/requestid"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+	"github.com/go-chi/chi"
+)
+
+var envKeyRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// HandleCreate returns an http.HandlerFunc that writes JSON encoded
+// result about manually triggered build to the http response body.
+//
+// @Summary Create build for repository
+// @Tags builds, repos
+// @Body form
+// @Success 201 resp
+// @Router /repos/{id}/builds [post]
+func HandleCreate(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler, ws *ws.Server) http.HandlerFunc {
+	type form struct {
+		Ref    string            `json:"ref"`
+		Commit string            `json:"commit"`
+		Env    map[string]string `json:"env"`
+	}
+
+	type resp struct {
+		ID uint `json:"id"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f form
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		for key := range f.Env {
+			if !envKeyRegexp.MatchString(key) {
+				render.BadRequestError(w, "invalid env variable name "+key)
+				return
+			}
+		}
+
+		if perms := repos.GetPermissions(uint(id), claims.ID); !perms.Exec {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if key := middlewares.APIKeyFromCtx(r.Context()); key != nil && !key.Can(core.ScopeTrigger, uint(id)) {
+			render.ForbiddenError(w, "API key scope does not allow triggering builds on this repository")
+			return
+		}
+
+		opts := core.TriggerBuildOpts{
+			ID:     uint(id),
+			SHA:    f.Commit,
+			Branch: strings.TrimPrefix(f.Ref, "refs/heads/"),
+			Env:    f.Env,
+			UserID: claims.ID,
+		}
+
+		if err := scheduler.Accept(false); err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
+		jobs, buildID, err := builds.TriggerBuild(opts)
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		for _, job := range jobs {
+			job.RequestID = requestid.FromContext(r.Context())
+			if err := scheduler.Next(job); err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+		}
+
+		// broadcast new build
+		if build, err := builds.Find(buildID); err == nil {
+			ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
+		}
+
+		render.JSON(w, http.StatusCreated, resp{ID: buildID})
+	}
+}
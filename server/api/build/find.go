@@ -6,13 +6,20 @@ import (
 
 	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 	"github.com/go-chi/chi"
 )
 
 // HandleFind returns an http.HandlerFunc that writes JSON encoded
 // result of build to the http response.
-func HandleFind(builds core.BuildStore) http.HandlerFunc {
+//
+// @Summary Get build
+// @Tags builds
+// @Param tz query string "time zone of returned times, e.g. Europe/Ljubljana"
+// @Success 200 core.Build
+// @Router /builds/{id} [get]
+func HandleFind(builds core.BuildStore, config *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -20,13 +27,19 @@ func HandleFind(builds core.BuildStore) http.HandlerFunc {
 			render.BadRequestError(w, err.Error())
 			return
 		}
+		loc, err := location(r, config)
+		if err != nil {
+			render.BadRequestError(w, "invalid time zone")
+			return
+		}
 
 		build, err := builds.FindUser(uint(id), claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "build", err.Error())
 			return
 		}
 
+		build.In(loc)
 		render.JSON(w, http.StatusOK, build)
 	}
 }
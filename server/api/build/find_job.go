@@ -6,13 +6,20 @@ import (
 
 	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 	"github.com/go-chi/chi"
 )
 
 // HandleFindJob returns http.handlerFunc that writes JSON encoded
 // job result to the http response body.
-func HandleFindJob(jobs core.JobStore, scheduler core.Scheduler) http.HandlerFunc {
+//
+// @Summary Get job
+// @Tags builds
+// @Param tz query string "time zone of returned times, e.g. Europe/Ljubljana"
+// @Success 200 resp
+// @Router /builds/job/{id} [get]
+func HandleFindJob(jobs core.JobStore, scheduler core.Scheduler, config *config.Config) http.HandlerFunc {
 	type resp struct {
 		*core.Job
 		Log string `json:"log"`
@@ -25,10 +32,15 @@ func HandleFindJob(jobs core.JobStore, scheduler core.Scheduler) http.HandlerFun
 			render.BadRequestError(w, err.Error())
 			return
 		}
+		loc, err := location(r, config)
+		if err != nil {
+			render.BadRequestError(w, "invalid time zone")
+			return
+		}
 
 		job, err := jobs.FindUser(uint(id), claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "job", err.Error())
 			return
 		}
 
@@ -36,6 +48,10 @@ func HandleFindJob(jobs core.JobStore, scheduler core.Scheduler) http.HandlerFun
 			job.Log = currentLog
 		}
 
+		job.In(loc)
+		if job.Build != nil {
+			job.Build.In(loc)
+		}
 		render.JSON(w, http.StatusOK, resp{job, job.Log})
 	}
 }
@@ -1,50 +1,143 @@
 package build
 
 import (
+	"encoding/base64"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 )
 
+// maxLimit is the maximum number of builds returned per page.
+const maxLimit = 100
+
 // HandleList returns an http.HandlerFunc that writes JSON encoded
 // list of builds to the http response body.
-func HandleList(builds core.BuildStore) http.HandlerFunc {
+//
+// @Summary List builds
+// @Tags builds
+// @Param limit query int "number of builds returned, maximum 100"
+// @Param offset query int
+// @Param cursor query string "cursor returned as next_cursor"
+// @Param repoID query int
+// @Param type query string "latest, commits or pull-requests"
+// @Param status query string "passing, failing or running"
+// @Param branch query string
+// @Param from query string "RFC 3339 start time"
+// @Param to query string "RFC 3339 end time"
+// @Param tz query string "time zone of returned times, e.g. Europe/Ljubljana"
+// @Success 200 resp
+// @Router /builds [get]
+func HandleList(builds core.BuildStore, config *config.Config) http.HandlerFunc {
+	type resp struct {
+		Data       []*core.Build `json:"data"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
-		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-		if err != nil {
+		query := r.URL.Query()
+
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
 			limit = 5
 		}
-		offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		offset, err := strconv.Atoi(query.Get("offset"))
 		if err != nil {
 			offset = 0
 		}
-		repoID, err := strconv.Atoi(r.URL.Query().Get("repoID"))
+		repoID, err := strconv.Atoi(query.Get("repoID"))
 		if err != nil {
 			repoID = 0
 		}
-		kind := r.URL.Query().Get("type")
+		loc, err := location(r, config)
+		if err != nil {
+			render.BadRequestError(w, "invalid time zone")
+			return
+		}
+		kind := query.Get("type")
 		if kind == "" {
 			kind = "latest"
 		}
 
+		var cursor uint
+		if c := query.Get("cursor"); c != "" {
+			if cursor, err = decodeCursor(c); err != nil {
+				render.BadRequestError(w, "invalid cursor")
+				return
+			}
+		}
+
+		status := query.Get("status")
+		switch status {
+		case "", core.BuildStatusPassing, core.BuildStatusFailing, core.BuildStatusRunning:
+		default:
+			render.BadRequestError(w, "invalid status")
+			return
+		}
+
+		var from, to time.Time
+		if f := query.Get("from"); f != "" {
+			if from, err = time.Parse(time.RFC3339, f); err != nil {
+				render.BadRequestError(w, "invalid from date")
+				return
+			}
+		}
+		if t := query.Get("to"); t != "" {
+			if to, err = time.Parse(time.RFC3339, t); err != nil {
+				render.BadRequestError(w, "invalid to date")
+				return
+			}
+		}
+
 		filters := core.BuildFilter{
-			Limit:        limit,
+			Limit:        limit + 1,
 			Offset:       offset,
+			Cursor:       cursor,
 			RepositoryID: repoID,
+			Branch:       query.Get("branch"),
+			Status:       status,
+			From:         from,
+			To:           to,
 			Kind:         kind,
 			UserID:       claims.ID,
 		}
 
-		builds, err := builds.List(filters)
+		list, err := builds.List(r.Context(), filters)
 		if err != nil {
 			render.NotFoundError(w, err.Error())
 			return
 		}
 
-		render.JSON(w, http.StatusOK, builds)
+		var next string
+		if len(list) > limit {
+			list = list[:limit]
+			next = encodeCursor(list[limit-1].ID)
+		}
+
+		for _, build := range list {
+			build.In(loc)
+		}
+		render.JSON(w, http.StatusOK, resp{Data: list, NextCursor: next})
+	}
+}
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
 	}
+	id, err := strconv.ParseUint(string(data), 10, 64)
+	return uint(id), err
 }
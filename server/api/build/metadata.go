@@ -0,0 +1,88 @@
+package build
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleMetadata returns an http.HandlerFunc that writes JSON encoded
+// environment metadata the build ran with to the http response body.
+//
+// @Summary Get build environment metadata
+// @Tags builds
+// @Success 200 resp
+// @Router /builds/{id}/metadata [get]
+func HandleMetadata(builds core.BuildStore) http.HandlerFunc {
+	type job struct {
+		ID           uint              `json:"id"`
+		Stage        string            `json:"stage"`
+		Image        string            `json:"image"`
+		ImageDigest  string            `json:"imageDigest"`
+		WorkerID     string            `json:"workerID"`
+		LogTruncated bool              `json:"logTruncated"`
+		Env          map[string]string `json:"env"`
+	}
+
+	type resp struct {
+		ID           uint   `json:"id"`
+		Ref          string `json:"ref"`
+		Branch       string `json:"branch"`
+		Commit       string `json:"commit"`
+		Config       string `json:"config"`
+		LogTruncated bool   `json:"logTruncated"` // log of any job was truncated
+		Jobs         []job  `json:"jobs"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		build, err := builds.FindUser(uint(id), claims.ID)
+		if err != nil {
+			render.ResourceNotFoundError(w, "build", err.Error())
+			return
+		}
+
+		if !build.Repository.Perms.Read {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		data := resp{
+			ID:     build.ID,
+			Ref:    build.Ref,
+			Branch: build.Branch,
+			Commit: build.Commit,
+			Config: build.Config,
+			Jobs:   []job{},
+		}
+		for _, j := range build.Jobs {
+			env := make(map[string]string)
+			if j.Environment != "" {
+				json.Unmarshal([]byte(j.Environment), &env)
+			}
+			data.Jobs = append(data.Jobs, job{
+				ID:           j.ID,
+				Stage:        j.Stage,
+				Image:        j.Image,
+				ImageDigest:  j.ImageDigest,
+				WorkerID:     j.WorkerID,
+				LogTruncated: j.LogTruncated,
+				Env:          env,
+			})
+			data.LogTruncated = data.LogTruncated || j.LogTruncated
+		}
+
+		render.JSON(w, http.StatusOK, data)
+	}
+}
@@ -0,0 +1,74 @@
+package build
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/internal/requestid"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+	"github.com/go-chi/chi"
+)
+
+// HandleRebuild returns an http.HandlerFunc that writes JSON encoded
+// result about creating new build with the same parameters as
+// existing build to the http response body.
+//
+// @Summary Rebuild build
+// @Tags builds
+// @Success 201 resp
+// @Router /builds/{id}/restart [post]
+func HandleRebuild(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler, ws *ws.Server) http.HandlerFunc {
+	type resp struct {
+		ID uint `json:"id"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		build, err := builds.Find(uint(id))
+		if err != nil {
+			render.ResourceNotFoundError(w, "build", err.Error())
+			return
+		}
+
+		if perms := repos.GetPermissions(build.RepositoryID, claims.ID); !perms.Exec {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err := scheduler.Accept(false); err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
+		jobs, buildID, err := builds.Rebuild(build.ID)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		for _, job := range jobs {
+			job.RequestID = requestid.FromContext(r.Context())
+			if err := scheduler.Next(job); err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+		}
+
+		// broadcast new build
+		if build, err := builds.Find(buildID); err == nil {
+			ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
+		}
+
+		render.JSON(w, http.StatusCreated, resp{ID: buildID})
+	}
+}
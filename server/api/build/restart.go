@@ -12,6 +12,12 @@ import (
 
 // HandleRestart returns an http.HandlerFunc that writes JSON encoded
 // result about restarting build to http response body.
+//
+// @Summary Restart build
+// @Tags builds
+// @Body form
+// @Success 200 render.Empty
+// @Router /builds/restart [put]
 func HandleRestart(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler) http.HandlerFunc {
 	type form struct {
 		ID uint `json:"id" valid:"required"`
@@ -29,13 +35,13 @@ func HandleRestart(builds core.BuildStore, repos core.RepositoryStore, scheduler
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
 		build, err := builds.Find(uint(f.ID))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "build", err.Error())
 			return
 		}
 
@@ -44,6 +50,11 @@ func HandleRestart(builds core.BuildStore, repos core.RepositoryStore, scheduler
 			return
 		}
 
+		if err := scheduler.Accept(false); err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
 		if err := scheduler.RestartBuild(build.ID); err != nil {
 			render.InternalServerError(w, err.Error())
 			return
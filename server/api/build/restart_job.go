@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/internal/requestid"
 	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
@@ -12,6 +13,12 @@ import (
 
 // HandleRestartJob returns an http.HandlerFunc that writes JSON encoded
 // result about restarting job to http response body.
+//
+// @Summary Restart job
+// @Tags builds
+// @Body form
+// @Success 200 render.Empty
+// @Router /builds/job/restart [put]
 func HandleRestartJob(jobs core.JobStore, repos core.RepositoryStore, scheduler core.Scheduler) http.HandlerFunc {
 	type form struct {
 		ID uint `json:"id" valid:"required"`
@@ -29,7 +36,7 @@ func HandleRestartJob(jobs core.JobStore, repos core.RepositoryStore, scheduler
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -40,10 +47,16 @@ func HandleRestartJob(jobs core.JobStore, repos core.RepositoryStore, scheduler
 
 		job, err := jobs.Find(uint(f.ID))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "job", err.Error())
 			return
 		}
 
+		if err := scheduler.Accept(false); err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
+		job.RequestID = requestid.FromContext(r.Context())
 		if err := scheduler.Next(job); err != nil {
 			render.InternalServerError(w, err.Error())
 			return
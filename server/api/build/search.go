@@ -0,0 +1,100 @@
+package build
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// HandleSearch returns an http.HandlerFunc that writes JSON encoded
+// list of jobs which logs match search query to the http response body.
+//
+// @Summary Search job logs
+// @Tags builds
+// @Param q query string required "search query"
+// @Param limit query int
+// @Param cursor query string "cursor returned as next_cursor"
+// @Param repoID query int
+// @Param from query string "RFC 3339 start time"
+// @Param to query string "RFC 3339 end time"
+// @Success 200 resp
+// @Router /builds/search [get]
+func HandleSearch(jobs core.JobStore) http.HandlerFunc {
+	type resp struct {
+		Data       []*core.LogMatch `json:"data"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		query := r.URL.Query()
+
+		q := query.Get("q")
+		if len(q) < 3 {
+			render.BadRequestError(w, "search query must be at least 3 characters long")
+			return
+		}
+
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			limit = 20
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		repoID, err := strconv.Atoi(query.Get("repoID"))
+		if err != nil {
+			repoID = 0
+		}
+
+		var cursor uint
+		if c := query.Get("cursor"); c != "" {
+			if cursor, err = decodeCursor(c); err != nil {
+				render.BadRequestError(w, "invalid cursor")
+				return
+			}
+		}
+
+		var from, to time.Time
+		if f := query.Get("from"); f != "" {
+			if from, err = time.Parse(time.RFC3339, f); err != nil {
+				render.BadRequestError(w, "invalid from date")
+				return
+			}
+		}
+		if t := query.Get("to"); t != "" {
+			if to, err = time.Parse(time.RFC3339, t); err != nil {
+				render.BadRequestError(w, "invalid to date")
+				return
+			}
+		}
+
+		filter := core.LogSearchFilter{
+			Query:        q,
+			RepositoryID: uint(repoID),
+			From:         from,
+			To:           to,
+			Limit:        limit + 1,
+			Cursor:       cursor,
+			UserID:       claims.ID,
+		}
+
+		matches, err := jobs.Search(r.Context(), filter)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		var next string
+		if len(matches) > limit {
+			matches = matches[:limit]
+			next = encodeCursor(matches[limit-1].JobID)
+		}
+
+		render.JSON(w, http.StatusOK, resp{Data: matches, NextCursor: next})
+	}
+}
@@ -1,6 +1,7 @@
 package build
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -12,7 +13,13 @@ import (
 
 // HandleStop returns an http.HandlerFunc that writes JSON encoded
 // result about stopping build to http response body.
-func HandleStop(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler) http.HandlerFunc {
+//
+// @Summary Stop build
+// @Tags builds
+// @Body form
+// @Success 200 render.Empty
+// @Router /builds/stop [put]
+func HandleStop(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		ID uint `json:"id" valid:"required"`
 	}
@@ -29,13 +36,13 @@ func HandleStop(builds core.BuildStore, repos core.RepositoryStore, scheduler co
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
 		build, err := builds.Find(uint(f.ID))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "build", err.Error())
 			return
 		}
 
@@ -49,6 +56,8 @@ func HandleStop(builds core.BuildStore, repos core.RepositoryStore, scheduler co
 			return
 		}
 
+		audit.Record(middlewares.AuditEvent(r, core.AuditBuildStop, fmt.Sprintf("build:%d", build.ID)))
+
 		render.JSON(w, http.StatusOK, render.Empty{})
 	}
 }
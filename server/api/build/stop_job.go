@@ -12,6 +12,12 @@ import (
 
 // HandleStopJob returns an http.HandlerFunc that writes JSON encoded
 // result about stopping job to the http response body.
+//
+// @Summary Stop job
+// @Tags builds
+// @Body form
+// @Success 200 bool
+// @Router /builds/job/stop [put]
 func HandleStopJob(jobs core.JobStore, repos core.RepositoryStore, scheduler core.Scheduler) http.HandlerFunc {
 	type form struct {
 		ID uint `json:"id" valid:"required"`
@@ -29,13 +35,13 @@ func HandleStopJob(jobs core.JobStore, repos core.RepositoryStore, scheduler cor
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
 		job, err := jobs.Find(uint(f.ID))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "job", err.Error())
 			return
 		}
 
@@ -0,0 +1,80 @@
+package build
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleTests returns an http.HandlerFunc that writes JSON encoded
+// test results of the build jobs to the http response body. Totals sum
+// results of all jobs, jobs without test reports are left out.
+//
+// @Summary Get build test results
+// @Tags builds
+// @Success 200 resp
+// @Router /builds/{id}/tests [get]
+func HandleTests(builds core.BuildStore) http.HandlerFunc {
+	type job struct {
+		ID    uint             `json:"id"`
+		Stage string           `json:"stage"`
+		Env   string           `json:"env"`
+		Tests *core.TestReport `json:"tests"`
+	}
+
+	type failure struct {
+		JobID uint `json:"jobID"`
+		*core.TestFailure
+	}
+
+	type resp struct {
+		ID       uint      `json:"id"`
+		Total    int       `json:"total"`
+		Passed   int       `json:"passed"`
+		Failed   int       `json:"failed"`
+		Skipped  int       `json:"skipped"`
+		Failures []failure `json:"failures"` // failures of all jobs
+		Jobs     []job     `json:"jobs"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		build, err := builds.FindUser(uint(id), claims.ID)
+		if err != nil {
+			render.ResourceNotFoundError(w, "build", err.Error())
+			return
+		}
+
+		if !build.Repository.Perms.Read {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		data := resp{ID: build.ID, Failures: []failure{}, Jobs: []job{}}
+		for _, j := range build.Jobs {
+			if j.Tests == nil {
+				continue
+			}
+			data.Total += j.Tests.Total
+			data.Passed += j.Tests.Passed
+			data.Failed += j.Tests.Failed
+			data.Skipped += j.Tests.Skipped
+			for _, f := range j.Tests.Failures {
+				data.Failures = append(data.Failures, failure{JobID: j.ID, TestFailure: f})
+			}
+			data.Jobs = append(data.Jobs, job{ID: j.ID, Stage: j.Stage, Env: j.Env, Tests: j.Tests})
+		}
+
+		render.JSON(w, http.StatusOK, data)
+	}
+}
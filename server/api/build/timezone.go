@@ -0,0 +1,17 @@
+package build
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+)
+
+// location returns time zone build times are presented in, from tz query
+// parameter or display config.
+func location(r *http.Request, config *config.Config) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		return time.LoadLocation(tz)
+	}
+	return config.Display.Location()
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/internal/requestid"
 	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
@@ -13,12 +14,19 @@ import (
 
 // HandleTrigger returns an http.HandlerFunc that writes JSON encoded
 // result about triggering build to http response body.
-func HandleTrigger(builds core.BuildStore, scheduler core.Scheduler, ws *ws.Server) http.HandlerFunc {
+//
+// @Summary Trigger build
+// @Tags builds
+// @Body form
+// @Success 200 render.Empty
+// @Router /builds/trigger [put]
+func HandleTrigger(builds core.BuildStore, repos core.RepositoryStore, scheduler core.Scheduler, ws *ws.Server) http.HandlerFunc {
 	type form struct {
 		ID     uint   `json:"id" valid:"required"`
 		Config string `json:"config"`
 		SHA    string `json:"sha"`
 		Branch string `json:"branch"`
+		Worker string `json:"worker"` // pins jobs to worker
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -33,7 +41,17 @@ func HandleTrigger(builds core.BuildStore, scheduler core.Scheduler, ws *ws.Serv
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
+			return
+		}
+
+		if perms := repos.GetPermissions(f.ID, claims.ID); !perms.Exec {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if key := middlewares.APIKeyFromCtx(r.Context()); key != nil && !key.Can(core.ScopeTrigger, f.ID) {
+			render.ForbiddenError(w, "API key scope does not allow triggering builds on this repository")
 			return
 		}
 
@@ -42,16 +60,23 @@ func HandleTrigger(builds core.BuildStore, scheduler core.Scheduler, ws *ws.Serv
 			Config: f.Config,
 			SHA:    f.SHA,
 			Branch: f.Branch,
+			Worker: f.Worker,
 			UserID: claims.ID,
 		}
 
-		jobs, err := builds.TriggerBuild(opts)
+		if err := scheduler.Accept(false); err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
+		jobs, id, err := builds.TriggerBuild(opts)
 		if err != nil {
 			render.InternalServerError(w, err.Error())
 			return
 		}
 
 		for _, job := range jobs {
+			job.RequestID = requestid.FromContext(r.Context())
 			if err := scheduler.Next(job); err != nil {
 				render.InternalServerError(w, err.Error())
 				return
@@ -59,7 +84,7 @@ func HandleTrigger(builds core.BuildStore, scheduler core.Scheduler, ws *ws.Serv
 		}
 
 		// broadcast new build
-		if build, err := builds.Find(f.ID); err == nil {
+		if build, err := builds.Find(id); err == nil {
 			ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
 		}
 
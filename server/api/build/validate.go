@@ -0,0 +1,42 @@
+package build
+
+import (
+	"net/http"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/parser"
+)
+
+// HandleValidate returns an http.HandlerFunc that validates build config
+// with the same parser and image policy used when builds are created
+// and writes JSON encoded errors and warnings to the http response body.
+// Nothing is queued and no worker is needed.
+//
+// @Summary Validate build config
+// @Tags builds
+// @Body form
+// @Success 200 parser.Validation
+// @Router /validate [post]
+func HandleValidate(config *config.Config) http.HandlerFunc {
+	type form struct {
+		Config string `json:"config"` // content of .abstruse.yml
+		Branch string `json:"branch"` // branch conditions are evaluated for
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var f form
+		defer r.Body.Close()
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+		if f.Branch == "" {
+			f.Branch = "master"
+		}
+
+		render.JSON(w, http.StatusOK, parser.Validate(f.Config, f.Branch, config.Images.Policy()))
+	}
+}
@@ -0,0 +1,149 @@
+package event
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+)
+
+// heartbeat is interval of comment lines keeping idle streams alive.
+const heartbeat = 15 * time.Second
+
+// HandleStream returns an http.HandlerFunc that streams build, job,
+// queue and worker events as server-sent events. Build and job events
+// are sent only for repositories user can read. Stream is resumed
+// after event sent in Last-Event-ID header, when the event is no
+// longer kept reset event is sent first and clients should reload
+// their state.
+//
+// @Summary Stream events
+// @Tags events
+// @Param Last-Event-ID header int "id of the last received event"
+// @Success 200 string "text/event-stream of build, job, queue, worker_connected and worker_disconnected events"
+// @Router /events [get]
+func HandleStream(events *ws.Events, repos core.RepositoryStore, builds core.BuildStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			render.InternalServerError(w, "streaming not supported")
+			return
+		}
+
+		var last uint64
+		if id := r.Header.Get("Last-Event-ID"); id != "" {
+			last, _ = strconv.ParseUint(id, 10, 64)
+		}
+
+		missed, ch, ok := events.Subscribe(last)
+		defer events.Unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+		if !ok {
+			fmt.Fprintf(w, "event: reset\ndata: {}\n\n")
+		}
+
+		f := filter{
+			admin:  claims.Role == core.RoleAdmin,
+			userID: claims.ID,
+			repos:  repos,
+			builds: builds,
+			read:   make(map[uint]bool),
+			build:  make(map[uint]uint),
+		}
+		for _, e := range missed {
+			if f.allowed(e) {
+				write(w, e)
+			}
+		}
+		flusher.Flush()
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				fmt.Fprintf(w, ": ping\n\n")
+				flusher.Flush()
+			case e, ok := <-ch:
+				if !ok {
+					// subscriber fell behind, client resumes with Last-Event-ID.
+					return
+				}
+				if f.allowed(e) {
+					write(w, e)
+					flusher.Flush()
+				}
+			}
+		}
+	}
+}
+
+func write(w http.ResponseWriter, e ws.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Payload)
+}
+
+// filter checks read permissions of repositories events belong to,
+// results are cached for the lifetime of the stream.
+type filter struct {
+	admin  bool
+	userID uint
+	repos  core.RepositoryStore
+	builds core.BuildStore
+	read   map[uint]bool // repository id -> read permission
+	build  map[uint]uint // build id -> repository id
+}
+
+func (f *filter) allowed(e ws.Event) bool {
+	if f.admin {
+		return true
+	}
+
+	switch e.Type {
+	case "build":
+		build, ok := e.Data["build"].(*core.Build)
+		if !ok {
+			return false
+		}
+		return f.canRead(build.RepositoryID)
+	case "job":
+		id, ok := e.Data["buildID"].(uint)
+		if !ok {
+			return false
+		}
+		repoID, ok := f.build[id]
+		if !ok {
+			build, err := f.builds.Find(id)
+			if err != nil {
+				return false
+			}
+			repoID = build.RepositoryID
+			f.build[id] = repoID
+		}
+		return f.canRead(repoID)
+	default:
+		return true
+	}
+}
+
+func (f *filter) canRead(repoID uint) bool {
+	read, ok := f.read[repoID]
+	if !ok {
+		read = f.repos.GetPermissions(repoID, f.userID).Read
+		f.read[repoID] = read
+	}
+	return read
+}
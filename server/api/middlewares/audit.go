@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// AuditEvent returns audit event of the request performed by
+// authenticated user, with source IP of the client.
+func AuditEvent(r *http.Request, action, target string) core.AuditEvent {
+	claims := ClaimsFromCtx(r.Context())
+	return core.AuditEvent{
+		ActorID:  claims.ID,
+		Actor:    claims.Email,
+		Action:   action,
+		Target:   target,
+		SourceIP: SourceIP(r),
+	}
+}
+
+// SourceIP returns IP address of the client, RealIP middleware must
+// be applied for addresses of clients behind reverse proxy.
+func SourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
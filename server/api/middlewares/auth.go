@@ -2,7 +2,9 @@ package middlewares
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/bleenco/abstruse/internal/auth"
 	"github.com/bleenco/abstruse/server/api/render"
@@ -11,32 +13,111 @@ import (
 
 type ctxKey int
 
-const ctxClaims ctxKey = iota
+const (
+	ctxClaims ctxKey = iota
+	ctxAPIKey
+)
 
-// Authenticator middleware.
-func Authenticator(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, claims, err := auth.FromContext(r.Context())
+// Authenticator middleware authenticates user by JWT token or
+// by API key passed in X-API-Key or Authorization header.
+func Authenticator(keys core.APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if plain := apiKeyFromRequest(r); plain != "" {
+				key, err := keys.FindKey(plain)
+				if err != nil || key.User == nil || !key.User.Active {
+					render.UnathorizedError(w, "invalid API key")
+					return
+				}
+
+				if (r.Method == http.MethodGet || r.Method == http.MethodHead) && !key.Can(core.ScopeRead, 0) {
+					render.ForbiddenError(w, "API key scope does not allow read access")
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), ctxClaims, key.User.Claims())
+				ctx = context.WithValue(ctx, ctxAPIKey, key)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 
-		if err != nil {
-			render.UnathorizedError(w, err.Error())
-			return
-		}
+			token, claims, err := auth.FromContext(r.Context())
 
-		if !token.Valid {
-			render.UnathorizedError(w, "token expired")
-			return
-		}
+			if err != nil {
+				render.UnathorizedError(w, err.Error())
+				return
+			}
 
-		var c auth.UserClaims
-		if err := c.ParseClaims(claims); err != nil {
-			render.UnathorizedError(w, "invalid access token")
-			return
-		}
+			if !token.Valid {
+				render.UnathorizedError(w, "token expired")
+				return
+			}
 
-		ctx := context.WithValue(r.Context(), ctxClaims, c)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			var c auth.UserClaims
+			if err := c.ParseClaims(claims); err != nil {
+				render.UnathorizedError(w, "invalid access token")
+				return
+			}
+
+			if c.Revoked() {
+				render.UnathorizedError(w, "token revoked")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ctxClaims, c)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Scope middleware allows access to requests authenticated with
+// API key only if key has the scope. Requests authenticated with
+// JWT token are not affected.
+func Scope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := APIKeyFromCtx(r.Context()); key != nil && !key.CanAny(scope) {
+				render.ForbiddenError(w, fmt.Sprintf("API key scope %s required", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeyFromCtx returns API key used to authenticate request or
+// nil if request was authenticated with JWT token.
+func APIKeyFromCtx(ctx context.Context) *core.APIKey {
+	key, _ := ctx.Value(ctxAPIKey).(*core.APIKey)
+	return key
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	bearer := r.Header.Get("Authorization")
+	if len(bearer) > 7 && strings.ToLower(bearer[0:6]) == "bearer" && strings.HasPrefix(bearer[7:], core.APIKeyPrefix) {
+		return bearer[7:]
+	}
+	return ""
+}
+
+// Authorize middleware allows access only to users with one of
+// the roles. Role is read from token claims.
+func Authorize(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := ClaimsFromCtx(r.Context())
+			if !core.HasRole(claims.Role, roles...) {
+				render.ForbiddenError(w, "permission denied")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // ClaimsFromCtx returns user claims from context.
@@ -0,0 +1,117 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/api/render"
+)
+
+// bucketTTL defines how long idle client buckets are kept in memory.
+const bucketTTL = 10 * time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter is a token bucket rate limiter keyed by client.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter returns a new RateLimiter which allows limit
+// requests per minute for each client.
+func NewRateLimiter(limit int) *RateLimiter {
+	l := &RateLimiter{
+		rate:    float64(limit) / 60,
+		burst:   float64(limit),
+		buckets: make(map[string]*bucket),
+	}
+	go l.cleanup()
+	return l
+}
+
+// Allow takes a token from the client bucket and returns true if
+// request is allowed, otherwise returns duration after which next
+// request will be allowed.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) cleanup() {
+	ticker := time.NewTicker(bucketTTL)
+	for range ticker.C {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if time.Since(b.last) > bucketTTL {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// RateLimit middleware limits number of requests per minute for each
+// client. Authenticated users are limited by user ID, others by IP
+// address. Limit of 0 disables rate limiting.
+func RateLimit(limit int) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	limiter := NewRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := limiter.Allow(clientKey(r))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				render.TooManyRequestsError(w, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request) string {
+	if claims, ok := r.Context().Value(ctxClaims).(auth.UserClaims); ok {
+		return fmt.Sprintf("user:%d", claims.ID)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return fmt.Sprintf("ip:%s", host)
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+var (
+	xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
+	xRealIP       = http.CanonicalHeaderKey("X-Real-IP")
+)
+
+// RealIP middleware sets remote address of requests sent through trusted
+// proxies to client address from X-Forwarded-For or X-Real-IP headers.
+// Headers of other requests are ignored, so clients cannot change address
+// they are rate limited and audited by. Requests received on unix socket
+// come from reverse proxy in front of the socket and are always trusted.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isTrusted(r.RemoteAddr, trusted) {
+				if ip := forwardedIP(r, trusted); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns client address of request sent through trusted
+// proxies, X-Forwarded-For is read from the last entry skipping trusted
+// proxies as entries before them are set by the client.
+func forwardedIP(r *http.Request, trusted []*net.IPNet) string {
+	if xff := r.Header.Get(xForwardedFor); xff != "" {
+		addrs := strings.Split(xff, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if net.ParseIP(addr) == nil {
+				return ""
+			}
+			if i == 0 || !isTrusted(addr, trusted) {
+				return addr
+			}
+		}
+	}
+	if addr := strings.TrimSpace(r.Header.Get(xRealIP)); net.ParseIP(addr) != nil {
+		return addr
+	}
+	return ""
+}
+
+// isTrusted reports whether address is in trusted networks, addresses
+// which are not IP addresses are unix socket peers.
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
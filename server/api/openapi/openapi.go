@@ -0,0 +1,35 @@
+// Package openapi serves OpenAPI 3 specification of the HTTP API.
+// Specification is generated from handler annotations with
+// `make openapi`, see cmd/abstruse-openapi.
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/bleenco/abstruse/server/api/render"
+)
+
+// spec is set by generated openapi_gen.go file.
+var spec []byte
+
+// HandleSpec returns an http.HandlerFunc that writes OpenAPI
+// specification to the http response body.
+//
+// @Summary Get OpenAPI specification
+// @Tags system
+// @Success 200 map[string]interface{}
+// @Security none
+// @Server /
+// @Router /openapi.json [get]
+func HandleSpec() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(spec) == 0 {
+			render.NotFoundError(w, "openapi specification not generated")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(spec)
+	}
+}
@@ -12,6 +12,12 @@ import (
 
 // HandleCreate returns http.HandlerFunc which writes JSON encoded
 // result about creating provider to the http response body.
+//
+// @Summary Create provider
+// @Tags providers
+// @Body form
+// @Success 200 core.Provider
+// @Router /providers [post]
 func HandleCreate(providers core.ProviderStore) http.HandlerFunc {
 	type form struct {
 		Name        string `json:"name" valid:"stringlength(4|12),required"`
@@ -33,7 +39,7 @@ func HandleCreate(providers core.ProviderStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
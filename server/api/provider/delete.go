@@ -12,6 +12,11 @@ import (
 
 // HandleDelete returns an http.HandlerFunc that writes JSON encoded
 // result about deleting provider to the http response body.
+//
+// @Summary Delete provider
+// @Tags providers
+// @Success 200 render.Empty
+// @Router /providers/{id} [delete]
 func HandleDelete(providers core.ProviderStore, users core.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
@@ -29,7 +34,7 @@ func HandleDelete(providers core.ProviderStore, users core.UserStore) http.Handl
 
 		provider, err := providers.Find(uint(id))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "provider", err.Error())
 			return
 		}
 
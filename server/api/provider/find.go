@@ -11,6 +11,11 @@ import (
 )
 
 // HandleFind writes JSON encoded provider data to the http response body.
+//
+// @Summary Get provider
+// @Tags providers
+// @Success 200 core.Provider
+// @Router /providers/{id} [get]
 func HandleFind(providers core.ProviderStore, users core.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
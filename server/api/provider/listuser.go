@@ -10,6 +10,11 @@ import (
 
 // HandleListUser returns http.HandlerFunc that writes JSON encoded
 // list of providers based on user id to http response body.
+//
+// @Summary List providers of user
+// @Tags providers
+// @Success 200 []core.Provider
+// @Router /providers [get]
 func HandleListUser(providers core.ProviderStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
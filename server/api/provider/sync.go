@@ -11,6 +11,12 @@ import (
 
 // HandleSync returns an http.HandlerFunc that writes JSON encoded
 // result about syncing provider to the http response body.
+//
+// @Summary Sync provider repositories
+// @Tags providers
+// @Body form
+// @Success 200 render.Empty
+// @Router /providers/sync [put]
 func HandleSync(providers core.ProviderStore) http.HandlerFunc {
 	type form struct {
 		ID uint `json:"id" valid:"required"`
@@ -30,7 +36,7 @@ func HandleSync(providers core.ProviderStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
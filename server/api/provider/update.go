@@ -12,6 +12,12 @@ import (
 
 // HandleUpdate returns http.HandlerFunc which writes JSON encoded
 // result about updating provider to the http response body.
+//
+// @Summary Update provider
+// @Tags providers
+// @Body form
+// @Success 200 core.Provider
+// @Router /providers [put]
 func HandleUpdate(providers core.ProviderStore, users core.UserStore) http.HandlerFunc {
 	type form struct {
 		ID          uint   `json:"id" valid:"required"`
@@ -34,7 +40,7 @@ func HandleUpdate(providers core.ProviderStore, users core.UserStore) http.Handl
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -46,7 +52,7 @@ func HandleUpdate(providers core.ProviderStore, users core.UserStore) http.Handl
 
 		p, err := providers.Find(f.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "provider", err.Error())
 			return
 		}
 
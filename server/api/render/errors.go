@@ -2,29 +2,78 @@ package render
 
 import (
 	"net/http"
+
+	"github.com/asaskevich/govalidator"
+)
+
+// Error codes of API errors.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeTooManyRequests    = "too_many_requests"
+	CodeInternalError      = "internal_error"
+	CodeServiceUnavailable = "service_unavailable"
 )
 
+// ErrorResponse writes API error with status, code and optional details.
+func ErrorResponse(w http.ResponseWriter, status int, code, msg string, details interface{}) {
+	JSON(w, status, Error{Error: ErrorBody{Code: code, Message: msg, Details: details}})
+}
+
 // InternalServerError helper.
 func InternalServerError(w http.ResponseWriter, msg string) {
-	JSON(w, http.StatusInternalServerError, Error{Message: msg})
+	ErrorResponse(w, http.StatusInternalServerError, CodeInternalError, msg, nil)
 }
 
 // UnathorizedError helper.
 func UnathorizedError(w http.ResponseWriter, msg string) {
-	JSON(w, http.StatusUnauthorized, Error{Message: msg})
+	ErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, msg, nil)
 }
 
 // NotFoundError helper.
 func NotFoundError(w http.ResponseWriter, msg string) {
-	JSON(w, http.StatusNotFound, Error{Message: msg})
+	ErrorResponse(w, http.StatusNotFound, CodeNotFound, msg, nil)
+}
+
+// ResourceNotFoundError writes not found error with code of the resource,
+// e.g. build_not_found.
+func ResourceNotFoundError(w http.ResponseWriter, resource, msg string) {
+	ErrorResponse(w, http.StatusNotFound, resource+"_not_found", msg, nil)
 }
 
 // ForbiddenError helper.
 func ForbiddenError(w http.ResponseWriter, msg string) {
-	JSON(w, http.StatusForbidden, Error{Message: msg})
+	ErrorResponse(w, http.StatusForbidden, CodeForbidden, msg, nil)
 }
 
 // BadRequestError helper.
 func BadRequestError(w http.ResponseWriter, msg string) {
-	JSON(w, http.StatusBadRequest, Error{Message: msg})
+	ErrorResponse(w, http.StatusBadRequest, CodeBadRequest, msg, nil)
+}
+
+// ValidationError writes errors of govalidator.ValidateStruct, details
+// contain error message per field.
+func ValidationError(w http.ResponseWriter, err error) {
+	if err == nil {
+		ErrorResponse(w, http.StatusBadRequest, CodeValidationFailed, "validation failed", nil)
+		return
+	}
+	var details map[string]string
+	if fields := govalidator.ErrorsByField(err); len(fields) > 0 {
+		details = fields
+	}
+	ErrorResponse(w, http.StatusBadRequest, CodeValidationFailed, err.Error(), details)
+}
+
+// TooManyRequestsError helper.
+func TooManyRequestsError(w http.ResponseWriter, msg string) {
+	ErrorResponse(w, http.StatusTooManyRequests, CodeTooManyRequests, msg, nil)
+}
+
+// ServiceUnavailableError helper.
+func ServiceUnavailableError(w http.ResponseWriter, msg string) {
+	ErrorResponse(w, http.StatusServiceUnavailable, CodeServiceUnavailable, msg, nil)
 }
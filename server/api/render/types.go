@@ -5,5 +5,13 @@ type Empty struct{}
 
 // Error represents a JSON encoded API error.
 type Error struct {
-	Message string `json:"message"`
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody is the content of API error. Code is stable machine readable
+// identifier of the error, message is meant for humans and may change.
+type ErrorBody struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
 }
@@ -13,6 +13,12 @@ import (
 
 // HandleActive returns an http.HandlerFunc that writes JSON encoded
 // result about saving active status to the http response body.
+//
+// @Summary Set repository active status
+// @Tags repos
+// @Body form
+// @Success 200 render.Empty
+// @Router /repos/{id}/active [put]
 func HandleActive(repos core.RepositoryStore) http.HandlerFunc {
 	type form struct {
 		Active bool `json:"active"`
@@ -41,7 +47,7 @@ func HandleActive(repos core.RepositoryStore) http.HandlerFunc {
 		}
 
 		if err = repos.SetActive(uint(id), f.Active); err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "repository", err.Error())
 			return
 		}
 
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleAutoCancel returns an http.HandlerFunc that writes JSON encoded
+// result about saving auto cancel settings of the repository to the
+// http response body.
+//
+// @Summary Set auto cancel of superseded builds
+// @Description When enabled queued and running builds of the ref are cancelled when webhook triggers new build of the same ref. Tags and release branches are excluded unless releases is set.
+// @Tags repos, config
+// @Body core.AutoCancel
+// @Success 200 core.AutoCancel
+// @Router /repos/{id}/autocancel [put]
+func HandleAutoCancel(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f core.AutoCancel
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err = repos.SetAutoCancel(uint(id), f); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, f)
+	}
+}
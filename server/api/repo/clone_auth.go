@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// cloneAuthResp is clone credentials of the repository without secrets.
+type cloneAuthResp struct {
+	Type      string `json:"type"` // empty when provider token is used
+	Username  string `json:"username,omitempty"`
+	PublicKey string `json:"publicKey,omitempty"` // deploy key to add on provider
+}
+
+// HandleCloneAuth returns an http.HandlerFunc that writes JSON encoded
+// clone credentials of the repository to the http response body.
+// Token and private key are never returned.
+//
+// @Summary Get repository clone credentials
+// @Tags repos, config
+// @Success 200 cloneAuthResp
+// @Router /repos/{id}/cloneauth [get]
+func HandleCloneAuth(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		auth, err := repo.CloneCredentials()
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		resp, err := newCloneAuthResp(auth)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleSetCloneAuth returns an http.HandlerFunc that writes JSON encoded
+// result about saving clone credentials of the repository to the http
+// response body. Workers clone repository and its submodules with SSH
+// deploy key or HTTPS token instead of provider access token.
+//
+// @Summary Set repository clone credentials
+// @Tags repos, config
+// @Body core.CloneAuth
+// @Success 200 cloneAuthResp
+// @Router /repos/{id}/cloneauth [put]
+func HandleSetCloneAuth(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f core.CloneAuth
+		defer r.Body.Close()
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, "invalid clone credentials")
+			return
+		}
+
+		if err := f.Validate(); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		data, err := json.Marshal(f)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err := repos.SetCloneAuth(repo.ID, string(data)); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditCloneAuthSet, fmt.Sprintf("repo:%d", repo.ID)))
+
+		resp, err := newCloneAuthResp(&f)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, resp)
+	}
+}
+
+// HandleRemoveCloneAuth returns an http.HandlerFunc that writes JSON
+// encoded result about removing clone credentials of the repository to
+// the http response body, provider access token is used afterwards.
+//
+// @Summary Remove repository clone credentials
+// @Tags repos, config
+// @Success 200 render.Empty
+// @Router /repos/{id}/cloneauth [delete]
+func HandleRemoveCloneAuth(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		if err := repos.SetCloneAuth(repo.ID, ""); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditCloneAuthRemove, fmt.Sprintf("repo:%d", repo.ID)))
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
+
+func newCloneAuthResp(auth *core.CloneAuth) (cloneAuthResp, error) {
+	if auth == nil {
+		return cloneAuthResp{}, nil
+	}
+	resp := cloneAuthResp{Type: auth.Type, Username: auth.Username}
+	if auth.Type == core.CloneAuthSSH {
+		key, err := auth.PublicKey()
+		if err != nil {
+			return resp, err
+		}
+		resp.PublicKey = key
+	}
+	return resp, nil
+}
@@ -14,6 +14,11 @@ import (
 
 // HandleConfig returns an http.HandlerFunc that writes JSON encoded
 // result about repository config to the http response body.
+//
+// @Summary Get repository build config
+// @Tags repos, config
+// @Success 200 resp
+// @Router /repos/{id}/config [get]
 func HandleConfig(repos core.RepositoryStore) http.HandlerFunc {
 	type resp struct {
 		Content string `json:"content"`
@@ -29,7 +34,7 @@ func HandleConfig(repos core.RepositoryStore) http.HandlerFunc {
 
 		repo, err := repos.Find(uint(id), claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "repository", err.Error())
 			return
 		}
 
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleCreateCron returns an http.HandlerFunc that writes JSON encoded
+// result about creating cron to the http response body.
+//
+// @Summary Create repository cron schedule
+// @Tags repos, config
+// @Body form
+// @Success 200 core.Cron
+// @Router /repos/{id}/crons [put]
+func HandleCreateCron(cron core.CronService, repos core.RepositoryStore) http.HandlerFunc {
+	type form struct {
+		Spec     string `json:"spec" valid:"required"`
+		Branch   string `json:"branch"`
+		Timezone string `json:"timezone"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f form
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
+			render.ValidationError(w, err)
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		c := &core.Cron{
+			Spec:         f.Spec,
+			Branch:       f.Branch,
+			Timezone:     f.Timezone,
+			RepositoryID: uint(id),
+		}
+
+		if err := cron.Create(c); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, c)
+	}
+}
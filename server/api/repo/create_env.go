@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -14,7 +15,13 @@ import (
 
 // HandleCreateEnv returns an http.HandlerFunc that writes json encoded
 // result about creating env variable to the http response body.
-func HandleCreateEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore) http.HandlerFunc {
+//
+// @Summary Create repository env variable
+// @Tags repos, config
+// @Body form
+// @Success 200 core.EnvVariable
+// @Router /repos/{id}/envs [put]
+func HandleCreateEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		Key    string `json:"key" valid:"required"`
 		Value  string `json:"value" valid:"required"`
@@ -39,7 +46,7 @@ func HandleCreateEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -60,6 +67,10 @@ func HandleCreateEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 			return
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditEnvCreate, fmt.Sprintf("repo:%d", id))
+		event.Details = fmt.Sprintf("key: %s, secret: %t", env.Key, env.Secret)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, env)
 	}
 }
@@ -14,6 +14,12 @@ import (
 
 // HandleCreateHooks returns an http.HandlerFunc that writes JSON encoded
 // result about creating webhooks on repository to the http response body.
+//
+// @Summary Create repository webhooks
+// @Tags repos
+// @Body gitscm.HookForm
+// @Success 200 render.Empty
+// @Router /repos/{id}/hooks [put]
 func HandleCreateHooks(repos core.RepositoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleDeleteCron returns http.HandlerFunc that writes JSON encoded
+// result about deleting cron to the http response body.
+//
+// @Summary Delete repository cron schedule
+// @Tags repos, config
+// @Success 200 render.Empty
+// @Router /repos/{id}/crons/{cronid} [delete]
+func HandleDeleteCron(crons core.CronStore, repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		cronid, err := strconv.Atoi(chi.URLParam(r, "cronid"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		cron, err := crons.Find(uint(cronid))
+		if err != nil || cron.RepositoryID != uint(id) {
+			render.ResourceNotFoundError(w, "cron", "cron not found")
+			return
+		}
+
+		if err := crons.Delete(cron); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
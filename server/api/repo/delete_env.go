@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -12,7 +13,12 @@ import (
 
 // HandleDeleteEnv returns http.HandlerFunc that writes JSON encoded
 // result about deleting env variable to the http response body.
-func HandleDeleteEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore) http.HandlerFunc {
+//
+// @Summary Delete repository env variable
+// @Tags repos, config
+// @Success 200 render.Empty
+// @Router /repos/{id}/envs/{envid} [delete]
+func HandleDeleteEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
 
@@ -44,6 +50,10 @@ func HandleDeleteEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 			return
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditEnvDelete, fmt.Sprintf("repo:%d", id))
+		event.Details = fmt.Sprintf("key: %s, secret: %t", env.Key, env.Secret)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, render.Empty{})
 	}
 }
@@ -12,7 +12,12 @@ import (
 
 // HandleFind returns an http.HandlerFunc that writes JSON encoded
 // repository result to the http response body.
-func HandleFind(repos core.RepositoryStore) http.HandlerFunc {
+//
+// @Summary Get repository
+// @Tags repos
+// @Success 200 core.Repository
+// @Router /repos/{id} [get]
+func HandleFind(repos core.RepositoryStore, scheduler core.Scheduler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
 
@@ -24,10 +29,12 @@ func HandleFind(repos core.RepositoryStore) http.HandlerFunc {
 
 		repo, err := repos.Find(uint(id), claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "repository", err.Error())
 			return
 		}
 
+		repo.RunningBuilds = scheduler.RunningBuilds(repo.ID)
+
 		render.JSON(w, http.StatusOK, repo)
 	}
 }
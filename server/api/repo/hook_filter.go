@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleHookFilter returns an http.HandlerFunc that writes JSON encoded
+// result about saving webhook filter of the repository to the
+// http response body.
+//
+// @Summary Set webhook filter of repository
+// @Description Webhooks of branches or changed files not matching filter do not trigger builds, pushes with [skip ci] or [ci skip] in commit message are always skipped. Patterns are comma separated globs.
+// @Tags repos, config
+// @Body core.HookFilter
+// @Success 200 core.HookFilter
+// @Router /repos/{id}/filter [put]
+func HandleHookFilter(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f core.HookFilter
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err = repos.SetHookFilter(uint(id), f); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, f)
+	}
+}
@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleAddHookSecret returns an http.HandlerFunc that writes JSON encoded
+// new webhook secret of the repository to the http response body.
+// New secret is accepted together with current secret until it is
+// promoted and the old one is removed, so deliveries signed with either
+// are not rejected while secret is changed on provider.
+//
+// @Summary Add repository webhook secret
+// @Tags repos
+// @Body form
+// @Success 200 resp
+// @Router /repos/{id}/hooks/secrets [post]
+func HandleAddHookSecret(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	type form struct {
+		Secret string `json:"secret"` // generated when empty
+	}
+
+	type resp struct {
+		Secret string `json:"secret"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f form
+		defer r.Body.Close()
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil && err != io.EOF {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if repo.HookSecretAlt != "" {
+			render.BadRequestError(w, "repository already has two webhook secrets, remove old secret first")
+			return
+		}
+		if f.Secret == "" {
+			secret := make([]byte, 20)
+			if _, err := rand.Read(secret); err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+			f.Secret = hex.EncodeToString(secret)
+		}
+
+		if err := repos.SetHookSecrets(repo.ID, repo.HookSecret, f.Secret); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditHookSecret, fmt.Sprintf("repo:%d", repo.ID)))
+
+		render.JSON(w, http.StatusOK, resp{Secret: f.Secret})
+	}
+}
+
+// HandlePromoteHookSecret returns an http.HandlerFunc that writes JSON
+// encoded result about promoting new webhook secret of the repository
+// to the http response body. Webhooks are created with promoted secret,
+// previous secret is still accepted until it is removed.
+//
+// @Summary Promote repository webhook secret
+// @Tags repos
+// @Success 200 render.Empty
+// @Router /repos/{id}/hooks/secrets/promote [put]
+func HandlePromoteHookSecret(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		if repo.HookSecretAlt == "" {
+			render.BadRequestError(w, "repository has no new webhook secret")
+			return
+		}
+
+		if err := repos.SetHookSecrets(repo.ID, repo.HookSecretAlt, repo.WebhookSecret()); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditHookPromote, fmt.Sprintf("repo:%d", repo.ID)))
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
+
+// HandleRemoveHookSecret returns an http.HandlerFunc that writes JSON
+// encoded result about removing alternative webhook secret of the
+// repository to the http response body. After secret is promoted
+// old secret is removed, otherwise new secret which was not promoted.
+//
+// @Summary Remove repository webhook secret
+// @Tags repos
+// @Success 200 render.Empty
+// @Router /repos/{id}/hooks/secrets [delete]
+func HandleRemoveHookSecret(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		repo, ok := findHookRepo(w, r, repos, claims.ID)
+		if !ok {
+			return
+		}
+
+		if err := repos.SetHookSecrets(repo.ID, repo.HookSecret, ""); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditHookRemove, fmt.Sprintf("repo:%d", repo.ID)))
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
+
+// findHookRepo returns repository user can write to or writes error.
+func findHookRepo(w http.ResponseWriter, r *http.Request, repos core.RepositoryStore, userID uint) (core.Repository, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		render.BadRequestError(w, err.Error())
+		return core.Repository{}, false
+	}
+
+	if perm := repos.GetPermissions(uint(id), userID); !perm.Write {
+		render.UnathorizedError(w, "permission denied")
+		return core.Repository{}, false
+	}
+
+	repo, err := repos.Find(uint(id), userID)
+	if err != nil {
+		render.ResourceNotFoundError(w, "repository", err.Error())
+		return core.Repository{}, false
+	}
+	return repo, true
+}
@@ -11,6 +11,14 @@ import (
 
 // HandleList returns an http.HandlerFunc that writes JSON encoded
 // list of repositories based on user id to the http response body.
+//
+// @Summary List repositories
+// @Tags repos
+// @Param limit query int
+// @Param offset query int
+// @Param keyword query string
+// @Success 200 resp
+// @Router /repos [get]
 func HandleList(repos core.RepositoryStore) http.HandlerFunc {
 	type resp struct {
 		Count int               `json:"count"`
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleListCrons returns http.HandlerFunc that writes JSON encoded
+// list of crons with their next run times for repository to the
+// http response body.
+//
+// @Summary List repository cron schedules
+// @Tags repos, config
+// @Success 200 []core.Cron
+// @Router /repos/{id}/crons [get]
+func HandleListCrons(crons core.CronStore, repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Read {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		list, err := crons.List(uint(id))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, list)
+	}
+}
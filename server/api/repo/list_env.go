@@ -12,6 +12,11 @@ import (
 
 // HandleListEnv returns http.HandlerFunc that writes JSON encoded
 // list of env variables for repository to the http response body.
+//
+// @Summary List repository env variables
+// @Tags repos, config
+// @Success 200 []core.EnvVariable
+// @Router /repos/{id}/envs [get]
 func HandleListEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
@@ -12,6 +12,11 @@ import (
 
 // HandleListHooks returns an http.HandlerFunc that writes JSON encoded
 // list of hooks to the http response body.
+//
+// @Summary List repository webhooks
+// @Tags repos
+// @Success 200 []interface{}
+// @Router /repos/{id}/hooks [get]
 func HandleListHooks(repos core.RepositoryStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
@@ -23,7 +28,7 @@ func HandleListHooks(repos core.RepositoryStore) http.HandlerFunc {
 
 		hooks, err := repos.ListHooks(uint(id), claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "repository", err.Error())
 			return
 		}
 
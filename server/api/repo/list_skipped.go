@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleListSkipped returns http.HandlerFunc that writes JSON encoded
+// list of recent webhooks of the repository which did not trigger build,
+// with the reason, to the http response body.
+//
+// @Summary List skipped builds of repository
+// @Tags repos
+// @Param limit query int "number of skipped builds returned, maximum 100"
+// @Success 200 []core.SkippedBuild
+// @Router /repos/{id}/skipped [get]
+func HandleListSkipped(skipped core.SkippedBuildStore, repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Read {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		list, err := skipped.List(uint(id), limit)
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, list)
+	}
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+type maxBuilds struct {
+	MaxBuilds int `json:"maxBuilds"`
+}
+
+// HandleMaxBuilds returns an http.HandlerFunc that writes JSON encoded
+// result about saving maximum number of running builds of the repository
+// to the http response body. Zero value uses server default.
+//
+// @Summary Set maximum running builds of repository
+// @Tags repos, config
+// @Body maxBuilds
+// @Success 200 maxBuilds
+// @Router /repos/{id}/maxbuilds [put]
+func HandleMaxBuilds(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f maxBuilds
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil || f.MaxBuilds < 0 {
+			render.BadRequestError(w, "invalid maximum number of builds")
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err = repos.SetMaxBuilds(uint(id), f.MaxBuilds); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, maxBuilds{MaxBuilds: f.MaxBuilds})
+	}
+}
@@ -0,0 +1,82 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+type notificationsForm struct {
+	Emails     string  `json:"emails"`
+	Author     bool    `json:"author"`
+	SlackURL   *string `json:"slackURL"`   // kept when not set, removed when empty
+	DiscordURL *string `json:"discordURL"` // kept when not set, removed when empty
+	On         string  `json:"on"`
+}
+
+// HandleNotifications returns an http.HandlerFunc that writes JSON encoded
+// result about saving notification settings to the http response body.
+// Webhook URLs are never returned by the API, URLs not set in request
+// are kept.
+//
+// @Summary Set repository notifications
+// @Tags repos, config
+// @Body notificationsForm
+// @Success 200 render.Empty
+// @Router /repos/{id}/notifications [put]
+func HandleNotifications(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f notificationsForm
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		repo, err := repos.Find(uint(id), claims.ID)
+		if err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		notify := core.Notifications{
+			Emails:     f.Emails,
+			Author:     f.Author,
+			SlackURL:   repo.Notify.SlackURL,
+			DiscordURL: repo.Notify.DiscordURL,
+			On:         f.On,
+		}
+		if f.SlackURL != nil {
+			notify.SlackURL = *f.SlackURL
+		}
+		if f.DiscordURL != nil {
+			notify.DiscordURL = *f.DiscordURL
+		}
+
+		if err = repos.SetNotifications(uint(id), notify); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+type publicBadge struct {
+	Public bool `json:"public"`
+}
+
+// HandlePublicBadge returns an http.HandlerFunc that writes JSON encoded
+// result about enabling public branch status badge of the repository to
+// the http response body.
+//
+// @Summary Set public badge of repository
+// @Description Public badge is served without authentication at /api/badge/{repo}/{branch}.svg.
+// @Tags repos, config
+// @Body publicBadge
+// @Success 200 publicBadge
+// @Router /repos/{id}/badge [put]
+func HandlePublicBadge(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f publicBadge
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err = repos.SetPublicBadge(uint(id), f.Public); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, f)
+	}
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+type registryAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type registryConfig struct {
+	Auths map[string]registryAuth `json:"auths"`
+}
+
+// HandleRegistryAuth returns an http.HandlerFunc that writes JSON encoded
+// result about saving docker registry credentials to the http response body.
+// Credentials are expected in Docker's config.json format, empty auths
+// removes credentials from the repository.
+//
+// @Summary Set repository registry credentials
+// @Tags repos, config
+// @Body registryConfig
+// @Success 200 render.Empty
+// @Router /repos/{id}/registry [put]
+func HandleRegistryAuth(repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f registryConfig
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil {
+			render.BadRequestError(w, "invalid registry auth config")
+			return
+		}
+
+		if err := validateRegistryAuth(f); err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		var auth string
+		if len(f.Auths) > 0 {
+			data, err := json.Marshal(f)
+			if err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+			auth = string(data)
+		}
+
+		if err = repos.SetRegistryAuth(uint(id), auth); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		action := core.AuditRegistrySet
+		if auth == "" {
+			action = core.AuditRegistryRemove
+		}
+		audit.Record(middlewares.AuditEvent(r, action, fmt.Sprintf("repo:%d", id)))
+
+		render.JSON(w, http.StatusOK, render.Empty{})
+	}
+}
+
+func validateRegistryAuth(config registryConfig) error {
+	for host, auth := range config.Auths {
+		if strings.TrimSpace(host) == "" {
+			return fmt.Errorf("registry host is required")
+		}
+		if auth.Auth == "" {
+			if auth.Username == "" || auth.Password == "" {
+				return fmt.Errorf("username and password or auth required for registry %s", host)
+			}
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil || !strings.Contains(string(decoded), ":") {
+			return fmt.Errorf("invalid auth for registry %s", host)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleRetention returns an http.HandlerFunc that writes JSON encoded
+// result about saving build retention policy of the repository to the
+// http response body.
+//
+// @Summary Set build retention policy of repository
+// @Description Finished builds within last builds builds or newer than days days are kept, older builds are periodically deleted with their jobs and logs. Zero values disable limits.
+// @Tags repos, config
+// @Body core.Retention
+// @Success 200 core.Retention
+// @Router /repos/{id}/retention [put]
+func HandleRetention(repos core.RepositoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middlewares.ClaimsFromCtx(r.Context())
+		var f core.Retention
+		var err error
+		defer r.Body.Close()
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		if err = lib.DecodeJSON(r.Body, &f); err != nil || f.Builds < 0 || f.Days < 0 {
+			render.BadRequestError(w, "invalid retention policy")
+			return
+		}
+
+		if perm := repos.GetPermissions(uint(id), claims.ID); !perm.Write {
+			render.UnathorizedError(w, "permission denied")
+			return
+		}
+
+		if err = repos.SetRetention(uint(id), f); err != nil {
+			render.ResourceNotFoundError(w, "repository", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, f)
+	}
+}
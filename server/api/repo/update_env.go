@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -14,7 +15,13 @@ import (
 
 // HandleUpdateEnv returns an http.HandlerFunc that writes json encoded
 // result about updating env variable to the http response body.
-func HandleUpdateEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore) http.HandlerFunc {
+//
+// @Summary Update repository env variable
+// @Tags repos, config
+// @Body form
+// @Success 200 core.EnvVariable
+// @Router /repos/{id}/envs [post]
+func HandleUpdateEnv(envVariables core.EnvVariableStore, repos core.RepositoryStore, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		ID     uint   `json:"id" valid:"required"`
 		Key    string `json:"key" valid:"required"`
@@ -40,7 +47,7 @@ func HandleUpdateEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -51,7 +58,7 @@ func HandleUpdateEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 
 		env, err := envVariables.Find(f.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "env", err.Error())
 			return
 		}
 
@@ -66,6 +73,10 @@ func HandleUpdateEnv(envVariables core.EnvVariableStore, repos core.RepositorySt
 			return
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditEnvUpdate, fmt.Sprintf("repo:%d", id))
+		event.Details = fmt.Sprintf("key: %s, secret: %t", env.Key, env.Secret)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, env)
 	}
 }
@@ -9,6 +9,12 @@ import (
 
 // HandleReady returns an http.HandlerFunc that writes JSON encoded
 // status to the http response body.
+//
+// @Summary Get setup status
+// @Tags setup
+// @Success 200 resp
+// @Security none
+// @Router /setup/ready [get]
 func HandleReady(users core.UserStore) http.HandlerFunc {
 	type resp struct {
 		User bool `json:"user"`
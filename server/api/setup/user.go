@@ -11,13 +11,20 @@ import (
 
 // HandleUser returns an http.HandlerFunc that writes JSON encoded
 // result about creating initial user to the http response.
+//
+// @Summary Create initial user
+// @Tags setup
+// @Body form
+// @Success 200 render.Empty
+// @Security none
+// @Router /setup/user [post]
 func HandleUser(users core.UserStore) http.HandlerFunc {
 	type form struct {
 		Email    string `json:"email" valid:"email,required"`
 		Name     string `json:"name" valid:"stringlength(2|50),required"`
 		Avatar   string `json:"avatar" valid:"stringlength(5|255),required"`
 		Password string `json:"password" valid:"stringlength(8|50),required"`
-		Role     string `json:"role" valid:"in(admin|user),required"`
+		Role     string `json:"role" valid:"in(admin|maintainer|viewer|user),required"`
 		Active   bool   `json:"active"`
 	}
 
@@ -31,7 +38,7 @@ func HandleUser(users core.UserStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -10,6 +10,13 @@ import (
 
 // HandleJobs returns an http.HandlerFunc that writes JSON encoded
 // result about jobs statistics to the http response body.
+//
+// @Summary List jobs for statistics
+// @Tags stats
+// @Param from query string "first day in YYYY-MM-DD format, 7 days ago by default"
+// @Param to query string "last day in YYYY-MM-DD format, today by default"
+// @Success 200 []core.Job
+// @Router /stats/jobs [get]
 func HandleJobs(jobs core.JobStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		layout := "2006-01-02"
@@ -10,6 +10,11 @@ import (
 
 // HandlePause returns an http.HandlerFunc which writes JSON encoded
 // result about pausing scheduler to the http response body
+//
+// @Summary Pause scheduler
+// @Tags stats
+// @Success 200 render.Empty
+// @Router /stats/scheduler/pause [put]
 func HandlePause(users core.UserStore, scheduler core.Scheduler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
@@ -10,6 +10,11 @@ import (
 
 // HandleResume returns an http.HandlerFunc which writes JSON encoded
 // result about resuming scheduler to the http response body
+//
+// @Summary Resume scheduler
+// @Tags stats
+// @Success 200 render.Empty
+// @Router /stats/scheduler/resume [put]
 func HandleResume(users core.UserStore, scheduler core.Scheduler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
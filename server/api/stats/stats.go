@@ -9,6 +9,11 @@ import (
 
 // HandleStats returns an http.HandlerFunc that writes JSON encoded
 // server stats to the http response body.
+//
+// @Summary Get server stats
+// @Tags stats
+// @Success 200 resp
+// @Router /stats [get]
 func HandleStats(stats core.StatsService) http.HandlerFunc {
 	type resp struct {
 		Usage  []core.Usage          `json:"usage"`
@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// storageCacheTTL is how long storage usage report is cached, the
+// report sums logs of all jobs and is expensive on large databases.
+const storageCacheTTL = 5 * time.Minute
+
+// HandleStorage returns an http.HandlerFunc that writes JSON encoded
+// database storage used by builds of each repository to the http
+// response body. Report is cached, refresh=true recomputes it.
+//
+// @Summary Storage usage of builds per repository
+// @Tags stats
+// @Param refresh query bool "recompute cached report"
+// @Success 200 resp
+// @Router /stats/storage [get]
+func HandleStorage(builds core.BuildStore) http.HandlerFunc {
+	type resp struct {
+		Data        []*core.StorageUsage `json:"data"`
+		GeneratedAt time.Time            `json:"generatedAt"`
+	}
+
+	var (
+		mu     sync.Mutex
+		cached resp
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Query().Get("refresh") == "true" || time.Since(cached.GeneratedAt) > storageCacheTTL {
+			usage, err := builds.Usage(r.Context())
+			if err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+			if usage == nil {
+				usage = []*core.StorageUsage{}
+			}
+			cached = resp{usage, time.Now()}
+		}
+
+		render.JSON(w, http.StatusOK, cached)
+	}
+}
@@ -0,0 +1,88 @@
+package system
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// maxLimit is the maximum number of audit events returned per page.
+const maxLimit = 500
+
+// HandleAudit returns an http.HandlerFunc that writes JSON encoded
+// list of audit events stored in database to the http response body,
+// newest first. Events are stored only when enabled with --audit-db.
+//
+// @Summary List audit events
+// @Tags system
+// @Param limit query int "number of events returned, maximum 500"
+// @Param cursor query int "cursor returned as next_cursor"
+// @Param action query string "e.g. user.login_failed"
+// @Param actorID query int
+// @Param from query string "RFC 3339 start time"
+// @Param to query string "RFC 3339 end time"
+// @Success 200 resp
+// @Router /system/audit [get]
+func HandleAudit(events core.AuditStore) http.HandlerFunc {
+	type resp struct {
+		Data       []*core.AuditEvent `json:"data"`
+		NextCursor uint               `json:"next_cursor,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 {
+			limit = 100
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+		cursor, err := strconv.ParseUint(query.Get("cursor"), 10, 32)
+		if err != nil {
+			cursor = 0
+		}
+		actorID, err := strconv.ParseUint(query.Get("actorID"), 10, 32)
+		if err != nil {
+			actorID = 0
+		}
+
+		var from, to time.Time
+		if f := query.Get("from"); f != "" {
+			if from, err = time.Parse(time.RFC3339, f); err != nil {
+				render.BadRequestError(w, "invalid from date")
+				return
+			}
+		}
+		if t := query.Get("to"); t != "" {
+			if to, err = time.Parse(time.RFC3339, t); err != nil {
+				render.BadRequestError(w, "invalid to date")
+				return
+			}
+		}
+
+		list, err := events.List(core.AuditFilter{
+			Action:  query.Get("action"),
+			ActorID: uint(actorID),
+			From:    from,
+			To:      to,
+			Cursor:  uint(cursor),
+			Limit:   limit + 1,
+		})
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		var next uint
+		if len(list) > limit {
+			list = list[:limit]
+			next = list[limit-1].ID
+		}
+		render.JSON(w, http.StatusOK, resp{Data: list, NextCursor: next})
+	}
+}
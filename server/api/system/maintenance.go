@@ -0,0 +1,83 @@
+package system
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// HandleMaintenance returns an http.HandlerFunc that writes JSON encoded
+// maintenance mode status with number of builds waiting to start to the
+// http response body.
+//
+// @Summary Get maintenance mode status
+// @Tags system
+// @Success 200 core.Maintenance
+// @Router /system/maintenance [get]
+func HandleMaintenance(scheduler core.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, http.StatusOK, scheduler.Maintenance())
+	}
+}
+
+// HandleStartMaintenance returns an http.HandlerFunc that writes JSON
+// encoded maintenance mode status to the http response body after
+// maintenance is started. Queued builds are not started until
+// maintenance is lifted, running builds finish.
+//
+// @Summary Start maintenance mode
+// @Tags system
+// @Body form
+// @Success 200 core.Maintenance
+// @Router /system/maintenance [put]
+func HandleStartMaintenance(scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
+	type form struct {
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var f form
+		defer r.Body.Close()
+
+		if err := lib.DecodeJSON(r.Body, &f); err != nil && err != io.EOF {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		if err := scheduler.StartMaintenance(f.Message); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		event := middlewares.AuditEvent(r, core.AuditMaintenanceOn, "scheduler")
+		event.Details = f.Message
+		audit.Record(event)
+
+		render.JSON(w, http.StatusOK, scheduler.Maintenance())
+	}
+}
+
+// HandleStopMaintenance returns an http.HandlerFunc that writes JSON
+// encoded maintenance mode status to the http response body after
+// maintenance is lifted and queued builds are started.
+//
+// @Summary Stop maintenance mode
+// @Tags system
+// @Success 200 core.Maintenance
+// @Router /system/maintenance [delete]
+func HandleStopMaintenance(scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := scheduler.StopMaintenance(); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		audit.Record(middlewares.AuditEvent(r, core.AuditMaintenanceOff, "scheduler"))
+
+		render.JSON(w, http.StatusOK, scheduler.Maintenance())
+	}
+}
@@ -9,6 +9,11 @@ import (
 
 // HandleVersion returns an http.HandlerFunc that writes JSON
 // encoded version data to the http response body.
+//
+// @Summary Get server version
+// @Tags system
+// @Success 200 version.BuildInfo
+// @Router /system/version [get]
 func HandleVersion() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		render.JSON(w, http.StatusOK, version.GetBuildInfo())
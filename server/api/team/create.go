@@ -1,6 +1,7 @@
 package team
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -12,7 +13,13 @@ import (
 
 // HandleCreate returns an http.HandlerFunc that writes JSON encoded
 // result about creating team to the http response body.
-func HandleCreate(teams core.TeamStore, users core.UserStore, permissions core.PermissionStore) http.HandlerFunc {
+//
+// @Summary Create team
+// @Tags teams
+// @Body form
+// @Success 200 core.Team
+// @Router /teams [post]
+func HandleCreate(teams core.TeamStore, users core.UserStore, permissions core.PermissionStore, audit core.AuditService) http.HandlerFunc {
 	type repoPerm struct {
 		ID    uint `json:"id"`
 		Read  bool `json:"read"`
@@ -45,7 +52,7 @@ func HandleCreate(teams core.TeamStore, users core.UserStore, permissions core.P
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -85,6 +92,10 @@ func HandleCreate(teams core.TeamStore, users core.UserStore, permissions core.P
 			}
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditTeamCreate, fmt.Sprintf("team:%d", team.ID))
+		event.Details = fmt.Sprintf("name: %s", team.Name)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, team)
 	}
 }
@@ -11,6 +11,11 @@ import (
 
 // HandleFind returns an http.HandlerFunc that writes JSON encoded
 // team result to the http response body.
+//
+// @Summary Get team
+// @Tags teams
+// @Success 200 core.Team
+// @Router /teams/{id} [get]
 func HandleFind(teams core.TeamStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -21,7 +26,7 @@ func HandleFind(teams core.TeamStore) http.HandlerFunc {
 
 		team, err := teams.Find(uint(id))
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "team", err.Error())
 			return
 		}
 
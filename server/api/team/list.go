@@ -9,6 +9,11 @@ import (
 
 // HandleList returns an http.HandlerFunc that writes JSON encoded
 // teams result to the http response body.
+//
+// @Summary List teams
+// @Tags teams
+// @Success 200 []core.Team
+// @Router /teams [get]
 func HandleList(teams core.TeamStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		teams, err := teams.List()
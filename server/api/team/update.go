@@ -1,6 +1,7 @@
 package team
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -12,7 +13,13 @@ import (
 
 // HandleUpdate returns an http.HandlerFunc that writes JSON encoded
 // result about updating team to the http response body.
-func HandleUpdate(teams core.TeamStore, users core.UserStore, permissions core.PermissionStore) http.HandlerFunc {
+//
+// @Summary Update team
+// @Tags teams
+// @Body form
+// @Success 200 core.Team
+// @Router /teams [put]
+func HandleUpdate(teams core.TeamStore, users core.UserStore, permissions core.PermissionStore, audit core.AuditService) http.HandlerFunc {
 	type repoPerm struct {
 		ID    uint `json:"id"`
 		Read  bool `json:"read"`
@@ -46,13 +53,13 @@ func HandleUpdate(teams core.TeamStore, users core.UserStore, permissions core.P
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
 		team, err := teams.Find(f.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "team", err.Error())
 			return
 		}
 
@@ -134,6 +141,10 @@ func HandleUpdate(teams core.TeamStore, users core.UserStore, permissions core.P
 			}
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditTeamUpdate, fmt.Sprintf("team:%d", team.ID))
+		event.Details = fmt.Sprintf("name: %s", team.Name)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, team)
 	}
 }
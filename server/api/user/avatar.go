@@ -14,6 +14,12 @@ import (
 
 // HandleAvatar returns http.HandlerFunc that writes JSON encoded
 // result about uploaded avatar to the http response body.
+//
+// @Summary Upload avatar
+// @Tags users
+// @Body file multipart/form-data
+// @Success 200 string "path of uploaded avatar"
+// @Router /users/avatar [post]
 func HandleAvatar(uploadDir string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.ParseMultipartForm(3 << 20)
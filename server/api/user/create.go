@@ -1,6 +1,7 @@
 package user
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -12,13 +13,19 @@ import (
 
 // HandleCreate returns an http.HandlerFunc that write JSON encoded
 // result about creating user to the http response body.
-func HandleCreate(users core.UserStore) http.HandlerFunc {
+//
+// @Summary Create user
+// @Tags users
+// @Body form
+// @Success 200 core.User
+// @Router /users [post]
+func HandleCreate(users core.UserStore, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		Email    string `json:"email" valid:"email,required"`
 		Password string `json:"password" valid:"stringlength(8|50),required"`
 		Name     string `json:"name" valid:"stringlength(3|50),required"`
 		Avatar   string `json:"avatar" valid:"stringlength(5|255),required"`
-		Role     string `json:"role" valid:"required"`
+		Role     string `json:"role" valid:"in(admin|maintainer|viewer|user),required"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +46,7 @@ func HandleCreate(users core.UserStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -57,6 +64,10 @@ func HandleCreate(users core.UserStore) http.HandlerFunc {
 			return
 		}
 
+		event := middlewares.AuditEvent(r, core.AuditUserCreate, fmt.Sprintf("user:%d", user.ID))
+		event.Details = fmt.Sprintf("email: %s, role: %s", user.Email, user.Role)
+		audit.Record(event)
+
 		render.JSON(w, http.StatusOK, user)
 	}
 }
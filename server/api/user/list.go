@@ -9,6 +9,11 @@ import (
 
 // HandleList returns an http.HandlerFunc that writes JSON encoded
 // list of users to the http response body.
+//
+// @Summary List users
+// @Tags users
+// @Success 200 []core.User
+// @Router /users [get]
 func HandleList(users core.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		data, err := users.List()
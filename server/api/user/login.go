@@ -1,17 +1,31 @@
 package user
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/bleenco/abstruse/internal/auth"
 	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 )
 
 // HandleLogin returns an http.HandlerFunc that writes JSON encoded
 // login data to the http response body.
-func HandleLogin(users core.UserStore) http.HandlerFunc {
+//
+// @Summary Login
+// @Description Failed logins are throttled per email with exponential delay
+// @Description and email is locked after too many failures.
+// @Tags auth
+// @Body form
+// @Success 200 resp
+// @Security none
+// @Router /auth/login [post]
+func HandleLogin(users core.UserStore, attempts core.LoginAttemptStore, audit core.AuditService, config *config.Config) http.HandlerFunc {
 	type form struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -30,8 +44,33 @@ func HandleLogin(users core.UserStore) http.HandlerFunc {
 			return
 		}
 
+		event := core.AuditEvent{Actor: f.Email, Target: "user:" + f.Email, SourceIP: middlewares.SourceIP(r)}
+
+		lockout := config.Auth.Lockout
+		throttle := lockout != nil && lockout.Attempts > 0
+
+		if throttle {
+			if attempt, err := attempts.Find(f.Email); err == nil {
+				if wait := attempt.RetryAfter(time.Now()); wait > 0 {
+					event.Action, event.Details = core.AuditLoginFailed, "locked out"
+					audit.Record(event)
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+					render.TooManyRequestsError(w, "too many failed login attempts, try again later")
+					return
+				}
+			}
+		}
+
 		if users.Login(f.Email, f.Password) {
+			if throttle {
+				if err := attempts.Reset(f.Email); err != nil {
+					render.InternalServerError(w, err.Error())
+					return
+				}
+			}
 			user, _ := users.FindEmail(f.Email)
+			event.Action, event.ActorID = core.AuditLogin, user.ID
+			audit.Record(event)
 			token, err := auth.JWT.CreateJWT(user.Claims())
 			if err != nil {
 				render.InternalServerError(w, err.Error())
@@ -41,6 +80,16 @@ func HandleLogin(users core.UserStore) http.HandlerFunc {
 			return
 		}
 
+		event.Action, event.Details = core.AuditLoginFailed, "invalid credentials"
+		audit.Record(event)
+
+		if throttle {
+			if _, err := attempts.Fail(f.Email, lockout.Attempts, lockout.Window, lockout.Duration); err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+		}
+
 		render.UnathorizedError(w, "invalid credentials")
 	}
 }
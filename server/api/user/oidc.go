@@ -0,0 +1,223 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// oidcCookie holds state and nonce of OIDC login in progress.
+const oidcCookie = "abstruse_oidc"
+
+// roleRank orders roles OIDC groups are mapped to by privileges.
+var roleRank = map[string]int{
+	core.RoleViewer:     1,
+	core.RoleMaintainer: 2,
+	core.RoleAdmin:      3,
+}
+
+// HandleOIDC returns an http.HandlerFunc that writes JSON encoded
+// single sign-on status to the http response body.
+//
+// @Summary Get single sign-on status
+// @Tags auth
+// @Success 200 resp
+// @Security none
+// @Router /auth/oidc [get]
+func HandleOIDC(provider core.OIDCProvider) http.HandlerFunc {
+	type resp struct {
+		Enabled bool   `json:"enabled"`
+		Name    string `json:"name,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			render.JSON(w, http.StatusOK, resp{})
+			return
+		}
+		render.JSON(w, http.StatusOK, resp{Enabled: true, Name: provider.Name()})
+	}
+}
+
+// HandleOIDCLogin returns an http.HandlerFunc that redirects to login
+// page of OpenID Connect provider.
+//
+// @Summary Login with single sign-on
+// @Tags auth
+// @Success 302 render.Empty "redirect to identity provider"
+// @Security none
+// @Router /auth/oidc/login [get]
+func HandleOIDCLogin(provider core.OIDCProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, nonce := randomToken(), randomToken()
+		u, err := provider.AuthURL(r.Context(), state, nonce)
+		if err != nil {
+			render.ServiceUnavailableError(w, err.Error())
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcCookie,
+			Value:    state + "." + nonce,
+			Path:     "/",
+			MaxAge:   600,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, u, http.StatusFound)
+	}
+}
+
+// HandleOIDCCallback returns an http.HandlerFunc that completes login
+// with OpenID Connect provider and redirects to user interface with
+// access token in URL fragment. Users are created on first login when
+// provisioning is enabled and their role is updated from groups.
+//
+// @Summary Single sign-on callback
+// @Description Redirects to /login#token=<token> on success and to
+// @Description /login#error=<message> on failure.
+// @Tags auth
+// @Param code query string "authorization code"
+// @Param state query string "state of the login"
+// @Success 302 render.Empty "redirect to user interface"
+// @Security none
+// @Router /auth/oidc/callback [get]
+func HandleOIDCCallback(provider core.OIDCProvider, users core.UserStore, audit core.AuditService, config *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loginURL := strings.TrimSuffix(config.HTTP.BaseURL, "/") + "/login"
+		event := core.AuditEvent{SourceIP: middlewares.SourceIP(r), Details: "oidc"}
+		fail := func(msg string) {
+			if event.Actor != "" {
+				event.Action, event.Details = core.AuditLoginFailed, "oidc: "+msg
+				audit.Record(event)
+			}
+			http.Redirect(w, r, loginURL+"#error="+url.QueryEscape(msg), http.StatusFound)
+		}
+
+		cookie, err := r.Cookie(oidcCookie)
+		http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/", MaxAge: -1})
+		if err != nil {
+			fail("login expired, try again")
+			return
+		}
+		parts := strings.SplitN(cookie.Value, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[0] != r.URL.Query().Get("state") {
+			fail("invalid login state, try again")
+			return
+		}
+		if e := r.URL.Query().Get("error"); e != "" {
+			fail(fmt.Sprintf("identity provider error: %s", e))
+			return
+		}
+
+		identity, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), parts[1])
+		if err != nil {
+			fail(err.Error())
+			return
+		}
+		event.Actor, event.Target = identity.Email, "user:"+identity.Email
+
+		oidc := config.Auth.OIDC
+		role := mapRole(oidc, identity.Groups)
+		if role == "" {
+			fail("user is not in any group allowed to login")
+			return
+		}
+
+		user, err := users.FindEmail(identity.Email)
+		if err != nil {
+			if !oidc.Provision {
+				fail("user does not exist")
+				return
+			}
+			name := identity.Name
+			if name == "" {
+				name = identity.Email
+			}
+			user = &core.User{
+				Email:    identity.Email,
+				Name:     name,
+				Avatar:   "/assets/images/avatars/avatar_1.svg",
+				Password: randomToken(), // hashed by the store, login only with provider
+				Role:     role,
+				Active:   true,
+			}
+			if err := users.Create(user); err != nil {
+				fail(err.Error())
+				return
+			}
+			audit.Record(core.AuditEvent{
+				Action:   core.AuditUserCreate,
+				Actor:    identity.Email,
+				ActorID:  user.ID,
+				Target:   fmt.Sprintf("user:%d", user.ID),
+				SourceIP: event.SourceIP,
+				Details:  fmt.Sprintf("oidc provisioning, email: %s, role: %s", user.Email, user.Role),
+			})
+		} else if !user.Active {
+			fail("user is not active")
+			return
+		} else if user.Role != role {
+			previous := user.Role
+			user.Role = role
+			if err := users.Update(user); err != nil {
+				fail(err.Error())
+				return
+			}
+			audit.Record(core.AuditEvent{
+				Action:   core.AuditUserUpdate,
+				Actor:    identity.Email,
+				ActorID:  user.ID,
+				Target:   fmt.Sprintf("user:%d", user.ID),
+				SourceIP: event.SourceIP,
+				Details:  fmt.Sprintf("oidc groups, role: %s -> %s", previous, role),
+			})
+		}
+
+		token, err := auth.JWT.CreateJWT(user.Claims())
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+		event.Action, event.ActorID = core.AuditLogin, user.ID
+		audit.Record(event)
+		http.Redirect(w, r, loginURL+"#token="+url.QueryEscape(token), http.StatusFound)
+	}
+}
+
+// mapRole returns highest role groups are mapped to, default role when
+// none of the groups is mapped. Group names are matched case
+// insensitively as config keys are.
+func mapRole(oidc *config.OIDC, groups []string) string {
+	role := ""
+	for _, group := range groups {
+		r, ok := oidc.Roles[strings.ToLower(group)]
+		if !ok {
+			r, ok = oidc.Roles[group]
+		}
+		if ok && roleRank[r] > roleRank[role] {
+			role = r
+		}
+	}
+	if role == "" {
+		role = oidc.DefaultRole
+	}
+	return role
+}
+
+// randomToken returns random hex encoded token.
+func randomToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -12,7 +13,13 @@ import (
 
 // HandlePassword returns http.HandlerFunc that writes JSON encoded
 // result about updated user password to the http response body.
-func HandlePassword(users core.UserStore) http.HandlerFunc {
+//
+// @Summary Change password of authenticated user
+// @Tags users
+// @Body form
+// @Success 200 render.Empty
+// @Router /users/password [put]
+func HandlePassword(users core.UserStore, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		CurrentPassword string `json:"currentPassword" valid:"stringlength(8|50),required"`
 		NewPassword     string `json:"newPassword" valid:"stringlength(8|50),required"`
@@ -29,7 +36,7 @@ func HandlePassword(users core.UserStore) http.HandlerFunc {
 		}
 
 		if _, err := govalidator.ValidateStruct(r); err != nil {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
@@ -38,6 +45,8 @@ func HandlePassword(users core.UserStore) http.HandlerFunc {
 			return
 		}
 
+		audit.Record(middlewares.AuditEvent(r, core.AuditUserPassword, fmt.Sprintf("user:%d", claims.ID)))
+
 		render.JSON(w, http.StatusOK, render.Empty{})
 	}
 }
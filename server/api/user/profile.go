@@ -10,13 +10,18 @@ import (
 
 // HandleProfile returns an http.HandlerFunc that writes JSON encoded
 // user data to the http response body.
+//
+// @Summary Get profile of authenticated user
+// @Tags users
+// @Success 200 core.User
+// @Router /users/profile [get]
 func HandleProfile(users core.UserStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := middlewares.ClaimsFromCtx(r.Context())
 
 		user, err := users.Find(claims.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "user", err.Error())
 			return
 		}
 
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/asaskevich/govalidator"
@@ -13,14 +14,20 @@ import (
 
 // HandleUpdate returns http.HandlerFunc that writes JSON encoded
 // result about updated user to the http response body.
-func HandleUpdate(users core.UserStore) http.HandlerFunc {
+//
+// @Summary Update user
+// @Tags users
+// @Body form
+// @Success 200 resp
+// @Router /users [put]
+func HandleUpdate(users core.UserStore, audit core.AuditService) http.HandlerFunc {
 	type form struct {
 		ID       uint   `json:"id" valid:"required"`
 		Email    string `json:"email" valid:"email,required"`
 		Password string `json:"password"`
 		Name     string `json:"name" valid:"stringlength(3|50),required"`
 		Avatar   string `json:"avatar" valid:"stringlength(5|255),required"`
-		Role     string `json:"role" valid:"required"`
+		Role     string `json:"role" valid:"in(admin|maintainer|viewer|user),required"`
 	}
 
 	type resp struct {
@@ -45,16 +52,24 @@ func HandleUpdate(users core.UserStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
 		user, err := users.Find(f.ID)
 		if err != nil {
-			render.NotFoundError(w, err.Error())
+			render.ResourceNotFoundError(w, "user", err.Error())
 			return
 		}
 
+		roleChanged := user.Role != f.Role
+		details := fmt.Sprintf("email: %s", f.Email)
+		if roleChanged {
+			details = fmt.Sprintf("%s, role: %s -> %s", details, user.Role, f.Role)
+		}
+		if f.Password != "" {
+			details += ", password changed"
+		}
 		user.Email = f.Email
 		user.Name = f.Name
 		user.Avatar = f.Avatar
@@ -75,6 +90,19 @@ func HandleUpdate(users core.UserStore) http.HandlerFunc {
 			return
 		}
 
+		target := fmt.Sprintf("user:%d", user.ID)
+		event := middlewares.AuditEvent(r, core.AuditUserUpdate, target)
+		event.Details = details
+		audit.Record(event)
+
+		if roleChanged {
+			if err := users.RevokeTokens(user.ID); err != nil {
+				render.InternalServerError(w, err.Error())
+				return
+			}
+			audit.Record(middlewares.AuditEvent(r, core.AuditTokensRevoke, target))
+		}
+
 		token, err := auth.JWT.CreateJWT(user.Claims())
 		if err != nil {
 			render.InternalServerError(w, err.Error())
@@ -13,6 +13,12 @@ import (
 
 // HandleUpdateProfile returns http.HandlerFunc that writes JSON encoded
 // result about updated user profile to the http response body.
+//
+// @Summary Update profile of authenticated user
+// @Tags users
+// @Body form
+// @Success 200 resp
+// @Router /users/profile [put]
 func HandleUpdateProfile(users core.UserStore) http.HandlerFunc {
 	type form struct {
 		Email  string `json:"email" valid:"email,required"`
@@ -36,7 +42,7 @@ func HandleUpdateProfile(users core.UserStore) http.HandlerFunc {
 		}
 
 		if valid, err := govalidator.ValidateStruct(f); err != nil || !valid {
-			render.BadRequestError(w, err.Error())
+			render.ValidationError(w, err)
 			return
 		}
 
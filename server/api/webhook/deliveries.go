@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+	"github.com/go-chi/chi"
+)
+
+// maxLimit is the maximum number of deliveries returned per page.
+const maxLimit = 100
+
+// HandleListDeliveries returns an http.HandlerFunc that writes JSON
+// encoded list of recent webhook deliveries to the http response body,
+// newest first. Headers and payload are returned only by single delivery.
+//
+// @Summary List webhook deliveries
+// @Tags webhooks
+// @Param repo query int "repository ID, 0 for deliveries which matched no repository"
+// @Param result query string "e.g. skipped"
+// @Param limit query int "number of deliveries returned, maximum 100"
+// @Param cursor query int "cursor returned as next_cursor"
+// @Success 200 resp
+// @Router /webhooks [get]
+func HandleListDeliveries(deliveries core.HookDeliveryStore) http.HandlerFunc {
+	type resp struct {
+		Data       []*core.HookDelivery `json:"data"`
+		NextCursor uint                 `json:"next_cursor,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > maxLimit {
+			limit = 20
+		}
+		cursor, err := strconv.ParseUint(query.Get("cursor"), 10, 32)
+		if err != nil {
+			cursor = 0
+		}
+		repoID, err := strconv.ParseUint(query.Get("repo"), 10, 32)
+		if err != nil {
+			repoID = 0
+		}
+
+		list, err := deliveries.List(core.HookDeliveryFilter{
+			RepositoryID: uint(repoID),
+			Result:       query.Get("result"),
+			Cursor:       uint(cursor),
+			Limit:        limit + 1,
+		})
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		var next uint
+		if len(list) > limit {
+			list = list[:limit]
+			next = list[limit-1].ID
+		}
+		render.JSON(w, http.StatusOK, resp{Data: list, NextCursor: next})
+	}
+}
+
+// HandleFindDelivery returns an http.HandlerFunc that writes JSON
+// encoded webhook delivery with headers and payload to the http
+// response body.
+//
+// @Summary Find webhook delivery
+// @Tags webhooks
+// @Success 200 core.HookDelivery
+// @Router /webhooks/{id} [get]
+func HandleFindDelivery(deliveries core.HookDeliveryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		delivery, err := deliveries.Find(uint(id))
+		if err != nil {
+			render.ResourceNotFoundError(w, "delivery", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, delivery)
+	}
+}
+
+// HandleReplayDelivery returns an http.HandlerFunc that processes
+// stored webhook delivery again and writes JSON encoded new delivery to
+// the http response body. Stored deliveries hold no signature, so only
+// deliveries which were verified when received can be replayed.
+//
+// @Summary Replay webhook delivery
+// @Tags webhooks
+// @Success 200 core.HookDelivery
+// @Router /webhooks/{id}/replay [post]
+func HandleReplayDelivery(repos core.RepositoryStore, builds core.BuildStore, skipped core.SkippedBuildStore, deliveries core.HookDeliveryStore, scheduler core.Scheduler, ws *ws.Server, config *config.Config, audit core.AuditService) http.HandlerFunc {
+	h := hookProcessor{repos, builds, skipped, scheduler, ws, config}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			render.BadRequestError(w, err.Error())
+			return
+		}
+
+		prev, err := deliveries.Find(uint(id))
+		if err != nil {
+			render.ResourceNotFoundError(w, "delivery", err.Error())
+			return
+		}
+		if !prev.Verified {
+			render.BadRequestError(w, "delivery was not verified and cannot be replayed")
+			return
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, "/webhooks", bytes.NewReader([]byte(prev.Payload)))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+		req.Header = prev.Header.Clone()
+
+		delivery := &core.HookDelivery{Event: prev.Event, Payload: prev.Payload, ReplayOf: prev.ID}
+		if err := delivery.SetHeader(prev.Header); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		err = h.process(req, delivery, true)
+		if err := deliveries.Create(delivery); err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+		audit.Record(middlewares.AuditEvent(r, core.AuditHookReplay, fmt.Sprintf("delivery:%d", prev.ID)))
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, delivery)
+	}
+}
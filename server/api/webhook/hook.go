@@ -3,103 +3,317 @@ package webhook
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/bleenco/abstruse/internal/requestid"
 	"github.com/bleenco/abstruse/pkg/gitscm"
 	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 	"github.com/bleenco/abstruse/server/service/githook"
 	"github.com/bleenco/abstruse/server/ws"
 )
 
-// HandleHook returns an http.HandlerFunc that writes JSON encoded
-// result to the http response body.
-func HandleHook(repos core.RepositoryStore, builds core.BuildStore, scheduler core.Scheduler, ws *ws.Server) http.HandlerFunc {
+// HandleHook returns an http.HandlerFunc that verifies webhook delivery
+// and queues it to be processed, writes JSON encoded result to the http
+// response body. Deliveries are recorded with the result of processing
+// them.
+//
+// @Summary Receive webhook delivery
+// @Description Payload of SCM provider webhook, verified with repository
+// @Description webhook secret. Returns 202 when delivery is queued.
+// @Tags webhooks
+// @Success 200 render.Empty
+// @Success 202 render.Empty "delivery queued"
+// @Security none
+// @Server /
+// @Router /webhooks [post]
+func HandleHook(repos core.RepositoryStore, builds core.BuildStore, skipped core.SkippedBuildStore, deliveries core.HookDeliveryStore, scheduler core.Scheduler, ws *ws.Server, config *config.Config) http.HandlerFunc {
+	h := hookProcessor{repos, builds, skipped, scheduler, ws, config}
+	queue := newHookQueue(h, deliveries, config.Webhooks)
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		repositories, _, err := repos.List(core.RepositoryFilter{})
+		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			render.InternalServerError(w, "no repositories found")
+			render.BadRequestError(w, err.Error())
 			return
 		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
 
-		fn := func(fullname string) *core.Repository {
-			for _, repo := range repositories {
-				if repo.FullName == fullname {
-					return &repo
-				}
-			}
-			return nil
+		delivery := &core.HookDelivery{Event: hookEvent(r.Header), Payload: string(body)}
+		if err := delivery.SetHeader(deliveryHeader(r.Header)); err != nil {
+			log.Printf("error encoding webhook delivery headers: %v\n", err)
 		}
 
-		for _, repo := range repositories {
-			gitscm, err := gitscm.New(
-				context.Background(),
-				repo.Provider.Name,
-				repo.Provider.URL,
-				repo.Provider.AccessToken,
-			)
-			if err != nil {
-				continue
+		ev, err := h.verify(r, delivery, false)
+		if ev == nil {
+			if err := deliveries.Create(delivery); err != nil {
+				log.Printf("error saving webhook delivery: %v\n", err)
 			}
-
-			parser := githook.NewParser(gitscm.Client())
-			hook, hrepo, err := parser.Parse(cloneRequest(r), fn)
 			if err != nil {
-				continue
-			}
-
-			if hook == nil {
-				render.JSON(w, http.StatusOK, render.Empty{})
+				render.InternalServerError(w, err.Error())
 				return
 			}
+			render.JSON(w, http.StatusOK, render.Empty{})
+			return
+		}
 
-			repo, err := repos.FindUID(hrepo.UID)
+		if queue == nil {
+			err := h.handle(ev)
+			if err := deliveries.Create(delivery); err != nil {
+				log.Printf("error saving webhook delivery: %v\n", err)
+			}
 			if err != nil {
-				continue
+				render.InternalServerError(w, err.Error())
+				return
 			}
+			render.JSON(w, http.StatusOK, render.Empty{})
+			return
+		}
 
-			if !repo.Active {
-				log.Println("webhook ignored, repository not active")
-				break
-			}
+		if !queue.push(ev) {
+			render.ServiceUnavailableError(w, "webhook queue full, retry later")
+			return
+		}
+		render.JSON(w, http.StatusAccepted, render.Empty{})
+	}
+}
 
-			if hook.Event == core.EventPush && hook.Action == core.ActionDelete {
-				log.Printf("branch %s deleted\n", hook.Target)
-				break
-			}
+// hookProcessor triggers builds from webhook deliveries.
+type hookProcessor struct {
+	repos     core.RepositoryStore
+	builds    core.BuildStore
+	skipped   core.SkippedBuildStore
+	scheduler core.Scheduler
+	ws        *ws.Server
+	config    *config.Config
+}
 
-			if hook.Event == core.EventPullRequest && hook.Action == core.ActionClose {
-				log.Printf("ref %s pull request closed\n", hook.Ref)
-				break
-			}
+// verifiedHook is verified webhook delivery of repository waiting to
+// be processed.
+type verifiedHook struct {
+	delivery  *core.HookDelivery
+	hook      *core.GitHook
+	repo      core.Repository
+	scm       gitscm.SCM
+	requestID string
+}
 
-			// all good, trigger build.
-			jobs, id, err := builds.GenerateBuild(&repo, hook)
-			if err != nil {
-				render.InternalServerError(w, err.Error())
-				return
-			}
+// process verifies webhook delivery and triggers build, result is set
+// on delivery. Signature is not verified when delivery is trusted.
+// Error is returned only when build could not be triggered.
+func (h hookProcessor) process(r *http.Request, delivery *core.HookDelivery, trusted bool) error {
+	ev, err := h.verify(r, delivery, trusted)
+	if ev == nil {
+		return err
+	}
+	return h.handle(ev)
+}
 
-			for _, job := range jobs {
-				if err := scheduler.Next(job); err != nil {
-					render.InternalServerError(w, err.Error())
-					return
-				}
-			}
+// verify parses webhook delivery and finds repository it was sent for,
+// nil is returned with result set on delivery when delivery does not
+// match any repository or does not trigger builds. Signature is not
+// verified when delivery is trusted. Error is returned only when
+// repositories cannot be listed.
+func (h hookProcessor) verify(r *http.Request, delivery *core.HookDelivery, trusted bool) (*verifiedHook, error) {
+	repositories, _, err := h.repos.List(core.RepositoryFilter{})
+	if err != nil {
+		delivery.Result, delivery.Reason = core.DeliveryError, "no repositories found"
+		return nil, fmt.Errorf("no repositories found")
+	}
 
-			// broadcast new build
-			if build, err := builds.Find(id); err == nil {
-				ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
+	fn := func(fullname string) *core.Repository {
+		for _, repo := range repositories {
+			if repo.FullName == fullname {
+				return &repo
 			}
+		}
+		return nil
+	}
 
-			render.JSON(w, http.StatusOK, render.Empty{})
-			return
+	delivery.Result, delivery.Reason = core.DeliveryRejected, "no repository matched"
+	for _, repo := range repositories {
+		gitscm, err := gitscm.New(
+			context.Background(),
+			repo.Provider.Name,
+			repo.Provider.URL,
+			repo.Provider.AccessToken,
+		)
+		if err != nil {
+			continue
+		}
+
+		parser := githook.NewParser(gitscm.Client())
+		if trusted {
+			parser = githook.NewReplayParser(gitscm.Client())
+		}
+		hook, hrepo, err := parser.Parse(cloneRequest(r), fn)
+		if err != nil {
+			delivery.Reason = err.Error()
+			continue
+		}
+
+		if hook == nil {
+			delivery.Result, delivery.Reason = core.DeliveryIgnored, "event not supported"
+			return nil, nil
+		}
+
+		repo, err := h.repos.FindUID(hrepo.UID)
+		if err != nil {
+			continue
+		}
+		delivery.RepositoryID = repo.ID
+		delivery.Verified = true
+		delivery.Result, delivery.Reason = core.DeliveryQueued, ""
+
+		return &verifiedHook{
+			delivery:  delivery,
+			hook:      hook,
+			repo:      repo,
+			scm:       gitscm,
+			requestID: requestid.FromContext(r.Context()),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// handle triggers build of verified webhook delivery, result is set on
+// delivery. Error is returned only when build could not be triggered.
+func (h hookProcessor) handle(ev *verifiedHook) error {
+	delivery, hook, repo := ev.delivery, ev.hook, ev.repo
+	delivery.Result = core.DeliveryIgnored
+
+	if !repo.Active {
+		log.Println("webhook ignored, repository not active")
+		delivery.Reason = "repository not active"
+		return nil
+	}
+
+	if hook.Event == core.EventPush && hook.Action == core.ActionDelete {
+		log.Printf("branch %s deleted\n", hook.Target)
+		delivery.Reason = "branch deleted"
+		return nil
+	}
+
+	if hook.Event == core.EventPullRequest && hook.Action == core.ActionClose {
+		log.Printf("ref %s pull request closed\n", hook.Ref)
+		delivery.Reason = "pull request closed"
+		return nil
+	}
+
+	if hook.Event == core.EventPullRequest && repo.Provider.Name == "gitlab" && h.config.GitLab != nil {
+		if hook.Draft && h.config.GitLab.SkipDrafts {
+			log.Printf("ref %s merge request is draft, skipping build\n", hook.Ref)
+			delivery.Result, delivery.Reason = core.DeliverySkipped, "merge request is draft"
+			return nil
+		}
+		if h.config.GitLab.MergeRef {
+			hook.Ref = strings.TrimSuffix(hook.Ref, "/head") + "/merge"
+		}
+	}
+
+	reason := repo.HookFilter.Skip(hook, changedFiles(ev.scm, &repo, hook))
+	if err := h.scheduler.Accept(true); reason == "" && err != nil {
+		reason = err.Error()
+	}
+	if reason != "" {
+		log.Printf("ref %s build skipped, %s\n", hook.Ref, reason)
+		delivery.Result, delivery.Reason = core.DeliverySkipped, reason
+		err := h.skipped.Create(&core.SkippedBuild{
+			RepositoryID: repo.ID,
+			Event:        hook.Event,
+			Ref:          hook.Ref,
+			Commit:       hook.After,
+			Message:      hook.Message,
+			Author:       hook.AuthorName,
+			Reason:       reason,
+		})
+		if err != nil {
+			log.Printf("error saving skipped build of ref %s: %v\n", hook.Ref, err)
+		}
+		return nil
+	}
+
+	// all good, trigger build.
+	jobs, id, err := h.builds.GenerateBuild(&repo, hook)
+	if err != nil {
+		delivery.Result, delivery.Reason = core.DeliveryError, err.Error()
+		return err
+	}
+	delivery.Result, delivery.Reason, delivery.BuildID = core.DeliveryBuild, "", id
+
+	for _, job := range jobs {
+		job.RequestID = ev.requestID
+		if err := h.scheduler.Next(job); err != nil {
+			delivery.Reason = err.Error()
+			return err
+		}
+	}
+
+	// broadcast new build
+	if build, err := h.builds.Find(id); err == nil {
+		h.ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
+
+		if repo.AutoCancel.Applies(build.Ref) {
+			go cancelSuperseded(h.builds, h.scheduler, build)
+		}
+	}
+
+	return nil
+}
+
+// cancelSuperseded cancels queued and running builds of the same ref
+// which are superseded by the build.
+func cancelSuperseded(builds core.BuildStore, scheduler core.Scheduler, build *core.Build) {
+	prev, err := builds.FindUnfinished(build)
+	if err != nil {
+		log.Printf("error finding builds superseded by build %d: %v\n", build.ID, err)
+		return
+	}
+	for _, b := range prev {
+		if _, err := scheduler.CancelBuild(b.ID); err != nil {
+			log.Printf("error cancelling build %d superseded by build %d: %v\n", b.ID, build.ID, err)
+			continue
 		}
+		log.Printf("build %d cancelled, superseded by build %d\n", b.ID, build.ID)
+	}
+}
+
+// changedFiles returns paths of files changed by push or pull request
+// when repository filters builds by changed files, nil otherwise or when
+// changes could not be listed, so path filters are not applied.
+func changedFiles(scm gitscm.SCM, repo *core.Repository, hook *core.GitHook) []string {
+	if !repo.HookFilter.PathFilter() {
+		return nil
+	}
 
-		render.JSON(w, http.StatusOK, render.Empty{})
+	var files []string
+	var err error
+	switch hook.Event {
+	case core.EventPush:
+		before := hook.Before
+		if strings.Trim(before, "0") == "" {
+			before = ""
+		}
+		files, err = scm.ListChanges(repo.FullName, before, hook.After)
+	case core.EventPullRequest:
+		files, err = scm.ListPullRequestChanges(repo.FullName, hook.PrNumber)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.Printf("error listing changed files of ref %s, path filter not applied: %v\n", hook.Ref, err)
+		return nil
+	}
+	if files == nil {
+		files = []string{}
 	}
+	return files
 }
 
 func cloneRequest(r *http.Request) *http.Request {
@@ -110,3 +324,38 @@ func cloneRequest(r *http.Request) *http.Request {
 	r2.Body = ioutil.NopCloser(bytes.NewReader(b.Bytes()))
 	return r2
 }
+
+// eventHeaders are headers providers send event type of delivery in.
+var eventHeaders = []string{"X-GitHub-Event", "X-Gitlab-Event", "X-Gitea-Event", "X-Gogs-Event", "X-Event-Key"}
+
+// secretHeaders are substrings of header names holding secrets or
+// signatures, which are not stored with deliveries.
+var secretHeaders = []string{"signature", "token", "authorization", "secret", "cookie"}
+
+// hookEvent returns event type of delivery.
+func hookEvent(header http.Header) string {
+	for _, name := range eventHeaders {
+		if event := header.Get(name); event != "" {
+			return event
+		}
+	}
+	return ""
+}
+
+// deliveryHeader returns copy of delivery headers without secrets.
+func deliveryHeader(header http.Header) http.Header {
+	h := make(http.Header, len(header))
+	for name, values := range header {
+		secret := false
+		for _, s := range secretHeaders {
+			if strings.Contains(strings.ToLower(name), s) {
+				secret = true
+				break
+			}
+		}
+		if !secret {
+			h[name] = values
+		}
+	}
+	return h
+}
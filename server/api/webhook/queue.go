@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// hookQueue processes verified webhook deliveries with bounded pool of
+// workers, so webhook requests return before builds are created.
+type hookQueue struct {
+	processor  hookProcessor
+	deliveries core.HookDeliveryStore
+	ch         chan *verifiedHook
+}
+
+// newHookQueue starts workers processing queued deliveries, nil is
+// returned when deliveries are processed synchronously.
+func newHookQueue(h hookProcessor, deliveries core.HookDeliveryStore, cfg *config.Webhooks) *hookQueue {
+	if cfg == nil || cfg.Workers <= 0 {
+		return nil
+	}
+	q := &hookQueue{
+		processor:  h,
+		deliveries: deliveries,
+		ch:         make(chan *verifiedHook, cfg.Queue),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		go q.run()
+	}
+	return q
+}
+
+// push records delivery as queued and queues it, false is returned
+// with error recorded on delivery when queue is full.
+func (q *hookQueue) push(ev *verifiedHook) bool {
+	if err := q.deliveries.Create(ev.delivery); err != nil {
+		log.Printf("error saving webhook delivery: %v\n", err)
+	}
+
+	select {
+	case q.ch <- ev:
+		return true
+	default:
+		ev.delivery.Result, ev.delivery.Reason = core.DeliveryError, "webhook queue full"
+		q.update(ev.delivery)
+		return false
+	}
+}
+
+func (q *hookQueue) run() {
+	for ev := range q.ch {
+		q.process(ev)
+	}
+}
+
+// process handles queued delivery and records the result, panics are
+// recorded as errors so worker keeps running.
+func (q *hookQueue) process(ev *verifiedHook) {
+	defer func() {
+		if r := recover(); r != nil {
+			ev.delivery.Result, ev.delivery.Reason = core.DeliveryError, fmt.Sprintf("panic: %v", r)
+			log.Printf("panic processing webhook delivery %d: %v\n", ev.delivery.ID, r)
+			q.update(ev.delivery)
+		}
+	}()
+
+	if err := q.processor.handle(ev); err != nil {
+		log.Printf("error processing webhook delivery %d: %v\n", ev.delivery.ID, err)
+	}
+	q.update(ev.delivery)
+}
+
+func (q *hookQueue) update(delivery *core.HookDelivery) {
+	if err := q.deliveries.Update(delivery); err != nil {
+		log.Printf("error saving webhook delivery %d: %v\n", delivery.ID, err)
+	}
+}
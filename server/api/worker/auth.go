@@ -14,6 +14,13 @@ import (
 
 // HandleAuth returns an http.HandlerFunc that writes JSON encoded
 // result of worker node authorization to http response body.
+//
+// @Summary Register worker
+// @Description Authenticated with worker token, server connects back to
+// @Description worker on returned address.
+// @Tags workers
+// @Success 200 resp
+// @Router /workers/auth [post]
 func HandleAuth(workers core.WorkerRegistry, config *config.Config, ws *ws.App) http.HandlerFunc {
 	type resp struct {
 		Auth string `json:"auth"`
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"net/http"
+
+	"github.com/bleenco/abstruse/server/api/middlewares"
+	"github.com/bleenco/abstruse/server/api/render"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/go-chi/chi"
+)
+
+// HandleDrainStatus returns an http.HandlerFunc that writes JSON encoded
+// drain status of the worker with jobs still running on it to the http
+// response body.
+//
+// @Summary Get worker drain status
+// @Tags workers
+// @Param id path string true "Worker ID"
+// @Success 200 core.WorkerDrain
+// @Router /workers/{id}/drain [get]
+func HandleDrainStatus(scheduler core.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		status, err := scheduler.DrainStatus(id)
+		if err != nil {
+			render.ResourceNotFoundError(w, "worker", err.Error())
+			return
+		}
+
+		render.JSON(w, http.StatusOK, status)
+	}
+}
+
+// HandleDrain returns an http.HandlerFunc that writes JSON encoded
+// drain status to the http response body after worker is drained.
+// Scheduler starts no new jobs on draining worker, running jobs finish.
+//
+// @Summary Drain worker
+// @Tags workers
+// @Param id path string true "Worker ID"
+// @Success 200 core.WorkerDrain
+// @Router /workers/{id}/drain [put]
+func HandleDrain(scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
+	return handleDrain(scheduler, audit, true)
+}
+
+// HandleUndrain returns an http.HandlerFunc that writes JSON encoded
+// drain status to the http response body after worker drain is lifted.
+//
+// @Summary Lift worker drain
+// @Tags workers
+// @Param id path string true "Worker ID"
+// @Success 200 core.WorkerDrain
+// @Router /workers/{id}/drain [delete]
+func HandleUndrain(scheduler core.Scheduler, audit core.AuditService) http.HandlerFunc {
+	return handleDrain(scheduler, audit, false)
+}
+
+func handleDrain(scheduler core.Scheduler, audit core.AuditService, drain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		status, err := scheduler.Drain(id, drain)
+		if err == core.ErrWorkerNotFound {
+			render.ResourceNotFoundError(w, "worker", err.Error())
+			return
+		}
+		if err != nil {
+			render.InternalServerError(w, err.Error())
+			return
+		}
+
+		action := core.AuditWorkerDrain
+		if !drain {
+			action = core.AuditWorkerUndrain
+		}
+		audit.Record(middlewares.AuditEvent(r, action, id))
+
+		render.JSON(w, http.StatusOK, status)
+	}
+}
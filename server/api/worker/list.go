@@ -9,12 +9,19 @@ import (
 
 // HandleList returns an http.HandlerFunc that writes JSON encoded
 // list of workers in registry to http response body.
+//
+// @Summary List workers
+// @Tags workers
+// @Success 200 []resp
+// @Security none
+// @Router /workers [get]
 func HandleList(workers core.WorkerRegistry) http.HandlerFunc {
 	type resp struct {
-		ID    string             `json:"id"`
-		Addr  string             `json:"addr"`
-		Host  core.HostInfo      `json:"host"`
-		Usage []core.WorkerUsage `json:"usage"`
+		ID       string             `json:"id"`
+		Addr     string             `json:"addr"`
+		Host     core.HostInfo      `json:"host"`
+		Usage    []core.WorkerUsage `json:"usage"`
+		Draining bool               `json:"draining"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -26,7 +33,9 @@ func HandleList(workers core.WorkerRegistry) http.HandlerFunc {
 
 		var response []resp
 		for _, worker := range workers {
-			response = append(response, resp{worker.ID, worker.Addr, worker.Host, worker.Usage})
+			worker.Lock()
+			response = append(response, resp{worker.ID, worker.Addr, worker.Host, worker.Usage, worker.Draining})
+			worker.Unlock()
 		}
 
 		render.JSON(w, http.StatusOK, response)
@@ -0,0 +1,278 @@
+// Package backup exports users, providers, repositories with their
+// settings and secrets, and teams into single portable file and
+// restores them into another or the same database. Records reference
+// each other by natural keys, like user email and repository full
+// name, so backups can be imported into databases with different ids.
+package backup
+
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// Version of the backup file format.
+const Version = 1
+
+type (
+	// Backup is content of backup file.
+	Backup struct {
+		Version    int            `json:"version"`
+		CreatedAt  time.Time      `json:"createdAt"`
+		Config     *config.Config `json:"config,omitempty"`     // redacted, not restored
+		Encryption *Encryption    `json:"encryption,omitempty"` // set when secrets are included
+		Users      []User         `json:"users"`
+		Providers  []Provider     `json:"providers"`
+		Repos      []Repo         `json:"repos"`
+		Teams      []Team         `json:"teams"`
+	}
+
+	// User is exported user, password hash is included only on request.
+	User struct {
+		Email    string `json:"email"`
+		Name     string `json:"name"`
+		Avatar   string `json:"avatar"`
+		Role     string `json:"role"`
+		Active   bool   `json:"active"`
+		Password string `json:"password,omitempty"`
+	}
+
+	// Provider is exported SCM provider identified by URL and user.
+	Provider struct {
+		Name        string `json:"name"`
+		URL         string `json:"url"`
+		Host        string `json:"host"`
+		User        string `json:"user"`
+		AccessToken string `json:"accessToken,omitempty"` // encrypted
+		Secret      string `json:"secret,omitempty"`      // encrypted
+	}
+
+	// Repo is exported repository identified by provider and full name.
+	Repo struct {
+		ProviderURL   string             `json:"providerURL"`
+		ProviderUser  string             `json:"providerUser"`
+		FullName      string             `json:"fullName"`
+		UID           string             `json:"uid"`
+		ProviderName  string             `json:"providerName"`
+		Namespace     string             `json:"namespace"`
+		Name          string             `json:"name"`
+		Private       bool               `json:"private"`
+		Fork          bool               `json:"fork"`
+		URL           string             `json:"url"`
+		Clone         string             `json:"clone"`
+		CloneSSH      string             `json:"cloneSSH"`
+		DefaultBranch string             `json:"defaultBranch"`
+		Active        bool               `json:"active"`
+		Timeout       uint               `json:"timeout"`
+		Token         string             `json:"token"`
+		User          string             `json:"user"`
+		Notify        core.Notifications `json:"notify"`
+		SlackURL      string             `json:"slackURL,omitempty"`      // encrypted
+		DiscordURL    string             `json:"discordURL,omitempty"`    // encrypted
+		RegistryAuth  string             `json:"registryAuth,omitempty"`  // encrypted
+		CloneAuth     string             `json:"cloneAuth,omitempty"`     // encrypted
+		HookSecret    string             `json:"hookSecret,omitempty"`    // encrypted
+		HookSecretAlt string             `json:"hookSecretAlt,omitempty"` // encrypted
+		MaxBuilds     int                `json:"maxBuilds"`
+		AutoCancel    core.AutoCancel    `json:"autoCancel"`
+		PublicBadge   bool               `json:"publicBadge"`
+		Retention     core.Retention     `json:"retention"`
+		Env           []Env              `json:"env"`
+		Crons         []Cron             `json:"crons"`
+	}
+
+	// Env is exported environment variable, values of secret variables
+	// are encrypted.
+	Env struct {
+		Key    string `json:"key"`
+		Value  string `json:"value,omitempty"`
+		Secret bool   `json:"secret"`
+	}
+
+	// Cron is exported cron schedule of the repository.
+	Cron struct {
+		Spec     string `json:"spec"`
+		Branch   string `json:"branch"`
+		Timezone string `json:"timezone"`
+	}
+
+	// Team is exported team with emails of its users and permissions
+	// by repository.
+	Team struct {
+		Name        string       `json:"name"`
+		About       string       `json:"about"`
+		Color       string       `json:"color"`
+		Users       []string     `json:"users"`
+		Permissions []Permission `json:"permissions"`
+	}
+
+	// Permission is exported team repository permission.
+	Permission struct {
+		ProviderURL  string `json:"providerURL"`
+		ProviderUser string `json:"providerUser"`
+		Repo         string `json:"repo"`
+		Read         bool   `json:"read"`
+		Write        bool   `json:"write"`
+		Exec         bool   `json:"exec"`
+	}
+
+	// ExportOptions defines what is included in backup. Secrets are
+	// exported encrypted with passphrase and omitted without it.
+	ExportOptions struct {
+		Passwords  bool
+		Passphrase string
+	}
+)
+
+// Export reads users, providers, repositories and teams from database.
+func Export(db *gorm.DB, opts ExportOptions) (*Backup, error) {
+	b := &Backup{Version: Version, CreatedAt: time.Now().UTC()}
+
+	var users []*core.User
+	if err := db.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	emails := make(map[uint]string)
+	for _, u := range users {
+		emails[u.ID] = u.Email
+		user := exportUser(u)
+		if !opts.Passwords {
+			user.Password = ""
+		}
+		b.Users = append(b.Users, user)
+	}
+
+	var providers []*core.Provider
+	if err := db.Order("id").Find(&providers).Error; err != nil {
+		return nil, err
+	}
+	owners := make(map[uint]*core.Provider)
+	for _, p := range providers {
+		owners[p.ID] = p
+		b.Providers = append(b.Providers, exportProvider(p, emails))
+	}
+
+	var repos []*core.Repository
+	if err := db.Order("id").Preload("EnvVariables").Find(&repos).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range repos {
+		p, ok := owners[r.ProviderID]
+		if !ok {
+			continue
+		}
+		var crons []*core.Cron
+		if err := db.Where("repository_id = ?", r.ID).Order("id").Find(&crons).Error; err != nil {
+			return nil, err
+		}
+		b.Repos = append(b.Repos, exportRepo(r, p, crons, emails))
+	}
+
+	var teams []*core.Team
+	if err := db.Order("id").Preload("Users").Preload("Permissions.Repository").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		b.Teams = append(b.Teams, exportTeam(t, owners, emails))
+	}
+
+	if opts.Passphrase == "" {
+		b.stripSecrets()
+		return b, nil
+	}
+	if err := b.seal(opts.Passphrase); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func exportUser(u *core.User) User {
+	return User{
+		Email:    u.Email,
+		Name:     u.Name,
+		Avatar:   u.Avatar,
+		Role:     u.Role,
+		Active:   u.Active,
+		Password: u.Password,
+	}
+}
+
+func exportProvider(p *core.Provider, emails map[uint]string) Provider {
+	return Provider{
+		Name:        p.Name,
+		URL:         p.URL,
+		Host:        p.Host,
+		User:        emails[p.UserID],
+		AccessToken: p.AccessToken,
+		Secret:      p.Secret,
+	}
+}
+
+func exportRepo(r *core.Repository, p *core.Provider, crons []*core.Cron, emails map[uint]string) Repo {
+	notify := r.Notify
+	notify.SlackURL, notify.DiscordURL = "", ""
+	repo := Repo{
+		ProviderURL:   p.URL,
+		ProviderUser:  emails[p.UserID],
+		FullName:      r.FullName,
+		UID:           r.UID,
+		ProviderName:  r.ProviderName,
+		Namespace:     r.Namespace,
+		Name:          r.Name,
+		Private:       r.Private,
+		Fork:          r.Fork,
+		URL:           r.URL,
+		Clone:         r.Clone,
+		CloneSSH:      r.CloneSSH,
+		DefaultBranch: r.DefaultBranch,
+		Active:        r.Active,
+		Timeout:       r.Timeout,
+		Token:         r.Token,
+		User:          emails[r.UserID],
+		Notify:        notify,
+		SlackURL:      r.Notify.SlackURL,
+		DiscordURL:    r.Notify.DiscordURL,
+		RegistryAuth:  r.RegistryAuth,
+		CloneAuth:     r.CloneAuth,
+		HookSecret:    r.HookSecret,
+		HookSecretAlt: r.HookSecretAlt,
+		MaxBuilds:     r.MaxBuilds,
+		AutoCancel:    r.AutoCancel,
+		PublicBadge:   r.PublicBadge,
+		Retention:     r.Retention,
+	}
+	for _, e := range r.EnvVariables {
+		repo.Env = append(repo.Env, Env{Key: e.Key, Value: e.Value, Secret: e.Secret})
+	}
+	for _, c := range crons {
+		repo.Crons = append(repo.Crons, Cron{Spec: c.Spec, Branch: c.Branch, Timezone: c.Timezone})
+	}
+	return repo
+}
+
+func exportTeam(t *core.Team, providers map[uint]*core.Provider, emails map[uint]string) Team {
+	team := Team{Name: t.Name, About: t.About, Color: t.Color}
+	for _, u := range t.Users {
+		team.Users = append(team.Users, u.Email)
+	}
+	for _, perm := range t.Permissions {
+		if perm.Repository == nil {
+			continue
+		}
+		p, ok := providers[perm.Repository.ProviderID]
+		if !ok {
+			continue
+		}
+		team.Permissions = append(team.Permissions, Permission{
+			ProviderURL:  p.URL,
+			ProviderUser: emails[p.UserID],
+			Repo:         perm.Repository.FullName,
+			Read:         perm.Read,
+			Write:        perm.Write,
+			Exec:         perm.Exec,
+		})
+	}
+	return team
+}
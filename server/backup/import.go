@@ -0,0 +1,428 @@
+package backup
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/pkg/cron"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// Import change actions.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionSkip   = "skip"
+)
+
+type (
+	// ImportOptions defines import behaviour. With DryRun changes are
+	// rolled back after import.
+	ImportOptions struct {
+		DryRun     bool
+		Passphrase string
+	}
+
+	// Report lists changes made by import.
+	Report struct {
+		Changes   []Change `json:"changes"`
+		Unchanged int      `json:"unchanged"`
+	}
+
+	// Change is record created, updated or skipped by import.
+	Change struct {
+		Action string `json:"action"`
+		Kind   string `json:"kind"`
+		Name   string `json:"name"`
+		Reason string `json:"reason,omitempty"`
+	}
+)
+
+// Import restores backup into database in single transaction. Records
+// are matched by natural keys and only created or updated, nothing is
+// deleted, so importing the same backup again changes nothing. Users
+// without password in backup get random password and need to reset it,
+// existing passwords and secrets are kept when backup does not contain
+// them.
+func Import(db *gorm.DB, b *Backup, opts ImportOptions) (*Report, error) {
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported backup version %d", b.Version)
+	}
+	secrets := b.Encryption != nil
+	if err := b.open(opts.Passphrase); err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	im := &importer{
+		tx:        tx.Set("gorm:save_associations", false),
+		secrets:   secrets,
+		report:    &Report{},
+		users:     make(map[string]uint),
+		providers: make(map[[2]string]*core.Provider),
+		repos:     make(map[[3]string]uint),
+	}
+	if err := im.run(b); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if opts.DryRun {
+		return im.report, tx.Rollback().Error
+	}
+	return im.report, tx.Commit().Error
+}
+
+type importer struct {
+	tx        *gorm.DB
+	secrets   bool
+	report    *Report
+	users     map[string]uint              // email -> id
+	providers map[[2]string]*core.Provider // url, user email -> provider
+	repos     map[[3]string]uint           // provider url, provider user, full name -> id
+}
+
+func (im *importer) run(b *Backup) error {
+	for _, u := range b.Users {
+		if err := im.user(u); err != nil {
+			return fmt.Errorf("user %s: %v", u.Email, err)
+		}
+	}
+	for _, p := range b.Providers {
+		if err := im.provider(p); err != nil {
+			return fmt.Errorf("provider %s: %v", p.URL, err)
+		}
+	}
+	for _, r := range b.Repos {
+		if err := im.repo(r); err != nil {
+			return fmt.Errorf("repository %s: %v", r.FullName, err)
+		}
+	}
+	for _, t := range b.Teams {
+		if err := im.team(t); err != nil {
+			return fmt.Errorf("team %s: %v", t.Name, err)
+		}
+	}
+	return nil
+}
+
+func (im *importer) change(action, kind, name string) {
+	im.report.Changes = append(im.report.Changes, Change{Action: action, Kind: kind, Name: name})
+}
+
+func (im *importer) user(u User) error {
+	var user core.User
+	err := im.tx.Where("email = ?", u.Email).First(&user).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	if err == nil {
+		if u.Password == "" {
+			u.Password = user.Password
+		}
+		im.users[u.Email] = user.ID
+		if exportUser(&user) == u {
+			im.report.Unchanged++
+			return nil
+		}
+		user.Name, user.Avatar, user.Role, user.Active, user.Password = u.Name, u.Avatar, u.Role, u.Active, u.Password
+		im.change(ActionUpdate, "user", u.Email)
+		return im.tx.Save(&user).Error
+	}
+
+	if u.Password == "" {
+		hash, err := auth.HashPassword(auth.Password{Password: lib.RandomString() + lib.RandomString()})
+		if err != nil {
+			return err
+		}
+		u.Password = hash
+	}
+	user = core.User{Email: u.Email, Name: u.Name, Avatar: u.Avatar, Role: u.Role, Active: u.Active, Password: u.Password}
+	if err := im.tx.Create(&user).Error; err != nil {
+		return err
+	}
+	if !u.Active {
+		// active defaults to true on insert.
+		if err := im.tx.Model(&user).Update("active", false).Error; err != nil {
+			return err
+		}
+	}
+	im.users[u.Email] = user.ID
+	im.change(ActionCreate, "user", u.Email)
+	return nil
+}
+
+// userID returns id of user imported or existing in database.
+func (im *importer) userID(email string) (uint, error) {
+	if id, ok := im.users[email]; ok {
+		return id, nil
+	}
+	var user core.User
+	if err := im.tx.Where("email = ?", email).First(&user).Error; err != nil {
+		return 0, fmt.Errorf("user %s not found", email)
+	}
+	im.users[email] = user.ID
+	return user.ID, nil
+}
+
+func (im *importer) provider(p Provider) error {
+	userID, err := im.userID(p.User)
+	if err != nil {
+		return err
+	}
+
+	var provider core.Provider
+	err = im.tx.Where("url = ? AND user_id = ?", p.URL, userID).First(&provider).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	key := [2]string{p.URL, p.User}
+
+	if err == nil {
+		if !im.secrets {
+			p.AccessToken, p.Secret = provider.AccessToken, provider.Secret
+		}
+		im.providers[key] = &provider
+		if exportProvider(&provider, map[uint]string{userID: p.User}) == p {
+			im.report.Unchanged++
+			return nil
+		}
+		provider.Name, provider.Host, provider.AccessToken, provider.Secret = p.Name, p.Host, p.AccessToken, p.Secret
+		im.change(ActionUpdate, "provider", p.URL)
+		return im.tx.Save(&provider).Error
+	}
+
+	provider = core.Provider{
+		Name:        p.Name,
+		URL:         p.URL,
+		Host:        p.Host,
+		UserID:      userID,
+		AccessToken: p.AccessToken,
+		Secret:      p.Secret,
+	}
+	if err := im.tx.Create(&provider).Error; err != nil {
+		return err
+	}
+	im.providers[key] = &provider
+	im.change(ActionCreate, "provider", p.URL)
+	return nil
+}
+
+func (im *importer) repo(r Repo) error {
+	provider, ok := im.providers[[2]string{r.ProviderURL, r.ProviderUser}]
+	if !ok {
+		return fmt.Errorf("provider %s of user %s not found in backup", r.ProviderURL, r.ProviderUser)
+	}
+	userID, err := im.userID(r.User)
+	if err != nil {
+		return err
+	}
+
+	var repo core.Repository
+	err = im.tx.Where("provider_id = ? AND full_name = ?", provider.ID, r.FullName).First(&repo).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+	created := gorm.IsRecordNotFoundError(err)
+	if !im.secrets && !created {
+		r.RegistryAuth, r.HookSecret, r.HookSecretAlt = repo.RegistryAuth, repo.HookSecret, repo.HookSecretAlt
+		r.CloneAuth, r.SlackURL, r.DiscordURL = repo.CloneAuth, repo.Notify.SlackURL, repo.Notify.DiscordURL
+	}
+
+	settings := r
+	settings.Env, settings.Crons = nil, nil
+	current := exportRepo(&repo, provider, nil, map[uint]string{userID: r.User, provider.UserID: r.ProviderUser})
+	current.Env, current.Crons = nil, nil
+	if !created && reflect.DeepEqual(current, settings) {
+		im.report.Unchanged++
+	} else {
+		repo.UID, repo.ProviderName, repo.Namespace, repo.Name, repo.FullName = r.UID, r.ProviderName, r.Namespace, r.Name, r.FullName
+		repo.Private, repo.Fork, repo.URL, repo.Clone, repo.CloneSSH = r.Private, r.Fork, r.URL, r.Clone, r.CloneSSH
+		repo.DefaultBranch, repo.Active, repo.Timeout, repo.Token = r.DefaultBranch, r.Active, r.Timeout, r.Token
+		repo.UserID, repo.ProviderID = userID, provider.ID
+		repo.Notify, repo.RegistryAuth, repo.MaxBuilds = r.Notify, r.RegistryAuth, r.MaxBuilds
+		repo.Notify.SlackURL, repo.Notify.DiscordURL = r.SlackURL, r.DiscordURL
+		repo.AutoCancel, repo.PublicBadge, repo.Retention = r.AutoCancel, r.PublicBadge, r.Retention
+		repo.HookSecret, repo.HookSecretAlt, repo.CloneAuth = r.HookSecret, r.HookSecretAlt, r.CloneAuth
+		if created {
+			im.change(ActionCreate, "repository", r.FullName)
+			err = im.tx.Create(&repo).Error
+		} else {
+			im.change(ActionUpdate, "repository", r.FullName)
+			err = im.tx.Save(&repo).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	im.repos[[3]string{r.ProviderURL, r.ProviderUser, r.FullName}] = repo.ID
+
+	for _, e := range r.Env {
+		if err := im.env(&repo, e); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Crons {
+		if err := im.cron(&repo, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) env(repo *core.Repository, e Env) error {
+	name := fmt.Sprintf("%s %s", repo.FullName, e.Key)
+	var env core.EnvVariable
+	// struct condition quotes key column, reserved word in some databases.
+	err := im.tx.Where(&core.EnvVariable{RepositoryID: repo.ID, Key: e.Key}).First(&env).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	if err == nil {
+		if e.Secret && !im.secrets {
+			e.Value = env.Value
+		}
+		if env.Value == e.Value && env.Secret == e.Secret {
+			im.report.Unchanged++
+			return nil
+		}
+		env.Value, env.Secret = e.Value, e.Secret
+		im.change(ActionUpdate, "env", name)
+		return im.tx.Save(&env).Error
+	}
+
+	if e.Secret && !im.secrets {
+		im.report.Changes = append(im.report.Changes, Change{
+			Action: ActionSkip,
+			Kind:   "env",
+			Name:   name,
+			Reason: "secret value not included in backup",
+		})
+		return nil
+	}
+	env = core.EnvVariable{Key: e.Key, Value: e.Value, Secret: e.Secret, RepositoryID: repo.ID}
+	im.change(ActionCreate, "env", name)
+	return im.tx.Create(&env).Error
+}
+
+func (im *importer) cron(repo *core.Repository, c Cron) error {
+	var count int
+	err := im.tx.Model(&core.Cron{}).
+		Where("repository_id = ? AND spec = ? AND branch = ? AND timezone = ?", repo.ID, c.Spec, c.Branch, c.Timezone).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		im.report.Unchanged++
+		return nil
+	}
+
+	schedule, err := cron.Parse(c.Spec, c.Timezone)
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("cron expression %s never runs", c.Spec)
+	}
+	next = next.UTC()
+	im.change(ActionCreate, "cron", fmt.Sprintf("%s %s", repo.FullName, c.Spec))
+	return im.tx.Create(&core.Cron{
+		Spec:         c.Spec,
+		Branch:       c.Branch,
+		Timezone:     c.Timezone,
+		NextRun:      &next,
+		RepositoryID: repo.ID,
+	}).Error
+}
+
+func (im *importer) team(t Team) error {
+	var team core.Team
+	err := im.tx.Where("name = ?", t.Name).Preload("Users").First(&team).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	switch {
+	case gorm.IsRecordNotFoundError(err):
+		team = core.Team{Name: t.Name, About: t.About, Color: t.Color}
+		im.change(ActionCreate, "team", t.Name)
+		if err := im.tx.Create(&team).Error; err != nil {
+			return err
+		}
+	case team.About != t.About || team.Color != t.Color:
+		team.About, team.Color = t.About, t.Color
+		im.change(ActionUpdate, "team", t.Name)
+		if err := im.tx.Save(&team).Error; err != nil {
+			return err
+		}
+	default:
+		im.report.Unchanged++
+	}
+
+	members := make(map[uint]bool)
+	for _, u := range team.Users {
+		members[u.ID] = true
+	}
+	for _, email := range t.Users {
+		id, err := im.userID(email)
+		if err != nil {
+			return err
+		}
+		if members[id] {
+			continue
+		}
+		im.change(ActionCreate, "team member", fmt.Sprintf("%s %s", t.Name, email))
+		if err := im.tx.Model(&team).Association("Users").Append(&core.User{ID: id}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, p := range t.Permissions {
+		repoID, ok := im.repos[[3]string{p.ProviderURL, p.ProviderUser, p.Repo}]
+		if !ok {
+			return fmt.Errorf("repository %s not found in backup", p.Repo)
+		}
+		if err := im.permission(&team, repoID, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) permission(team *core.Team, repoID uint, p Permission) error {
+	name := fmt.Sprintf("%s %s", team.Name, p.Repo)
+	var perm core.Permission
+	err := im.tx.Where("team_id = ? AND repository_id = ?", team.ID, repoID).First(&perm).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	if err == nil {
+		if perm.Read == p.Read && perm.Write == p.Write && perm.Exec == p.Exec {
+			im.report.Unchanged++
+			return nil
+		}
+		perm.Read, perm.Write, perm.Exec = p.Read, p.Write, p.Exec
+		im.change(ActionUpdate, "permission", name)
+		return im.tx.Save(&perm).Error
+	}
+
+	im.change(ActionCreate, "permission", name)
+	return im.tx.Create(&core.Permission{
+		TeamID:       team.ID,
+		RepositoryID: repoID,
+		Read:         p.Read,
+		Write:        p.Write,
+		Exec:         p.Exec,
+	}).Error
+}
@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encryption defines key derivation of secrets encrypted in backup,
+// secrets are encrypted with AES-256-GCM using key derived from
+// passphrase with argon2id.
+type Encryption struct {
+	Salt        string `json:"salt"`
+	Memory      uint32 `json:"memory"` // in KiB
+	Iterations  uint32 `json:"iterations"`
+	Parallelism uint8  `json:"parallelism"`
+}
+
+// ErrPassphrase is returned when secrets cannot be decrypted.
+var ErrPassphrase = fmt.Errorf("invalid backup passphrase")
+
+// secrets calls fn with pointer to every secret value of the backup.
+func (b *Backup) secrets(fn func(*string) error) error {
+	for i := range b.Providers {
+		if err := fn(&b.Providers[i].AccessToken); err != nil {
+			return err
+		}
+		if err := fn(&b.Providers[i].Secret); err != nil {
+			return err
+		}
+	}
+	for i := range b.Repos {
+		repo := &b.Repos[i]
+		for _, s := range []*string{&repo.SlackURL, &repo.DiscordURL, &repo.RegistryAuth, &repo.CloneAuth, &repo.HookSecret, &repo.HookSecretAlt} {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+		for j := range repo.Env {
+			if !repo.Env[j].Secret {
+				continue
+			}
+			if err := fn(&repo.Env[j].Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stripSecrets removes secret values from backup.
+func (b *Backup) stripSecrets() {
+	b.secrets(func(s *string) error {
+		*s = ""
+		return nil
+	})
+}
+
+// seal encrypts secret values with key derived from passphrase.
+func (b *Backup) seal(passphrase string) error {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	b.Encryption = &Encryption{
+		Salt:        base64.StdEncoding.EncodeToString(salt),
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+	}
+	gcm, err := b.Encryption.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return b.secrets(func(s *string) error {
+		if *s == "" {
+			return nil
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return err
+		}
+		*s = base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(*s), nil))
+		return nil
+	})
+}
+
+// open decrypts secret values with key derived from passphrase.
+func (b *Backup) open(passphrase string) error {
+	if b.Encryption == nil {
+		return nil
+	}
+	if passphrase == "" {
+		return fmt.Errorf("backup contains encrypted secrets, passphrase is required")
+	}
+	gcm, err := b.Encryption.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+
+	err = b.secrets(func(s *string) error {
+		if *s == "" {
+			return nil
+		}
+		data, err := base64.StdEncoding.DecodeString(*s)
+		if err != nil || len(data) < gcm.NonceSize() {
+			return fmt.Errorf("malformed encrypted secret")
+		}
+		plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+		if err != nil {
+			return ErrPassphrase
+		}
+		*s = string(plain)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.Encryption = nil
+	return nil
+}
+
+func (e *Encryption) cipher(passphrase string) (cipher.AEAD, error) {
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("malformed backup encryption salt")
+	}
+	key := argon2.IDKey([]byte(passphrase), salt, e.Iterations, e.Memory, e.Parallelism, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
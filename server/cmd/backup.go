@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/bleenco/abstruse/server/backup"
+	"github.com/spf13/cobra"
+)
+
+// envBackupPassphrase is environment variable backup secrets passphrase
+// is read from when not set with flag.
+const envBackupPassphrase = "ABSTRUSE_BACKUP_PASSPHRASE"
+
+var (
+	exportOut        string
+	exportPasswords  bool
+	importDryRun     bool
+	backupPassphrase string
+	exportCmd        = &cobra.Command{
+		Use:   "export",
+		Short: "Export users, providers, repositories with settings and secrets, and teams",
+		Long: fmt.Sprintf(`Export users, providers, repositories with their settings, environment
+variables, notifications and crons, and teams into single JSON file.
+Secrets are encrypted with passphrase from --passphrase or %s
+and omitted when passphrase is not set. Password hashes are included
+only with --passwords.`, envBackupPassphrase),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := newConfig()
+			if err != nil {
+				return err
+			}
+			db, err := openDB(cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			passphrase := readPassphrase()
+			if passphrase == "" {
+				fmt.Fprintln(os.Stderr, "passphrase not set, secrets are not exported")
+			}
+			b, err := backup.Export(db, backup.ExportOptions{Passwords: exportPasswords, Passphrase: passphrase})
+			if err != nil {
+				return err
+			}
+			b.Config = cfg.Redacted()
+
+			data, err := json.MarshalIndent(b, "", "  ")
+			if err != nil {
+				return err
+			}
+			if exportOut == "" || exportOut == "-" {
+				fmt.Println(string(data))
+				return nil
+			}
+			if err := ioutil.WriteFile(exportOut, data, 0600); err != nil {
+				return err
+			}
+			fmt.Printf("exported %d users, %d providers, %d repositories and %d teams to %s\n",
+				len(b.Users), len(b.Providers), len(b.Repos), len(b.Teams), exportOut)
+			return nil
+		},
+	}
+	importCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import backup created with export",
+		Long: `Import users, providers, repositories and teams from backup created with
+export. Records are matched by email, provider URL and repository name,
+existing records are updated and nothing is deleted, so import can be
+repeated. With --dry-run changes are printed and rolled back.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			var b backup.Backup
+			if err := json.Unmarshal(data, &b); err != nil {
+				return fmt.Errorf("malformed backup %s: %v", args[0], err)
+			}
+
+			cfg, err := newConfig()
+			if err != nil {
+				return err
+			}
+			db, err := openDB(cfg)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			report, err := backup.Import(db, &b, backup.ImportOptions{DryRun: importDryRun, Passphrase: readPassphrase()})
+			if err != nil {
+				return err
+			}
+			for _, c := range report.Changes {
+				if c.Reason != "" {
+					fmt.Printf("%s %s %s (%s)\n", c.Action, c.Kind, c.Name, c.Reason)
+					continue
+				}
+				fmt.Printf("%s %s %s\n", c.Action, c.Kind, c.Name)
+			}
+			fmt.Printf("%d changes, %d unchanged\n", len(report.Changes), report.Unchanged)
+			if importDryRun {
+				fmt.Println("dry run, no changes were made")
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "backup file (default is stdout)")
+	exportCmd.Flags().BoolVar(&exportPasswords, "passwords", false, "include password hashes of users")
+	exportCmd.Flags().StringVar(&backupPassphrase, "passphrase", "", fmt.Sprintf("passphrase secrets are encrypted with (default is $%s)", envBackupPassphrase))
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "print changes without applying them")
+	importCmd.Flags().StringVar(&backupPassphrase, "passphrase", "", fmt.Sprintf("passphrase secrets are decrypted with (default is $%s)", envBackupPassphrase))
+}
+
+func readPassphrase() string {
+	if backupPassphrase != "" {
+		return backupPassphrase
+	}
+	return os.Getenv(envBackupPassphrase)
+}
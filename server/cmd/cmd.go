@@ -1,17 +1,27 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/internal/rpc"
 	"github.com/bleenco/abstruse/internal/version"
+	"github.com/bleenco/abstruse/pkg/configfile"
 	"github.com/bleenco/abstruse/pkg/fs"
+	"github.com/bleenco/abstruse/pkg/gitscm"
 	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/pkg/tlsutil"
 	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/control"
+	"github.com/bleenco/abstruse/server/core"
 	"github.com/bleenco/abstruse/server/http"
 	"github.com/bleenco/abstruse/server/ws"
 	"github.com/jinzhu/gorm"
@@ -22,20 +32,24 @@ import (
 )
 
 var (
-	cfgFile string
-	rootCmd = &cobra.Command{
-		Use:   "abstruse",
-		Short: "Abstruse CI",
-		Run: func(cmd *cobra.Command, args []string) {
+	cfgFiles   []string
+	cfgLoaded  []string // config files with files they include
+	cfgProfile string
+	cfgNoWrite bool
+	cfgWatch   bool
+	cfgKeyFile string
+	flagKeys   = make(map[string]string) // flag names by config key
+	rootCmd    = &cobra.Command{
+		Use:           "abstruse",
+		Short:         "Abstruse CI",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := CreateApp()
 			if err != nil {
-				fatal(err)
-			}
-
-			if err := app.run(); err != nil {
-				fatal(err)
+				return err
 			}
-			os.Exit(0)
+			return app.run()
 		},
 	}
 	versionCmd = &cobra.Command{
@@ -43,17 +57,20 @@ var (
 		Short: "Print the version number and build info",
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println(version.GenerateBuildVersionString())
-			os.Exit(0)
 		},
 	}
 )
 
 type app struct {
-	config *config.Config
-	db     *gorm.DB
-	logger *zap.Logger
-	http   *http.Server
-	ws     *ws.Server
+	config    *config.Config
+	db        *gorm.DB
+	logger    *zap.Logger
+	http      *http.Server
+	control   *control.Server
+	ws        *ws.Server
+	users     core.UserStore
+	retention core.RetentionService
+	audit     core.AuditService
 }
 
 func newApp(
@@ -61,20 +78,40 @@ func newApp(
 	db *gorm.DB,
 	logger *zap.Logger,
 	http *http.Server,
+	control *control.Server,
 	ws *ws.Server,
+	users core.UserStore,
+	retention core.RetentionService,
+	audit core.AuditService,
 ) *app {
-	return &app{config, db, logger, http, ws}
+	return &app{config, db, logger, http, control, ws, users, retention, audit}
 }
 
 func (a app) run() error {
+	if err := seedAdmin(a.users, a.logger); err != nil {
+		return err
+	}
+
+	if c := a.config.ClientTLS; c != nil && c.InsecureSkipVerify {
+		a.logger.Warn("TLS verification of provider API and notification requests is disabled with clienttls.insecureskipverify, do not use in production")
+	}
+
 	errch := make(chan error, 1)
 
+	go a.reloadOnSignal()
+
 	go func() {
 		if err := a.http.Run(); err != nil {
 			errch <- err
 		}
 	}()
 
+	go func() {
+		if err := a.control.Run(); err != nil {
+			errch <- err
+		}
+	}()
+
 	go func() {
 		if err := a.ws.Run(); err != nil {
 			errch <- err
@@ -84,23 +121,45 @@ func (a app) run() error {
 	return <-errch
 }
 
-// Execute executes the root command.
+// Execute executes the root command, errors are returned to the caller
+// which is responsible for exiting the process.
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(seedAdminCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
 	cobra.OnInitialize(initDefaults)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/abstruse/abstruse.json)")
-	rootCmd.PersistentFlags().String("http-addr", "0.0.0.0:80", "HTTP server listen address")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgFiles, "config", nil, "config file in JSON, YAML or TOML format, repeat to layer files with later overriding earlier (default is $HOME/abstruse/abstruse.json)")
+	rootCmd.PersistentFlags().BoolVar(&cfgNoWrite, "no-write-config", false, "do not create config file when missing, run with defaults, environment variables and flags (default is $ABSTRUSE_NO_WRITE_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&cfgKeyFile, "config-keyfile", "", "file with key encrypted config values are decrypted with (default is $ABSTRUSE_CONFIG_KEYFILE)")
+	rootCmd.PersistentFlags().StringVar(&cfgRemote, "config-remote", "", "config stored in etcd or Consul merged over config files, e.g. etcd://10.0.0.1:2379/abstruse/config.yml (default is $ABSTRUSE_CONFIG_REMOTE)")
+	rootCmd.PersistentFlags().BoolVar(&cfgWatch, "watch-config", false, "reload config when config files change (default is $ABSTRUSE_WATCH_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&cfgProfile, "profile", "", "config profile overriding base config with profiles.<name> section (default is $ABSTRUSE_PROFILE)")
+	rootCmd.PersistentFlags().String("datadir", "", "data directory root relative paths of uploads, logs and certificates are resolved to (default is config file directory)")
+	rootCmd.PersistentFlags().String("http-addr", "0.0.0.0:80", "HTTP server listen address, host:port or unix:///path/to/sock")
+	rootCmd.PersistentFlags().String("http-baseurl", "", "external URL of the server links are created with, path is served as prefix, e.g. https://ci.example.com/abstruse/ (default is provider host)")
 	rootCmd.PersistentFlags().String("http-uploaddir", "uploads/", "HTTP uploads directory")
 	rootCmd.PersistentFlags().Bool("http-compress", false, "enable HTTP response gzip compression")
-	rootCmd.PersistentFlags().Bool("http-tls", false, "run HTTP server in TLS mode")
+	rootCmd.PersistentFlags().Bool("http-tls", false, "run HTTP server in TLS mode (ignored when listening on unix socket)")
+	rootCmd.PersistentFlags().StringSlice("http-trustedproxies", []string{}, "addresses or CIDR ranges of reverse proxies client address is read from X-Forwarded-For of, e.g. 10.0.0.0/8 (unix socket clients are always trusted)")
+	rootCmd.PersistentFlags().StringSlice("http-cors-allowedorigins", []string{}, "origins allowed to make CORS requests, supports wildcards (CORS disabled when empty)")
+	rootCmd.PersistentFlags().StringSlice("http-cors-allowedmethods", []string{"GET", "POST", "PATCH", "PUT", "DELETE", "OPTIONS"}, "methods allowed in CORS requests")
+	rootCmd.PersistentFlags().Bool("http-cors-allowcredentials", false, "allow credentials in CORS requests")
+	rootCmd.PersistentFlags().Duration("http-readtimeout", 30*time.Second, "maximum duration for reading entire HTTP request including body (0 disables)")
+	rootCmd.PersistentFlags().Duration("http-writetimeout", 60*time.Second, "maximum duration before timing out writes of HTTP response, streaming endpoints are exempt (0 disables)")
+	rootCmd.PersistentFlags().Duration("http-idletimeout", 120*time.Second, "maximum duration to wait for next request on keep-alive connection (0 disables)")
+	rootCmd.PersistentFlags().Duration("http-readheadertimeout", 10*time.Second, "maximum duration for reading HTTP request headers (0 disables)")
 	rootCmd.PersistentFlags().String("websocket-addr", "127.0.0.1:2220", "WebSocket server listen address")
-	rootCmd.PersistentFlags().String("tls-cert", "cert.pem", "path to SSL certificate file")
-	rootCmd.PersistentFlags().String("tls-key", "key.pem", "path to SSL private key file")
+	rootCmd.PersistentFlags().String("tls-cert", "certs/cert.pem", "path to SSL certificate file")
+	rootCmd.PersistentFlags().String("tls-key", "certs/key.pem", "path to SSL private key file")
 	rootCmd.PersistentFlags().String("db-driver", "mysql", "database client (available options: mysql, postgres, mssql)")
 	rootCmd.PersistentFlags().String("db-host", "localhost", "database server host address")
 	rootCmd.PersistentFlags().Int("db-port", 3306, "database server port")
@@ -108,122 +167,396 @@ func init() {
 	rootCmd.PersistentFlags().String("db-password", "", "database password")
 	rootCmd.PersistentFlags().String("db-name", "abstruse", "database name (file name when sqlite client used)")
 	rootCmd.PersistentFlags().String("db-charset", "utf8", "database charset")
+	rootCmd.PersistentFlags().Bool("db-automigrate", true, "apply pending database migrations on startup")
+	rootCmd.PersistentFlags().Bool("db-logqueries", false, "log executed SQL queries at debug level, parameters are not logged")
+	rootCmd.PersistentFlags().Duration("db-slowquerythreshold", 0, "log warning for SQL queries taking longer (0 disables)")
+	rootCmd.PersistentFlags().String("db-replica-host", "", "read replica host address, listing and search queries are sent to it when set")
+	rootCmd.PersistentFlags().Int("db-replica-port", 0, "read replica port (defaults to db-port)")
+	rootCmd.PersistentFlags().String("db-replica-user", "", "read replica username (defaults to db-user)")
+	rootCmd.PersistentFlags().String("db-replica-password", "", "read replica password (defaults to db-password)")
+	rootCmd.PersistentFlags().String("db-replica-name", "", "read replica database name (defaults to db-name)")
 	rootCmd.PersistentFlags().String("logger-level", "info", "logging level (available options: debug, info, warn, error, panic, fatal)")
 	rootCmd.PersistentFlags().Bool("logger-stdout", true, "print logs to stdout")
-	rootCmd.PersistentFlags().String("logger-filename", "abstruse.log", "log filename")
+	rootCmd.PersistentFlags().String("logger-filename", "logs/abstruse.log", "log filename")
 	rootCmd.PersistentFlags().Int("logger-max-size", 500, "maximum log file size (in MB)")
 	rootCmd.PersistentFlags().Int("logger-max-backups", 3, "maximum log file backups")
 	rootCmd.PersistentFlags().Int("logger-max-age", 3, "maximum log age")
+	rootCmd.PersistentFlags().String("logger-timeformat", "", "log timestamp format: rfc3339, rfc3339nano, iso8601, epoch, epochmillis or Go time layout (default is encoder default)")
+	rootCmd.PersistentFlags().String("audit-filename", "logs/audit.log", "audit log filename, append only JSON lines (disabled when empty)")
+	rootCmd.PersistentFlags().Bool("audit-db", false, "also store audit events in database, listed by admins via API")
+	rootCmd.PersistentFlags().String("display-timezone", "UTC", "time zone timestamps are logged and returned by API in, e.g. Europe/Ljubljana")
 	rootCmd.PersistentFlags().String("auth-jwtsecret", lib.RandomString(), "JWT authentication secret key")
+	rootCmd.PersistentFlags().Duration("auth-jwtexpiry", auth.DefaultJWTExpiry, "lifetime of user access tokens")
+	rootCmd.PersistentFlags().Uint32("auth-argon2-memory", auth.DefaultArgon2Params.Memory, "argon2id password hashing memory in KiB")
+	rootCmd.PersistentFlags().Uint32("auth-argon2-iterations", auth.DefaultArgon2Params.Iterations, "argon2id password hashing iterations")
+	rootCmd.PersistentFlags().Uint8("auth-argon2-parallelism", auth.DefaultArgon2Params.Parallelism, "argon2id password hashing parallelism")
+	rootCmd.PersistentFlags().Int("auth-lockout-attempts", 5, "number of failed logins within window after which account is locked (0 disables)")
+	rootCmd.PersistentFlags().Duration("auth-lockout-window", 15*time.Minute, "time window in which failed logins are counted")
+	rootCmd.PersistentFlags().Duration("auth-lockout-duration", 15*time.Minute, "duration of account lock after too many failed logins")
+	rootCmd.PersistentFlags().String("auth-oidc-issuer", "", "OpenID Connect issuer URL single sign-on is enabled with, e.g. https://keycloak.example.com/realms/ci")
+	rootCmd.PersistentFlags().String("auth-oidc-name", "SSO", "name of OpenID Connect provider shown on login page")
+	rootCmd.PersistentFlags().String("auth-oidc-clientid", "", "OpenID Connect client ID")
+	rootCmd.PersistentFlags().String("auth-oidc-clientsecret", "", "OpenID Connect client secret")
+	rootCmd.PersistentFlags().String("auth-oidc-redirecturl", "", "OpenID Connect redirect URL (default is http-baseurl/api/v1/auth/oidc/callback)")
+	rootCmd.PersistentFlags().StringSlice("auth-oidc-scopes", []string{"openid", "profile", "email"}, "OpenID Connect scopes requested on login")
+	rootCmd.PersistentFlags().String("auth-oidc-groupsclaim", "groups", "ID token claim holding groups of the user")
+	rootCmd.PersistentFlags().String("auth-oidc-defaultrole", "", "role of OpenID Connect users not in any mapped group (available options: admin, maintainer, viewer, login is denied when empty)")
+	rootCmd.PersistentFlags().Bool("auth-oidc-provision", true, "create users on first OpenID Connect login")
+	rootCmd.PersistentFlags().Int("ratelimit-auth", 10, "maximum requests per minute per client on authentication endpoints (0 disables)")
+	rootCmd.PersistentFlags().Int("ratelimit-webhooks", 60, "maximum requests per minute per client on webhook endpoints (0 disables)")
+	rootCmd.PersistentFlags().Int("ratelimit-api", 600, "maximum requests per minute per user on API endpoints (0 disables)")
+	rootCmd.PersistentFlags().Int("webhooks-workers", 4, "number of workers processing webhook deliveries (0 processes them synchronously in webhook request)")
+	rootCmd.PersistentFlags().Int("webhooks-queue", 100, "maximum number of webhook deliveries waiting to be processed, further deliveries are rejected")
+	rootCmd.PersistentFlags().String("smtp-host", "", "SMTP server host for email notifications (disabled when empty)")
+	rootCmd.PersistentFlags().Int("smtp-port", 587, "SMTP server port")
+	rootCmd.PersistentFlags().String("smtp-username", "", "SMTP authentication username")
+	rootCmd.PersistentFlags().String("smtp-password", "", "SMTP authentication password")
+	rootCmd.PersistentFlags().String("smtp-from", "abstruse@localhost", "email address notifications are sent from")
+	rootCmd.PersistentFlags().String("vault-addr", "", "address of HashiCorp Vault build secrets are read from (disabled when empty)")
+	rootCmd.PersistentFlags().String("vault-namespace", "", "Vault Enterprise namespace")
+	rootCmd.PersistentFlags().String("vault-auth", "token", "Vault auth method (available options: token, approle)")
+	rootCmd.PersistentFlags().String("vault-token", "", "Vault token used with token auth")
+	rootCmd.PersistentFlags().String("vault-roleid", "", "Vault AppRole role ID")
+	rootCmd.PersistentFlags().String("vault-secretid", "", "Vault AppRole secret ID")
+	rootCmd.PersistentFlags().String("vault-mount", "secret", "mount path of Vault KV secrets engine")
+	rootCmd.PersistentFlags().Int("vault-kvversion", 2, "version of Vault KV secrets engine (available options: 1, 2)")
+	rootCmd.PersistentFlags().Duration("vault-timeout", 10*time.Second, "timeout of Vault requests")
+	rootCmd.PersistentFlags().String("proxy-http", "", "proxy for provider API and notification http requests (default is $HTTP_PROXY)")
+	rootCmd.PersistentFlags().String("proxy-https", "", "proxy for provider API and notification https requests (default is $HTTPS_PROXY)")
+	rootCmd.PersistentFlags().String("proxy-noproxy", "", "comma separated hosts requested without proxy (default is $NO_PROXY)")
+	rootCmd.PersistentFlags().String("clienttls-cafile", "", "PEM file of CA certificates trusted by provider API and notification requests in addition to system ones")
+	rootCmd.PersistentFlags().String("clienttls-ca", "", "PEM encoded CA certificates trusted by provider API and notification requests")
+	rootCmd.PersistentFlags().Bool("clienttls-insecure-skip-verify", false, "disable TLS verification of provider API and notification requests, for development only")
+	rootCmd.PersistentFlags().Int("scheduler-maxrepobuilds", 0, "maximum running builds per repository unless set on repository (0 for unlimited)")
+	rootCmd.PersistentFlags().Int64("scheduler-maxlogsize", 0, "maximum log size of each job in bytes, further output is discarded (0 for unlimited)")
+	rootCmd.PersistentFlags().Bool("scheduler-logsizefail", false, "stop and fail job when its log exceeds maximum size")
+	rootCmd.PersistentFlags().Bool("scheduler-maintenance", false, "start in maintenance mode, queued builds are not started until maintenance is lifted")
+	rootCmd.PersistentFlags().Int("scheduler-maxqueue", 0, "maximum number of queued jobs, new builds are rejected by queue policy when reached (0 for unlimited)")
+	rootCmd.PersistentFlags().String("scheduler-queuepolicy", "reject", "builds rejected when queue is full (available options: reject, automated)")
+	rootCmd.PersistentFlags().Duration("scheduler-pintimeout", 30*time.Minute, "how long jobs pinned to worker wait for it to connect before failing (0 waits forever)")
+	rootCmd.PersistentFlags().String("images-default", "", "build image used when build config does not specify image")
+	rootCmd.PersistentFlags().StringSlice("images-allow", []string{}, "patterns of build images allowed to run, e.g. golang,ghcr.io/org/ (all allowed when empty)")
+	rootCmd.PersistentFlags().StringSlice("images-deny", []string{}, "patterns of build images denied to run")
+	rootCmd.PersistentFlags().String("grpc-addr", "", "control API gRPC listen address for command line clients (disabled when empty)")
+	rootCmd.PersistentFlags().Int("grpc-maxrecvmsgsize", rpc.DefaultMaxMsgSize, "maximum size of received gRPC message in bytes")
+	rootCmd.PersistentFlags().Int("grpc-maxsendmsgsize", rpc.DefaultMaxMsgSize, "maximum size of sent gRPC message in bytes")
+	rootCmd.PersistentFlags().Duration("grpc-keepalive-time", 30*time.Second, "interval of gRPC keepalive pings on idle connections (0 disables)")
+	rootCmd.PersistentFlags().Duration("grpc-keepalive-timeout", 10*time.Second, "time to wait for gRPC keepalive ping response before closing connection")
+	rootCmd.PersistentFlags().Bool("grpc-keepalive-permitwithoutstream", true, "send gRPC keepalive pings when there are no active streams")
+	rootCmd.PersistentFlags().Bool("gitlab-skipdrafts", false, "do not build draft GitLab merge requests")
+	rootCmd.PersistentFlags().Bool("gitlab-mergeref", false, "build GitLab merge requests from simulated merge commit instead of merge request head")
 }
 
 func initDefaults() {
-	viper.BindPFlag("http.addr", rootCmd.PersistentFlags().Lookup("http-addr"))
-	viper.BindPFlag("http.tls", rootCmd.PersistentFlags().Lookup("http-tls"))
-	viper.BindPFlag("http.uploaddir", rootCmd.PersistentFlags().Lookup("http-uploaddir"))
-	viper.BindPFlag("http.compress", rootCmd.PersistentFlags().Lookup("http-compress"))
-	viper.BindPFlag("websocket.addr", rootCmd.PersistentFlags().Lookup("websocket-addr"))
-	viper.BindPFlag("tls.cert", rootCmd.PersistentFlags().Lookup("tls-cert"))
-	viper.BindPFlag("tls.key", rootCmd.PersistentFlags().Lookup("tls-key"))
-	viper.BindPFlag("db.driver", rootCmd.PersistentFlags().Lookup("db-driver"))
-	viper.BindPFlag("db.host", rootCmd.PersistentFlags().Lookup("db-host"))
-	viper.BindPFlag("db.port", rootCmd.PersistentFlags().Lookup("db-port"))
-	viper.BindPFlag("db.user", rootCmd.PersistentFlags().Lookup("db-user"))
-	viper.BindPFlag("db.password", rootCmd.PersistentFlags().Lookup("db-password"))
-	viper.BindPFlag("db.name", rootCmd.PersistentFlags().Lookup("db-name"))
-	viper.BindPFlag("db.charset", rootCmd.PersistentFlags().Lookup("db-charset"))
-	viper.BindPFlag("logger.level", rootCmd.PersistentFlags().Lookup("logger-level"))
-	viper.BindPFlag("logger.stdout", rootCmd.PersistentFlags().Lookup("logger-stdout"))
-	viper.BindPFlag("logger.filename", rootCmd.PersistentFlags().Lookup("logger-filename"))
-	viper.BindPFlag("logger.maxsize", rootCmd.PersistentFlags().Lookup("logger-max-size"))
-	viper.BindPFlag("logger.maxbackups", rootCmd.PersistentFlags().Lookup("logger-max-backups"))
-	viper.BindPFlag("logger.maxage", rootCmd.PersistentFlags().Lookup("logger-max-age"))
-	viper.BindPFlag("auth.jwtsecret", rootCmd.PersistentFlags().Lookup("auth-jwtsecret"))
+	bindFlag("datadir", "datadir")
+	bindFlag("http.addr", "http-addr")
+	bindFlag("http.baseurl", "http-baseurl")
+	bindFlag("http.tls", "http-tls")
+	bindFlag("http.uploaddir", "http-uploaddir")
+	bindFlag("http.compress", "http-compress")
+	bindFlag("http.trustedproxies", "http-trustedproxies")
+	bindFlag("http.cors.allowedorigins", "http-cors-allowedorigins")
+	bindFlag("http.cors.allowedmethods", "http-cors-allowedmethods")
+	bindFlag("http.cors.allowcredentials", "http-cors-allowcredentials")
+	bindFlag("http.readtimeout", "http-readtimeout")
+	bindFlag("http.writetimeout", "http-writetimeout")
+	bindFlag("http.idletimeout", "http-idletimeout")
+	bindFlag("http.readheadertimeout", "http-readheadertimeout")
+	bindFlag("websocket.addr", "websocket-addr")
+	bindFlag("tls.cert", "tls-cert")
+	bindFlag("tls.key", "tls-key")
+	bindFlag("db.driver", "db-driver")
+	bindFlag("db.host", "db-host")
+	bindFlag("db.port", "db-port")
+	bindFlag("db.user", "db-user")
+	bindFlag("db.password", "db-password")
+	bindFlag("db.name", "db-name")
+	bindFlag("db.charset", "db-charset")
+	bindFlag("db.automigrate", "db-automigrate")
+	bindFlag("db.logqueries", "db-logqueries")
+	bindFlag("db.slowquerythreshold", "db-slowquerythreshold")
+	bindFlag("db.replica.host", "db-replica-host")
+	bindFlag("db.replica.port", "db-replica-port")
+	bindFlag("db.replica.user", "db-replica-user")
+	bindFlag("db.replica.password", "db-replica-password")
+	bindFlag("db.replica.name", "db-replica-name")
+	bindFlag("logger.level", "logger-level")
+	bindFlag("logger.stdout", "logger-stdout")
+	bindFlag("logger.filename", "logger-filename")
+	bindFlag("logger.maxsize", "logger-max-size")
+	bindFlag("logger.timeformat", "logger-timeformat")
+	bindFlag("display.timezone", "display-timezone")
+	bindFlag("audit.filename", "audit-filename")
+	bindFlag("audit.db", "audit-db")
+	bindFlag("logger.maxbackups", "logger-max-backups")
+	bindFlag("logger.maxage", "logger-max-age")
+	bindFlag("auth.jwtsecret", "auth-jwtsecret")
+	bindFlag("auth.jwtexpiry", "auth-jwtexpiry")
+	bindFlag("auth.argon2.memory", "auth-argon2-memory")
+	bindFlag("auth.argon2.iterations", "auth-argon2-iterations")
+	bindFlag("auth.argon2.parallelism", "auth-argon2-parallelism")
+	bindFlag("auth.lockout.attempts", "auth-lockout-attempts")
+	bindFlag("auth.lockout.window", "auth-lockout-window")
+	bindFlag("auth.lockout.duration", "auth-lockout-duration")
+	bindFlag("auth.oidc.issuer", "auth-oidc-issuer")
+	bindFlag("auth.oidc.name", "auth-oidc-name")
+	bindFlag("auth.oidc.clientid", "auth-oidc-clientid")
+	bindFlag("auth.oidc.clientsecret", "auth-oidc-clientsecret")
+	bindFlag("auth.oidc.redirecturl", "auth-oidc-redirecturl")
+	bindFlag("auth.oidc.scopes", "auth-oidc-scopes")
+	bindFlag("auth.oidc.groupsclaim", "auth-oidc-groupsclaim")
+	bindFlag("auth.oidc.defaultrole", "auth-oidc-defaultrole")
+	bindFlag("auth.oidc.provision", "auth-oidc-provision")
+	bindFlag("ratelimit.auth", "ratelimit-auth")
+	bindFlag("ratelimit.webhooks", "ratelimit-webhooks")
+	bindFlag("ratelimit.api", "ratelimit-api")
+	bindFlag("webhooks.workers", "webhooks-workers")
+	bindFlag("webhooks.queue", "webhooks-queue")
+	bindFlag("smtp.host", "smtp-host")
+	bindFlag("smtp.port", "smtp-port")
+	bindFlag("smtp.username", "smtp-username")
+	bindFlag("smtp.password", "smtp-password")
+	bindFlag("smtp.from", "smtp-from")
+	bindFlag("vault.addr", "vault-addr")
+	bindFlag("vault.namespace", "vault-namespace")
+	bindFlag("vault.auth", "vault-auth")
+	bindFlag("vault.token", "vault-token")
+	bindFlag("vault.roleid", "vault-roleid")
+	bindFlag("vault.secretid", "vault-secretid")
+	bindFlag("vault.mount", "vault-mount")
+	bindFlag("vault.kvversion", "vault-kvversion")
+	bindFlag("vault.timeout", "vault-timeout")
+	bindFlag("proxy.http", "proxy-http")
+	bindFlag("proxy.https", "proxy-https")
+	bindFlag("proxy.noproxy", "proxy-noproxy")
+	bindFlag("clienttls.cafile", "clienttls-cafile")
+	bindFlag("clienttls.ca", "clienttls-ca")
+	bindFlag("clienttls.insecureskipverify", "clienttls-insecure-skip-verify")
+	bindFlag("scheduler.maxrepobuilds", "scheduler-maxrepobuilds")
+	bindFlag("scheduler.maxlogsize", "scheduler-maxlogsize")
+	bindFlag("scheduler.logsizefail", "scheduler-logsizefail")
+	bindFlag("scheduler.pintimeout", "scheduler-pintimeout")
+	bindFlag("scheduler.maintenance", "scheduler-maintenance")
+	bindFlag("scheduler.maxqueue", "scheduler-maxqueue")
+	bindFlag("scheduler.queuepolicy", "scheduler-queuepolicy")
+	bindFlag("images.default", "images-default")
+	bindFlag("images.allow", "images-allow")
+	bindFlag("images.deny", "images-deny")
+	bindFlag("grpc.addr", "grpc-addr")
+	bindFlag("grpc.maxrecvmsgsize", "grpc-maxrecvmsgsize")
+	bindFlag("grpc.maxsendmsgsize", "grpc-maxsendmsgsize")
+	bindFlag("grpc.keepalive.time", "grpc-keepalive-time")
+	bindFlag("grpc.keepalive.timeout", "grpc-keepalive-timeout")
+	bindFlag("grpc.keepalive.permitwithoutstream", "grpc-keepalive-permitwithoutstream")
+	bindFlag("gitlab.skipdrafts", "gitlab-skipdrafts")
+	bindFlag("gitlab.mergeref", "gitlab-mergeref")
 }
 
-func newConfig() *config.Config {
-	var cfg *config.Config
+// bindFlag binds config key to persistent flag and records the
+// binding so origin of the config value can be resolved.
+func bindFlag(key, name string) {
+	flagKeys[key] = name
+	viper.BindPFlag(key, rootCmd.PersistentFlags().Lookup(name))
+}
 
-	if cfgFile == "" {
-		home, err := homedir.Dir()
-		if err != nil {
-			fatal(err)
+func newConfig() (*config.Config, error) {
+	cfg, err := resolveConfig(true)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !fs.Exists(cfg.HTTP.UploadDir) {
+		if err := fs.MakeDir(cfg.HTTP.UploadDir); err != nil {
+			return nil, err
 		}
-		cfgFile = filepath.Join(home, "abstruse", "abstruse.json")
+
+		if err := fs.MakeDir(filepath.Join(cfg.HTTP.UploadDir, "avatars")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := auth.Init(cfg.Auth.JWTSecret); err != nil {
+		return nil, err
+	}
+	auth.SetJWTExpiry(cfg.Auth.JWTExpiry)
+	if a := cfg.Auth.Argon2; a != nil {
+		auth.SetArgon2Params(a.Memory, a.Iterations, a.Parallelism)
+	}
+	transport, err := cfg.Transport()
+	if err != nil {
+		return nil, err
+	}
+	gitscm.SetTransport(transport)
+	core.SetBaseURL(cfg.HTTP.BaseURL)
+
+	if err := tlsutil.CheckAndGenerateCert(cfg.TLS.Cert, cfg.TLS.Key); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// resolveConfig merges flags, environment variables and config files
+// into config, relative paths are resolved to data directory which
+// defaults to directory of the last config file. When write is set,
+// config file is created on first run unless disabled with
+// --no-write-config.
+func resolveConfig(write bool) (*config.Config, error) {
+	var cfg *config.Config
+	write = write && !configNoWrite()
+
+	files, err := configFiles()
+	if err != nil {
+		return nil, err
 	}
+	cfgFiles = files
+	cfgFileUsed := files[len(files)-1]
 
-	viper.SetConfigFile(cfgFile)
-	viper.SetConfigType("json")
+	key, err := configKey()
+	if err != nil {
+		return nil, err
+	}
+
+	viper.SetConfigFile(cfgFileUsed)
+	viper.SetConfigType(configfile.Format(cfgFileUsed))
 	viper.SetEnvPrefix("abstruse")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
-	cfgFileUsed := viper.ConfigFileUsed()
-
-	if !fs.Exists(cfgFileUsed) {
-		if !fs.Exists(filepath.Dir(cfgFileUsed)) {
-			if err := fs.MakeDir(filepath.Dir(cfgFileUsed)); err != nil {
-				fatal(err)
-			}
+	if write && len(files) == 1 {
+		settings, err := encryptSecrets(viper.AllSettings(), key)
+		if err != nil {
+			return nil, err
 		}
-
-		if err := viper.SafeWriteConfigAs(cfgFileUsed); err != nil {
-			fatal(err)
+		if err := configfile.Create(cfgFileUsed, settings); err != nil {
+			return nil, err
 		}
 	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		fatal(err)
+	// discard values merged by previous load, so keys removed from
+	// config files are not kept when config is reloaded
+	viper.SetConfigType(configfile.FormatJSON)
+	if err := viper.ReadConfig(strings.NewReader("{}")); err != nil {
+		return nil, err
 	}
 
-	if err := viper.Unmarshal(&cfg); err != nil {
-		fatal(err)
+	loaded, err := configfile.Expand(files)
+	if err != nil {
+		return nil, err
 	}
+	cfgLoaded = loaded
 
-	if !strings.HasPrefix(cfg.HTTP.UploadDir, "/") {
-		cfg.HTTP.UploadDir = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), cfg.HTTP.UploadDir)
-	}
-
-	if !fs.Exists(cfg.HTTP.UploadDir) {
-		if err := fs.MakeDir(cfg.HTTP.UploadDir); err != nil {
-			fatal(err)
+	for _, file := range loaded {
+		data, err := configfile.Read(file)
+		if err != nil {
+			if !write && len(files) == 1 && file == cfgFileUsed && errors.Is(err, configfile.ErrNotExist) {
+				continue
+			}
+			return nil, err
 		}
-
-		if err := fs.MakeDir(filepath.Join(cfg.HTTP.UploadDir, "avatars")); err != nil {
-			fatal(err)
+		// layered files can be of different formats
+		viper.SetConfigType(configfile.Format(file))
+		if err := viper.MergeConfig(bytes.NewReader(data)); err != nil {
+			return nil, &configfile.FileError{Path: file, Err: configfile.ErrMalformed, Cause: err}
 		}
 	}
-
-	if !strings.HasPrefix(cfg.Logger.Filename, "/") {
-		cfg.Logger.Filename = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), cfg.Logger.Filename)
+	viper.SetConfigType(configfile.Format(cfgFileUsed))
+	if err := mergeRemoteConfig(); err != nil {
+		return nil, err
 	}
 
-	if !strings.HasPrefix(cfg.TLS.Cert, "/") {
-		cfg.TLS.Cert = filepath.Join(filepath.Dir(cfgFileUsed), cfg.TLS.Cert)
+	if err := applyProfile(configProfile()); err != nil {
+		return nil, err
 	}
 
-	if !strings.HasPrefix(cfg.TLS.Key, "/") {
-		cfg.TLS.Key = filepath.Join(filepath.Dir(cfgFileUsed), cfg.TLS.Key)
+	if err := viper.Unmarshal(&cfg, configfile.DecodeHook(key)); err != nil {
+		return nil, err
 	}
 
-	auth.Init(viper.GetString("auth.jwtsecret"))
+	cfg.DataDir = configfile.DataDir(cfgFileUsed, cfg.DataDir)
+	cfg.HTTP.UploadDir = configfile.Resolve(cfg.DataDir, cfg.HTTP.UploadDir)
+	cfg.Logger.Filename = configfile.Resolve(cfg.DataDir, cfg.Logger.Filename)
+	if cfg.Audit != nil && cfg.Audit.Filename != "" {
+		cfg.Audit.Filename = configfile.Resolve(cfg.DataDir, cfg.Audit.Filename)
+	}
+	if cfg.ClientTLS != nil && cfg.ClientTLS.CAFile != "" {
+		cfg.ClientTLS.CAFile = configfile.Resolve(cfg.DataDir, cfg.ClientTLS.CAFile)
+	}
+	cfg.TLS.Cert = configfile.Resolve(cfg.DataDir, cfg.TLS.Cert)
+	cfg.TLS.Key = configfile.Resolve(cfg.DataDir, cfg.TLS.Key)
 
-	cert, key := cfg.TLS.Cert, cfg.TLS.Key
-	if !strings.HasPrefix(cert, "/") {
-		cert = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), cert)
+	if err := resolveVaultValues(cfg); err != nil {
+		return nil, err
 	}
-	if !strings.HasPrefix(key, "/") {
-		key = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), key)
+
+	return cfg, nil
+}
+
+// configNoWrite returns true when creating missing config file is
+// disabled by --no-write-config flag or ABSTRUSE_NO_WRITE_CONFIG
+// environment variable.
+func configNoWrite() bool {
+	if !cfgNoWrite {
+		cfgNoWrite, _ = strconv.ParseBool(os.Getenv("ABSTRUSE_NO_WRITE_CONFIG"))
 	}
+	return cfgNoWrite
+}
 
-	if err := tlsutil.CheckAndGenerateCert(cert, key); err != nil {
-		fatal(err)
+// configProfile returns active config profile from --profile flag or
+// ABSTRUSE_PROFILE environment variable.
+func configProfile() string {
+	if cfgProfile == "" {
+		cfgProfile = os.Getenv("ABSTRUSE_PROFILE")
 	}
+	return cfgProfile
+}
 
-	return cfg
+// applyProfile merges profiles.<name> section of config files over base
+// config, so it overrides config file values while environment variables
+// and flags still take precedence.
+func applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	profiles := viper.GetStringMap("profiles")
+	profile, ok := profiles[strings.ToLower(name)].(map[string]interface{})
+	if !ok {
+		if len(profiles) == 0 {
+			return fmt.Errorf("unknown config profile %q, no profiles defined in config", name)
+		}
+		var names []string
+		for p := range profiles {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown config profile %q, available profiles: %s", name, strings.Join(names, ", "))
+	}
+	return viper.MergeConfigMap(profile)
 }
 
-func fatal(msg interface{}) {
-	fmt.Println(msg)
-	os.Exit(1)
+// configFiles returns config files from --config flags or colon separated
+// ABSTRUSE_CONFIG environment variable, ordered from base to override.
+func configFiles() ([]string, error) {
+	if len(cfgFiles) > 0 {
+		return cfgFiles, nil
+	}
+	if env := os.Getenv("ABSTRUSE_CONFIG"); env != "" {
+		var files []string
+		for _, file := range filepath.SplitList(env) {
+			if file != "" {
+				files = append(files, file)
+			}
+		}
+		if len(files) > 0 {
+			return files, nil
+		}
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return []string{configfile.Default(filepath.Join(home, "abstruse"), "abstruse")}, nil
 }
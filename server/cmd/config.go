@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bleenco/abstruse/pkg/configfile"
+	"github.com/bleenco/abstruse/pkg/fs"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/store"
+	"github.com/pelletier/go-toml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	configShowOrigin   bool
+	configExportFormat string
+	configCmd          = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate server configuration",
+	}
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Print effective configuration with secrets redacted",
+		Long: `Print effective configuration merged from flags, environment variables,
+active config profile, config file and defaults, in that order of precedence,
+with secrets redacted.
+With --show-origin every value is printed with its source.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := resolveConfig(false)
+			if err != nil {
+				return err
+			}
+
+			var out interface{} = cfg.Redacted()
+			if configShowOrigin {
+				if out, err = configOrigins(); err != nil {
+					return err
+				}
+			}
+
+			data, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+	configExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Print effective configuration in JSON, YAML or TOML with secrets redacted",
+		Long: `Print effective configuration merged from flags, environment variables,
+active config profile, config files and defaults with secrets redacted, in
+format given with --format, so it can be compared with config files.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := resolveConfig(false)
+			if err != nil {
+				return err
+			}
+
+			data, err := exportConfig(cfg.Redacted(), configExportFormat)
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(data))
+			return nil
+		},
+	}
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check configuration without starting the server",
+		Long: `Load configuration the same way server does and check all values,
+listen addresses, certificates, database connection string and that
+directories server writes to are writable. Problems are printed one per
+line and command exits with non-zero status when any is found.
+Database is not connected to.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := resolveConfig(false)
+			if err != nil {
+				return err
+			}
+
+			var problems []string
+			for _, file := range cfgFiles {
+				if !fs.Exists(file) {
+					problems = append(problems, fmt.Sprintf("config file %s does not exist", file))
+				}
+			}
+			problems = append(problems, configProblems(cfg)...)
+			for _, p := range problems {
+				fmt.Fprintf(os.Stderr, "error: %s\n", p)
+			}
+			if len(problems) > 0 {
+				return errors.New("config is not valid")
+			}
+			fmt.Printf("config %s is valid\n", strings.Join(cfgFiles, ", "))
+			return nil
+		},
+	}
+)
+
+// origin is config value with its source.
+type origin struct {
+	Value  interface{} `json:"value"`
+	Origin string      `json:"origin"`
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowOrigin, "show-origin", false, "print source of each config value")
+	configExportCmd.Flags().StringVar(&configExportFormat, "format", configfile.FormatJSON, "output format (available options: json, yaml, toml)")
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configExportCmd)
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// exportConfig returns config encoded in format, keys are config keys
+// as in config files.
+func exportConfig(cfg *config.Config, format string) ([]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case configfile.FormatJSON:
+		data, err := json.MarshalIndent(values, "", "  ")
+		return append(data, '\n'), err
+	case configfile.FormatYAML:
+		return yaml.Marshal(exportValue(values, false))
+	case configfile.FormatTOML:
+		// TOML has no null values, unset sections are left out
+		tree, err := toml.TreeFromMap(exportValue(values, true).(map[string]interface{}))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(tree.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (available options: json, yaml, toml)", format)
+	}
+}
+
+// exportValue converts numbers decoded from JSON to integers, so they
+// are not encoded in exponent notation, and drops null values of maps
+// when dropNull is set.
+func exportValue(v interface{}, dropNull bool) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			if v == nil && dropNull {
+				continue
+			}
+			m[k] = exportValue(v, dropNull)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, v := range value {
+			s[i] = exportValue(v, dropNull)
+		}
+		return s
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// configProblems returns all problems found in config, validation errors
+// first, then problems with the host config is used on.
+func configProblems(cfg *config.Config) []string {
+	var problems []string
+	for _, err := range []error{cfg.Validate(), cfg.Check()} {
+		var verr config.ValidationError
+		if errors.As(err, &verr) {
+			problems = append(problems, verr...)
+		} else if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if cfg.DB != nil {
+		if err := store.CheckDSN(cfg.DB); err != nil {
+			problems = append(problems, fmt.Sprintf("db connection string cannot be parsed: %v", err))
+		}
+	}
+	return problems
+}
+
+// configOrigins returns raw config values by key with their origin.
+func configOrigins() (map[string]origin, error) {
+	var files []*viper.Viper
+	for _, path := range cfgLoaded {
+		if !fs.Exists(path) {
+			continue
+		}
+		file := viper.New()
+		file.SetConfigFile(path)
+		file.SetConfigType(configfile.Format(path))
+		if err := file.ReadInConfig(); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	keys := viper.AllKeys()
+	origins := make(map[string]origin, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, "profiles.") || key == configfile.IncludeKey {
+			continue
+		}
+		value := viper.Get(key)
+		if config.IsSecret(key) {
+			value = config.Redact(fmt.Sprint(value))
+		} else if config.IsURL(key) {
+			value = config.RedactURL(fmt.Sprint(value))
+		}
+		origins[key] = origin{Value: value, Origin: configOrigin(key, files)}
+	}
+	return origins, nil
+}
+
+// configOrigin returns source of config value following viper precedence,
+// remote config overrides config files, later files override earlier ones.
+func configOrigin(key string, files []*viper.Viper) string {
+	if name, ok := flagKeys[key]; ok {
+		if f := rootCmd.PersistentFlags().Lookup(name); f != nil && f.Changed {
+			return "flag --" + name
+		}
+	}
+	env := "ABSTRUSE_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return "env " + env
+	}
+	if cfgProfile != "" {
+		profileKey := fmt.Sprintf("profiles.%s.%s", strings.ToLower(cfgProfile), key)
+		if cfgRemoteLoaded != nil && cfgRemoteLoaded.IsSet(profileKey) {
+			return fmt.Sprintf("profile %s in remote %s", cfgProfile, cfgRemote)
+		}
+		for i := len(files) - 1; i >= 0; i-- {
+			if files[i].IsSet(profileKey) {
+				return fmt.Sprintf("profile %s in file %s", cfgProfile, files[i].ConfigFileUsed())
+			}
+		}
+	}
+	if cfgRemoteLoaded != nil && cfgRemoteLoaded.IsSet(key) {
+		return "remote " + cfgRemote
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if files[i].IsSet(key) {
+			return "file " + files[i].ConfigFileUsed()
+		}
+	}
+	return "default"
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bleenco/abstruse/pkg/configfile"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configEncryptCmd = &cobra.Command{
+		Use:   "encrypt [value]",
+		Short: "Encrypt config value with config key",
+		Long: `Encrypt config value with key from --config-keyfile, ABSTRUSE_CONFIG_KEYFILE
+or ABSTRUSE_CONFIG_KEY and print it in enc:v1:... form, which can be used as
+value of any config key. Value is read from standard input when not given,
+so it is not kept in shell history.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := configKey()
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				return errors.New("config key is not set, use --config-keyfile or ABSTRUSE_CONFIG_KEY")
+			}
+
+			var value string
+			if len(args) > 0 {
+				value = args[0]
+			} else {
+				value, err = bufio.NewReader(os.Stdin).ReadString('\n')
+				if err != nil && value == "" {
+					return errors.New("value must be given as argument or on standard input")
+				}
+				value = strings.TrimRight(value, "\r\n")
+			}
+
+			encrypted, err := configfile.Encrypt(key, value)
+			if err != nil {
+				return err
+			}
+			fmt.Println(encrypted)
+			return nil
+		},
+	}
+	configGenKeyCmd = &cobra.Command{
+		Use:   "genkey",
+		Short: "Generate key for encrypting config values",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := configfile.GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Println(key)
+			return nil
+		},
+	}
+)
+
+func init() {
+	configCmd.AddCommand(configEncryptCmd)
+	configCmd.AddCommand(configGenKeyCmd)
+}
+
+// configKey returns key encrypted config values are decrypted with, read
+// from --config-keyfile flag, file in ABSTRUSE_CONFIG_KEYFILE or base64
+// encoded key in ABSTRUSE_CONFIG_KEY environment variable. Nil key is
+// returned when none is set.
+func configKey() ([]byte, error) {
+	if cfgKeyFile == "" {
+		cfgKeyFile = os.Getenv("ABSTRUSE_CONFIG_KEYFILE")
+	}
+	if cfgKeyFile != "" {
+		return configfile.ReadKey(cfgKeyFile)
+	}
+	if env := os.Getenv("ABSTRUSE_CONFIG_KEY"); env != "" {
+		return configfile.ParseKey(env)
+	}
+	return nil, nil
+}
+
+// encryptSecrets returns settings with secret values encrypted with key,
+// so generated config file does not hold plaintext secrets. Settings are
+// returned unchanged when key is not set.
+func encryptSecrets(settings map[string]interface{}, key []byte) (map[string]interface{}, error) {
+	if key == nil {
+		return settings, nil
+	}
+
+	var walk func(prefix string, m map[string]interface{}) error
+	walk = func(prefix string, m map[string]interface{}) error {
+		for k, v := range m {
+			switch value := v.(type) {
+			case map[string]interface{}:
+				if err := walk(prefix+k+".", value); err != nil {
+					return err
+				}
+			case string:
+				if !config.IsSecret(prefix+k) || value == "" || configfile.IsEncrypted(value) {
+					continue
+				}
+				if _, _, ok := config.VaultRef(value); ok {
+					continue
+				}
+				encrypted, err := configfile.Encrypt(key, value)
+				if err != nil {
+					return err
+				}
+				m[k] = encrypted
+			}
+		}
+		return nil
+	}
+	return settings, walk("", settings)
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/pkg/configfile"
+	"github.com/bleenco/abstruse/pkg/fs"
+	"github.com/bleenco/abstruse/pkg/tlsutil"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/store"
+	"github.com/bleenco/abstruse/server/store/migrate"
+	"github.com/bleenco/abstruse/server/store/user"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// dbPorts are default ports of database drivers offered by init.
+var dbPorts = map[string]int{
+	"mysql":    3306,
+	"postgres": 5432,
+	"mssql":    1433,
+}
+
+var (
+	initForce bool
+	initCmd   = &cobra.Command{
+		Use:   "init",
+		Short: "Create config file interactively",
+		Long: `Create config file interactively.
+
+Prompts for database connection, initial admin user, HTTP listen address
+and TLS settings, tests the database connection, generates self-signed
+certificate when certificate files do not exist and writes config file.
+Answers default to values of flags, environment variables and existing
+config file. Admin user is created when database connection succeeds
+and no users exist.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInit(newPrompter(os.Stdin, os.Stdout))
+		},
+	}
+)
+
+func init() {
+	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite existing config file")
+}
+
+func runInit(p *prompter) error {
+	files, err := configFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) > 1 {
+		return errors.New("init writes single config file, multiple config files are set")
+	}
+	path := files[0]
+	if fs.Exists(path) && !initForce {
+		return fmt.Errorf("config file %s already exists, use --force to overwrite it", path)
+	}
+	key, err := configKey()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Creating config file %s\n\n", path)
+
+	var (
+		cfg *config.Config
+		db  *gorm.DB
+	)
+	for {
+		driver := p.choose("Database driver", viper.GetString("db.driver"), []string{"mysql", "postgres", "mssql"})
+		port := viper.GetInt("db.port")
+		if driver != viper.GetString("db.driver") {
+			port = dbPorts[driver]
+		}
+		viper.Set("db.driver", driver)
+		viper.Set("db.host", p.ask("Database host", viper.GetString("db.host")))
+		viper.Set("db.port", p.askInt("Database port", port))
+		viper.Set("db.name", p.ask("Database name", viper.GetString("db.name")))
+		viper.Set("db.user", p.ask("Database user", viper.GetString("db.user")))
+		viper.Set("db.password", p.password("Database password", viper.GetString("db.password")))
+		if p.err != nil {
+			return p.err
+		}
+
+		if cfg, err = resolveConfig(false); err != nil {
+			return err
+		}
+		if db, err = store.Open(cfg.DB); err == nil {
+			fmt.Fprintf(p.out, "Database connection succeeded.\n\n")
+			break
+		}
+		if db != nil {
+			db.Close()
+			db = nil
+		}
+		fmt.Fprintf(p.out, "Database connection failed: %v\n", err)
+		if !p.confirm("Try again", true) {
+			fmt.Fprintf(p.out, "Database settings are written as entered, admin user is not created.\n\n")
+			break
+		}
+	}
+	if db != nil {
+		defer db.Close()
+	}
+
+	var email, name, pass string
+	if db != nil {
+		for p.err == nil && !govalidator.IsEmail(email) {
+			email = p.ask("Admin email", os.Getenv(envAdminEmail))
+		}
+		name = p.ask("Admin name", "Administrator")
+		for p.err == nil && (len(pass) < 8 || len(pass) > 50) {
+			pass = p.password("Admin password (8 to 50 characters)", "")
+		}
+		fmt.Fprintln(p.out)
+	}
+
+	viper.Set("http.addr", p.ask("HTTP listen address", viper.GetString("http.addr")))
+	viper.Set("http.baseurl", p.ask("Public URL of server (empty to derive it from requests)", viper.GetString("http.baseurl")))
+	viper.Set("http.tls", p.confirm("Serve HTTP over TLS", viper.GetBool("http.tls")))
+	viper.Set("tls.cert", p.ask("TLS certificate file", viper.GetString("tls.cert")))
+	viper.Set("tls.key", p.ask("TLS key file", viper.GetString("tls.key")))
+	fmt.Fprintln(p.out)
+	if p.err != nil {
+		return p.err
+	}
+
+	if cfg, err = resolveConfig(false); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if !fs.Exists(cfg.TLS.Cert) || !fs.Exists(cfg.TLS.Key) {
+		if err := tlsutil.CheckAndGenerateCert(cfg.TLS.Cert, cfg.TLS.Key); err != nil {
+			return err
+		}
+		fmt.Fprintf(p.out, "Generated self-signed certificate %s and key %s\n", cfg.TLS.Cert, cfg.TLS.Key)
+	}
+
+	settings, err := encryptSecrets(viper.AllSettings(), key)
+	if err != nil {
+		return err
+	}
+	if err := configfile.Write(path, settings); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Wrote config file %s\n", path)
+
+	if db == nil {
+		return nil
+	}
+	if cfg.DB.AutoMigrate {
+		if _, err := migrate.Up(db); err != nil {
+			return err
+		}
+	}
+	if a := cfg.Auth.Argon2; a != nil {
+		auth.SetArgon2Params(a.Memory, a.Iterations, a.Parallelism)
+	}
+	users := user.New(db)
+	list, err := users.List()
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		fmt.Fprintf(p.out, "Users already exist, skipped creating admin user %s\n", email)
+		return nil
+	}
+	if err := createAdmin(users, email, name, pass); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "Created admin user %s\n", email)
+	return nil
+}
+
+// prompter asks questions on terminal and reads answers.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int // file descriptor of terminal, -1 when input is not terminal
+	err error
+}
+
+// errInputEnded is returned when input ends before all questions are
+// answered.
+var errInputEnded = errors.New("input ended before all questions were answered")
+
+func newPrompter(in *os.File, out io.Writer) *prompter {
+	fd := int(in.Fd())
+	if !terminal.IsTerminal(fd) {
+		fd = -1
+	}
+	return &prompter{in: bufio.NewReader(in), out: out, fd: fd}
+}
+
+// ask returns answer to question or def when answer is empty or input
+// has ended.
+func (p *prompter) ask(label, def string) string {
+	if p.err != nil {
+		return def
+	}
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out)
+		p.err = errInputEnded
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+func (p *prompter) askInt(label string, def int) int {
+	for {
+		answer := p.ask(label, strconv.Itoa(def))
+		if n, err := strconv.Atoi(answer); err == nil || p.err != nil {
+			return n
+		}
+		fmt.Fprintf(p.out, "%s is not a number\n", answer)
+	}
+}
+
+func (p *prompter) choose(label, def string, options []string) string {
+	for {
+		answer := p.ask(fmt.Sprintf("%s (%s)", label, strings.Join(options, ", ")), def)
+		for _, o := range options {
+			if answer == o || p.err != nil {
+				return answer
+			}
+		}
+		fmt.Fprintf(p.out, "%s is not one of %s\n", answer, strings.Join(options, ", "))
+	}
+}
+
+func (p *prompter) confirm(label string, def bool) bool {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	for {
+		answer := strings.ToLower(p.ask(fmt.Sprintf("%s (%s)", label, choices), ""))
+		if p.err != nil {
+			return def
+		}
+		switch answer {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// password reads answer without echoing it when input is terminal,
+// def is kept when answer is empty.
+func (p *prompter) password(label, def string) string {
+	if p.fd < 0 || p.err != nil {
+		if answer := p.ask(label, ""); answer != "" {
+			return answer
+		}
+		return def
+	}
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [keep current]: ", label)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+	answer, _ := terminal.ReadPassword(p.fd)
+	fmt.Fprintln(p.out)
+	if len(answer) > 0 {
+		return string(answer)
+	}
+	return def
+}
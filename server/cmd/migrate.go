@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/logger"
+	"github.com/bleenco/abstruse/server/store"
+	"github.com/bleenco/abstruse/server/store/migrate"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateSteps int
+	migrateCmd   = &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+	migrateUpCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openMigrateDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			applied, err := migrate.Up(db)
+			for _, m := range applied {
+				fmt.Printf("applied %d %s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(applied) == 0 {
+				fmt.Println("database schema is up to date")
+			}
+			return nil
+		},
+	}
+	migrateDownCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Roll back last applied migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openMigrateDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			rolledBack, err := migrate.Down(db, migrateSteps)
+			for _, m := range rolledBack {
+				fmt.Printf("rolled back %d %s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(rolledBack) == 0 {
+				fmt.Println("no migrations to roll back")
+			}
+			return nil
+		},
+	}
+	migrateStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print database schema version and migrations status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openMigrateDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			version, err := migrate.Version(db)
+			if err != nil {
+				return err
+			}
+			list, err := migrate.List(db)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("schema version: %d (supported: %d)\n\n", version, migrate.Latest())
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, m := range list {
+				applied := "pending"
+				if m.AppliedAt != nil {
+					applied = m.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+			}
+			return w.Flush()
+		},
+	}
+)
+
+func init() {
+	migrateDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "number of migrations to roll back")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+}
+
+// openMigrateDB opens database without schema check, so migrations can
+// be applied and reverted.
+func openMigrateDB() (*gorm.DB, error) {
+	cfg, err := newConfig()
+	if err != nil {
+		return nil, err
+	}
+	return store.Open(cfg.DB)
+}
+
+// openDB opens database for commands using stores, schema is checked
+// the same way as on server startup.
+func openDB(cfg *config.Config) (*gorm.DB, error) {
+	log, err := logger.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenChecked(cfg.DB, log)
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/logger"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadable are prefixes of config keys applied on reload, changes
+// of other keys take effect after restart.
+var reloadable = []string{"logger.", "auth.argon2.", "auth.jwtexpiry", "http.compress", "http.cors"}
+
+// watchDelay is time config files must stay unchanged before they are
+// reloaded, so files written in several steps are read once complete.
+const watchDelay = 500 * time.Millisecond
+
+// reloadOnSignal reloads config each time SIGHUP is received, values
+// read from Vault change before their lease expires or, when enabled,
+// config file changes. Triggers received during reload are coalesced
+// into single reload.
+func (a *app) reloadOnSignal() {
+	sigch := make(chan os.Signal, 1)
+	signal.Notify(sigch, syscall.SIGHUP)
+
+	changed := make(chan struct{}, 1)
+	if configWatch() {
+		if err := watchConfig(changed); err != nil {
+			a.logger.Sugar().Errorf("error watching config files, reload with SIGHUP: %v", err)
+		}
+		if err := watchRemoteConfig(changed); err != nil {
+			a.logger.Sugar().Errorf("error watching remote config, reload with SIGHUP: %v", err)
+		}
+	}
+	watchVault(changed)
+
+	for {
+		select {
+		case <-sigch:
+		case <-changed:
+		}
+		a.reload()
+	}
+}
+
+// watchConfig watches directories of config files and notifies changed
+// once changed files stay unchanged for watchDelay. Directories
+// are watched so files replaced by editors or Kubernetes config map
+// updates are noticed.
+func watchConfig(changed chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, file := range cfgLoaded {
+		names[filepath.Base(file)] = true
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+		timer := time.NewTimer(watchDelay)
+		timer.Stop()
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				base := filepath.Base(ev.Name)
+				if ev.Op == fsnotify.Chmod || !(names[base] || strings.HasPrefix(base, "..")) {
+					continue
+				}
+				timer.Reset(watchDelay)
+			case <-watcher.Errors:
+			case <-timer.C:
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// configWatch returns true when reloading config on file changes is
+// enabled by --watch-config flag or ABSTRUSE_WATCH_CONFIG environment
+// variable.
+func configWatch() bool {
+	if !cfgWatch {
+		cfgWatch, _ = strconv.ParseBool(os.Getenv("ABSTRUSE_WATCH_CONFIG"))
+	}
+	return cfgWatch
+}
+
+// reload resolves config again and applies logger and auth changes,
+// invalid config is reported and previous config is kept.
+func (a *app) reload() {
+	log := a.logger.With(zap.String("type", "config")).Sugar()
+	log.Infof("reloading config")
+
+	cfg, err := resolveConfig(false)
+	if err != nil {
+		log.Errorf("error reloading config, keeping previous config: %v", err)
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		var verr config.ValidationError
+		if errors.As(err, &verr) {
+			for _, e := range verr {
+				log.Errorf("invalid config: %s", e)
+			}
+		}
+		log.Errorf("config not reloaded, keeping previous config")
+		return
+	}
+
+	changed, err := config.Diff(a.config, cfg)
+	if err != nil {
+		log.Errorf("error comparing configs, keeping previous config: %v", err)
+		return
+	}
+	if len(changed) == 0 {
+		log.Infof("config reloaded, nothing changed")
+		return
+	}
+
+	if err := logger.Reload(cfg); err != nil {
+		log.Errorf("error reloading logger, keeping previous config: %v", err)
+		return
+	}
+	if p := cfg.Auth.Argon2; p != nil {
+		auth.SetArgon2Params(p.Memory, p.Iterations, p.Parallelism)
+	}
+	auth.SetJWTExpiry(cfg.Auth.JWTExpiry)
+	a.http.Reload(cfg.HTTP)
+	a.config = cfg
+	a.audit.Record(core.AuditEvent{
+		Action:  core.AuditConfigReload,
+		Target:  strings.Join(cfgFiles, ","),
+		Details: strings.Join(changed, ", "),
+	})
+
+	var applied, restart []string
+	for _, key := range changed {
+		if isReloadable(key) {
+			applied = append(applied, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	if len(applied) > 0 {
+		log.Infof("config reloaded, applied changes: %s", strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		log.Warnf("config changes take effect after restart: %s", strings.Join(restart, ", "))
+	}
+}
+
+func isReloadable(key string) bool {
+	for _, prefix := range reloadable {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/configfile"
+	"github.com/spf13/viper"
+)
+
+const (
+	// remoteInterval is how often remote config is checked for changes
+	// when config is watched.
+	remoteInterval = 10 * time.Second
+
+	// remoteTimeout is timeout of requests to etcd or Consul.
+	remoteTimeout = 10 * time.Second
+)
+
+var (
+	cfgRemote       string
+	cfgRemoteLoaded *viper.Viper // remote config merged by last load
+)
+
+// remoteConfig is config stored under key of etcd or Consul key/value
+// store, given as URL, e.g. etcd://10.0.0.1:2379/abstruse/config.yml.
+type remoteConfig struct {
+	URL      string
+	Provider string
+	Endpoint string
+	Key      string
+}
+
+// configRemote returns remote config set with --config-remote flag or
+// ABSTRUSE_CONFIG_REMOTE environment variable, nil when none is set.
+func configRemote() (*remoteConfig, error) {
+	if cfgRemote == "" {
+		cfgRemote = os.Getenv("ABSTRUSE_CONFIG_REMOTE")
+	}
+	if cfgRemote == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(cfgRemote)
+	if err != nil {
+		return nil, fmt.Errorf("remote config %s: %v", cfgRemote, err)
+	}
+	if u.Host == "" || u.Path == "" || u.Path == "/" {
+		return nil, fmt.Errorf("remote config %s: expected provider://host:port/key", cfgRemote)
+	}
+	rc := &remoteConfig{URL: cfgRemote, Key: u.Path}
+	switch u.Scheme {
+	case "etcd":
+		rc.Provider, rc.Endpoint = "etcd", "http://"+u.Host
+	case "etcds":
+		rc.Provider, rc.Endpoint = "etcd", "https://"+u.Host
+	case "consul":
+		rc.Provider, rc.Endpoint = "consul", "http://"+u.Host
+	case "consuls":
+		rc.Provider, rc.Endpoint = "consul", "https://"+u.Host
+	default:
+		return nil, fmt.Errorf("remote config %s: unsupported provider %s, use etcd, etcds, consul or consuls", cfgRemote, u.Scheme)
+	}
+	return rc, nil
+}
+
+// read reads remote config, format is detected from extension of the
+// key and defaults to JSON.
+func (rc *remoteConfig) read() (*viper.Viper, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch rc.Provider {
+	case "etcd":
+		data, err = rc.readEtcd()
+	case "consul":
+		data, err = rc.readConsul()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote config %s cannot be read: %v", rc.URL, err)
+	}
+
+	v := viper.New()
+	v.SetConfigType(configfile.Format(rc.Key))
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("remote config %s is not valid %s: %v", rc.URL, strings.ToUpper(configfile.Format(rc.Key)), err)
+	}
+	return v, nil
+}
+
+// readEtcd reads value of the key with etcd v3 JSON API.
+func (rc *remoteConfig) readEtcd() ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(rc.Key))})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, rc.Endpoint+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := remoteDo(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("key %s does not exist", rc.Key)
+	}
+	return base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+}
+
+// readConsul reads raw value of the key with Consul KV API, token is
+// read from CONSUL_HTTP_TOKEN environment variable.
+func (rc *remoteConfig) readConsul() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rc.Endpoint+"/v1/kv/"+strings.TrimPrefix(rc.Key, "/")+"?raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	return remoteDo(req)
+}
+
+// remoteDo sends request to etcd or Consul and returns response body.
+func remoteDo(req *http.Request) ([]byte, error) {
+	client := &http.Client{Timeout: remoteTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("key does not exist")
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d: %s", res.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// mergeRemoteConfig merges remote config over config files.
+func mergeRemoteConfig() error {
+	cfgRemoteLoaded = nil
+	rc, err := configRemote()
+	if err != nil || rc == nil {
+		return err
+	}
+	v, err := rc.read()
+	if err != nil {
+		return err
+	}
+	if err := viper.MergeConfigMap(v.AllSettings()); err != nil {
+		return fmt.Errorf("remote config %s: %v", rc.URL, err)
+	}
+	cfgRemoteLoaded = v
+	return nil
+}
+
+// watchRemoteConfig checks remote config every remoteInterval and
+// notifies changed when its values differ from last loaded ones.
+// Errors reading remote config are left to reload to report.
+func watchRemoteConfig(changed chan<- struct{}) error {
+	rc, err := configRemote()
+	if err != nil || rc == nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(remoteInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			v, err := rc.read()
+			if err != nil {
+				continue
+			}
+			if last := cfgRemoteLoaded; last != nil && reflect.DeepEqual(last.AllSettings(), v.AllSettings()) {
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/logger"
+	"github.com/bleenco/abstruse/server/store/user"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// Environment variables initial admin user is created from.
+const (
+	envAdminEmail    = "ABSTRUSE_ADMIN_EMAIL"
+	envAdminPassword = "ABSTRUSE_ADMIN_PASSWORD"
+	envAdminName     = "ABSTRUSE_ADMIN_NAME"
+)
+
+var seedAdminCmd = &cobra.Command{
+	Use:   "seed-admin",
+	Short: fmt.Sprintf("Create initial admin user from %s and %s environment variables if no users exist", envAdminEmail, envAdminPassword),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := newConfig()
+		if err != nil {
+			return err
+		}
+		db, err := openDB(cfg)
+		if err != nil {
+			return err
+		}
+		log, err := logger.New(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		return seedAdmin(user.New(db), log)
+	},
+}
+
+// seedAdmin creates initial admin user from environment variables when no
+// users exist. It does nothing when variables are not set or users exist.
+func seedAdmin(users core.UserStore, logger *zap.Logger) error {
+	log := logger.With(zap.String("type", "seed")).Sugar()
+	email, password := os.Getenv(envAdminEmail), os.Getenv(envAdminPassword)
+	if email == "" && password == "" {
+		return nil
+	}
+	if !govalidator.IsEmail(email) {
+		return fmt.Errorf("%s is not valid email address", envAdminEmail)
+	}
+	if len(password) < 8 || len(password) > 50 {
+		return fmt.Errorf("%s must be between 8 and 50 characters long", envAdminPassword)
+	}
+
+	list, err := users.List()
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		log.Infof("users already exist, skipped creating initial admin user %s", email)
+		return nil
+	}
+
+	if err := createAdmin(users, email, os.Getenv(envAdminName), password); err != nil {
+		return err
+	}
+	log.Infof("created initial admin user %s", email)
+	return nil
+}
+
+// createAdmin creates active admin user, name defaults to Administrator.
+func createAdmin(users core.UserStore, email, name, password string) error {
+	if name == "" {
+		name = "Administrator"
+	}
+	admin := &core.User{
+		Email:    email,
+		Name:     name,
+		Avatar:   "/assets/images/avatars/avatar_1.svg",
+		Password: password, // hashed by the store
+		Role:     core.RoleAdmin,
+		Active:   true,
+	}
+	return users.Create(admin)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/service/secret"
+)
+
+const (
+	// vaultTimeout is maximum time to read all config values from Vault.
+	vaultTimeout = 30 * time.Second
+
+	// vaultRetry is time to wait before values are read again after
+	// config reload was triggered by changed or unreadable values.
+	vaultRetry = 30 * time.Second
+
+	// vaultIdle is how often values are checked for renewal, so leases
+	// of values loaded by reload are noticed.
+	vaultIdle = time.Minute
+)
+
+// vaultValues are config values read from Vault by last config load.
+type vaultValues struct {
+	provider core.SecretProvider
+	refs     map[string]string // Vault references by config keys
+	values   map[string]string
+	lease    time.Duration // shortest lease of the values, 0 when none has lease
+	readAt   time.Time
+}
+
+var (
+	vaultMu     sync.Mutex
+	vaultLoaded *vaultValues
+)
+
+// resolveVaultValues replaces config values referring to Vault secrets
+// with the secrets, each secret is read again when config is reloaded.
+func resolveVaultValues(cfg *config.Config) error {
+	values := cfg.VaultValues()
+	refs := make(map[string]string)
+	var keys []string
+	for key, value := range values {
+		if _, _, ok := config.VaultRef(*value); ok {
+			keys = append(keys, key)
+			refs[key] = *value
+		}
+	}
+	if len(keys) == 0 {
+		setVaultLoaded(nil)
+		return nil
+	}
+	sort.Strings(keys)
+
+	if cfg.Vault == nil || cfg.Vault.Addr == "" {
+		return fmt.Errorf("%s refers to Vault but vault.addr is not set", keys[0])
+	}
+	for _, key := range keys {
+		if path, name, _ := config.VaultRef(refs[key]); path == "" || name == "" {
+			return fmt.Errorf("%s Vault reference must be in vault:path#key form", key)
+		}
+	}
+	transport, err := cfg.Transport()
+	if err != nil {
+		return err
+	}
+
+	loaded := &vaultValues{provider: secret.NewVault(cfg.Vault, transport), refs: refs}
+	if err := loaded.read(); err != nil {
+		return err
+	}
+	for key, value := range loaded.values {
+		*values[key] = value
+	}
+	setVaultLoaded(loaded)
+	return nil
+}
+
+// read reads values of all references and their shortest lease.
+func (v *vaultValues) read() error {
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+
+	values := make(map[string]string)
+	var lease time.Duration
+	for key, ref := range v.refs {
+		path, name, _ := config.VaultRef(ref)
+		var (
+			value string
+			l     time.Duration
+			err   error
+		)
+		if f, ok := v.provider.(secret.LeaseFetcher); ok {
+			value, l, err = f.FetchLease(ctx, path, name)
+		} else {
+			value, err = v.provider.Fetch(ctx, path, name)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s from Vault: %v", key, err)
+		}
+		values[key] = value
+		if l > 0 && (lease == 0 || l < lease) {
+			lease = l
+		}
+	}
+	v.values, v.lease, v.readAt = values, lease, time.Now()
+	return nil
+}
+
+func setVaultLoaded(v *vaultValues) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	vaultLoaded = v
+}
+
+// vaultRenewal returns loaded values and time they must be read again,
+// zero time when they have no lease.
+func vaultRenewal() (*vaultValues, time.Time) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vaultLoaded == nil || vaultLoaded.lease <= 0 {
+		return vaultLoaded, time.Time{}
+	}
+	return vaultLoaded, vaultLoaded.readAt.Add(vaultLoaded.lease * 2 / 3)
+}
+
+// watchVault reads config values from Vault again when two thirds of
+// their lease passed and notifies changed when values differ or cannot
+// be read, so config is reloaded before leases expire. Values without
+// lease are read only when config is reloaded.
+func watchVault(changed chan<- struct{}) {
+	go func() {
+		for {
+			loaded, due := vaultRenewal()
+			if due.IsZero() {
+				time.Sleep(vaultIdle)
+				continue
+			}
+			if wait := time.Until(due); wait > 0 {
+				if wait > vaultIdle {
+					wait = vaultIdle
+				}
+				time.Sleep(wait)
+				continue
+			}
+
+			current := &vaultValues{provider: loaded.provider, refs: loaded.refs}
+			err := current.read()
+			if err == nil && reflect.DeepEqual(current.values, loaded.values) {
+				vaultMu.Lock()
+				loaded.readAt, loaded.lease = current.readAt, current.lease
+				vaultMu.Unlock()
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+			time.Sleep(vaultRetry)
+		}
+	}()
+}
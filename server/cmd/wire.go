@@ -4,17 +4,31 @@ package cmd
 
 import (
 	"github.com/bleenco/abstruse/server/api"
+	"github.com/bleenco/abstruse/server/control"
 	"github.com/bleenco/abstruse/server/http"
 	"github.com/bleenco/abstruse/server/logger"
 	"github.com/bleenco/abstruse/server/scheduler"
+	"github.com/bleenco/abstruse/server/service/audit"
+	"github.com/bleenco/abstruse/server/service/cron"
+	"github.com/bleenco/abstruse/server/service/notify"
+	"github.com/bleenco/abstruse/server/service/oidc"
+	"github.com/bleenco/abstruse/server/service/retention"
+	"github.com/bleenco/abstruse/server/service/secret"
 	"github.com/bleenco/abstruse/server/service/stats"
+	"github.com/bleenco/abstruse/server/service/status"
 	"github.com/bleenco/abstruse/server/store"
+	"github.com/bleenco/abstruse/server/store/apikey"
+	auditstore "github.com/bleenco/abstruse/server/store/audit"
 	"github.com/bleenco/abstruse/server/store/build"
+	cronstore "github.com/bleenco/abstruse/server/store/cron"
+	"github.com/bleenco/abstruse/server/store/delivery"
 	"github.com/bleenco/abstruse/server/store/envvariable"
 	"github.com/bleenco/abstruse/server/store/job"
+	"github.com/bleenco/abstruse/server/store/login"
 	"github.com/bleenco/abstruse/server/store/permission"
 	"github.com/bleenco/abstruse/server/store/provider"
 	"github.com/bleenco/abstruse/server/store/repo"
+	"github.com/bleenco/abstruse/server/store/skipped"
 	"github.com/bleenco/abstruse/server/store/team"
 	"github.com/bleenco/abstruse/server/store/user"
 	"github.com/bleenco/abstruse/server/worker"
@@ -34,12 +48,26 @@ func CreateApp() (*app, error) {
 		wire.NewSet(job.New),
 		wire.NewSet(repo.New),
 		wire.NewSet(envvariable.New),
+		wire.NewSet(apikey.New),
+		wire.NewSet(cronstore.New),
+		wire.NewSet(login.New),
+		wire.NewSet(auditstore.New),
+		wire.NewSet(skipped.New),
+		wire.NewSet(delivery.New),
 		wire.NewSet(worker.NewRegistry),
 		wire.NewSet(http.New),
+		wire.NewSet(control.New),
 		wire.NewSet(logger.New),
 		wire.NewSet(ws.New),
 		wire.NewSet(scheduler.New),
 		wire.NewSet(stats.New),
+		wire.NewSet(notify.New),
+		wire.NewSet(status.New),
+		wire.NewSet(cron.New),
+		wire.NewSet(retention.New),
+		wire.NewSet(audit.New),
+		wire.NewSet(secret.New),
+		wire.NewSet(oidc.New),
 		wire.NewSet(newApp, newConfig),
 	)))
 }
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Check checks config against the host it runs on, that certificates
+// are readable and directories server writes to are writable. Unlike
+// Validate it touches the filesystem, so it is run only on request.
+func (c *Config) Check() error {
+	var errs ValidationError
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	writable := func(key, path string) {
+		if err := writableDir(path); err != nil {
+			add("%s %s is not writable: %v", key, path, err)
+		}
+	}
+
+	if c.DataDir != "" {
+		writable("datadir", c.DataDir)
+	}
+
+	if c.TLS != nil && c.TLS.Cert != "" && c.TLS.Key != "" {
+		_, certErr := os.Stat(c.TLS.Cert)
+		_, keyErr := os.Stat(c.TLS.Key)
+		if certErr == nil && keyErr == nil {
+			if _, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key); err != nil {
+				add("tls.cert %s and tls.key %s cannot be loaded: %v", c.TLS.Cert, c.TLS.Key, err)
+			}
+		} else {
+			// missing certificate and key are generated on startup
+			writable("tls.cert", filepath.Dir(c.TLS.Cert))
+			writable("tls.key", filepath.Dir(c.TLS.Key))
+		}
+	}
+
+	if c.HTTP != nil && c.HTTP.UploadDir != "" {
+		writable("http.uploaddir", c.HTTP.UploadDir)
+	}
+	if c.Logger != nil && c.Logger.Filename != "" {
+		writable("logger.filename", filepath.Dir(c.Logger.Filename))
+	}
+	if c.Audit != nil && c.Audit.Filename != "" {
+		writable("audit.filename", filepath.Dir(c.Audit.Filename))
+	}
+
+	if c.DB != nil {
+		switch strings.ToLower(c.DB.Driver) {
+		case "sqlite", "sqlite3":
+			writable("db.name", filepath.Dir(c.DB.Name))
+		default:
+			if c.DB.Host == "" {
+				add("db.host must not be empty")
+			}
+			if c.DB.Name == "" {
+				add("db.name must not be empty")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// writableDir checks files can be created in directory, directories
+// which do not exist yet are created on startup, so their nearest
+// existing parent must be writable.
+func writableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return errors.New("not a directory")
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	file, err := ioutil.TempFile(dir, ".abstruse-check-")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -1,14 +1,52 @@
 package config
 
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/imagepolicy"
+	"github.com/bleenco/abstruse/pkg/proxy"
+	"github.com/bleenco/abstruse/pkg/tlsutil"
+)
+
+// Queue policies applied when build queue is full.
+const (
+	QueueReject    = "reject"    // all new builds are rejected
+	QueueAutomated = "automated" // only webhook and cron builds are dropped
+)
+
+// Vault auth methods.
+const (
+	VaultAuthToken   = "token"
+	VaultAuthAppRole = "approle"
+)
+
 type (
 	// Config holds configuration data,
 	Config struct {
+		DataDir   string     `json:"datadir"` // root of relative paths, defaults to config file directory
 		DB        *DB        `json:"db"`
 		HTTP      *HTTP      `json:"http"`
 		TLS       *TLS       `json:"tls"`
 		Logger    *Logger    `json:"logger"`
+		Audit     *Audit     `json:"audit"`
 		Auth      *Auth      `json:"auth"`
 		Websocket *WebSocket `json:"websocket"`
+		SMTP      *SMTP      `json:"smtp"`
+		RateLimit *RateLimit `json:"ratelimit"`
+		Scheduler *Scheduler `json:"scheduler"`
+		Images    *Images    `json:"images"`
+		GitLab    *GitLab    `json:"gitlab"`
+		GRPC      *GRPC      `json:"grpc"`
+		Proxy     *Proxy     `json:"proxy"`
+		ClientTLS *ClientTLS `json:"clienttls"`
+		Display   *Display   `json:"display"`
+		Vault     *Vault     `json:"vault"`
+		Webhooks  *Webhooks  `json:"webhooks"`
 	}
 
 	// DB database config.
@@ -20,14 +58,49 @@ type (
 		Password string `json:"password" valid:"ascii,optional"`
 		Port     int    `json:"port" valid:"port,required"`
 		User     string `json:"user" valid:"ascii,required"`
+
+		AutoMigrate bool `json:"automigrate"`
+
+		LogQueries         bool          `json:"logqueries"`         // log SQL at debug level
+		SlowQueryThreshold time.Duration `json:"slowquerythreshold"` // 0 disables slow query warnings
+
+		Replica DBReplica `json:"replica"` // read-only queries tolerating stale data
+	}
+
+	// DBReplica read replica config, replica is used when host is set.
+	// Empty values default to values of the primary database.
+	DBReplica struct {
+		Host     string `json:"host" valid:"host,optional"`
+		Port     int    `json:"port" valid:"port,optional"`
+		Name     string `json:"name" valid:"ascii,optional"`
+		User     string `json:"user" valid:"ascii,optional"`
+		Password string `json:"password" valid:"ascii,optional"`
 	}
 
 	// HTTP server config.
 	HTTP struct {
 		Addr      string `json:"addr" valid:"host,required"`
+		BaseURL   string `json:"baseurl"` // external URL, e.g. https://ci.example.com/abstruse/
 		TLS       bool   `json:"tls"`
 		UploadDir string `json:"uploadDir"`
 		Compress  bool   `json:"compress"`
+		CORS      *CORS  `json:"cors"`
+
+		// TrustedProxies are addresses or CIDR ranges of reverse proxies
+		// client address is read from X-Forwarded-For and X-Real-IP of.
+		TrustedProxies []string `json:"trustedproxies"`
+
+		ReadTimeout       time.Duration `json:"readtimeout"`
+		WriteTimeout      time.Duration `json:"writetimeout"` // not applied to streaming endpoints
+		IdleTimeout       time.Duration `json:"idletimeout"`
+		ReadHeaderTimeout time.Duration `json:"readheadertimeout"`
+	}
+
+	// CORS config.
+	CORS struct {
+		AllowedOrigins   []string `json:"allowedorigins"`
+		AllowedMethods   []string `json:"allowedmethods"`
+		AllowCredentials bool     `json:"allowcredentials"`
 	}
 
 	// TLS config.
@@ -44,15 +117,233 @@ type (
 		MaxAge     int    `json:"maxage"`
 		Level      string `json:"level"`
 		Stdout     bool   `json:"stdout"`
+		TimeFormat string `json:"timeformat"` // see lib.TimeEncoder
+	}
+
+	// Audit log config, audit events are appended to file as JSON
+	// lines and optionally stored in database.
+	Audit struct {
+		Filename string `json:"filename"` // disabled when empty
+		DB       bool   `json:"db"`
+	}
+
+	// Display config, times are stored in UTC and converted to time
+	// zone only when presented.
+	Display struct {
+		Timezone string `json:"timezone"`
 	}
 
 	// Auth config.
 	Auth struct {
-		JWTSecret string `json:"jwtSecret"`
+		JWTSecret string        `json:"jwtSecret"`
+		JWTExpiry time.Duration `json:"jwtexpiry"` // lifetime of user access tokens
+		Argon2    *Argon2       `json:"argon2"`
+		Lockout   *Lockout      `json:"lockout"`
+		OIDC      *OIDC         `json:"oidc"`
+	}
+
+	// OIDC single sign-on config, disabled when issuer is empty. Role
+	// of users is mapped from groups in ID token, highest role of
+	// matching groups wins and DefaultRole is used when none matches.
+	OIDC struct {
+		Issuer       string            `json:"issuer"` // discovery is read from issuer/.well-known/openid-configuration
+		Name         string            `json:"name"`   // name of the provider on login page
+		ClientID     string            `json:"clientid"`
+		ClientSecret string            `json:"clientsecret"`
+		RedirectURL  string            `json:"redirecturl"` // defaults to http.baseurl/api/v1/auth/oidc/callback
+		Scopes       []string          `json:"scopes"`
+		GroupsClaim  string            `json:"groupsclaim"`
+		Roles        map[string]string `json:"roles"`       // role by group
+		DefaultRole  string            `json:"defaultrole"` // empty denies users not in any mapped group
+		Provision    bool              `json:"provision"`   // create users on first login
+	}
+
+	// Argon2 password hashing config.
+	Argon2 struct {
+		Memory      uint32 `json:"memory"` // in KiB
+		Iterations  uint32 `json:"iterations"`
+		Parallelism uint8  `json:"parallelism"`
+	}
+
+	// Lockout defines account lockout after repeated failed logins,
+	// 0 attempts disables lockout and login throttling.
+	Lockout struct {
+		Attempts int           `json:"attempts"`
+		Window   time.Duration `json:"window"`
+		Duration time.Duration `json:"duration"`
 	}
 
 	// WebSocket server config.
 	WebSocket struct {
 		Addr string `json:"addr"`
 	}
+
+	// RateLimit defines maximum number of requests per minute
+	// per client for each route group, 0 disables rate limiting.
+	RateLimit struct {
+		Auth     int `json:"auth"`
+		Webhooks int `json:"webhooks"`
+		API      int `json:"api"`
+	}
+
+	// Scheduler config.
+	Scheduler struct {
+		MaxRepoBuilds int `json:"maxrepobuilds"` // 0 for unlimited
+
+		MaxLogSize  int64 `json:"maxlogsize"`  // log bytes of each job, 0 for unlimited
+		LogSizeFail bool  `json:"logsizefail"` // fail job when log is truncated
+
+		PinTimeout time.Duration `json:"pintimeout"` // wait for pinned worker, 0 forever
+
+		Maintenance bool `json:"maintenance"` // start with scheduler paused for maintenance
+
+		MaxQueue    int    `json:"maxqueue"`    // queued jobs, 0 for unlimited
+		QueuePolicy string `json:"queuepolicy"` // reject or automated
+	}
+
+	// Images build image policy config, patterns are described in
+	// imagepolicy package.
+	Images struct {
+		Default string   `json:"default"` // used when build does not specify image
+		Allow   []string `json:"allow"`   // all images allowed when empty
+		Deny    []string `json:"deny"`
+	}
+
+	// Proxy of provider API and notification requests, environment
+	// proxy settings are used for values not set.
+	Proxy = proxy.Config
+
+	// ClientTLS verification of provider API and notification requests,
+	// not used by TLS the server is served with.
+	ClientTLS struct {
+		CAFile             string `json:"cafile"`             // PEM file of CA certificates trusted in addition to system ones
+		CA                 string `json:"ca"`                 // PEM encoded CA certificates
+		InsecureSkipVerify bool   `json:"insecureskipverify"` // disables verification, for development only
+	}
+
+	// Webhooks processing config, verified deliveries are queued and
+	// processed by workers. Workers set to 0 processes deliveries
+	// synchronously in webhook request.
+	Webhooks struct {
+		Workers int `json:"workers"`
+		Queue   int `json:"queue"` // deliveries waiting for workers
+	}
+
+	// GitLab merge request builds config.
+	GitLab struct {
+		SkipDrafts bool `json:"skipdrafts"`
+		MergeRef   bool `json:"mergeref"` // build simulated merge commit instead of merge request head
+	}
+
+	// GRPC worker connections and control API config.
+	GRPC struct {
+		Addr           string     `json:"addr"` // control API listen address, disabled when empty
+		MaxRecvMsgSize int        `json:"maxrecvmsgsize"`
+		MaxSendMsgSize int        `json:"maxsendmsgsize"`
+		Keepalive      *Keepalive `json:"keepalive"`
+	}
+
+	// Keepalive defines gRPC keepalive pings, 0 time disables pings.
+	Keepalive struct {
+		Time                time.Duration `json:"time"`
+		Timeout             time.Duration `json:"timeout"`
+		PermitWithoutStream bool          `json:"permitwithoutstream"`
+	}
+
+	// Vault secret provider config, builds reference secrets of KV
+	// secrets engine mounted at mount. Disabled when address is empty.
+	Vault struct {
+		Addr      string        `json:"addr"`      // e.g. https://vault.example.com:8200
+		Namespace string        `json:"namespace"` // Vault Enterprise namespace
+		Auth      string        `json:"auth"`      // token or approle
+		Token     string        `json:"token"`
+		RoleID    string        `json:"roleid"`
+		SecretID  string        `json:"secretid"`
+		Mount     string        `json:"mount"`     // secret by default
+		KVVersion int           `json:"kvversion"` // 1 or 2 (default)
+		Timeout   time.Duration `json:"timeout"`
+	}
+
+	// SMTP email notifications config.
+	SMTP struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		From     string `json:"from"`
+	}
 )
+
+// Policy returns image policy, all images are allowed when images
+// config is not set.
+func (i *Images) Policy() imagepolicy.Policy {
+	if i == nil {
+		return imagepolicy.Policy{}
+	}
+	return imagepolicy.Policy{Default: i.Default, Allow: i.Allow, Deny: i.Deny}
+}
+
+// Prefix returns path prefix of base URL without trailing slash, empty
+// when server is served from root.
+func (h *HTTP) Prefix() string {
+	if h == nil || h.BaseURL == "" {
+		return ""
+	}
+	u, err := url.Parse(h.BaseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// Proxies returns networks of trusted proxies, single addresses are
+// returned as networks of one address and invalid values are skipped.
+func (h *HTTP) Proxies() []*net.IPNet {
+	if h == nil {
+		return nil
+	}
+	var nets []*net.IPNet
+	for _, p := range h.TrustedProxies {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			nets = append(nets, n)
+		} else if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		}
+	}
+	return nets
+}
+
+// Location returns display time zone, UTC when not set.
+func (d *Display) Location() (*time.Location, error) {
+	if d == nil || d.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(d.Timezone)
+}
+
+// Config returns TLS config of outbound requests, nil when defaults are
+// used.
+func (c *ClientTLS) Config() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return tlsutil.ClientConfig(c.CAFile, c.CA, c.InsecureSkipVerify)
+}
+
+// Transport returns transport of provider API and notification requests
+// with proxy and client TLS settings applied.
+func (c *Config) Transport() (*http.Transport, error) {
+	tlsConfig, err := c.ClientTLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	t := c.Proxy.Transport()
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	return t, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Diff returns sorted keys of config values which differ between
+// configs, keys are lowercased as config keys of flags and environment.
+func Diff(a, b *Config) ([]string, error) {
+	va, err := flatten(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := flatten(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key, value := range va {
+		if other, ok := vb[key]; !ok || !reflect.DeepEqual(value, other) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range vb {
+		if _, ok := va[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// flatten returns config values by dotted key.
+func flatten(c *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for key, value := range m {
+			key = strings.ToLower(prefix + key)
+			if sub, ok := value.(map[string]interface{}); ok {
+				walk(key+".", sub)
+				continue
+			}
+			values[key] = value
+		}
+	}
+	walk("", m)
+	return values, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "********"
+
+// secretKeys are config keys holding secrets.
+var secretKeys = map[string]bool{
+	"db.password":            true,
+	"db.replica.password":    true,
+	"auth.jwtsecret":         true,
+	"auth.oidc.clientsecret": true,
+	"smtp.password":          true,
+	"vault.token":            true,
+	"vault.secretid":         true,
+}
+
+// urlKeys are config keys holding URLs which may contain credentials.
+var urlKeys = map[string]bool{
+	"proxy.http":  true,
+	"proxy.https": true,
+}
+
+// IsSecret reports whether config key holds a secret.
+func IsSecret(key string) bool {
+	return secretKeys[strings.ToLower(key)]
+}
+
+// Redact returns masked value, empty values are left as is.
+func Redact(value string) string {
+	if value == "" {
+		return value
+	}
+	return redactedValue
+}
+
+// IsURL reports whether config key holds URL which may contain credentials.
+func IsURL(key string) bool {
+	return urlKeys[strings.ToLower(key)]
+}
+
+// RedactURL returns URL with user info removed, values without scheme
+// like user:pass@host:port are handled too.
+func RedactURL(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		u.User = nil
+		return u.String()
+	}
+	if i := strings.LastIndex(value, "@"); i >= 0 {
+		return value[i+1:]
+	}
+	return value
+}
+
+// Redacted returns copy of the config with secrets masked so it
+// can be safely printed or logged.
+func (c Config) Redacted() *Config {
+	if c.DB != nil {
+		db := *c.DB
+		db.Password = Redact(db.Password)
+		db.Replica.Password = Redact(db.Replica.Password)
+		c.DB = &db
+	}
+	if c.Auth != nil {
+		auth := *c.Auth
+		auth.JWTSecret = Redact(auth.JWTSecret)
+		if auth.OIDC != nil {
+			oidc := *auth.OIDC
+			oidc.ClientSecret = Redact(oidc.ClientSecret)
+			auth.OIDC = &oidc
+		}
+		c.Auth = &auth
+	}
+	if c.SMTP != nil {
+		smtp := *c.SMTP
+		smtp.Password = Redact(smtp.Password)
+		c.SMTP = &smtp
+	}
+	if c.Vault != nil {
+		vault := *c.Vault
+		vault.Token = Redact(vault.Token)
+		vault.SecretID = Redact(vault.SecretID)
+		c.Vault = &vault
+	}
+	if c.Proxy != nil {
+		proxy := *c.Proxy
+		proxy.HTTP = RedactURL(proxy.HTTP)
+		proxy.HTTPS = RedactURL(proxy.HTTPS)
+		c.Proxy = &proxy
+	}
+	return &c
+}
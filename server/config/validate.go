@@ -0,0 +1,216 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// oidcRoles are roles OIDC groups can be mapped to.
+var oidcRoles = map[string]bool{"admin": true, "maintainer": true, "viewer": true}
+
+// ValidationError lists invalid config values.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e, "; "))
+}
+
+// Validate checks config values which would fail only when used, like
+// unknown database driver or logging level.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	nonNegative := func(key string, d time.Duration) {
+		if d < 0 {
+			add("%s must not be negative", key)
+		}
+	}
+	listenAddr := func(key, addr string) {
+		if strings.HasPrefix(addr, "unix://") {
+			return
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			add("%s %q is not valid host:port address", key, addr)
+			return
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			add("%s port %q is not in range 1-65535", key, port)
+		}
+	}
+
+	if c.DB != nil {
+		switch strings.ToLower(c.DB.Driver) {
+		case "mysql", "mariadb", "postgres", "postgresql", "mssql":
+			if c.DB.Port <= 0 || c.DB.Port > 65535 {
+				add("db.port %d is not valid port", c.DB.Port)
+			}
+		case "sqlite", "sqlite3":
+			if c.DB.Replica.Host != "" {
+				add("db.replica is not supported with %s driver", c.DB.Driver)
+			}
+		default:
+			add("db.driver %q is not supported", c.DB.Driver)
+		}
+		nonNegative("db.slowquerythreshold", c.DB.SlowQueryThreshold)
+		if port := c.DB.Replica.Port; port < 0 || port > 65535 {
+			add("db.replica.port %d is not valid port", port)
+		}
+	}
+
+	if c.HTTP != nil {
+		if c.HTTP.Addr == "" {
+			add("http.addr must not be empty")
+		} else {
+			listenAddr("http.addr", c.HTTP.Addr)
+		}
+		nonNegative("http.readtimeout", c.HTTP.ReadTimeout)
+		nonNegative("http.writetimeout", c.HTTP.WriteTimeout)
+		nonNegative("http.idletimeout", c.HTTP.IdleTimeout)
+		nonNegative("http.readheadertimeout", c.HTTP.ReadHeaderTimeout)
+		for _, p := range c.HTTP.TrustedProxies {
+			if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+				add("http.trustedproxies %q is not valid address or CIDR range", p)
+			}
+		}
+		if c.HTTP.BaseURL != "" {
+			if u, err := url.Parse(c.HTTP.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("http.baseurl %q is not absolute http or https URL", c.HTTP.BaseURL)
+			}
+		}
+	}
+
+	if c.TLS != nil && (c.TLS.Cert == "" || c.TLS.Key == "") {
+		add("tls.cert and tls.key must not be empty")
+	}
+
+	if c.Logger != nil {
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(c.Logger.Level)); err != nil {
+			add("logger.level %q is not valid level", c.Logger.Level)
+		}
+	}
+
+	if _, err := c.Display.Location(); err != nil {
+		add("display.timezone %q is not valid time zone", c.Display.Timezone)
+	}
+
+	if c.Auth != nil {
+		if c.Auth.JWTSecret == "" {
+			add("auth.jwtsecret must not be empty")
+		}
+		nonNegative("auth.jwtexpiry", c.Auth.JWTExpiry)
+		if l := c.Auth.Lockout; l != nil {
+			if l.Attempts < 0 {
+				add("auth.lockout.attempts must not be negative")
+			}
+			nonNegative("auth.lockout.window", l.Window)
+			nonNegative("auth.lockout.duration", l.Duration)
+		}
+		if o := c.Auth.OIDC; o != nil && o.Issuer != "" {
+			if u, err := url.Parse(o.Issuer); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				add("auth.oidc.issuer %q is not valid URL", o.Issuer)
+			}
+			if o.ClientID == "" {
+				add("auth.oidc.clientid must be set when auth.oidc.issuer is set")
+			}
+			if o.RedirectURL == "" && (c.HTTP == nil || c.HTTP.BaseURL == "") {
+				add("auth.oidc.redirecturl or http.baseurl must be set when auth.oidc.issuer is set")
+			}
+			for group, role := range o.Roles {
+				if !oidcRoles[role] {
+					add("auth.oidc.roles role %q of group %q is not valid role (available options: admin, maintainer, viewer)", role, group)
+				}
+			}
+			if o.DefaultRole != "" && !oidcRoles[o.DefaultRole] {
+				add("auth.oidc.defaultrole %q is not valid role (available options: admin, maintainer, viewer)", o.DefaultRole)
+			}
+		}
+	}
+
+	if r := c.RateLimit; r != nil && (r.Auth < 0 || r.Webhooks < 0 || r.API < 0) {
+		add("ratelimit values must not be negative")
+	}
+
+	if wh := c.Webhooks; wh != nil && (wh.Workers < 0 || wh.Queue < 0) {
+		add("webhooks values must not be negative")
+	}
+
+	if sc := c.Scheduler; sc != nil {
+		if sc.MaxRepoBuilds < 0 {
+			add("scheduler.maxrepobuilds must not be negative")
+		}
+		if sc.MaxLogSize < 0 {
+			add("scheduler.maxlogsize must not be negative")
+		}
+		nonNegative("scheduler.pintimeout", sc.PinTimeout)
+		if sc.MaxQueue < 0 {
+			add("scheduler.maxqueue must not be negative")
+		}
+		switch sc.QueuePolicy {
+		case "", QueueReject, QueueAutomated:
+		default:
+			add("scheduler.queuepolicy %q is not valid policy (available options: reject, automated)", sc.QueuePolicy)
+		}
+	}
+
+	if err := c.Images.Policy().Validate(); err != nil {
+		add("images: %v", err)
+	}
+
+	if _, err := c.ClientTLS.Config(); err != nil {
+		add("clienttls: %v", err)
+	}
+
+	if c.Websocket != nil && c.Websocket.Addr != "" {
+		listenAddr("websocket.addr", c.Websocket.Addr)
+	}
+
+	if g := c.GRPC; g != nil {
+		if g.Addr != "" {
+			listenAddr("grpc.addr", g.Addr)
+		}
+		if g.MaxRecvMsgSize < 0 || g.MaxSendMsgSize < 0 {
+			add("grpc message size limits must not be negative")
+		}
+		if ka := g.Keepalive; ka != nil {
+			nonNegative("grpc.keepalive.time", ka.Time)
+			nonNegative("grpc.keepalive.timeout", ka.Timeout)
+		}
+	}
+
+	if v := c.Vault; v != nil && v.Addr != "" {
+		if u, err := url.Parse(v.Addr); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("vault.addr %q is not absolute http or https URL", v.Addr)
+		}
+		switch v.Auth {
+		case "", VaultAuthToken:
+			if v.Token == "" {
+				add("vault.token must not be empty with token auth")
+			}
+		case VaultAuthAppRole:
+			if v.RoleID == "" || v.SecretID == "" {
+				add("vault.roleid and vault.secretid must not be empty with approle auth")
+			}
+		default:
+			add("vault.auth %q is not valid auth method (available options: token, approle)", v.Auth)
+		}
+		if v.KVVersion != 0 && v.KVVersion != 1 && v.KVVersion != 2 {
+			add("vault.kvversion %d is not valid version (available options: 1, 2)", v.KVVersion)
+		}
+		nonNegative("vault.timeout", v.Timeout)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import "strings"
+
+// VaultPrefix is prefix of config values read from Vault on startup,
+// e.g. vault:abstruse/db#password is key password of secret abstruse/db.
+const VaultPrefix = "vault:"
+
+// VaultRef returns path and key of the Vault secret config value refers
+// to, ok is false when value is not Vault reference.
+func VaultRef(value string) (path, key string, ok bool) {
+	if !strings.HasPrefix(value, VaultPrefix) {
+		return "", "", false
+	}
+	ref := strings.TrimPrefix(value, VaultPrefix)
+	i := strings.LastIndex(ref, "#")
+	if i < 0 {
+		return ref, "", true
+	}
+	return ref[:i], ref[i+1:], true
+}
+
+// VaultValues returns config values which can be read from Vault by
+// their config keys. Credentials of Vault itself cannot.
+func (c *Config) VaultValues() map[string]*string {
+	values := make(map[string]*string)
+	if c.DB != nil {
+		values["db.password"] = &c.DB.Password
+		values["db.replica.password"] = &c.DB.Replica.Password
+	}
+	if c.Auth != nil {
+		values["auth.jwtsecret"] = &c.Auth.JWTSecret
+		if c.Auth.OIDC != nil {
+			values["auth.oidc.clientsecret"] = &c.Auth.OIDC.ClientSecret
+		}
+	}
+	if c.SMTP != nil {
+		values["smtp.password"] = &c.SMTP.Password
+	}
+	return values
+}
@@ -0,0 +1,113 @@
+package control
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// writeMethods are control API calls changing server state, API keys
+// need write scope for them.
+var writeMethods = map[string]bool{
+	"/api.Control/DrainWorker": true,
+}
+
+type claimsKey struct{}
+
+func (s *Server) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	scope := core.ScopeRead
+	if writeMethods[info.FullMethod] {
+		scope = core.ScopeWrite
+	}
+	claims, err := s.authenticate(ctx, scope)
+	if err != nil {
+		s.logger.Warnf("control API call %s rejected: %v", info.FullMethod, err)
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+}
+
+func (s *Server) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if _, err := s.authenticate(stream.Context(), core.ScopeRead); err != nil {
+		s.logger.Warnf("control API call %s rejected: %v", info.FullMethod, err)
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// authenticate authenticates caller by API key passed in x-api-key or
+// authorization metadata, or by user JWT access token passed as bearer
+// token in authorization metadata. Only administrators are allowed, API
+// keys need scope.
+func (s *Server) authenticate(ctx context.Context, scope string) (auth.UserClaims, error) {
+	var claims auth.UserClaims
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return claims, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	token := tokenFromMetadata(md)
+	if token == "" {
+		return claims, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	if strings.HasPrefix(token, core.APIKeyPrefix) {
+		key, err := s.keys.FindKey(token)
+		if err != nil || key.User == nil || !key.User.Active {
+			return claims, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if !key.Can(scope, 0) {
+			return claims, status.Errorf(codes.PermissionDenied, "API key scope does not allow %s access", scope)
+		}
+		claims = key.User.Claims()
+	} else {
+		c, err := auth.UserClaimsFromJWT(token)
+		if err != nil {
+			return claims, status.Error(codes.Unauthenticated, "invalid access token")
+		}
+		if c.Revoked() {
+			return claims, status.Error(codes.Unauthenticated, "token revoked")
+		}
+		claims = c
+	}
+
+	if !core.HasRole(claims.Role, core.RoleAdmin) {
+		return claims, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return claims, nil
+}
+
+// auditEvent returns audit event of the control API call.
+func auditEvent(ctx context.Context, action, target string) core.AuditEvent {
+	claims, _ := ctx.Value(claimsKey{}).(auth.UserClaims)
+	event := core.AuditEvent{
+		ActorID: claims.ID,
+		Actor:   claims.Email,
+		Action:  action,
+		Target:  target,
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			event.SourceIP = host
+		}
+	}
+	return event
+}
+
+func tokenFromMetadata(md metadata.MD) string {
+	if key := strings.Join(md["x-api-key"], ""); key != "" {
+		return key
+	}
+	bearer := strings.Join(md["authorization"], "")
+	if len(bearer) > 7 && strings.ToLower(bearer[0:6]) == "bearer" {
+		return bearer[7:]
+	}
+	return ""
+}
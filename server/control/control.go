@@ -0,0 +1,284 @@
+// Package control implements gRPC control API used by command line
+// clients to inspect connected workers, build queue and job logs.
+package control
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/bleenco/abstruse/internal/rpc"
+	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/pkg/tlsutil"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/golang/protobuf/ptypes/empty"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// logInterval is interval in which running job log is checked for new
+// output when log is followed.
+const logInterval = 500 * time.Millisecond
+
+// Server is gRPC control API server.
+type Server struct {
+	config    *config.Config
+	workers   core.WorkerRegistry
+	scheduler core.Scheduler
+	jobs      core.JobStore
+	keys      core.APIKeyStore
+	audit     core.AuditService
+	logger    *zap.SugaredLogger
+}
+
+// New returns new control API server.
+func New(
+	config *config.Config,
+	workers core.WorkerRegistry,
+	scheduler core.Scheduler,
+	jobs core.JobStore,
+	keys core.APIKeyStore,
+	audit core.AuditService,
+	logger *zap.Logger,
+) *Server {
+	return &Server{
+		config:    config,
+		workers:   workers,
+		scheduler: scheduler,
+		jobs:      jobs,
+		keys:      keys,
+		audit:     audit,
+		logger:    logger.With(zap.String("type", "control")).Sugar(),
+	}
+}
+
+// Run starts the gRPC server, server is not started when listen
+// address is not configured.
+func (s *Server) Run() error {
+	if s.config.GRPC == nil || s.config.GRPC.Addr == "" {
+		return nil
+	}
+
+	certs, err := tlsutil.NewCertReloader(s.config.TLS.Cert, s.config.TLS.Key)
+	if err != nil {
+		return err
+	}
+	if err := certs.Watch(s.certReloaded); err != nil {
+		return err
+	}
+	defer certs.Close()
+
+	listener, err := net.Listen("tcp", s.config.GRPC.Addr)
+	if err != nil {
+		return err
+	}
+
+	grpcOpts := []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(&tls.Config{GetCertificate: certs.GetCertificate})),
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+			s.unaryInterceptor,
+			grpc_recovery.UnaryServerInterceptor(),
+		)),
+		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
+			s.streamInterceptor,
+			grpc_recovery.StreamServerInterceptor(),
+		)),
+	}
+	grpcOpts = append(grpcOpts, rpc.ServerOptions(s.rpcOptions())...)
+
+	server := grpc.NewServer(grpcOpts...)
+	pb.RegisterControlServer(server, s)
+	s.logger.Infof("control API listening on %s", s.config.GRPC.Addr)
+
+	return server.Serve(listener)
+}
+
+// ListWorkers returns connected workers.
+func (s *Server) ListWorkers(ctx context.Context, in *empty.Empty) (*pb.WorkerList, error) {
+	workers, err := s.workers.List()
+	if err != nil {
+		return nil, err
+	}
+
+	list := &pb.WorkerList{}
+	for _, w := range workers {
+		w.Lock()
+		worker := &pb.WorkerStatus{
+			Id:          w.ID,
+			Addr:        w.Addr,
+			Hostname:    w.Host.Hostname,
+			Labels:      w.Host.Labels,
+			Max:         int32(w.Max),
+			Running:     int32(w.Running),
+			ConnectedAt: millis(w.Host.ConnectedAt),
+			Draining:    w.Draining,
+		}
+		if n := len(w.Usage); n > 0 {
+			usage := w.Usage[n-1]
+			worker.Cpu = int32(usage.CPU)
+			worker.Mem = int32(usage.Mem)
+			worker.Heartbeat = millis(usage.Timestamp)
+		}
+		w.Unlock()
+		list.Workers = append(list.Workers, worker)
+	}
+	sort.Slice(list.Workers, func(i, j int) bool { return list.Workers[i].Id < list.Workers[j].Id })
+
+	return list, nil
+}
+
+// ListBuilds returns builds with queued or running jobs.
+func (s *Server) ListBuilds(ctx context.Context, in *empty.Empty) (*pb.BuildList, error) {
+	builds := make(map[uint]*pb.BuildStatus)
+	for _, job := range s.scheduler.Jobs() {
+		build, ok := builds[job.BuildID]
+		if !ok {
+			build = &pb.BuildStatus{Id: uint64(job.BuildID)}
+			if b := job.Build; b != nil {
+				build.Branch, build.Ref, build.Commit = b.Branch, b.Ref, b.Commit
+				build.RepositoryId = uint64(b.RepositoryID)
+				if b.Repository != nil {
+					build.Repository = b.Repository.FullName
+				}
+			}
+			builds[job.BuildID] = build
+		}
+
+		build.Jobs = append(build.Jobs, &pb.JobStatus{
+			Id:        uint64(job.ID),
+			Stage:     job.Stage,
+			Image:     job.Image,
+			Status:    job.Status,
+			WorkerId:  job.WorkerID,
+			QueuedAt:  millisPtr(job.QueuedAt),
+			StartTime: millisPtr(job.StartTime),
+		})
+	}
+
+	list := &pb.BuildList{}
+	for _, build := range builds {
+		sort.Slice(build.Jobs, func(i, j int) bool { return build.Jobs[i].Id < build.Jobs[j].Id })
+		list.Builds = append(list.Builds, build)
+	}
+	sort.Slice(list.Builds, func(i, j int) bool { return list.Builds[i].Id < list.Builds[j].Id })
+
+	return list, nil
+}
+
+// StreamLog sends job log, when follow is set new output is sent until
+// job finishes. Log of running job is read from scheduler, log of queued
+// or finished job from the datastore.
+func (s *Server) StreamLog(in *pb.LogRequest, stream pb.Control_StreamLogServer) error {
+	id := uint(in.GetJobId())
+	ticker := time.NewTicker(logInterval)
+	defer ticker.Stop()
+
+	var sent int
+	for {
+		state := "running"
+		log, err := s.scheduler.JobLog(id)
+		if err != nil {
+			job, err := s.jobs.Find(id)
+			if err != nil {
+				return status.Errorf(codes.NotFound, "job %d not found", id)
+			}
+			log, state = job.Log, job.Status
+		}
+
+		if len(log) < sent {
+			sent = 0 // job has been restarted
+		}
+		if len(log) > sent {
+			if err := stream.Send(&pb.LogChunk{Content: []byte(log[sent:]), Status: state}); err != nil {
+				return err
+			}
+			sent = len(log)
+		}
+
+		if !in.GetFollow() || (state != "queued" && state != "running") {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DrainWorker stops starting new jobs on the worker, or lifts the drain
+// when drain is not set. Returned status lists jobs still running on
+// the worker.
+func (s *Server) DrainWorker(ctx context.Context, in *pb.DrainWorkerRequest) (*pb.DrainStatus, error) {
+	d, err := s.scheduler.Drain(in.GetId(), in.GetDrain())
+	if err == core.ErrWorkerNotFound {
+		return nil, status.Errorf(codes.NotFound, "worker %s not found", in.GetId())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	action := core.AuditWorkerDrain
+	if !in.GetDrain() {
+		action = core.AuditWorkerUndrain
+	}
+	s.audit.Record(auditEvent(ctx, action, in.GetId()))
+
+	resp := &pb.DrainStatus{
+		Id:       d.ID,
+		Draining: d.Draining,
+		Since:    millisPtr(d.Since),
+		Idle:     d.Idle,
+	}
+	for _, id := range d.Jobs {
+		resp.Jobs = append(resp.Jobs, uint64(id))
+	}
+	for _, id := range d.Builds {
+		resp.Builds = append(resp.Builds, uint64(id))
+	}
+	return resp, nil
+}
+
+func (s *Server) rpcOptions() rpc.Options {
+	opts := rpc.Options{
+		MaxRecvMsgSize: s.config.GRPC.MaxRecvMsgSize,
+		MaxSendMsgSize: s.config.GRPC.MaxSendMsgSize,
+	}
+	if ka := s.config.GRPC.Keepalive; ka != nil {
+		opts.KeepaliveTime = ka.Time
+		opts.KeepaliveTimeout = ka.Timeout
+	}
+	return opts
+}
+
+func (s *Server) certReloaded(err error) {
+	if err != nil {
+		s.logger.Warnf("error reloading TLS certificate, serving previous one: %v", err)
+		return
+	}
+	s.logger.Infof("TLS certificate reloaded from %s", s.config.TLS.Cert)
+}
+
+// millis returns unix time in milliseconds, 0 for zero time.
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func millisPtr(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return millis(*t)
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// API key scope constants.
+const (
+	ScopeRead    = "read"
+	ScopeWrite   = "write"
+	ScopeTrigger = "trigger"
+)
+
+// APIKeyPrefix is prefix of generated API keys.
+const APIKeyPrefix = "abs_"
+
+type (
+	// APIKey defines `api_keys` database table.
+	APIKey struct {
+		ID     uint   `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Name   string `gorm:"not null;size:255" json:"name"`
+		Prefix string `gorm:"not null;size:20" json:"prefix"`
+		Hash   string `gorm:"not null;size:64;unique_index" json:"-"`
+		Scopes string `gorm:"not null" json:"scopes"` // comma separated, e.g. read,trigger:12
+		User   *User  `json:"user,omitempty"`
+		UserID uint   `gorm:"not null" json:"userID"`
+		Timestamp
+	}
+
+	// APIKeyStore defines operations on API keys in datastore.
+	APIKeyStore interface {
+		// Find returns API key by id from the datastore.
+		Find(uint) (*APIKey, error)
+
+		// FindKey returns API key with associated user by plain key.
+		FindKey(string) (*APIKey, error)
+
+		// List returns list of API keys from the datastore.
+		List() ([]*APIKey, error)
+
+		// Create generates new key, persists it to the datastore and
+		// returns plain key which is not stored.
+		Create(*APIKey) (string, error)
+
+		// Delete deletes API key from the datastore.
+		Delete(*APIKey) error
+	}
+)
+
+// Can returns true if API key has scope, either globally or for
+// repository with provided id. Write scope includes all other scopes.
+func (k *APIKey) Can(scope string, repoID uint) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		s = strings.TrimSpace(s)
+		if s == ScopeWrite || s == scope || (repoID > 0 && s == fmt.Sprintf("%s:%d", scope, repoID)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAny returns true if API key has scope for any repository.
+func (k *APIKey) CanAny(scope string) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		s = strings.TrimSpace(s)
+		if s == ScopeWrite || s == scope || strings.HasPrefix(s, scope+":") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package core
+
+import "time"
+
+// Audit event actions.
+const (
+	AuditLogin           = "user.login"
+	AuditLoginFailed     = "user.login_failed"
+	AuditUserCreate      = "user.create"
+	AuditUserUpdate      = "user.update"
+	AuditUserPassword    = "user.password"
+	AuditTokensRevoke    = "user.revoke_tokens"
+	AuditTeamCreate      = "team.create"
+	AuditTeamUpdate      = "team.update"
+	AuditAPIKeyCreate    = "apikey.create"
+	AuditAPIKeyDelete    = "apikey.delete"
+	AuditEnvCreate       = "env.create"
+	AuditEnvUpdate       = "env.update"
+	AuditEnvDelete       = "env.delete"
+	AuditHookSecret      = "hook_secret.add"
+	AuditHookPromote     = "hook_secret.promote"
+	AuditHookRemove      = "hook_secret.remove"
+	AuditHookReplay      = "webhook.replay"
+	AuditCloneAuthSet    = "clone_auth.set"
+	AuditCloneAuthRemove = "clone_auth.remove"
+	AuditRegistrySet     = "registry_auth.set"
+	AuditRegistryRemove  = "registry_auth.remove"
+	AuditBuildCancel     = "build.cancel"
+	AuditBuildStop       = "build.stop"
+	AuditConfigReload    = "config.reload"
+	AuditMaintenanceOn   = "maintenance.start"
+	AuditMaintenanceOff  = "maintenance.stop"
+	AuditWorkerDrain     = "worker.drain"
+	AuditWorkerUndrain   = "worker.undrain"
+)
+
+type (
+	// AuditEvent defines `audit_events` database table, records of
+	// security relevant actions. Actor is email of the user, ActorID
+	// is 0 when action is not performed by authenticated user.
+	AuditEvent struct {
+		ID       uint      `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Time     time.Time `gorm:"not null;index" json:"time"`
+		ActorID  uint      `gorm:"index" json:"actorId"`
+		Actor    string    `gorm:"size:255" json:"actor"`
+		Action   string    `gorm:"not null;size:64;index" json:"action"`
+		Target   string    `gorm:"size:255" json:"target"`
+		SourceIP string    `gorm:"size:64" json:"sourceIp"`
+		Details  string    `gorm:"type:text" json:"details,omitempty"`
+	}
+
+	// AuditFilter defines filters when listing audit events from the
+	// datastore, zero values are not applied.
+	AuditFilter struct {
+		Action  string
+		ActorID uint
+		From    time.Time
+		To      time.Time
+		Cursor  uint // events with lower ID, for paging
+		Limit   int
+	}
+
+	// AuditStore defines operations on audit events in datastore.
+	AuditStore interface {
+		// Create persists audit event to the datastore.
+		Create(*AuditEvent) error
+
+		// List returns audit events matching filter, newest first.
+		List(AuditFilter) ([]*AuditEvent, error)
+	}
+
+	// AuditService records audit events to the audit log, which is
+	// kept separate from the application log.
+	AuditService interface {
+		// Record writes audit event, time is set when zero. Errors
+		// are logged and not returned, so audited actions do not fail.
+		Record(AuditEvent)
+	}
+)
@@ -1,40 +1,56 @@
 package core
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/pipeline"
+)
 
 // BuildStatus for badge.
 const (
-	BuildStatusUnknown = "unknown"
-	BuildStatusPassing = "passing"
-	BuildStatusFailing = "failing"
-	BuildStatusRunning = "running"
+	BuildStatusUnknown   = "unknown"
+	BuildStatusPassing   = "passing"
+	BuildStatusFailing   = "failing"
+	BuildStatusRunning   = "running"
+	BuildStatusCancelled = "cancelled"
 )
 
 type (
 	// Build defines `builds` database table.
 	Build struct {
-		ID              uint        `gorm:"primary_key;auto_increment;not null" json:"id"`
-		Branch          string      `json:"branch"`
-		Commit          string      `json:"commit"`
-		CommitMessage   string      `json:"commitMessage"`
-		Ref             string      `gorm:"default:'refs/heads/master'" json:"ref"`
-		PR              int         `json:"pr"`
-		PRTitle         string      `json:"prTitle"`
-		PRBody          string      `json:"pr_body"`
-		Config          string      `sql:"type:text" json:"config"`
-		AuthorLogin     string      `json:"authorLogin"`
-		AuthorName      string      `json:"authorName"`
-		AuthorEmail     string      `json:"authorEmail"`
-		AuthorAvatar    string      `gorm:"default:'/assets/images/avatars/avatar_1.svg'" json:"authorAvatar"`
-		CommitterLogin  string      `json:"committerLogin"`
-		CommitterName   string      `json:"committerName"`
-		CommitterEmail  string      `json:"committerEmail"`
-		CommitterAvatar string      `gorm:"default:'/assets/images/avatars/avatar_1.svg'" json:"committerAvatar"`
-		StartTime       *time.Time  `json:"startTime"`
-		EndTime         *time.Time  `json:"endTime"`
-		Jobs            []*Job      `gorm:"preload:false" json:"jobs,omitempty"`
-		Repository      *Repository `gorm:"preload:false" json:"repository,omitempty"`
-		RepositoryID    uint        `json:"repositoryID"`
+		ID              uint                   `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Branch          string                 `json:"branch"`
+		Commit          string                 `json:"commit"`
+		CommitMessage   string                 `json:"commitMessage"`
+		Ref             string                 `gorm:"default:'refs/heads/master'" json:"ref"`
+		PR              int                    `json:"pr"`
+		PRTitle         string                 `json:"prTitle"`
+		PRBody          string                 `json:"pr_body"`
+		Config          string                 `sql:"type:text" json:"config"`
+		Env             string                 `sql:"type:text" json:"env"` // JSON encoded env overrides
+		AuthorLogin     string                 `json:"authorLogin"`
+		AuthorName      string                 `json:"authorName"`
+		AuthorEmail     string                 `json:"authorEmail"`
+		AuthorAvatar    string                 `gorm:"default:'/assets/images/avatars/avatar_1.svg'" json:"authorAvatar"`
+		CommitterLogin  string                 `json:"committerLogin"`
+		CommitterName   string                 `json:"committerName"`
+		CommitterEmail  string                 `json:"committerEmail"`
+		CommitterAvatar string                 `gorm:"default:'/assets/images/avatars/avatar_1.svg'" json:"committerAvatar"`
+		QueuedAt        *time.Time             `json:"queuedAt"`
+		StartTime       *time.Time             `json:"startTime"`
+		EndTime         *time.Time             `json:"endTime"`
+		Duration        int64                  `gorm:"-" json:"duration"` // in milliseconds, 0 until finished
+		Jobs            []*Job                 `gorm:"preload:false" json:"jobs,omitempty"`
+		Repository      *Repository            `gorm:"preload:false" json:"repository,omitempty"`
+		RepositoryID    uint                   `json:"repositoryID"`
+		ParentID        uint                   `json:"parentID"`    // original build when rebuilt
+		Worker          string                 `json:"worker"`      // ID of worker jobs are pinned to, any when empty
+		Concurrency     string                 `json:"concurrency"` // group of builds which never run at the same time
+		Stages          []pipeline.StageStatus `gorm:"-" json:"stages,omitempty"`
 		Timestamp
 	}
 
@@ -42,7 +58,12 @@ type (
 	BuildFilter struct {
 		Limit        int
 		Offset       int
+		Cursor       uint // return builds with ID lower than cursor
 		RepositoryID int
+		Branch       string
+		Status       string
+		From         time.Time
+		To           time.Time
 		Kind         string
 		UserID       uint
 	}
@@ -53,6 +74,8 @@ type (
 		Config string
 		SHA    string
 		Branch string
+		Env    map[string]string
+		Worker string // pins jobs to worker, overrides build config
 		UserID uint
 	}
 
@@ -67,8 +90,21 @@ type (
 		// FindStatus returns build by repo token and branch.
 		FindStatus(string, string) (string, error)
 
-		// List returns list of builds from datastore
-		List(BuildFilter) ([]*Build, error)
+		// FindPrevious returns last finished build before specified
+		// build on the same branch.
+		FindPrevious(*Build) (*Build, error)
+
+		// FindLatest returns last finished build of the repository
+		// branch, pull request builds are excluded.
+		FindLatest(uint, string) (*Build, error)
+
+		// FindUnfinished returns queued and running builds of the same
+		// repository and ref created before the build.
+		FindUnfinished(*Build) ([]*Build, error)
+
+		// List returns list of builds from datastore, query is
+		// cancelled when context is done.
+		List(context.Context, BuildFilter) ([]*Build, error)
 
 		// Create persists build to the datastore.
 		Create(*Build) error
@@ -80,9 +116,121 @@ type (
 		Delete(*Build) error
 
 		// TriggerBuild creates new build and returns associated jobs.
-		TriggerBuild(TriggerBuildOpts) ([]*Job, error)
+		TriggerBuild(TriggerBuildOpts) ([]*Job, uint, error)
+
+		// Rebuild creates new build with the same parameters as
+		// build with provided id and returns associated jobs.
+		Rebuild(uint) ([]*Job, uint, error)
+
+		// Prune deletes finished builds of the repository outside of its
+		// retention policy together with their jobs in single transaction,
+		// at most limit builds are deleted. Returns number of deleted builds.
+		Prune(repo *Repository, now time.Time, limit int) (int, error)
 
 		// GenerateBuild generates and triggers build based on post-commit hook.
 		GenerateBuild(repo *Repository, base *GitHook) ([]*Job, uint, error)
+
+		// Usage returns storage used by builds of each repository with
+		// builds, query is cancelled when context is done.
+		Usage(context.Context) ([]*StorageUsage, error)
+	}
+
+	// StorageUsage defines database storage used by builds of repository.
+	StorageUsage struct {
+		RepositoryID    uint       `json:"repositoryID"`
+		FullName        string     `json:"fullName"`
+		Builds          int        `json:"builds"`
+		Jobs            int        `json:"jobs"`
+		LogBytes        int64      `json:"logBytes"`
+		TestReportBytes int64      `json:"testReportBytes"`
+		OldestBuild     *time.Time `json:"oldestBuild"` // creation time of oldest retained build
 	}
 )
+
+// Status returns build status computed from statuses of its jobs.
+func (b *Build) Status() string {
+	if len(b.Jobs) == 0 {
+		return BuildStatusUnknown
+	}
+
+	running, failing, cancelled := false, false, false
+	for _, job := range b.Jobs {
+		if job.Status == "running" {
+			running = true
+		}
+		if job.Status == "failing" {
+			failing = true
+		}
+		if job.Status == BuildStatusCancelled {
+			cancelled = true
+		}
+	}
+
+	if running {
+		return BuildStatusRunning
+	}
+	if failing {
+		return BuildStatusFailing
+	}
+	if cancelled {
+		return BuildStatusCancelled
+	}
+	return BuildStatusPassing
+}
+
+// AfterFind computes build duration after build is loaded from the datastore.
+func (b *Build) AfterFind() error {
+	b.Duration = duration(b.StartTime, b.EndTime)
+	return nil
+}
+
+// EnvVariables returns build env overrides.
+func (b *Build) EnvVariables() map[string]string {
+	env := make(map[string]string)
+	if b.Env != "" {
+		json.Unmarshal([]byte(b.Env), &env)
+	}
+	return env
+}
+
+// In converts times of the build and its jobs to location for
+// presentation, builds are stored in UTC.
+func (b *Build) In(loc *time.Location) {
+	b.QueuedAt = timeIn(b.QueuedAt, loc)
+	b.StartTime = timeIn(b.StartTime, loc)
+	b.EndTime = timeIn(b.EndTime, loc)
+	b.Timestamp.In(loc)
+	for _, job := range b.Jobs {
+		job.In(loc)
+	}
+}
+
+// Pipeline returns pipeline of build stages with statuses of build jobs.
+func (b *Build) Pipeline() (*pipeline.Pipeline, error) {
+	var stages []pipeline.Stage
+	index := make(map[string]int)
+	for _, job := range b.Jobs {
+		i, ok := index[job.Stage]
+		if !ok {
+			i = len(stages)
+			index[job.Stage] = i
+			stages = append(stages, pipeline.Stage{Name: job.Stage})
+		}
+		for _, need := range strings.Split(job.Needs, ",") {
+			if need != "" && !lib.Include(stages[i].Needs, need) {
+				stages[i].Needs = append(stages[i].Needs, need)
+			}
+		}
+	}
+
+	p, err := pipeline.New(stages)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range b.Jobs {
+		if err := p.AddJob(job.ID, job.Stage, job.Status); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Clone credential types.
+const (
+	CloneAuthSSH   = "ssh"
+	CloneAuthToken = "token"
+)
+
+// CloneAuth defines credentials workers clone repository and its
+// submodules with, SSH deploy key or HTTPS token. Provider access token
+// is used when repository has no clone credentials.
+type CloneAuth struct {
+	Type       string `json:"type"`
+	Username   string `json:"username,omitempty"`   // HTTPS username, git for SSH when empty
+	Token      string `json:"token,omitempty"`      // HTTPS token or password
+	Key        string `json:"key,omitempty"`        // PEM encoded SSH private key
+	Passphrase string `json:"passphrase,omitempty"` // of encrypted SSH private key
+}
+
+// Validate checks clone credentials are complete and SSH key can be
+// parsed.
+func (a CloneAuth) Validate() error {
+	switch a.Type {
+	case CloneAuthToken:
+		if a.Token == "" {
+			return fmt.Errorf("token is required")
+		}
+	case CloneAuthSSH:
+		if _, err := a.PublicKey(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid clone credentials type %s (available options: %s, %s)", a.Type, CloneAuthSSH, CloneAuthToken)
+	}
+	return nil
+}
+
+// PublicKey returns authorized_keys encoded public key of SSH private
+// key, to be added as deploy key of the repository.
+func (a CloneAuth) PublicKey() (string, error) {
+	if strings.TrimSpace(a.Key) == "" {
+		return "", fmt.Errorf("private key is required")
+	}
+	var signer ssh.Signer
+	var err error
+	if a.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(a.Key), []byte(a.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(a.Key))
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
+
+// CloneCredentials returns clone credentials of the repository, nil
+// when not set.
+func (r Repository) CloneCredentials() (*CloneAuth, error) {
+	if r.CloneAuth == "" {
+		return nil, nil
+	}
+	var auth CloneAuth
+	if err := json.Unmarshal([]byte(r.CloneAuth), &auth); err != nil {
+		return nil, fmt.Errorf("malformed clone credentials: %v", err)
+	}
+	return &auth, nil
+}
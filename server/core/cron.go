@@ -0,0 +1,48 @@
+package core
+
+import "time"
+
+type (
+	// Cron defines `crons` database table. Cron triggers build of the
+	// branch at times specified with cron expression.
+	Cron struct {
+		ID           uint        `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Spec         string      `gorm:"not null" json:"spec"`
+		Branch       string      `json:"branch"`
+		Timezone     string      `json:"timezone"`
+		NextRun      *time.Time  `gorm:"index" json:"nextRun"`
+		LastRun      *time.Time  `json:"lastRun"`
+		RepositoryID uint        `gorm:"not null" json:"repositoryID"`
+		Repository   *Repository `gorm:"preload:false" json:"-"`
+		Timestamp
+	}
+
+	// CronStore defines operations on cron schedules in datastore.
+	CronStore interface {
+		// Find returns cron from datastore.
+		Find(uint) (*Cron, error)
+
+		// List returns list of crons of the repository from the datastore.
+		List(uint) ([]*Cron, error)
+
+		// ListDue returns crons with next run at or before provided time.
+		ListDue(time.Time) ([]*Cron, error)
+
+		// Create persists a new cron to the datastore.
+		Create(*Cron) error
+
+		// Claim sets next run of the cron only if it was not changed since
+		// the cron was loaded and reports whether cron was claimed.
+		Claim(*Cron, time.Time) (bool, error)
+
+		// Delete deletes cron from the datastore.
+		Delete(*Cron) error
+	}
+
+	// CronService triggers builds of scheduled crons.
+	CronService interface {
+		// Create validates cron expression and persists cron
+		// with its next run time to the datastore.
+		Create(*Cron) error
+	}
+)
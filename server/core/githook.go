@@ -39,6 +39,7 @@ type (
 		PrNumber     int       `json:"pr"`
 		PrTitle      string    `json:"pr_title"`
 		PrBody       string    `json:"pr_body"`
+		Draft        bool      `json:"draft"`
 		AuthorEmail  string    `json:"author_email"`
 		AuthorAvatar string    `json:"author_avatar"`
 		AuthorName   string    `json:"author_name"`
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Webhook delivery results.
+const (
+	DeliveryQueued   = "queued"   // waiting to be processed
+	DeliveryBuild    = "build"    // build was triggered
+	DeliverySkipped  = "skipped"  // build was skipped by repository settings
+	DeliveryIgnored  = "ignored"  // event does not trigger builds
+	DeliveryRejected = "rejected" // no repository matched or signature is invalid
+	DeliveryError    = "error"    // build could not be created
+)
+
+type (
+	// HookDelivery defines `hook_deliveries` database table, recent
+	// webhook deliveries with the result of processing them. Headers
+	// holding secrets or signatures are not stored.
+	HookDelivery struct {
+		ID           uint        `gorm:"primary_key;auto_increment;not null" json:"id"`
+		RepositoryID uint        `gorm:"index" json:"repositoryID"` // 0 when delivery matched no repository
+		Event        string      `gorm:"size:64" json:"event"`
+		Headers      string      `sql:"type:text" json:"-"` // JSON encoded headers
+		Header       http.Header `gorm:"-" json:"headers,omitempty"`
+		Payload      string      `sql:"type:text" json:"payload,omitempty"`
+		Verified     bool        `json:"verified"` // signature of delivery was valid
+		Result       string      `gorm:"size:20" json:"result"`
+		Reason       string      `json:"reason"`
+		BuildID      uint        `json:"buildID"`
+		ReplayOf     uint        `json:"replayOf"` // ID of replayed delivery
+		CreatedAt    time.Time   `json:"createdAt"`
+	}
+
+	// HookDeliveryFilter defines filters when listing webhook
+	// deliveries, zero values are not applied.
+	HookDeliveryFilter struct {
+		RepositoryID uint
+		Result       string
+		Cursor       uint // deliveries with lower ID, for paging
+		Limit        int
+	}
+
+	// HookDeliveryStore defines operations on webhook deliveries in
+	// datastore.
+	HookDeliveryStore interface {
+		// Find returns webhook delivery with headers and payload.
+		Find(uint) (*HookDelivery, error)
+
+		// List returns webhook deliveries without headers and
+		// payload, newest first.
+		List(HookDeliveryFilter) ([]*HookDelivery, error)
+
+		// Create persists webhook delivery to the datastore, only
+		// recent deliveries of the repository are kept.
+		Create(*HookDelivery) error
+
+		// Update persists result of processing webhook delivery to
+		// the datastore.
+		Update(*HookDelivery) error
+	}
+)
+
+// SetHeader sets headers of delivery and their encoded form persisted
+// to datastore.
+func (d *HookDelivery) SetHeader(header http.Header) error {
+	d.Header, d.Headers = header, ""
+	if len(header) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	d.Headers = string(data)
+	return nil
+}
+
+// AfterFind decodes headers after delivery is loaded from the datastore.
+func (d *HookDelivery) AfterFind() error {
+	if d.Headers == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(d.Headers), &d.Header)
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// skipMarkers are commit message markers which skip build of the push.
+var skipMarkers = []string{"[skip ci]", "[ci skip]"}
+
+type (
+	// HookFilter defines which webhooks of the repository trigger builds.
+	// Patterns are comma separated globs, * matches within single path
+	// segment, ** across segments and pattern ending with / matches all
+	// paths under directory. Path patterns without / match file name in
+	// any directory. Empty values disable filters.
+	HookFilter struct {
+		Branches       string `json:"branches"`       // only these branches are built
+		IgnoreBranches string `json:"ignoreBranches"` // branches never built
+		Paths          string `json:"paths"`          // build only when matching files changed
+		IgnorePaths    string `json:"ignorePaths"`    // skip when all changed files match
+	}
+
+	// SkippedBuild defines `skipped_builds` database table, webhooks
+	// which did not trigger build with the reason.
+	SkippedBuild struct {
+		ID           uint      `gorm:"primary_key;auto_increment;not null" json:"id"`
+		RepositoryID uint      `gorm:"not null;index" json:"repositoryID"`
+		Event        string    `json:"event"`
+		Ref          string    `json:"ref"`
+		Commit       string    `json:"commit"`
+		Message      string    `sql:"type:text" json:"message"`
+		Author       string    `json:"author"`
+		Reason       string    `json:"reason"`
+		CreatedAt    time.Time `json:"createdAt"`
+	}
+
+	// SkippedBuildStore defines operations on skipped builds in datastore.
+	SkippedBuildStore interface {
+		// Create persists skipped build to the datastore, only recent
+		// skipped builds of the repository are kept.
+		Create(*SkippedBuild) error
+
+		// List returns recent skipped builds of the repository.
+		List(repoID uint, limit int) ([]*SkippedBuild, error)
+	}
+)
+
+// PathFilter returns true when filter depends on changed files.
+func (f HookFilter) PathFilter() bool {
+	return strings.TrimSpace(f.Paths) != "" || strings.TrimSpace(f.IgnorePaths) != ""
+}
+
+// Skip returns reason why hook should not trigger build, empty when
+// build should be triggered. Files are paths changed by the hook, path
+// filters are not applied when they are nil.
+func (f HookFilter) Skip(hook *GitHook, files []string) string {
+	if hook.Event == EventPush && SkipMessage(hook.Message) {
+		return "commit message contains skip marker"
+	}
+
+	if hook.Event != EventTag {
+		branch := hook.Target
+		if patterns := splitPatterns(f.Branches); len(patterns) > 0 && !matchAny(patterns, branch) {
+			return fmt.Sprintf("branch %s does not match branch filter", branch)
+		}
+		if matchAny(splitPatterns(f.IgnoreBranches), branch) {
+			return fmt.Sprintf("branch %s is ignored by branch filter", branch)
+		}
+	}
+
+	if files == nil {
+		return ""
+	}
+	if patterns := splitPatterns(f.Paths); len(patterns) > 0 {
+		var match bool
+		for _, file := range files {
+			if matchAnyPath(patterns, file) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return "no changed files match path filter"
+		}
+	}
+	if patterns := splitPatterns(f.IgnorePaths); len(patterns) > 0 && len(files) > 0 {
+		for _, file := range files {
+			if !matchAnyPath(patterns, file) {
+				return ""
+			}
+		}
+		return "all changed files are ignored by path filter"
+	}
+	return ""
+}
+
+// SkipMessage returns true when commit message contains skip marker,
+// e.g. [skip ci].
+func SkipMessage(message string) bool {
+	message = strings.ToLower(message)
+	for _, marker := range skipMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if globRegexp(pattern).MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyPath(patterns []string, file string) bool {
+	file = strings.TrimPrefix(file, "/")
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(pattern, "/")
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		if globRegexp(pattern).MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp converts glob pattern to regular expression.
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
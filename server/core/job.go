@@ -1,24 +1,136 @@
 package core
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Annotation levels.
+const (
+	AnnotationInfo    = "info"
+	AnnotationWarning = "warning"
+	AnnotationError   = "error"
+)
 
 type (
 	// Job defines `jobs` database table.
 	Job struct {
-		ID        uint       `gorm:"primary_key;auto_increment;not null" json:"id"`
-		Commands  string     `sql:"type:text" json:"commands"`
-		Image     string     `json:"image"`
-		Env       string     `json:"env"`
-		StartTime *time.Time `json:"startTime"`
-		EndTime   *time.Time `json:"endTime"`
-		Status    string     `gorm:"not null;size:20;default:'queued'" json:"status"` // queued | running | passing | failing
-		Log       string     `sql:"type:text" json:"-"`
-		Stage     string     `json:"stage"`
-		Build     *Build     `gorm:"preload:false" json:"build,omitempty"`
-		BuildID   uint       `json:"buildID"`
+		ID           uint          `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Commands     string        `sql:"type:text" json:"commands"`
+		Image        string        `json:"image"`
+		Env          string        `json:"env"`
+		QueuedAt     *time.Time    `json:"queuedAt"`
+		StartTime    *time.Time    `json:"startTime"`
+		EndTime      *time.Time    `json:"endTime"`
+		Duration     int64         `gorm:"-" json:"duration"`                               // in milliseconds, 0 until finished
+		Status       string        `gorm:"not null;size:20;default:'queued'" json:"status"` // queued | running | passing | failing
+		Log          string        `sql:"type:text" json:"-"`
+		Stage        string        `json:"stage"`
+		Needs        string        `json:"needs"`     // comma separated stages job depends on
+		CPUs         float64       `json:"cpus"`      // CPU limit, 0 for worker default
+		Memory       int64         `json:"memory"`    // memory limit in bytes, 0 for worker default
+		PidsLimit    int64         `json:"pidsLimit"` // pids limit, 0 for worker default
+		Weight       int           `json:"weight"`    // worker capacity job consumes, 0 for 1
+		Arch         string        `json:"arch"`      // architecture of worker job runs on, empty for any
+		Reason       string        `json:"reason"`    // reason for failing status
+		ImageDigest  string        `json:"imageDigest"`
+		LogTruncated bool          `json:"logTruncated"` // log exceeded maximum size
+		WorkerID     string        `json:"workerID"`
+		Environment  string        `sql:"type:text" json:"-"` // JSON encoded env variables job ran with, secrets masked
+		StepTimings  string        `sql:"type:text" json:"-"` // JSON encoded steps
+		Steps        []*JobStep    `gorm:"-" json:"steps,omitempty"`
+		TestResults  string        `sql:"type:text" json:"-"` // JSON encoded tests
+		Tests        *TestReport   `gorm:"-" json:"tests,omitempty"`
+		UsageStats   string        `sql:"type:text" json:"-"` // JSON encoded resource usage
+		Usage        *JobUsage     `gorm:"-" json:"usage,omitempty"`
+		Notes        string        `sql:"type:text" json:"-"` // JSON encoded annotations
+		Annotations  []*Annotation `gorm:"-" json:"annotations,omitempty"`
+		Build        *Build        `gorm:"preload:false" json:"build,omitempty"`
+		BuildID      uint          `json:"buildID"`
+		RequestID    string        `gorm:"-" json:"-"`
 		Timestamp
 	}
 
+	// JobStep holds timing of job step executed on worker, like
+	// cloning repository, pulling image or running script command.
+	JobStep struct {
+		Name      string    `json:"name"`
+		StartTime time.Time `json:"startTime"`
+		EndTime   time.Time `json:"endTime"`
+		Duration  int64     `json:"duration"` // in milliseconds
+		Skipped   bool      `json:"skipped,omitempty"`
+	}
+
+	// JobUsage holds resource usage of job container sampled by worker
+	// while job ran, CPU is in number of CPUs and memory in bytes.
+	JobUsage struct {
+		CPUPeak    float64 `json:"cpuPeak"`
+		CPUAvg     float64 `json:"cpuAvg"`
+		MemoryPeak int64   `json:"memoryPeak"`
+		MemoryAvg  int64   `json:"memoryAvg"`
+		Samples    int     `json:"samples"`
+	}
+
+	// Annotation is message build script emitted with log directive to
+	// summarize the job, e.g. where it deployed to.
+	Annotation struct {
+		Level   string `json:"level"` // info | warning | error
+		Message string `json:"message"`
+	}
+
+	// TestReport holds results parsed from test reports collected after
+	// job ran, failures are limited to first ones.
+	TestReport struct {
+		Total    int            `json:"total"`
+		Passed   int            `json:"passed"`
+		Failed   int            `json:"failed"` // failed and errored
+		Skipped  int            `json:"skipped"`
+		Duration int64          `json:"duration"` // in milliseconds
+		Suites   []*TestSuite   `json:"suites"`
+		Failures []*TestFailure `json:"failures"`
+	}
+
+	// TestSuite holds test counts of the test suite.
+	TestSuite struct {
+		Name     string `json:"name"`
+		File     string `json:"file"` // report suite was parsed from
+		Total    int    `json:"total"`
+		Passed   int    `json:"passed"`
+		Failed   int    `json:"failed"`
+		Skipped  int    `json:"skipped"`
+		Duration int64  `json:"duration"` // in milliseconds
+	}
+
+	// TestFailure is failed or errored test.
+	TestFailure struct {
+		Suite     string `json:"suite"`
+		Classname string `json:"classname"`
+		Name      string `json:"name"`
+		Status    string `json:"status"` // failed | error
+		Message   string `json:"message"`
+	}
+
+	// LogSearchFilter defines filters used to search job logs.
+	LogSearchFilter struct {
+		Query        string
+		RepositoryID uint
+		From         time.Time
+		To           time.Time
+		Limit        int
+		Cursor       uint // return matches with job ID lower than cursor
+		UserID       uint
+	}
+
+	// LogMatch represents job which log matches search query.
+	LogMatch struct {
+		JobID        uint     `json:"jobID"`
+		BuildID      uint     `json:"buildID"`
+		RepositoryID uint     `json:"repositoryID"`
+		Branch       string   `json:"branch"`
+		Snippets     []string `json:"snippets"`
+	}
+
 	// JobStore defines operations for working with jobs database table.
 	JobStore interface {
 		// Find returns job by id from datastore.
@@ -30,6 +142,10 @@ type (
 		// List returns jobs based bu from and to dates.
 		List(time.Time, time.Time) ([]*Job, error)
 
+		// Search returns jobs which logs contain search query, query
+		// is cancelled when context is done.
+		Search(context.Context, LogSearchFilter) ([]*LogMatch, error)
+
 		// Create persists job to the datastore.
 		Create(*Job) error
 
@@ -40,3 +156,118 @@ type (
 		Delete(*Job) error
 	}
 )
+
+// In converts times of the job and its steps to location for
+// presentation, jobs are stored in UTC.
+func (j *Job) In(loc *time.Location) {
+	j.QueuedAt = timeIn(j.QueuedAt, loc)
+	j.StartTime = timeIn(j.StartTime, loc)
+	j.EndTime = timeIn(j.EndTime, loc)
+	j.Timestamp.In(loc)
+	for _, step := range j.Steps {
+		step.StartTime, step.EndTime = step.StartTime.In(loc), step.EndTime.In(loc)
+	}
+}
+
+// AfterFind decodes job steps, test results, resource usage and
+// annotations and computes duration after job is loaded from the
+// datastore.
+func (j *Job) AfterFind() error {
+	j.Duration = duration(j.StartTime, j.EndTime)
+	if j.TestResults != "" {
+		if err := json.Unmarshal([]byte(j.TestResults), &j.Tests); err != nil {
+			return err
+		}
+	}
+	if j.UsageStats != "" {
+		if err := json.Unmarshal([]byte(j.UsageStats), &j.Usage); err != nil {
+			return err
+		}
+	}
+	if j.Notes != "" {
+		if err := json.Unmarshal([]byte(j.Notes), &j.Annotations); err != nil {
+			return err
+		}
+	}
+	if j.StepTimings == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(j.StepTimings), &j.Steps)
+}
+
+// CapacityWeight returns worker capacity consumed by the job, jobs
+// created before weights were introduced weigh 1.
+func (j *Job) CapacityWeight() int {
+	if j.Weight < 1 {
+		return 1
+	}
+	return j.Weight
+}
+
+// SetSteps sets job steps and their encoded form persisted to datastore.
+func (j *Job) SetSteps(steps []*JobStep) error {
+	j.Steps, j.StepTimings = steps, ""
+	if len(steps) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+	j.StepTimings = string(data)
+	return nil
+}
+
+// SetTests sets test results and their encoded form persisted to
+// datastore.
+func (j *Job) SetTests(report *TestReport) error {
+	j.Tests, j.TestResults = report, ""
+	if report == nil {
+		return nil
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	j.TestResults = string(data)
+	return nil
+}
+
+// SetUsage sets resource usage and its encoded form persisted to
+// datastore.
+func (j *Job) SetUsage(usage *JobUsage) error {
+	j.Usage, j.UsageStats = usage, ""
+	if usage == nil {
+		return nil
+	}
+	data, err := json.Marshal(usage)
+	if err != nil {
+		return err
+	}
+	j.UsageStats = string(data)
+	return nil
+}
+
+// SetAnnotations sets annotations and their encoded form persisted to
+// datastore.
+func (j *Job) SetAnnotations(annotations []*Annotation) error {
+	j.Annotations, j.Notes = annotations, ""
+	if len(annotations) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return err
+	}
+	j.Notes = string(data)
+	return nil
+}
+
+// duration returns duration between start and end time in milliseconds,
+// 0 is returned when any of them is not set.
+func duration(start, end *time.Time) int64 {
+	if start == nil || end == nil {
+		return 0
+	}
+	return end.Sub(*start).Milliseconds()
+}
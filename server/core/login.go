@@ -0,0 +1,61 @@
+package core
+
+import "time"
+
+const (
+	// loginDelay is delay required after first failed login attempt,
+	// it doubles with every next failure up to maxLoginDelay.
+	loginDelay    = time.Second
+	maxLoginDelay = time.Minute
+)
+
+type (
+	// LoginAttempt defines `login_attempts` database table. Failed
+	// login attempts are tracked by email whether or not account
+	// exists so responses do not reveal registered accounts.
+	LoginAttempt struct {
+		ID          uint       `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Email       string     `gorm:"not null;size:255;unique_index" json:"email"`
+		Failures    int        `gorm:"not null;default:0" json:"failures"`
+		LastFailure time.Time  `json:"lastFailure"`
+		LockedUntil *time.Time `json:"lockedUntil"`
+	}
+
+	// LoginAttemptStore defines operations on failed login attempts
+	// in datastore.
+	LoginAttemptStore interface {
+		// Find returns login attempts for email from datastore.
+		Find(string) (*LoginAttempt, error)
+
+		// Fail records failed login attempt for email. Failures older
+		// than window are discarded, when number of failures reaches
+		// max attempts the email is locked for duration.
+		Fail(email string, max int, window, duration time.Duration) (*LoginAttempt, error)
+
+		// Reset deletes login attempts for email from datastore.
+		Reset(string) error
+	}
+)
+
+// RetryAfter returns duration the client must wait before next login
+// attempt, either until the lock expires or exponential delay after
+// last failure passes.
+func (a *LoginAttempt) RetryAfter(now time.Time) time.Duration {
+	if a.LockedUntil != nil && a.LockedUntil.After(now) {
+		return a.LockedUntil.Sub(now)
+	}
+	if a.Failures == 0 {
+		return 0
+	}
+	delay := maxLoginDelay
+	if a.Failures <= 6 {
+		delay = loginDelay << uint(a.Failures-1)
+	}
+	if delay > maxLoginDelay {
+		delay = maxLoginDelay
+	}
+	if wait := a.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
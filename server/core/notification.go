@@ -0,0 +1,61 @@
+package core
+
+// Notification event constants.
+const (
+	NotifyEventPassed       = "passed"
+	NotifyEventFailed       = "failed"
+	NotifyEventFixed        = "fixed"
+	NotifyEventStillFailing = "still_failing"
+)
+
+// Notification filter constants.
+const (
+	NotifyOnFailure = "failure"
+	NotifyOnAll     = "all"
+)
+
+type (
+	// Notifications defines repository build notification settings.
+	// Slack and Discord webhook URLs grant posting to the channel, like
+	// registry credentials they are never encoded to JSON and are only
+	// set with notification settings endpoint.
+	Notifications struct {
+		Emails     string `json:"emails"`
+		Author     bool   `json:"author"`
+		SlackURL   string `json:"-"`
+		DiscordURL string `json:"-"`
+		On         string `json:"on"` // failure | all
+	}
+
+	// Notification represents build status change notification.
+	Notification struct {
+		Event string
+		Build *Build
+		URL   string
+	}
+
+	// Notifier sends build notifications to a single destination.
+	Notifier interface {
+		// Name returns notifier name.
+		Name() string
+
+		// Notify sends notification about build status.
+		Notify(*Notification) error
+	}
+
+	// NotifyService dispatches build notifications to notifiers.
+	NotifyService interface {
+		// Notify queues notifications for finished build and
+		// returns without waiting for them to be sent.
+		Notify(*Build)
+	}
+)
+
+// ShouldNotify returns true if webhook notifications are enabled
+// for the event.
+func (n Notifications) ShouldNotify(event string) bool {
+	if n.On == NotifyOnAll {
+		return true
+	}
+	return event == NotifyEventFailed || event == NotifyEventStillFailing
+}
@@ -0,0 +1,29 @@
+package core
+
+import "context"
+
+type (
+	// OIDCIdentity is user identity from verified OpenID Connect ID token.
+	OIDCIdentity struct {
+		Subject string
+		Email   string
+		Name    string
+		Groups  []string
+	}
+
+	// OIDCProvider implements OpenID Connect authorization code flow
+	// with external identity provider.
+	OIDCProvider interface {
+		// Name returns name of the provider shown on login page.
+		Name() string
+
+		// AuthURL returns URL user is redirected to for login, state
+		// and nonce are returned back with callback and ID token.
+		AuthURL(ctx context.Context, state, nonce string) (string, error)
+
+		// Exchange exchanges authorization code for ID token and
+		// returns identity from it, token is verified with provider
+		// keys and nonce.
+		Exchange(ctx context.Context, code, nonce string) (*OIDCIdentity, error)
+	}
+)
@@ -1,11 +1,15 @@
 package core
 
 import (
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
 )
 
+// baseURL is canonical external URL of the server, see SetBaseURL.
+var baseURL string
+
 type (
 	// Provider represents `providers` db table.
 	Provider struct {
@@ -47,6 +51,22 @@ type (
 	}
 )
 
+// SetBaseURL sets canonical external URL of the server, links to builds
+// and webhook targets are created with it instead of provider host. It
+// should be called before any link is created.
+func SetBaseURL(url string) {
+	baseURL = strings.TrimSuffix(url, "/")
+}
+
+// ServerURL returns external URL of the server links of the provider
+// repositories are created with, base URL when set and host otherwise.
+func (p Provider) ServerURL() string {
+	if baseURL != "" {
+		return baseURL
+	}
+	return strings.TrimSuffix(p.Host, "/")
+}
+
 // AfterDelete hook on provider which deletes all related repositories.
 func (p *Provider) AfterDelete(tx *gorm.DB) error {
 	return tx.Model(&Repository{}).
@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/bleenco/abstruse/pkg/gitscm"
 	"github.com/bleenco/abstruse/pkg/lib"
@@ -10,7 +11,10 @@ import (
 )
 
 type (
-	// Repository defines `repositories` db table.
+	// Repository defines `repositories` db table. RegistryAuth, CloneAuth
+	// and hook secrets are never encoded to JSON, registry and clone
+	// credentials are only sent to worker running the job and exports
+	// include them encrypted with passphrase or not at all.
 	Repository struct {
 		ID            uint          `gorm:"primary_key;auto_increment;not null" json:"id"`
 		UID           string        `gorm:"not null" json:"uid"`
@@ -33,9 +37,44 @@ type (
 		Provider      Provider      `json:"-"`
 		EnvVariables  []EnvVariable `json:"-"`
 		Perms         Perms         `json:"perms"`
+		Notify        Notifications `gorm:"embedded;embedded_prefix:notify_" json:"notify"`
+		RegistryAuth  string        `sql:"type:text" json:"-"` // Docker config.json encoded registry credentials
+		CloneAuth     string        `sql:"type:text" json:"-"` // JSON encoded clone credentials
+		MaxBuilds     int           `json:"maxBuilds"`         // maximum running builds, 0 for server default
+		AutoCancel    AutoCancel    `gorm:"embedded;embedded_prefix:autocancel_" json:"autoCancel"`
+		PublicBadge   bool          `json:"publicBadge"` // serve branch status badge without authentication
+		Retention     Retention     `gorm:"embedded;embedded_prefix:retention_" json:"retention"`
+		HookSecret    string        `json:"-"` // webhook secret, provider secret when empty
+		HookSecretAlt string        `json:"-"` // secret accepted during rotation
+		HookFilter    HookFilter    `gorm:"embedded;embedded_prefix:filter_" json:"hookFilter"`
+		RunningBuilds int           `gorm:"-" json:"runningBuilds"`
 		Timestamp
 	}
 
+	// AutoCancel defines cancelling of queued and running builds when
+	// newer commit of the same ref is pushed.
+	AutoCancel struct {
+		Enabled  bool `json:"enabled"`
+		Releases bool `json:"releases"` // also cancel builds of tags and release branches
+	}
+
+	// Retention defines which finished builds of the repository are kept,
+	// builds within last Builds builds or newer than Days are kept and
+	// older are deleted with their jobs and logs. Zero values disable limits.
+	Retention struct {
+		Builds        int  `json:"builds"`
+		Days          int  `json:"days"`
+		KeepLastGreen bool `json:"keepLastGreen"` // never delete last passing build of default branch
+	}
+
+	// RetentionService deletes builds of repositories outside of
+	// their retention policy.
+	RetentionService interface {
+		// Prune deletes expired builds of the repository and returns
+		// number of deleted builds.
+		Prune(*Repository) (int, error)
+	}
+
 	// RepositoryFilter defines filters when listing repositories
 	// from the datastore.
 	RepositoryFilter struct {
@@ -59,6 +98,9 @@ type (
 		// FindToken returns repository by token.
 		FindToken(string) (*Repository, error)
 
+		// FindName returns repository by full name.
+		FindName(string) (*Repository, error)
+
 		// List returns list of repositories from the datastore.
 		List(RepositoryFilter) ([]Repository, int, error)
 
@@ -89,6 +131,34 @@ type (
 
 		// DeleteHooks deletes all related webhooks for specified repository
 		DeleteHooks(uint, uint) error
+
+		// SetNotifications persists build notification settings to the repository.
+		SetNotifications(uint, Notifications) error
+
+		// SetRegistryAuth persists docker registry credentials to the repository.
+		SetRegistryAuth(uint, string) error
+
+		// SetCloneAuth persists clone credentials to the repository.
+		SetCloneAuth(uint, string) error
+
+		// SetMaxBuilds persists maximum number of running builds to the repository.
+		SetMaxBuilds(uint, int) error
+
+		// SetAutoCancel persists auto cancel settings to the repository.
+		SetAutoCancel(uint, AutoCancel) error
+
+		// SetPublicBadge persists public badge flag to the repository.
+		SetPublicBadge(uint, bool) error
+
+		// SetRetention persists build retention policy to the repository.
+		SetRetention(uint, Retention) error
+
+		// SetHookFilter persists webhook filter to the repository.
+		SetHookFilter(uint, HookFilter) error
+
+		// SetHookSecrets persists primary and alternative webhook secrets
+		// to the repository.
+		SetHookSecrets(uint, string, string) error
 	}
 )
 
@@ -106,3 +176,45 @@ func (r *Repository) BeforeCreate(tx *gorm.DB) (err error) {
 	r.Timeout = 3600
 	return
 }
+
+// WebhookSecret returns secret webhooks of the repository are created
+// with, provider secret is used until repository secret is set.
+func (r Repository) WebhookSecret() string {
+	if r.HookSecret != "" {
+		return r.HookSecret
+	}
+	return r.Provider.Secret
+}
+
+// WebhookSecrets returns secrets webhook deliveries are accepted with,
+// alternative secret is accepted while secret is being rotated.
+func (r Repository) WebhookSecrets() []string {
+	var secrets []string
+	if secret := r.WebhookSecret(); secret != "" {
+		secrets = append(secrets, secret)
+	}
+	if r.HookSecretAlt != "" {
+		secrets = append(secrets, r.HookSecretAlt)
+	}
+	return secrets
+}
+
+// Applies returns true if older builds of the ref should be cancelled.
+func (a AutoCancel) Applies(ref string) bool {
+	if !a.Enabled {
+		return false
+	}
+	return a.Releases || !IsReleaseRef(ref)
+}
+
+// IsReleaseRef returns true for tags and release branches.
+func IsReleaseRef(ref string) bool {
+	return strings.HasPrefix(ref, "refs/tags/") ||
+		strings.HasPrefix(ref, "refs/heads/release/") ||
+		strings.HasPrefix(ref, "refs/heads/releases/")
+}
+
+// Enabled returns true if retention policy limits builds.
+func (r Retention) Enabled() bool {
+	return r.Builds > 0 || r.Days > 0
+}
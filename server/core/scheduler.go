@@ -1,6 +1,16 @@
 package core
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrQueueFull is returned when new build is rejected because build
+// queue reached its maximum size.
+var ErrQueueFull = errors.New("build queue is full")
+
+// ErrWorkerNotFound is returned when worker is not connected.
+var ErrWorkerNotFound = errors.New("worker not found")
 
 type (
 	// SchedulerStats defines scheduler statistics.
@@ -10,9 +20,32 @@ type (
 		Workers   int       `json:"workers"`
 		Max       int       `json:"max"`
 		Running   int       `json:"running"`
+		MaxQueued int       `json:"maxQueued"` // maximum queued jobs, 0 for unlimited
 		Timestamp time.Time `json:"timestamp"`
 	}
 
+	// Maintenance defines maintenance mode status. While maintenance is
+	// active queued jobs are not started, running jobs finish.
+	Maintenance struct {
+		Active       bool       `json:"active"`
+		Message      string     `json:"message"`
+		Since        *time.Time `json:"since"`
+		QueuedBuilds int        `json:"queuedBuilds"` // builds waiting to start
+		QueuedJobs   int        `json:"queuedJobs"`
+		RunningJobs  int        `json:"runningJobs"`
+	}
+
+	// WorkerDrain defines drain status of the worker. Draining worker
+	// gets no new jobs, jobs running on it finish.
+	WorkerDrain struct {
+		ID       string     `json:"id"`
+		Draining bool       `json:"draining"`
+		Since    *time.Time `json:"since"`
+		Jobs     []uint     `json:"jobs"`   // jobs still running on worker
+		Builds   []uint     `json:"builds"` // builds of running jobs
+		Idle     bool       `json:"idle"`   // worker can be safely stopped
+	}
+
 	// Scheduler represents build jobs scheduler.
 	Scheduler interface {
 		// Next schedules job for execution.
@@ -28,6 +61,10 @@ type (
 		// StopBuild stops the build or associated jobs.
 		StopBuild(uint) error
 
+		// CancelBuild removes queued and stops running build jobs,
+		// marks them as cancelled and returns updated build.
+		CancelBuild(uint) (*Build, error)
+
 		// Pause pauses the scheduler.
 		Pause() error
 
@@ -40,7 +77,35 @@ type (
 		// JobLog returns jobs current log output.
 		JobLog(uint) (string, error)
 
+		// Jobs returns copies of queued and running jobs.
+		Jobs() []*Job
+
 		// Stats returns scheduler current statistics.
 		Stats() SchedulerStats
+
+		// Accept returns ErrQueueFull when new build is rejected by
+		// queue policy because queue reached its maximum size.
+		// Automated builds are triggered by webhooks and cron.
+		Accept(automated bool) error
+
+		// RunningBuilds returns number of running builds of the repository.
+		RunningBuilds(uint) int
+
+		// StartMaintenance pauses the scheduler for maintenance with
+		// message for users.
+		StartMaintenance(string) error
+
+		// StopMaintenance lifts maintenance and resumes the scheduler.
+		StopMaintenance() error
+
+		// Maintenance returns maintenance mode status.
+		Maintenance() Maintenance
+
+		// Drain stops scheduling jobs to the worker, or resumes it when
+		// drain is false.
+		Drain(id string, drain bool) (WorkerDrain, error)
+
+		// DrainStatus returns drain status of the worker.
+		DrainStatus(id string) (WorkerDrain, error)
 	}
 )
@@ -0,0 +1,32 @@
+package core
+
+import "context"
+
+// SecretProviderVault is name of HashiCorp Vault secret provider.
+const SecretProviderVault = "vault"
+
+type (
+	// SecretRef references value of key in secret stored in external
+	// secret provider.
+	SecretRef struct {
+		Provider string
+		Path     string
+		Key      string
+	}
+
+	// SecretProvider fetches secrets from external secret store.
+	SecretProvider interface {
+		// Name returns provider name referenced by builds.
+		Name() string
+
+		// Fetch returns value of key in secret at path.
+		Fetch(ctx context.Context, path, key string) (string, error)
+	}
+
+	// SecretService fetches build secrets from configured providers.
+	SecretService interface {
+		// Fetch returns value of referenced secret, error is returned
+		// when provider is not configured or secret cannot be fetched.
+		Fetch(context.Context, SecretRef) (string, error)
+	}
+)
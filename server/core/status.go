@@ -0,0 +1,13 @@
+package core
+
+import "github.com/drone/go-scm/scm"
+
+type (
+	// StatusReporter reports build status back to the SCM provider
+	// so it's visible on commits and pull requests.
+	StatusReporter interface {
+		// Report sends build status to the SCM provider in the
+		// background. Errors are logged and never returned.
+		Report(*Build, scm.State)
+	}
+)
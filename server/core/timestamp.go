@@ -8,3 +8,17 @@ type Timestamp struct {
 	UpdatedAt time.Time  `json:"updatedAt"`
 	DeletedAt *time.Time `json:"deletedAt"`
 }
+
+// In converts timestamps to location for presentation.
+func (t *Timestamp) In(loc *time.Location) {
+	t.CreatedAt, t.UpdatedAt = t.CreatedAt.In(loc), t.UpdatedAt.In(loc)
+	t.DeletedAt = timeIn(t.DeletedAt, loc)
+}
+
+func timeIn(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	in := t.In(loc)
+	return &in
+}
@@ -1,18 +1,33 @@
 package core
 
-import "github.com/bleenco/abstruse/internal/auth"
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/pkg/lib"
+)
+
+// User role constants.
+const (
+	RoleAdmin      = "admin"
+	RoleMaintainer = "maintainer"
+	RoleViewer     = "viewer"
+	// RoleUser is legacy role with maintainer privileges.
+	RoleUser = "user"
+)
 
 type (
 	// User represents user of the system.
 	User struct {
-		ID       uint    `gorm:"primary_key;auto_increment;not null" json:"id"`
-		Email    string  `gorm:"not null;size:255;unique_index" json:"email"`
-		Password string  `gorm:"not null;size:255;column:password" json:"-"`
-		Name     string  `gorm:"not null;size:255" json:"name"`
-		Avatar   string  `gorm:"not null;size:255;default:'/assets/images/avatars/avatar_1.svg'" json:"avatar"`
-		Role     string  `gorm:"not null;size:20;default:'user'" json:"role"`
-		Active   bool    `gorm:"not null;default:true" json:"active"`
-		Teams    []*Team `gorm:"many2many:team_users;" json:"teams"`
+		ID        uint       `gorm:"primary_key;auto_increment;not null" json:"id"`
+		Email     string     `gorm:"not null;size:255;unique_index" json:"email"`
+		Password  string     `gorm:"not null;size:255;column:password" json:"-"`
+		Name      string     `gorm:"not null;size:255" json:"name"`
+		Avatar    string     `gorm:"not null;size:255;default:'/assets/images/avatars/avatar_1.svg'" json:"avatar"`
+		Role      string     `gorm:"not null;size:20;default:'user'" json:"role"`
+		Active    bool       `gorm:"not null;default:true" json:"active"`
+		Teams     []*Team    `gorm:"many2many:team_users;" json:"teams"`
+		RevokedAt *time.Time `json:"-"`
 		Timestamp
 	}
 
@@ -44,6 +59,9 @@ type (
 
 		// AdminExists checks and returns if admin user exists in datastore.
 		AdminExists() bool
+
+		// RevokeTokens invalidates all previously issued user tokens.
+		RevokeTokens(uint) error
 	}
 )
 
@@ -57,3 +75,12 @@ func (u User) Claims() auth.UserClaims {
 		Role:   u.Role,
 	}
 }
+
+// HasRole returns true if role is one of the roles, legacy user
+// role is treated as maintainer.
+func HasRole(role string, roles ...string) bool {
+	if role == RoleUser {
+		role = RoleMaintainer
+	}
+	return lib.Include(roles, role)
+}
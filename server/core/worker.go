@@ -9,11 +9,15 @@ import (
 	"time"
 
 	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/internal/rpc"
 	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/ws"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -33,37 +37,44 @@ type (
 	// Worker represents connected worker node.
 	Worker struct {
 		sync.Mutex
-		ID       string
-		Addr     string
-		Max      int
-		Running  int
-		Host     HostInfo
-		Usage    []WorkerUsage
-		Conn     *grpc.ClientConn
-		CLI      pb.APIClient
-		Registry WorkerRegistry
-		WS       *ws.App
+		ID        string
+		Addr      string
+		Max       int
+		Running   int
+		Capacity  int // total weight of jobs worker runs in parallel
+		Used      int // weight of running jobs
+		Host      HostInfo
+		Usage     []WorkerUsage
+		Draining  bool // worker gets no new jobs
+		DrainedAt *time.Time
+		Conn      *grpc.ClientConn
+		CLI       pb.APIClient
+		Registry  WorkerRegistry
+		WS        *ws.App
 	}
 
 	// HostInfo holds host information about remote worker node.
 	HostInfo struct {
-		ID                   string    `json:"id"`
-		Addr                 string    `json:"addr"`
-		Hostname             string    `json:"hostname"`
-		Uptime               uint64    `json:"uptime"`
-		BootTime             uint64    `json:"bootTime"`
-		Procs                uint64    `json:"procs"`
-		Os                   string    `json:"os"`
-		Platform             string    `json:"platform"`
-		PlatformFamily       string    `json:"platformFamily"`
-		PlatformVersion      string    `json:"platformVersion"`
-		KernelVersion        string    `json:"kernelVersion"`
-		KernelArch           string    `json:"kernelArch"`
-		VirtualizationSystem string    `json:"virtualizationSystem"`
-		VirtualizationRole   string    `json:"virtualizationRole"`
-		HostID               string    `json:"hostID"`
-		MaxParallel          uint64    `json:"maxParallel"`
-		ConnectedAt          time.Time `json:"connectedAt"`
+		ID                   string            `json:"id"`
+		Addr                 string            `json:"addr"`
+		Hostname             string            `json:"hostname"`
+		Uptime               uint64            `json:"uptime"`
+		BootTime             uint64            `json:"bootTime"`
+		Procs                uint64            `json:"procs"`
+		Os                   string            `json:"os"`
+		Platform             string            `json:"platform"`
+		PlatformFamily       string            `json:"platformFamily"`
+		PlatformVersion      string            `json:"platformVersion"`
+		KernelVersion        string            `json:"kernelVersion"`
+		KernelArch           string            `json:"kernelArch"`
+		VirtualizationSystem string            `json:"virtualizationSystem"`
+		VirtualizationRole   string            `json:"virtualizationRole"`
+		HostID               string            `json:"hostID"`
+		MaxParallel          uint64            `json:"maxParallel"`
+		Capacity             uint64            `json:"capacity"`
+		Labels               map[string]string `json:"labels"`
+		Archs                []string          `json:"archs"`
+		ConnectedAt          time.Time         `json:"connectedAt"`
 	}
 
 	// WorkerUsage holds remote worker node usage information.
@@ -72,6 +83,7 @@ type (
 		Mem       int       `json:"mem"`
 		Max       int       `json:"jobsMax"`
 		Running   int       `json:"jobsRunning"`
+		Draining  bool      `json:"draining"`
 		Timestamp time.Time `json:"timestamp"`
 	}
 )
@@ -103,6 +115,7 @@ func NewWorker(id, addr string, config *config.Config, registry WorkerRegistry,
 
 	grpcOpts = append(grpcOpts, grpc.WithTransportCredentials(creds))
 	grpcOpts = append(grpcOpts, grpc.WithPerRPCCredentials(auth))
+	grpcOpts = append(grpcOpts, rpc.DialOptions(rpcOptions(config.GRPC))...)
 
 	conn, err := grpc.Dial(addr, grpcOpts...)
 	if err != nil {
@@ -120,6 +133,13 @@ func NewWorker(id, addr string, config *config.Config, registry WorkerRegistry,
 	}, nil
 }
 
+// SupportsArch reports whether worker runs jobs of architecture, jobs
+// without architecture run on any worker. Workers not reporting their
+// architectures run only jobs without architecture.
+func (h HostInfo) SupportsArch(arch string) bool {
+	return arch == "" || lib.Include(h.Archs, arch)
+}
+
 // Connect attept connect to worker node and retrieve
 // worker information.
 func (w *Worker) Connect(ctx context.Context) error {
@@ -145,9 +165,21 @@ func (w *Worker) Connect(ctx context.Context) error {
 		VirtualizationRole:   info.GetVirtualizationRole(),
 		HostID:               info.GetHostID(),
 		MaxParallel:          info.GetMaxParallel(),
+		Capacity:             info.GetCapacity(),
+		Labels:               info.GetLabels(),
+		Archs:                info.GetArchs(),
 		ConnectedAt:          time.Now(),
 	}
+	if info.GetDraining() {
+		// worker drained before it reconnected.
+		w.Draining, w.DrainedAt = true, lib.TimeNow()
+	}
 	w.Max = int(info.GetMaxParallel())
+	w.Capacity = int(info.GetCapacity())
+	if w.Capacity == 0 {
+		// workers not reporting capacity run jobs of weight 1.
+		w.Capacity = w.Max
+	}
 
 	go func() {
 		if err := w.usageStats(ctx); err != nil {
@@ -168,6 +200,8 @@ func (w *Worker) StartJob(ctx context.Context, job *pb.Job) (*pb.Job, error) {
 		return job, err
 	}
 
+	logs := &jobLog{worker: w, ctx: ctx, job: job, next: 1}
+
 	for {
 		resp, err := stream.Recv()
 		if err != nil {
@@ -180,12 +214,21 @@ func (w *Worker) StartJob(ctx context.Context, job *pb.Job) (*pb.Job, error) {
 
 		switch resp.GetType() {
 		case pb.JobResp_Log:
-			id, log := resp.GetId(), string(resp.GetContent())
-			job.Log = append(job.Log, log)
-			w.WS.Broadcast(fmt.Sprintf("/subs/logs/%d", id), map[string]interface{}{
-				"id":  id,
-				"log": log,
-			})
+			logs.add(resp.GetSeq(), resp.GetContent())
+		case pb.JobResp_Metadata:
+			if digest := resp.GetImageDigest(); digest != "" {
+				job.ImageDigest = digest
+			}
+			if resp.GetLogTruncated() {
+				job.LogTruncated = true
+			}
+			if usage := resp.GetUsage(); usage != nil {
+				job.Usage = usage
+			}
+		case pb.JobResp_Timing:
+			job.Steps = append(job.Steps, resp.GetStep())
+		case pb.JobResp_Report:
+			job.ReportFiles = append(job.ReportFiles, resp.GetReport())
 		case pb.JobResp_Done:
 			status := "unknown"
 			switch resp.GetStatus() {
@@ -201,6 +244,8 @@ func (w *Worker) StartJob(ctx context.Context, job *pb.Job) (*pb.Job, error) {
 				status = "running"
 			}
 			job.Status = status
+			job.Reason = resp.GetReason()
+			logs.end(resp.GetSeq())
 			break
 		}
 	}
@@ -208,6 +253,80 @@ func (w *Worker) StartJob(ctx context.Context, job *pb.Job) (*pb.Job, error) {
 	return job, nil
 }
 
+// jobLog appends log chunks to the job in order of their sequence
+// numbers, chunks missing from the stream are requested from worker
+// again. Chunks without sequence number, sent by older workers, are
+// appended as received.
+type jobLog struct {
+	worker *Worker
+	ctx    context.Context
+	job    *pb.Job
+	next   uint64 // sequence number of next chunk
+}
+
+func (l *jobLog) add(seq uint64, content []byte) {
+	if seq == 0 {
+		l.append(content)
+		return
+	}
+	if seq < l.next {
+		return // duplicate
+	}
+	if seq > l.next {
+		l.resend(seq - 1)
+	}
+	l.append(content)
+	l.next = seq + 1
+}
+
+// end requests chunks missing at the end of the log, last is sequence
+// number of the last chunk worker sent.
+func (l *jobLog) end(last uint64) {
+	if last >= l.next {
+		l.resend(last)
+	}
+}
+
+// resend requests chunks from next to to from worker, chunks worker
+// no longer keeps are reported as missing in the log.
+func (l *jobLog) resend(to uint64) {
+	from := l.next
+	stream, err := l.worker.CLI.ResendLog(l.ctx, &pb.LogRange{JobId: l.job.GetId(), From: from, To: to})
+	if err == nil {
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			if resp.GetSeq() < l.next || resp.GetSeq() > to {
+				continue
+			}
+			if resp.GetSeq() > l.next {
+				l.missing(l.next, resp.GetSeq()-1)
+			}
+			l.append(resp.GetContent())
+			l.next = resp.GetSeq() + 1
+		}
+	}
+	if l.next <= to {
+		l.missing(l.next, to)
+		l.next = to + 1
+	}
+}
+
+func (l *jobLog) missing(from, to uint64) {
+	l.append([]byte(fmt.Sprintf("\r\n==> Log chunks %d-%d of job %d are missing\r\n", from, to, l.job.GetId())))
+}
+
+func (l *jobLog) append(content []byte) {
+	id, log := l.job.GetId(), string(content)
+	l.job.Log = append(l.job.Log, log)
+	l.worker.WS.Broadcast(fmt.Sprintf("/subs/logs/%d", id), map[string]interface{}{
+		"id":  id,
+		"log": log,
+	})
+}
+
 // StopJob stops the running job.
 func (w *Worker) StopJob(job *pb.Job) (bool, error) {
 	res, err := w.CLI.StopJob(context.Background(), job)
@@ -217,6 +336,28 @@ func (w *Worker) StopJob(job *pb.Job) (bool, error) {
 	return res.GetStopped(), nil
 }
 
+// Drain stops or resumes accepting new jobs on the worker. Workers not
+// implementing drain are drained by server only and lose drain state
+// when they reconnect.
+func (w *Worker) Drain(ctx context.Context, drain bool) error {
+	_, err := w.CLI.Drain(ctx, &pb.DrainRequest{Draining: drain})
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		return err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if drain && !w.Draining {
+		w.DrainedAt = lib.TimeNow()
+	}
+	if !drain {
+		w.DrainedAt = nil
+	}
+	w.Draining = drain
+	w.emitUsage()
+	return nil
+}
+
 // usageStats gRPC stream.
 func (w *Worker) usageStats(ctx context.Context) error {
 	stream, err := w.CLI.Usage(ctx)
@@ -237,6 +378,7 @@ func (w *Worker) usageStats(ctx context.Context) error {
 			Mem:       int(stats.GetMem()),
 			Max:       w.Max,
 			Running:   w.Running,
+			Draining:  w.Draining,
 			Timestamp: time.Now(),
 		}
 		w.Usage = append(w.Usage, usage)
@@ -283,6 +425,27 @@ func (w *Worker) emitUsage() {
 		"mem":         usage.Mem,
 		"jobsMax":     w.Max,
 		"jobsRunning": w.Running,
+		"capacity":    w.Capacity,
+		"used":        w.Used,
+		"draining":    w.Draining,
 		"timestamp":   time.Now(),
 	})
 }
+
+// rpcOptions returns gRPC options from config, defaults are used when
+// config is not set.
+func rpcOptions(cfg *config.GRPC) rpc.Options {
+	if cfg == nil {
+		return rpc.Options{}
+	}
+	opts := rpc.Options{
+		MaxRecvMsgSize: cfg.MaxRecvMsgSize,
+		MaxSendMsgSize: cfg.MaxSendMsgSize,
+	}
+	if ka := cfg.Keepalive; ka != nil {
+		opts.KeepaliveTime = ka.Time
+		opts.KeepaliveTimeout = ka.Timeout
+		opts.PermitWithoutStream = ka.PermitWithoutStream
+	}
+	return opts
+}
@@ -1,15 +1,18 @@
 package http
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 
+	"github.com/bleenco/abstruse/internal/requestid"
+	"github.com/bleenco/abstruse/pkg/tlsutil"
 	"github.com/bleenco/abstruse/server/api"
+	"github.com/bleenco/abstruse/server/api/middlewares"
 	"github.com/bleenco/abstruse/server/config"
 	"github.com/dustin/go-humanize"
 	"github.com/felixge/httpsnoop"
-	"github.com/go-chi/chi/middleware"
 	"go.uber.org/zap"
 )
 
@@ -20,7 +23,9 @@ var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
 type Server struct {
 	*http.Server
 	router    *api.Router
+	settings  *settingsHandler
 	config    *config.HTTP
+	tls       *config.TLS
 	logger    *zap.SugaredLogger
 	listener  net.Listener
 	isRunning bool
@@ -30,24 +35,60 @@ type Server struct {
 // New creates a new HTTP server instance.
 func New(config *config.Config, logger *zap.Logger, router *api.Router) *Server {
 	return &Server{
-		Server:  &http.Server{},
-		router:  router,
-		logger:  logger.With(zap.String("type", "http")).Sugar(),
-		config:  config.HTTP,
-		running: make(chan error),
+		Server: &http.Server{
+			ReadTimeout:       config.HTTP.ReadTimeout,
+			WriteTimeout:      config.HTTP.WriteTimeout,
+			IdleTimeout:       config.HTTP.IdleTimeout,
+			ReadHeaderTimeout: config.HTTP.ReadHeaderTimeout,
+			ConnContext:       withConn,
+		},
+		router:   router,
+		settings: &settingsHandler{},
+		logger:   logger.With(zap.String("type", "http")).Sugar(),
+		config:   config.HTTP,
+		tls:      config.TLS,
+		running:  make(chan error),
 	}
 }
 
-// Run starts HTTP server instance and listens of specified port.
+// Run starts HTTP server instance and listens of specified port or
+// unix socket when address is in unix:///path/to/sock form.
 func (s Server) Run() error {
-	addr := s.config.Addr
-	listener, err := net.Listen("tcp", addr)
+	network, addr := listenAddr(s.config.Addr)
+	listener, err := listen(network, addr)
 	if err != nil {
 		return err
 	}
-	s.Handler = s.logHandler(s.router.Handler())
+	s.settings.next = s.router.Handler()
+	s.settings.apply(s.config)
+	s.Handler = streamHandler(s.logHandler(s.settings))
+	if s.config.BaseURL == "" {
+		s.Handler = forwarded(s.Handler)
+	} else if prefix := s.config.Prefix(); prefix != "" {
+		s.Handler = prefixHandler(prefix, s.Handler)
+	}
+
+	if s.config.TLS && network == "unix" {
+		s.logger.Infof("TLS disabled on unix socket, expecting reverse proxy to terminate TLS")
+	}
 
-	s.logger.Infof("starting HTTP server on %s", addr)
+	if s.config.TLS && network != "unix" {
+		certs, err := tlsutil.NewCertReloader(s.tls.Cert, s.tls.Key)
+		if err != nil {
+			return err
+		}
+		if err := certs.Watch(s.certReloaded); err != nil {
+			return err
+		}
+		s.RegisterOnShutdown(func() { certs.Close() })
+		s.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+
+		s.logger.Infof("starting HTTPS server on %s", addr)
+		go s.closeWith(s.ServeTLS(listener, "", ""))
+		return nil
+	}
+
+	s.logger.Infof("starting HTTP server on %s", s.config.Addr)
 
 	go s.closeWith(s.Serve(listener))
 
@@ -76,17 +117,39 @@ func (s Server) closeWith(err error) {
 	s.running <- err
 }
 
+// Reload applies compression and CORS settings of reloaded config,
+// other HTTP settings take effect after restart.
+func (s Server) Reload(config *config.HTTP) {
+	s.settings.apply(config)
+}
+
+func (s Server) certReloaded(err error) {
+	if err != nil {
+		s.logger.Warnf("error reloading TLS certificate, serving previous one: %v", err)
+		return
+	}
+	s.logger.Infof("TLS certificate reloaded from %s", s.tls.Cert)
+}
+
 func (s Server) logHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		m := httpsnoop.CaptureMetrics(middleware.RealIP(handler), w, r)
-		s.logger.Debugf(
-			"%s %s (code=%d dt=%s written=%s remote=%s)",
-			r.Method,
-			r.URL,
-			m.Code,
-			m.Duration,
-			humanize.Bytes(uint64(m.Written)),
-			r.RemoteAddr,
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		r = r.WithContext(requestid.WithContext(r.Context(), id))
+
+		m := httpsnoop.CaptureMetrics(middlewares.RealIP(s.config.Proxies())(handler), w, r)
+		s.logger.Infow(
+			"request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", m.Code,
+			"latency", m.Duration,
+			"written", humanize.Bytes(uint64(m.Written)),
+			"remote", r.RemoteAddr,
+			"request_id", id,
 		)
 	})
 }
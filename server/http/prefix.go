@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+var (
+	xForwardedProto = http.CanonicalHeaderKey("X-Forwarded-Proto")
+	xForwardedHost  = http.CanonicalHeaderKey("X-Forwarded-Host")
+)
+
+// prefixHandler serves handler under path prefix of base URL, prefix is
+// stripped from request path. Requests outside prefix are not found and
+// prefix itself is redirected to prefix with trailing slash.
+func prefixHandler(prefix string, handler http.Handler) http.Handler {
+	strip := http.StripPrefix(prefix, handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == prefix:
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, prefix+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// forwarded sets scheme and host of request from headers set by reverse
+// proxy, so links created from request point to the proxy. It is used
+// only when base URL is not set.
+func forwarded(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proto := r.Header.Get(xForwardedProto); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := r.Header.Get(xForwardedHost); host != "" {
+			r.Host = strings.TrimSpace(strings.Split(host, ",")[0])
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
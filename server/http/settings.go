@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/cors"
+)
+
+// settingsHandler wraps handler with compression and CORS middlewares
+// configured by HTTP settings, which are replaced on config reload
+// without affecting in-flight requests.
+type settingsHandler struct {
+	next    http.Handler
+	current atomic.Value // http.Handler
+}
+
+func (h *settingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// apply builds middlewares from config and serves following requests
+// with them.
+func (h *settingsHandler) apply(config *config.HTTP) {
+	handler := h.next
+	if c := config.CORS; c != nil && len(c.AllowedOrigins) > 0 {
+		cors := cors.New(cors.Options{
+			AllowedOrigins:   c.AllowedOrigins,
+			AllowedMethods:   c.AllowedMethods,
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			ExposedHeaders:   []string{"Link"},
+			AllowCredentials: c.AllowCredentials,
+			MaxAge:           300,
+		})
+		handler = cors.Handler(handler)
+	}
+	if config.Compress {
+		handler = middleware.Compress(5)(handler)
+	}
+	h.current.Store(handler)
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamPaths are endpoints serving long-lived connections, like websocket
+// log streaming, which are exempt from server read and write timeouts.
+var streamPaths = []string{"/ws", "/api/v1/events"}
+
+type connKey struct{}
+
+// withConn stores connection in the context so its deadlines can be
+// changed by handlers.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// streamHandler clears deadlines set by the server on connections of
+// streaming endpoints so they are not cut off after timeout.
+func streamHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStream(r.URL.Path) {
+			if conn, ok := r.Context().Value(connKey{}).(net.Conn); ok {
+				conn.SetDeadline(time.Time{})
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isStream(path string) bool {
+	for _, p := range streamPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
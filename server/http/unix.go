@@ -0,0 +1,61 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// socketPerm is file mode of the unix socket, reverse proxy is expected
+// to run as the same user or in the same group as the server.
+const socketPerm = 0660
+
+// listenAddr returns network and address to listen on, addresses in
+// unix:///path/to/sock form listen on unix socket, others on TCP.
+func listenAddr(addr string) (string, string) {
+	if strings.HasPrefix(addr, "unix://") {
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	return "tcp", addr
+}
+
+// listen creates listener on network address. Stale unix socket left
+// by server that was not shut down gracefully is removed first.
+func listen(network, addr string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if err := removeStaleSocket(addr); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, socketPerm); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
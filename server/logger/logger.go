@@ -1,44 +1,117 @@
 package logger
 
 import (
+	"io"
 	"os"
+	"sync"
+	"sync/atomic"
 
+	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/server/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// New returns new zap logger from config.
+var (
+	mu      sync.Mutex
+	current atomic.Value // zapcore.Core
+	file    io.Closer
+)
+
+// New returns new zap logger from config. Loggers share core which is
+// replaced by Reload, so derived loggers follow reloaded config.
 func New(config *config.Config) (*zap.Logger, error) {
+	if err := Reload(config); err != nil {
+		return nil, err
+	}
+
+	logger := zap.New(&swapCore{core: &current})
+	zap.ReplaceGlobals(logger)
+
+	return logger, nil
+}
+
+// Reload replaces logging level, timestamp format and outputs from
+// config, previous log file is closed.
+func Reload(config *config.Config) error {
 	cfg := config.Logger
-	var logger *zap.Logger
 	level := zap.NewAtomicLevel()
 
 	err := level.UnmarshalText([]byte(cfg.Level))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	loc, err := config.Display.Location()
+	if err != nil {
+		return err
 	}
 
-	fw := zapcore.AddSync(&lumberjack.Logger{
+	lj := &lumberjack.Logger{
 		Filename:   cfg.Filename,
 		MaxSize:    cfg.MaxSize, // megabytes
 		MaxBackups: cfg.MaxBackups,
 		MaxAge:     cfg.MaxAge, // days
-	})
+	}
+	fw := zapcore.AddSync(lj)
 	cw := zapcore.Lock(os.Stdout)
 	cores := make([]zapcore.Core, 0, 2)
-	je := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	jc, cc := zap.NewProductionEncoderConfig(), zap.NewDevelopmentEncoderConfig()
+	if enc := lib.TimeEncoder(cfg.TimeFormat, loc); enc != nil {
+		jc.EncodeTime, cc.EncodeTime = enc, enc
+	}
+	je := zapcore.NewJSONEncoder(jc)
 	cores = append(cores, zapcore.NewCore(je, fw, level))
 
 	if cfg.Stdout {
-		ce := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+		ce := zapcore.NewConsoleEncoder(cc)
 		cores = append(cores, zapcore.NewCore(ce, cw, level))
 	}
 
-	core := zapcore.NewTee(cores...)
-	logger = zap.New(core)
-	zap.ReplaceGlobals(logger)
+	mu.Lock()
+	defer mu.Unlock()
+	current.Store(zapcore.NewTee(cores...))
+	if file != nil {
+		file.Close()
+	}
+	file = lj
 
-	return logger, nil
+	return nil
+}
+
+// swapCore delegates to core stored in the value, fields added with
+// With are applied to the core current at the time of logging.
+type swapCore struct {
+	core   *atomic.Value
+	fields []zapcore.Field
+}
+
+func (c *swapCore) load() zapcore.Core {
+	core := c.core.Load().(zapcore.Core)
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
+	}
+	return core
+}
+
+func (c *swapCore) Enabled(level zapcore.Level) bool {
+	return c.core.Load().(zapcore.Core).Enabled(level)
+}
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	f := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	f = append(f, c.fields...)
+	return &swapCore{core: c.core, fields: append(f, fields...)}
+}
+
+func (c *swapCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.load().Check(ent, ce)
+}
+
+func (c *swapCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.load().Write(ent, fields)
+}
+
+func (c *swapCore) Sync() error {
+	return c.core.Load().(zapcore.Core).Sync()
 }
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Clone defaults.
+const (
+	DefaultCloneDepth     = 50
+	maxSubmoduleRecursion = 10
+)
+
+// ParseCloneConfig returns clone config from raw .abstruse.yml config.
+func ParseCloneConfig(raw string) (CloneConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return CloneConfig{}, err
+	}
+	return parsed.Clone, parsed.Clone.validate()
+}
+
+// CloneDepth returns number of commits to fetch, 0 for full history.
+func (c CloneConfig) CloneDepth() int {
+	if c.Depth == nil {
+		return DefaultCloneDepth
+	}
+	return *c.Depth
+}
+
+// SubmoduleDepth returns submodule recursion depth, 0 when submodules
+// are not fetched.
+func (c CloneConfig) SubmoduleDepth() int {
+	switch c.Submodules {
+	case "false":
+		return 0
+	case "true":
+		return 1
+	default:
+		return maxSubmoduleRecursion
+	}
+}
+
+// TagMode returns which tags are fetched, all, none or following for
+// tags pointing to fetched commits.
+func (c CloneConfig) TagMode() string {
+	switch {
+	case c.Tags == nil:
+		return "following"
+	case *c.Tags:
+		return "all"
+	default:
+		return "none"
+	}
+}
+
+func (c CloneConfig) validate() error {
+	if c.Depth != nil && *c.Depth < 0 {
+		return fmt.Errorf("invalid clone depth %d", *c.Depth)
+	}
+	switch c.Submodules {
+	case "", "true", "false", "recursive":
+	default:
+		return fmt.Errorf("invalid clone submodules %s (available options: true, false, recursive)", c.Submodules)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/step"
+)
+
+// CommandConfig defines command in .abstruse.yml file, either plain
+// command or command with conditions it runs on:
+//
+//	deploy:
+//	  - run: ./deploy.sh
+//	    when:
+//	      - branch: [main, release/.*]
+//	        pull_request: false
+//	      - tag: true
+//
+// Command runs when any of conditions matches, condition matches when
+// all of its predicates match. Env sets variables of the command only.
+// Failed command is run again up to Retries times, Backoff is duration
+// to wait before the first retry, doubled before each next one.
+// Shell and Workdir override shell and working directory of the build.
+type CommandConfig struct {
+	Run     string     `yaml:"run"`
+	When    Conditions `yaml:"when"`
+	Env     EnvConfig  `yaml:"env"`
+	Retries int        `yaml:"retries"`
+	Backoff string     `yaml:"backoff"`
+	Shell   string     `yaml:"shell"`
+	Workdir string     `yaml:"workdir"`
+}
+
+// ConditionConfig defines predicates of command condition. Branch
+// patterns are regular expressions matched against whole branch name,
+// pull request builds are matched by target branch and tag builds
+// never match branch patterns. Status selects whether command runs
+// when previous commands passed (success, default), failed (failure)
+// or in both cases (always).
+type ConditionConfig struct {
+	Branch      Patterns `yaml:"branch"`
+	Tag         *bool    `yaml:"tag"`
+	PullRequest *bool    `yaml:"pull_request"`
+	Status      string   `yaml:"status"`
+}
+
+// Conditions is list of conditions, single condition can be written
+// without list.
+type Conditions []ConditionConfig
+
+// Patterns is list of patterns, single pattern can be written without list.
+type Patterns []string
+
+// UnmarshalYAML decodes command from string or mapping.
+func (c *CommandConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var run string
+	if err := unmarshal(&run); err == nil {
+		*c = CommandConfig{Run: run}
+		return nil
+	}
+	type plain CommandConfig
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	*c = CommandConfig(p)
+	return nil
+}
+
+// UnmarshalYAML decodes conditions from list or single mapping.
+func (c *Conditions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var cond ConditionConfig
+	if err := unmarshal(&cond); err == nil {
+		*c = Conditions{cond}
+		return nil
+	}
+	var conds []ConditionConfig
+	if err := unmarshal(&conds); err != nil {
+		return err
+	}
+	*c = conds
+	return nil
+}
+
+// UnmarshalYAML decodes patterns from list or single string.
+func (p *Patterns) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var pattern string
+	if err := unmarshal(&pattern); err == nil {
+		*p = Patterns{pattern}
+		return nil
+	}
+	var patterns []string
+	if err := unmarshal(&patterns); err != nil {
+		return err
+	}
+	*p = patterns
+	return nil
+}
+
+func (c CommandConfig) validate() error {
+	if strings.TrimSpace(c.Run) == "" {
+		return fmt.Errorf("command not specified")
+	}
+	if c.Retries < 0 || c.Retries > step.MaxRetries {
+		return fmt.Errorf("retries of command %s must be between 0 and %d", c.Run, step.MaxRetries)
+	}
+	if c.Backoff != "" {
+		if d, err := time.ParseDuration(c.Backoff); err != nil || d < 0 {
+			return fmt.Errorf("invalid backoff %q of command %s", c.Backoff, c.Run)
+		}
+	}
+	if !step.ValidWorkdir(c.Workdir) {
+		return fmt.Errorf("workdir %q of command %s must be relative path within workspace", c.Workdir, c.Run)
+	}
+	for _, cond := range c.When {
+		switch cond.Status {
+		case "", step.OnSuccess, step.OnFailure, step.Always:
+		default:
+			return fmt.Errorf("invalid status condition %q of command %s", cond.Status, c.Run)
+		}
+		for _, pattern := range cond.Branch {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid branch condition %q of command %s: %v", pattern, c.Run, err)
+			}
+		}
+	}
+	return c.Env.validate()
+}
+
+// command returns step with status condition of the first condition
+// matching the build, command is skipped when no condition matches.
+func (c *ConfigParser) command(cmd CommandConfig) step.Step {
+	s := step.Step{Run: cmd.Run, Env: cmd.Env, Retries: cmd.Retries, Shell: cmd.Shell, Workdir: cmd.Workdir}
+	if strings.TrimSpace(s.Shell) == "" {
+		s.Shell = c.Parsed.Shell
+	}
+	if s.Workdir == "" {
+		s.Workdir = c.Parsed.Workdir
+	}
+	if cmd.Retries > 0 && cmd.Backoff != "" {
+		s.Backoff, _ = time.ParseDuration(cmd.Backoff)
+	}
+	if len(cmd.When) == 0 {
+		return s
+	}
+	for _, cond := range cmd.When {
+		if c.matches(cond) {
+			s.When = cond.Status
+			return s
+		}
+	}
+	s.Skip = true
+	return s
+}
+
+func (c *ConfigParser) matches(cond ConditionConfig) bool {
+	tag := strings.HasPrefix(c.Ref, "refs/tags/")
+	if cond.Tag != nil && *cond.Tag != tag {
+		return false
+	}
+	if cond.PullRequest != nil && *cond.PullRequest != c.PR {
+		return false
+	}
+	if len(cond.Branch) > 0 {
+		if tag {
+			return false
+		}
+		var match bool
+		for _, pattern := range cond.Branch {
+			if ok, _ := regexp.MatchString(fmt.Sprintf("^(?:%s)$", pattern), c.Branch); ok {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// runs returns commands without conditions, used for job titles.
+func runs(cmds []CommandConfig) []string {
+	var r []string
+	for _, cmd := range cmds {
+		r = append(r, cmd.Run)
+	}
+	return r
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestCommandConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    string
+		branch string
+		ref    string
+		pr     bool
+		failed bool
+		want   bool
+	}{
+		{"plain command", "make", "main", "refs/heads/main", false, false, true},
+		{"plain command after failure", "make", "main", "refs/heads/main", false, true, false},
+		{"branch", "{run: make, when: {branch: main}}", "main", "refs/heads/main", false, false, true},
+		{"other branch", "{run: make, when: {branch: main}}", "dev", "refs/heads/dev", false, false, false},
+		{"branch pattern", "{run: make, when: {branch: release/.*}}", "release/1.0", "refs/heads/release/1.0", false, false, true},
+		{"branch pattern matches whole name", "{run: make, when: {branch: release/.*}}", "old-release/1.0", "refs/heads/old-release/1.0", false, false, false},
+		{"branch prefix", "{run: make, when: {branch: main}}", "main-fix", "refs/heads/main-fix", false, false, false},
+		{"branch list", "{run: make, when: {branch: [main, dev]}}", "dev", "refs/heads/dev", false, false, true},
+		{"pull request target branch", "{run: make, when: {branch: main}}", "main", "refs/pull/1/head", true, false, true},
+		{"tag", "{run: make, when: {tag: true}}", "v1.0", "refs/tags/v1.0", false, false, true},
+		{"tag on branch", "{run: make, when: {tag: true}}", "main", "refs/heads/main", false, false, false},
+		{"not tag", "{run: make, when: {tag: false}}", "v1.0", "refs/tags/v1.0", false, false, false},
+		{"not tag on branch", "{run: make, when: {tag: false}}", "main", "refs/heads/main", false, false, true},
+		{"tag never matches branch", "{run: make, when: {branch: .*}}", "v1.0", "refs/tags/v1.0", false, false, false},
+		{"pull request", "{run: make, when: {pull_request: true}}", "main", "refs/pull/1/head", true, false, true},
+		{"pull request on push", "{run: make, when: {pull_request: true}}", "main", "refs/heads/main", false, false, false},
+		{"not pull request", "{run: make, when: {pull_request: false}}", "main", "refs/pull/1/head", true, false, false},
+		{"all predicates match", "{run: make, when: {branch: main, pull_request: false}}", "main", "refs/heads/main", false, false, true},
+		{"one predicate fails", "{run: make, when: {branch: main, pull_request: false}}", "main", "refs/pull/1/head", true, false, false},
+		{"any condition matches", "{run: make, when: [{branch: main}, {tag: true}]}", "v1.0", "refs/tags/v1.0", false, false, true},
+		{"no condition matches", "{run: make, when: [{branch: main}, {tag: true}]}", "dev", "refs/heads/dev", false, false, false},
+		{"success", "{run: make, when: {status: success}}", "main", "refs/heads/main", false, false, true},
+		{"success after failure", "{run: make, when: {status: success}}", "main", "refs/heads/main", false, true, false},
+		{"failure", "{run: make, when: {status: failure}}", "main", "refs/heads/main", false, false, false},
+		{"failure after failure", "{run: make, when: {status: failure}}", "main", "refs/heads/main", false, true, true},
+		{"always", "{run: make, when: {status: always}}", "main", "refs/heads/main", false, false, true},
+		{"always after failure", "{run: make, when: {status: always}}", "main", "refs/heads/main", false, true, true},
+		{"always on other branch", "{run: make, when: {branch: main, status: always}}", "dev", "refs/heads/dev", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmd CommandConfig
+			if err := yaml.Unmarshal([]byte(tt.cmd), &cmd); err != nil {
+				t.Fatalf("unmarshal %s: %v", tt.cmd, err)
+			}
+			if err := cmd.validate(); err != nil {
+				t.Fatalf("validate %s: %v", tt.cmd, err)
+			}
+			c := ConfigParser{Branch: tt.branch, Ref: tt.ref, PR: tt.pr}
+			if got := c.command(cmd).Runs(tt.failed); got != tt.want {
+				t.Errorf("command %s runs = %v, want %v", tt.cmd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandConditionsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  string
+	}{
+		{"invalid branch pattern", "{run: make, when: {branch: 'release/[0-9'}}"},
+		{"invalid pattern in list", "{run: make, when: {branch: [main, '(dev']}}"},
+		{"invalid status", "{run: make, when: {status: sometimes}}"},
+		{"invalid status in second condition", "{run: make, when: [{branch: main}, {status: never}]}"},
+		{"empty command", "{run: ' ', when: {branch: main}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cmd CommandConfig
+			if err := yaml.Unmarshal([]byte(tt.cmd), &cmd); err != nil {
+				t.Fatalf("unmarshal %s: %v", tt.cmd, err)
+			}
+			if err := cmd.validate(); err == nil {
+				t.Errorf("validate %s: expected error", tt.cmd)
+			}
+		})
+	}
+}
@@ -2,10 +2,62 @@ package parser
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/bleenco/abstruse/pkg/envvar"
 	"github.com/bleenco/abstruse/server/core"
+	yaml "gopkg.in/yaml.v2"
 )
 
+// EnvConfig defines environment variables in .abstruse.yml file as list
+// of KEY=value pairs or mapping, variables are defined in order so
+// values can reference variables defined before them with ${KEY}.
+type EnvConfig []string
+
+// UnmarshalYAML decodes variables from list or mapping.
+func (e *EnvConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var pairs []string
+	if err := unmarshal(&pairs); err == nil {
+		*e = pairs
+		return nil
+	}
+	var m yaml.MapSlice
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	*e = nil
+	for _, item := range m {
+		value := ""
+		if item.Value != nil {
+			value = fmt.Sprintf("%v", item.Value)
+		}
+		*e = append(*e, fmt.Sprintf("%v=%s", item.Key, value))
+	}
+	return nil
+}
+
+func (e EnvConfig) validate() error {
+	for _, pair := range e {
+		if err := envvar.Validate(pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseEnvConfig returns global environment variables from raw
+// .abstruse.yml config.
+func ParseEnvConfig(raw string) (EnvConfig, error) {
+	var parsed struct {
+		Env EnvConfig `yaml:"env"`
+	}
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed.Env, parsed.Env.validate()
+}
+
 // GenerateGlobalEnv generates global env variables.
 func GenerateGlobalEnv(build *core.Build) []string {
 	envs := make(map[string]string)
@@ -24,6 +76,46 @@ func GenerateGlobalEnv(build *core.Build) []string {
 	return toSlice(envs)
 }
 
+// JobEnv resolves environment of the job defined in layers, variables
+// of later layers override variables of earlier ones:
+//
+//  1. built-in ABSTRUSE_* variables
+//  2. env of .abstruse.yml
+//  3. environment variables of the repository
+//  4. variables the build was triggered with
+//  5. matrix env of the job
+//
+// Env of commands is resolved by worker on top of job environment.
+// Job must be loaded with its build and repository, environment is
+// returned without config env when it is invalid.
+func JobEnv(job *core.Job) (*envvar.Env, error) {
+	env := envvar.New()
+	env.SetAll(GenerateGlobalEnv(job.Build), false)
+
+	global, err := ParseEnvConfig(job.Build.Config)
+	if err == nil {
+		env.SetAll(global, false)
+	}
+
+	for _, e := range job.Build.Repository.EnvVariables {
+		env.Set(e.Key, e.Value, e.Secret)
+	}
+
+	vars := job.Build.EnvVariables()
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env.Set(key, vars[key], false)
+	}
+
+	env.SetAll(strings.Fields(job.Env), false)
+
+	return env, err
+}
+
 func toSlice(envs map[string]string) []string {
 	var result []string
 	for key, val := range envs {
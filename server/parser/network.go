@@ -0,0 +1,50 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Network modes of build containers.
+const (
+	NetworkBridge = "bridge"
+	NetworkHost   = "host"
+	NetworkNone   = "none"
+)
+
+var networkName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// NetworkConfig defines network of build containers in .abstruse.yml
+// file. Mode is bridge, host, none or name of existing network, worker
+// default network is used when not set. ExtraHosts are host:ip entries
+// added to /etc/hosts of the container.
+type NetworkConfig struct {
+	Mode       string   `yaml:"mode"`
+	ExtraHosts []string `yaml:"extra_hosts"`
+}
+
+// ParseNetworkConfig returns network config from raw .abstruse.yml config.
+func ParseNetworkConfig(raw string) (NetworkConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return NetworkConfig{}, err
+	}
+	return parsed.Network, parsed.Network.validate()
+}
+
+func (n NetworkConfig) validate() error {
+	if n.Mode != "" && !networkName.MatchString(n.Mode) {
+		return fmt.Errorf("invalid network mode %q (available options: bridge, host, none or network name)", n.Mode)
+	}
+	for _, entry := range n.ExtraHosts {
+		i := strings.Index(entry, ":")
+		if i < 1 || net.ParseIP(entry[i+1:]) == nil {
+			return fmt.Errorf("invalid extra host %q, must be host:ip", entry)
+		}
+	}
+	return nil
+}
@@ -5,9 +5,16 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/bleenco/abstruse/pkg/imagepolicy"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/pkg/step"
+	"github.com/bleenco/abstruse/server/pipeline"
+	units "github.com/docker/go-units"
 	yaml "gopkg.in/yaml.v2"
 )
 
+var archName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
 // Job stage constants
 const (
 	JobStageTest   = "test"
@@ -16,26 +23,69 @@ const (
 
 // RepoConfig defines structure for .abstruse.yml configuration files.
 type RepoConfig struct {
-	Image         string         `yaml:"image"`
-	Branches      BranchesConfig `yaml:"branches"`
-	Matrix        []MatrixConfig `yaml:"matrix"`
-	BeforeInstall []string       `yaml:"before_install"`
-	Install       []string       `yaml:"install"`
-	BeforeScript  []string       `yaml:"before_script"`
-	Script        []string       `yaml:"script"`
-	AfterSuccess  []string       `yaml:"after_success"`
-	AfterFailure  []string       `yaml:"after_failure"`
-	BeforeDeploy  []string       `yaml:"before_deploy"`
-	Deploy        []string       `yaml:"deploy"`
-	AfterDeploy   []string       `yaml:"after_deploy"`
-	AfterScript   []string       `yaml:"after_script"`
-	Cache         []string       `yaml:"cache"`
+	Image         string          `yaml:"image"`
+	Env           EnvConfig       `yaml:"env"`
+	Branches      BranchesConfig  `yaml:"branches"`
+	Matrix        []MatrixConfig  `yaml:"matrix"`
+	BeforeInstall []CommandConfig `yaml:"before_install"`
+	Install       []CommandConfig `yaml:"install"`
+	BeforeScript  []CommandConfig `yaml:"before_script"`
+	Script        []CommandConfig `yaml:"script"`
+	AfterSuccess  []CommandConfig `yaml:"after_success"`
+	AfterFailure  []CommandConfig `yaml:"after_failure"`
+	BeforeDeploy  []CommandConfig `yaml:"before_deploy"`
+	Deploy        []CommandConfig `yaml:"deploy"`
+	AfterDeploy   []CommandConfig `yaml:"after_deploy"`
+	AfterScript   []CommandConfig `yaml:"after_script"`
+	Cache         []string        `yaml:"cache"`
+	Resources     ResourceConfig  `yaml:"resources"`
+	Stages        []StageConfig   `yaml:"stages"`
+	Clone         CloneConfig     `yaml:"clone"`
+	Reports       ReportsConfig   `yaml:"reports"`
+	Network       NetworkConfig   `yaml:"network"`
+	Services      ServicesConfig  `yaml:"services"`
+	Secrets       SecretsConfig   `yaml:"secrets"`
+	Status        StatusConfig    `yaml:"status"`
+	Worker        string          `yaml:"worker"`      // ID of worker jobs are pinned to
+	Concurrency   string          `yaml:"concurrency"` // group of builds which never run at the same time
+	Arch          string          `yaml:"arch"`        // architecture of workers jobs run on, any by default
+	Shell         string          `yaml:"shell"`       // shell commands run with, strict bash by default
+	Workdir       string          `yaml:"workdir"`     // working directory relative to workspace
+}
+
+// StageConfig defines structure for stage config in .abstruse.yml file.
+// Stage starts when all stages it needs have passed, matrix jobs of the
+// stage run in parallel.
+type StageConfig struct {
+	Name   string          `yaml:"name"`
+	Needs  []string        `yaml:"needs"`
+	Image  string          `yaml:"image"`
+	Matrix []MatrixConfig  `yaml:"matrix"`
+	Script []CommandConfig `yaml:"script"`
+}
+
+// ResourceConfig defines build container resource limits in .abstruse.yml file.
+// Limits that are not set default to worker configuration. Weight is
+// part of worker capacity each job of the build consumes, 1 by default.
+type ResourceConfig struct {
+	CPUs      float64 `yaml:"cpus"`
+	Memory    string  `yaml:"memory"` // e.g. 512m, 2g
+	PidsLimit int64   `yaml:"pids_limit"`
+	Weight    int     `yaml:"weight"`
+}
+
+// CloneConfig defines structure for clone config in .abstruse.yml file.
+type CloneConfig struct {
+	Depth      *int   `yaml:"depth"`      // default 50, 0 for full history
+	Submodules string `yaml:"submodules"` // true, false or recursive (default)
+	Tags       *bool  `yaml:"tags"`       // tags pointing to fetched commits when not set
 }
 
 // MatrixConfig defines structure for matrix job config in .abstruse.yml file.
 type MatrixConfig struct {
 	Env   string `yaml:"env"`
 	Image string `yaml:"image"`
+	Arch  string `yaml:"arch"` // overrides architecture of the build
 }
 
 // BranchesConfig defines structure for branches config in .abstruse.yml file.
@@ -46,18 +96,29 @@ type BranchesConfig struct {
 
 // JobConfig represents generated job configuration.
 type JobConfig struct {
-	Image    string   `json:"image"`
-	Env      []string `json:"env"`
-	Stage    string   `json:"stage"`
-	Title    string   `json:"title"`
-	Commands []string `json:"commands"`
-	Cache    []string `json:"cache"`
+	Image     string      `json:"image"`
+	Env       []string    `json:"env"`
+	Stage     string      `json:"stage"`
+	Needs     []string    `json:"needs"`
+	Title     string      `json:"title"`
+	Commands  []step.Step `json:"commands"`
+	Cache     []string    `json:"cache"`
+	CPUs      float64     `json:"cpus"`
+	Memory    int64       `json:"memory"` // in bytes
+	PidsLimit int64       `json:"pidsLimit"`
+	Weight    int         `json:"weight"`
+	Arch      string      `json:"arch"`
 }
 
-// ConfigParser defines repository configuration parser.
+// ConfigParser defines repository configuration parser. Ref and PR
+// describe the build command conditions are evaluated for, Images
+// sets default image and images jobs are allowed to use.
 type ConfigParser struct {
 	Raw    string
 	Branch string
+	Ref    string
+	PR     bool
+	Images imagepolicy.Policy
 	Parsed RepoConfig
 	Env    []string
 }
@@ -82,16 +143,77 @@ func (c *ConfigParser) Parse() ([]JobConfig, error) {
 	if err := yaml.Unmarshal([]byte(c.Raw), &c.Parsed); err != nil {
 		return jobs, err
 	}
+	c.Parsed.Image = c.Images.Image(c.Parsed.Image)
 
-	if len(c.Parsed.Script) == 0 {
+	if len(c.Parsed.Stages) == 0 && len(c.Parsed.Script) == 0 {
 		return jobs, fmt.Errorf("script commands not specified")
 	}
 
-	if len(c.Parsed.Matrix) > 0 {
+	if err := c.Parsed.Clone.validate(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Reports.validate(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Network.validate(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Services.validate(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Secrets.validate(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Status.validate(); err != nil {
+		return jobs, err
+	}
+	if len(c.Parsed.Services) > 0 {
+		switch c.Parsed.Network.Mode {
+		case NetworkBridge, NetworkHost, NetworkNone:
+			return jobs, fmt.Errorf("services require default or named network, not %s", c.Parsed.Network.Mode)
+		}
+		for _, svc := range c.Parsed.Services {
+			if err := c.Images.Check(svc.Image); err != nil {
+				return jobs, err
+			}
+		}
+	}
+
+	if !step.ValidWorkdir(c.Parsed.Workdir) {
+		return jobs, fmt.Errorf("workdir %q must be relative path within workspace", c.Parsed.Workdir)
+	}
+	if err := c.Parsed.validateCommands(); err != nil {
+		return jobs, err
+	}
+	if err := c.Parsed.Env.validate(); err != nil {
+		return jobs, err
+	}
+
+	resources := c.Parsed.Resources
+	if resources.CPUs < 0 || resources.PidsLimit < 0 || resources.Weight < 0 {
+		return jobs, fmt.Errorf("invalid resource limits")
+	}
+	var memory int64
+	if resources.Memory != "" {
+		m, err := units.RAMInBytes(resources.Memory)
+		if err != nil || m < 0 {
+			return jobs, fmt.Errorf("invalid memory limit %s", resources.Memory)
+		}
+		memory = m
+	}
+
+	if len(c.Parsed.Stages) > 0 {
+		stages, err := c.parseStages()
+		if err != nil {
+			return jobs, err
+		}
+		jobs = stages
+	} else if len(c.Parsed.Matrix) > 0 {
 		for _, item := range c.Parsed.Matrix {
-			job := JobConfig{}
+			job := JobConfig{Arch: item.Arch}
 
 			// set image
+			job.Arch = item.Arch
 			if item.Image != "" {
 				job.Image = item.Image
 			} else {
@@ -115,7 +237,7 @@ func (c *ConfigParser) Parse() ([]JobConfig, error) {
 			if item.Env != "" {
 				job.Title = item.Env
 			} else {
-				job.Title = strings.Join(c.Parsed.Script, " ")
+				job.Title = strings.Join(runs(c.Parsed.Script), " ")
 			}
 			job.Commands = c.generateCommands()
 
@@ -126,7 +248,7 @@ func (c *ConfigParser) Parse() ([]JobConfig, error) {
 			Image:    c.Parsed.Image,
 			Env:      c.Env,
 			Stage:    JobStageTest,
-			Title:    strings.Join(c.Parsed.Script, " "),
+			Title:    strings.Join(runs(c.Parsed.Script), " "),
 			Commands: c.generateCommands(),
 		}
 		if job.Image == "" {
@@ -136,12 +258,13 @@ func (c *ConfigParser) Parse() ([]JobConfig, error) {
 		jobs = append(jobs, job)
 	}
 
-	if len(c.Parsed.Deploy) > 0 {
+	if len(c.Parsed.Stages) == 0 && len(c.Parsed.Deploy) > 0 {
 		job := JobConfig{
 			Image:    c.Parsed.Image,
 			Env:      c.Env,
 			Stage:    JobStageDeploy,
-			Title:    strings.Join(c.Parsed.Deploy, " "),
+			Needs:    []string{JobStageTest},
+			Title:    strings.Join(runs(c.Parsed.Deploy), " "),
 			Commands: c.generateDeployCommands(),
 		}
 		if job.Image == "" {
@@ -151,6 +274,27 @@ func (c *ConfigParser) Parse() ([]JobConfig, error) {
 		jobs = append(jobs, job)
 	}
 
+	for i := range jobs {
+		if err := c.Images.Check(jobs[i].Image); err != nil {
+			return nil, err
+		}
+		if jobs[i].Arch == "" {
+			jobs[i].Arch = c.Parsed.Arch
+		}
+		arch := lib.NormalizeArch(jobs[i].Arch)
+		if arch != "" && !archName.MatchString(arch) {
+			return nil, fmt.Errorf("invalid arch %q", jobs[i].Arch)
+		}
+		jobs[i].Arch = arch
+		jobs[i].CPUs = resources.CPUs
+		jobs[i].Memory = memory
+		jobs[i].PidsLimit = resources.PidsLimit
+		jobs[i].Weight = resources.Weight
+		if jobs[i].Weight == 0 {
+			jobs[i].Weight = 1
+		}
+	}
+
 	return jobs, nil
 }
 
@@ -188,30 +332,117 @@ func (c *ConfigParser) ShouldBuild() bool {
 	return true
 }
 
-func (c *ConfigParser) generateCommands() []string {
-	var commands []string
-	commands = appendCommands(commands, c.Parsed.BeforeInstall)
-	commands = appendCommands(commands, c.Parsed.Install)
-	commands = appendCommands(commands, c.Parsed.BeforeScript)
-	commands = appendCommands(commands, c.Parsed.Script)
-	commands = appendCommands(commands, c.Parsed.AfterSuccess)
-	commands = appendCommands(commands, c.Parsed.AfterFailure)
-	commands = appendCommands(commands, c.Parsed.AfterScript)
+// parseStages generates jobs of stages defined in config ordered
+// so that jobs of stage come after jobs of stages it needs.
+func (c *ConfigParser) parseStages() ([]JobConfig, error) {
+	var jobs []JobConfig
+	var stages []pipeline.Stage
+	configs := make(map[string]StageConfig)
+	for _, stage := range c.Parsed.Stages {
+		stages = append(stages, pipeline.Stage{Name: stage.Name, Needs: stage.Needs})
+		configs[stage.Name] = stage
+	}
+
+	p, err := pipeline.New(stages)
+	if err != nil {
+		return jobs, err
+	}
+
+	for _, name := range p.Order() {
+		stage := configs[name]
+		if len(stage.Script) == 0 {
+			return jobs, fmt.Errorf("script commands not specified for stage %s", name)
+		}
+
+		matrix := stage.Matrix
+		if len(matrix) == 0 {
+			matrix = []MatrixConfig{{}}
+		}
+
+		for _, item := range matrix {
+			job := JobConfig{
+				Image:    stage.Image,
+				Stage:    name,
+				Needs:    stage.Needs,
+				Title:    name,
+				Commands: c.generateStageCommands(stage),
+			}
+			if item.Image != "" {
+				job.Image = item.Image
+			}
+			if job.Image == "" {
+				job.Image = c.Parsed.Image
+			}
+			if job.Image == "" {
+				return jobs, fmt.Errorf("image not specified for stage %s", name)
+			}
+
+			job.Env = append(job.Env, c.Env...)
+			if item.Env != "" {
+				job.Env = append(job.Env, item.Env)
+				job.Title = item.Env
+			}
+
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs, nil
+}
+
+func (c *ConfigParser) generateCommands() []step.Step {
+	var commands []step.Step
+	commands = c.appendCommands(commands, c.Parsed.BeforeInstall)
+	commands = c.appendCommands(commands, c.Parsed.Install)
+	commands = c.appendCommands(commands, c.Parsed.BeforeScript)
+	commands = c.appendCommands(commands, c.Parsed.Script)
+	commands = c.appendCommands(commands, c.Parsed.AfterSuccess)
+	commands = c.appendCommands(commands, c.Parsed.AfterFailure)
+	commands = c.appendCommands(commands, c.Parsed.AfterScript)
 	return commands
 }
 
-func (c *ConfigParser) generateDeployCommands() []string {
-	var commands []string
-	commands = appendCommands(commands, c.Parsed.BeforeDeploy)
-	commands = appendCommands(commands, c.Parsed.Deploy)
-	commands = appendCommands(commands, c.Parsed.AfterDeploy)
+func (c *ConfigParser) generateStageCommands(stage StageConfig) []step.Step {
+	var commands []step.Step
+	commands = c.appendCommands(commands, c.Parsed.BeforeInstall)
+	commands = c.appendCommands(commands, c.Parsed.Install)
+	commands = c.appendCommands(commands, c.Parsed.BeforeScript)
+	commands = c.appendCommands(commands, stage.Script)
+	commands = c.appendCommands(commands, c.Parsed.AfterScript)
 	return commands
 }
 
-func appendCommands(commands []string, cmds []string) []string {
+func (c *ConfigParser) generateDeployCommands() []step.Step {
+	var commands []step.Step
+	commands = c.appendCommands(commands, c.Parsed.BeforeDeploy)
+	commands = c.appendCommands(commands, c.Parsed.Deploy)
+	commands = c.appendCommands(commands, c.Parsed.AfterDeploy)
+	return commands
+}
+
+func (c *ConfigParser) appendCommands(commands []step.Step, cmds []CommandConfig) []step.Step {
 	for _, cmd := range cmds {
-		commands = append(commands, cmd)
+		commands = append(commands, c.command(cmd))
 	}
 
 	return commands
 }
+
+// validateCommands checks commands and their conditions.
+func (r RepoConfig) validateCommands() error {
+	lists := [][]CommandConfig{
+		r.BeforeInstall, r.Install, r.BeforeScript, r.Script, r.AfterSuccess, r.AfterFailure,
+		r.BeforeDeploy, r.Deploy, r.AfterDeploy, r.AfterScript,
+	}
+	for _, stage := range r.Stages {
+		lists = append(lists, stage.Script)
+	}
+	for _, cmds := range lists {
+		for _, cmd := range cmds {
+			if err := cmd.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ReportsConfig defines test report files in .abstruse.yml file, paths
+// are glob patterns relative to repository root, e.g. reports/*.xml.
+type ReportsConfig []string
+
+// ParseReportsConfig returns test report paths from raw .abstruse.yml config.
+func ParseReportsConfig(raw string) (ReportsConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed.Reports, parsed.Reports.validate()
+}
+
+func (r ReportsConfig) validate() error {
+	for _, pattern := range r {
+		clean := path.Clean(pattern)
+		if pattern == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid report path %q, must be relative to repository root", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid report path %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/bleenco/abstruse/pkg/envvar"
+	"github.com/bleenco/abstruse/server/core"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var providerName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// SecretConfig defines secret in .abstruse.yml file fetched from
+// external secret provider when job starts and set as secret
+// environment variable Name, e.g. key `password` of Vault secret at
+// path `ci/db`.
+type SecretConfig struct {
+	Name     string `yaml:"name"`
+	Provider string `yaml:"provider"` // vault by default
+	Path     string `yaml:"path"`
+	Key      string `yaml:"key"`
+}
+
+// SecretsConfig is list of secrets of each job of the build.
+type SecretsConfig []SecretConfig
+
+// ParseSecretsConfig returns secrets from raw .abstruse.yml config.
+func ParseSecretsConfig(raw string) (SecretsConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed.Secrets, parsed.Secrets.validate()
+}
+
+// Ref returns reference of the secret in its provider.
+func (s SecretConfig) Ref() core.SecretRef {
+	return core.SecretRef{Provider: s.Provider, Path: s.Path, Key: s.Key}
+}
+
+func (s SecretsConfig) validate() error {
+	names := make(map[string]bool)
+	for _, secret := range s {
+		if err := envvar.Validate(secret.Name + "="); err != nil {
+			return fmt.Errorf("invalid secret name %q", secret.Name)
+		}
+		if names[secret.Name] {
+			return fmt.Errorf("duplicate secret %s", secret.Name)
+		}
+		names[secret.Name] = true
+		if secret.Provider != "" && !providerName.MatchString(secret.Provider) {
+			return fmt.Errorf("invalid provider %q of secret %s", secret.Provider, secret.Name)
+		}
+		if secret.Path == "" || secret.Key == "" {
+			return fmt.Errorf("path and key of secret %s not specified", secret.Name)
+		}
+	}
+	return nil
+}
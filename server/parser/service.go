@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var serviceName = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// ServiceConfig defines service container in .abstruse.yml file, e.g.
+// database started before build container and reachable from it by
+// name. Healthcheck is command run in the service container which exits
+// with 0 when service is ready, with Wait build starts only after the
+// service is healthy.
+type ServiceConfig struct {
+	Name        string    `yaml:"name"`
+	Image       string    `yaml:"image"`
+	Env         EnvConfig `yaml:"env"`
+	Ports       []int     `yaml:"ports"`
+	Healthcheck string    `yaml:"healthcheck"`
+	Wait        bool      `yaml:"wait"`
+}
+
+// ServicesConfig is list of service containers of each job of the build.
+type ServicesConfig []ServiceConfig
+
+// ParseServicesConfig returns service containers from raw .abstruse.yml config.
+func ParseServicesConfig(raw string) (ServicesConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed.Services, parsed.Services.validate()
+}
+
+func (s ServicesConfig) validate() error {
+	names := make(map[string]bool)
+	for _, svc := range s {
+		if !serviceName.MatchString(svc.Name) {
+			return fmt.Errorf("invalid service name %q, must be valid host name", svc.Name)
+		}
+		if names[svc.Name] {
+			return fmt.Errorf("duplicate service %s", svc.Name)
+		}
+		names[svc.Name] = true
+		if svc.Image == "" {
+			return fmt.Errorf("image of service %s not specified", svc.Name)
+		}
+		for _, port := range svc.Ports {
+			if port < 1 || port > 65535 {
+				return fmt.Errorf("invalid port %d of service %s", port, svc.Name)
+			}
+		}
+		if err := svc.Env.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
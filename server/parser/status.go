@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/bleenco/abstruse/pkg/envvar"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Commit status report modes.
+const (
+	StatusPerBuild = "build"
+	StatusPerJob   = "job"
+	StatusPerStage = "stage"
+)
+
+// Default commit status contexts.
+const (
+	DefaultBuildContext = "continuous-integration"
+	DefaultJobContext   = "abstruse/{{.Stage}}-{{.Index}}"
+	DefaultStageContext = "abstruse/{{.Stage}}"
+)
+
+// StatusConfig defines commit statuses reported to provider in
+// .abstruse.yml file. Build is reported with single status by default,
+// with per job or stage each job or stage is reported with own status
+// named by Go template Context, so branch protection can require them
+// separately. Jobs with the same context are reported together.
+type StatusConfig struct {
+	Per     string `yaml:"per"`     // build (default), job or stage
+	Context string `yaml:"context"` // e.g. abstruse/test-go{{.Vars.GO_VERSION}}
+}
+
+// StatusContext holds values of the job status context template is
+// executed with.
+type StatusContext struct {
+	Stage string
+	Index int               // index of the job in its stage, from 1
+	Env   string            // matrix env of the job
+	Vars  map[string]string // matrix env variables of the job
+	Image string
+	Arch  string
+}
+
+// ParseStatusConfig returns commit status config from raw .abstruse.yml
+// config.
+func ParseStatusConfig(raw string) (StatusConfig, error) {
+	var parsed RepoConfig
+	if err := yaml.Unmarshal([]byte(raw), &parsed); err != nil {
+		return StatusConfig{}, err
+	}
+	return parsed.Status, parsed.Status.validate()
+}
+
+// Template returns status context template, default template of the
+// report mode is used when context is not set.
+func (s StatusConfig) Template() (*template.Template, error) {
+	text := s.Context
+	if text == "" {
+		switch s.Per {
+		case StatusPerJob:
+			text = DefaultJobContext
+		case StatusPerStage:
+			text = DefaultStageContext
+		default:
+			text = DefaultBuildContext
+		}
+	}
+	return template.New("context").Option("missingkey=zero").Parse(text)
+}
+
+// ExecuteContext returns status context of the job, Vars are set from
+// Env of the job.
+func ExecuteContext(tmpl *template.Template, ctx StatusContext) (string, error) {
+	env := envvar.New()
+	env.SetAll(strings.Fields(ctx.Env), false)
+	ctx.Vars = make(map[string]string)
+	for _, v := range env.Vars() {
+		ctx.Vars[v.Key] = v.Value
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ctx); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("status context is empty")
+	}
+	return buf.String(), nil
+}
+
+func (s StatusConfig) validate() error {
+	switch s.Per {
+	case "", StatusPerBuild, StatusPerJob, StatusPerStage:
+	default:
+		return fmt.Errorf("invalid status per %q (available options: build, job, stage)", s.Per)
+	}
+	if _, err := s.Template(); err != nil {
+		return fmt.Errorf("invalid status context: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/bleenco/abstruse/pkg/imagepolicy"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	yamlLineRe   = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownKeyRe = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// Problem is error or warning found in config, line is 0 when unknown.
+type Problem struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Validation is result of config validation.
+type Validation struct {
+	Valid    bool      `json:"valid"`
+	Jobs     int       `json:"jobs"`
+	Errors   []Problem `json:"errors"`
+	Warnings []Problem `json:"warnings"`
+}
+
+// Validate parses raw config the same way as when build is created for
+// the branch, without creating jobs. Config which would fail the build
+// is reported with errors, keys which are ignored and empty matrix
+// entries are reported with warnings.
+func Validate(raw, branch string, images imagepolicy.Policy) Validation {
+	v := Validation{Errors: []Problem{}, Warnings: []Problem{}}
+
+	parser := ConfigParser{Raw: raw, Branch: branch, Ref: "refs/heads/" + branch, Images: images}
+	jobs, err := parser.Parse()
+	if err != nil {
+		v.Errors = append(v.Errors, yamlProblems(err)...)
+	}
+	v.Jobs = len(jobs)
+
+	if len(v.Errors) == 0 {
+		var strict RepoConfig
+		if err := yaml.UnmarshalStrict([]byte(raw), &strict); err != nil {
+			v.Warnings = append(v.Warnings, yamlProblems(err)...)
+		}
+		for i, item := range parser.Parsed.Matrix {
+			if item.Env == "" && item.Image == "" {
+				v.Warnings = append(v.Warnings, Problem{Message: fmt.Sprintf("matrix entry %d is empty", i+1)})
+			}
+		}
+		for _, stage := range parser.Parsed.Stages {
+			for i, item := range stage.Matrix {
+				if item.Env == "" && item.Image == "" {
+					v.Warnings = append(v.Warnings, Problem{Message: fmt.Sprintf("matrix entry %d of stage %s is empty", i+1, stage.Name)})
+				}
+			}
+		}
+		if !parser.ShouldBuild() {
+			v.Warnings = append(v.Warnings, Problem{Message: fmt.Sprintf("branch %s is ignored or not marked to build in config", branch)})
+		}
+	}
+
+	v.Valid = len(v.Errors) == 0
+	return v
+}
+
+// yamlProblems splits YAML errors into problems with line numbers.
+func yamlProblems(err error) []Problem {
+	msgs := []string{err.Error()}
+	if terr, ok := err.(*yaml.TypeError); ok {
+		msgs = terr.Errors
+	}
+	var problems []Problem
+	for _, msg := range msgs {
+		p := Problem{Message: msg}
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = m[2]
+		}
+		if m := unknownKeyRe.FindStringSubmatch(p.Message); m != nil {
+			p.Message = fmt.Sprintf("unknown key %s", m[1])
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
@@ -0,0 +1,257 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Job and stage statuses.
+const (
+	StatusWaiting   = "waiting" // stage waits for its dependencies
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusPassing   = "passing"
+	StatusFailing   = "failing"
+	StatusCancelled = "cancelled"
+	StatusSkipped   = "skipped" // dependency stage did not pass
+)
+
+// Stage defines named group of jobs which run in parallel after
+// all stages it needs have passed.
+type Stage struct {
+	Name  string   `json:"name"`
+	Needs []string `json:"needs"`
+}
+
+// StageStatus represents stage with its status.
+type StageStatus struct {
+	Stage
+	Status string `json:"status"`
+	Jobs   []uint `json:"jobs"`
+}
+
+// Pipeline tracks directed acyclic graph of build stages and
+// statuses of jobs in each stage.
+type Pipeline struct {
+	mu     sync.Mutex
+	stages map[string]*Stage
+	order  []string
+	jobs   map[string][]uint
+	status map[uint]string
+}
+
+// New returns new Pipeline from stages. Error is returned when stage
+// depends on unknown stage or when dependencies form a cycle.
+func New(stages []Stage) (*Pipeline, error) {
+	p := &Pipeline{
+		stages: make(map[string]*Stage),
+		jobs:   make(map[string][]uint),
+		status: make(map[uint]string),
+	}
+	for i := range stages {
+		stage := stages[i]
+		if stage.Name == "" {
+			return nil, fmt.Errorf("stage name not specified")
+		}
+		if _, ok := p.stages[stage.Name]; ok {
+			return nil, fmt.Errorf("duplicate stage %s", stage.Name)
+		}
+		p.stages[stage.Name] = &stage
+	}
+	for _, stage := range stages {
+		for _, need := range stage.Needs {
+			if _, ok := p.stages[need]; !ok {
+				return nil, fmt.Errorf("stage %s needs unknown stage %s", stage.Name, need)
+			}
+		}
+	}
+	order, err := sortStages(stages)
+	if err != nil {
+		return nil, err
+	}
+	p.order = order
+	return p, nil
+}
+
+// AddJob adds job with status to the stage.
+func (p *Pipeline) AddJob(id uint, stage, status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.stages[stage]; !ok {
+		return fmt.Errorf("unknown stage %s", stage)
+	}
+	p.jobs[stage] = append(p.jobs[stage], id)
+	p.status[id] = status
+	return nil
+}
+
+// SetStatus updates status of the job.
+func (p *Pipeline) SetStatus(id uint, status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.status[id]; ok {
+		p.status[id] = status
+	}
+}
+
+// Order returns stage names in topological order.
+func (p *Pipeline) Order() []string {
+	return append([]string(nil), p.order...)
+}
+
+// Ready returns true if all dependencies of the stage have passed.
+func (p *Pipeline) Ready(stage string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.stages[stage]
+	if !ok {
+		return true
+	}
+	for _, need := range s.Needs {
+		if p.stageStatus(need) != StatusPassing {
+			return false
+		}
+	}
+	return true
+}
+
+// Blocked returns true if any direct or transitive dependency of the
+// stage has failed, was cancelled or skipped, so stage will never run.
+func (p *Pipeline) Blocked(stage string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blocked(stage)
+}
+
+// Status returns status of the stage.
+func (p *Pipeline) Status(stage string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stageStatus(stage)
+}
+
+// Done returns true when no job is queued or running.
+func (p *Pipeline) Done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, status := range p.status {
+		if status == StatusQueued || status == StatusRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// Stages returns stages with their statuses in topological order.
+func (p *Pipeline) Stages() []StageStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var stages []StageStatus
+	for _, name := range p.order {
+		stages = append(stages, StageStatus{
+			Stage:  *p.stages[name],
+			Status: p.stageStatus(name),
+			Jobs:   p.jobs[name],
+		})
+	}
+	return stages
+}
+
+func (p *Pipeline) blocked(stage string) bool {
+	s, ok := p.stages[stage]
+	if !ok {
+		return false
+	}
+	for _, need := range s.Needs {
+		switch p.stageStatus(need) {
+		case StatusFailing, StatusCancelled, StatusSkipped:
+			return true
+		}
+		if p.blocked(need) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pipeline) stageStatus(stage string) string {
+	var running, failing, cancelled, skipped, queued bool
+	for _, id := range p.jobs[stage] {
+		switch p.status[id] {
+		case StatusRunning:
+			running = true
+		case StatusFailing:
+			failing = true
+		case StatusCancelled:
+			cancelled = true
+		case StatusSkipped:
+			skipped = true
+		case StatusPassing:
+		default:
+			queued = true
+		}
+	}
+
+	switch {
+	case running:
+		return StatusRunning
+	case queued:
+		if p.blocked(stage) {
+			return StatusSkipped
+		}
+		for _, need := range p.stages[stage].Needs {
+			if p.stageStatus(need) != StatusPassing {
+				return StatusWaiting
+			}
+		}
+		return StatusQueued
+	case failing:
+		return StatusFailing
+	case cancelled:
+		return StatusCancelled
+	case skipped:
+		return StatusSkipped
+	}
+	return StatusPassing
+}
+
+// sortStages returns stage names in topological order using Kahn's
+// algorithm, stages on the same level are ordered by definition.
+func sortStages(stages []Stage) ([]string, error) {
+	index := make(map[string]int)
+	indegree := make(map[string]int)
+	dependents := make(map[string][]string)
+	for i, stage := range stages {
+		index[stage.Name] = i
+		indegree[stage.Name] += 0
+		for _, need := range stage.Needs {
+			indegree[stage.Name]++
+			dependents[need] = append(dependents[need], stage.Name)
+		}
+	}
+
+	var ready, order []string
+	for _, stage := range stages {
+		if indegree[stage.Name] == 0 {
+			ready = append(ready, stage.Name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return index[ready[i]] < index[ready[j]] })
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dep := range dependents[name] {
+			indegree[dep]--
+			if indegree[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+
+	if len(order) != len(stages) {
+		return nil, fmt.Errorf("stage dependencies contain a cycle")
+	}
+	return order, nil
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+const (
+	// maxAnnotations is number of annotations stored per build.
+	maxAnnotations = 50
+
+	// maxAnnotationSize is size of the longest annotation message in
+	// bytes, longer messages are truncated.
+	maxAnnotationSize = 1024
+)
+
+// annotationLevels are levels of annotation directives, directive is
+// log line starting with ::level:: followed by the message, e.g.
+// ::warning::3 flaky tests retried.
+var annotationLevels = []string{core.AnnotationInfo, core.AnnotationWarning, core.AnnotationError}
+
+// annotations returns annotations emitted in job log, limited so that
+// build does not exceed maximum number of annotations. Repeated
+// annotations are stored once.
+func (s *scheduler) annotations(job *core.Job) []*core.Annotation {
+	limit := maxAnnotations
+	if build, err := s.buildStore.Find(job.BuildID); err == nil {
+		for _, j := range build.Jobs {
+			if j.ID != job.ID {
+				limit -= len(j.Annotations)
+			}
+		}
+	}
+
+	annotations, dropped := parseAnnotations(job.Log, limit)
+	if dropped > 0 {
+		s.logger.Infof("%d annotations of job %d dropped, build reached limit of %d annotations", dropped, job.ID, maxAnnotations)
+	}
+	return annotations
+}
+
+// parseAnnotations returns at most limit annotations emitted in log
+// and number of annotations dropped over the limit.
+func parseAnnotations(log string, limit int) ([]*core.Annotation, int) {
+	var annotations []*core.Annotation
+	var dropped int
+	seen := make(map[core.Annotation]bool)
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if i := strings.LastIndex(line, "\r"); i != -1 {
+			line = line[i+1:]
+		}
+		if !strings.HasPrefix(line, "::") {
+			continue
+		}
+		for _, level := range annotationLevels {
+			prefix := fmt.Sprintf("::%s::", level)
+			if !strings.HasPrefix(line, prefix) {
+				continue
+			}
+			a := core.Annotation{Level: level, Message: strings.TrimSpace(line[len(prefix):])}
+			if len(a.Message) > maxAnnotationSize {
+				a.Message = a.Message[:maxAnnotationSize] + "..."
+			}
+			if a.Message == "" || seen[a] {
+				break
+			}
+			seen[a] = true
+			if len(annotations) >= limit {
+				dropped++
+				break
+			}
+			annotations = append(annotations, &a)
+			break
+		}
+	}
+	return annotations, dropped
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// drainTimeout is maximum time to wait for worker to confirm drain.
+const drainTimeout = 10 * time.Second
+
+// Drain stops starting jobs on the worker, jobs running on it finish.
+// When drain is lifted queued jobs can start on the worker again.
+func (s *scheduler) Drain(id string, drain bool) (core.WorkerDrain, error) {
+	worker, err := s.getWorker(id)
+	if err != nil {
+		return core.WorkerDrain{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, drainTimeout)
+	defer cancel()
+	if err := worker.Drain(ctx, drain); err != nil {
+		return core.WorkerDrain{}, err
+	}
+
+	if drain {
+		s.logger.Infof("worker %s draining, no new jobs will be started on it", id)
+	} else {
+		s.logger.Infof("worker %s drain lifted", id)
+		s.next(s.ctx)
+	}
+
+	return s.DrainStatus(id)
+}
+
+// DrainStatus returns drain status of the worker with jobs still
+// running on it.
+func (s *scheduler) DrainStatus(id string) (core.WorkerDrain, error) {
+	worker, err := s.getWorker(id)
+	if err != nil {
+		return core.WorkerDrain{}, err
+	}
+
+	worker.Lock()
+	d := core.WorkerDrain{ID: id, Draining: worker.Draining, Since: worker.DrainedAt}
+	running := worker.Running // includes jobs being started
+	worker.Unlock()
+
+	s.mu.Lock()
+	builds := make(map[uint]bool)
+	for _, job := range s.pending {
+		if job.job.WorkerID != id {
+			continue
+		}
+		d.Jobs = append(d.Jobs, job.job.ID)
+		if !builds[job.job.BuildID] {
+			builds[job.job.BuildID] = true
+			d.Builds = append(d.Builds, job.job.BuildID)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(d.Jobs, func(i, j int) bool { return d.Jobs[i] < d.Jobs[j] })
+	sort.Slice(d.Builds, func(i, j int) bool { return d.Builds[i] < d.Builds[j] })
+	d.Idle = d.Draining && len(d.Jobs) == 0 && running == 0
+
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// maintenance holds state of active maintenance.
+type maintenance struct {
+	message string
+	since   *time.Time
+}
+
+// StartMaintenance pauses the scheduler for maintenance. Builds created
+// in the meantime are queued, running jobs finish.
+func (s *scheduler) StartMaintenance(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.window == nil {
+		s.window = &maintenance{since: lib.TimeNow()}
+		s.logger.Infof("maintenance started, queued jobs will not be started")
+	}
+	s.window.message = message
+	s.paused = true
+	return nil
+}
+
+// StopMaintenance lifts maintenance, queued jobs start in the order
+// they were queued in.
+func (s *scheduler) StopMaintenance() error {
+	s.logger.Infof("maintenance lifted, resuming scheduler")
+	return s.Resume()
+}
+
+func (s *scheduler) Maintenance() core.Maintenance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	builds := make(map[uint]bool)
+	for _, job := range s.queued {
+		builds[job.BuildID] = true
+	}
+	m := core.Maintenance{
+		QueuedBuilds: len(builds),
+		QueuedJobs:   len(s.queued),
+		RunningJobs:  len(s.pending),
+	}
+	if s.window != nil {
+		m.Active = true
+		m.Message = s.window.message
+		m.Since = s.window.since
+	}
+	return m
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"strings"
+
+	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/pkg/junit"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// Test report limits keeping encoded results small enough to be stored
+// in job text column.
+const (
+	maxTestSuites     = 100
+	maxTestFailures   = 50
+	maxFailureMessage = 500
+)
+
+// testReport returns results parsed from test report files collected
+// from worker, nil when job produced no reports. Files which are not
+// valid JUnit reports are skipped.
+func (s *scheduler) testReport(job *core.Job, files []*pb.ReportFile) *core.TestReport {
+	if len(files) == 0 {
+		return nil
+	}
+
+	report := &core.TestReport{Suites: []*core.TestSuite{}, Failures: []*core.TestFailure{}}
+	for _, file := range files {
+		suites, err := junit.Parse(file.GetContent())
+		if err != nil {
+			s.logger.Errorf("error parsing test report %s of job %d: %v", file.GetPath(), job.ID, err)
+			continue
+		}
+
+		for _, suite := range suites {
+			ts := &core.TestSuite{
+				Name:     suite.Name,
+				File:     file.GetPath(),
+				Duration: int64(suite.Time * 1000),
+			}
+			for _, c := range suite.Cases {
+				ts.Total++
+				switch c.Status() {
+				case junit.StatusPassed:
+					ts.Passed++
+				case junit.StatusSkipped:
+					ts.Skipped++
+				default:
+					ts.Failed++
+					if len(report.Failures) < maxTestFailures {
+						report.Failures = append(report.Failures, testFailure(suite, c))
+					}
+				}
+			}
+
+			report.Total += ts.Total
+			report.Passed += ts.Passed
+			report.Failed += ts.Failed
+			report.Skipped += ts.Skipped
+			report.Duration += ts.Duration
+			if len(report.Suites) < maxTestSuites {
+				report.Suites = append(report.Suites, ts)
+			}
+		}
+	}
+	return report
+}
+
+func testFailure(suite junit.Suite, c junit.Case) *core.TestFailure {
+	result := c.Failure
+	if result == nil {
+		result = c.Error
+	}
+	message := result.Message
+	if message == "" {
+		message = strings.TrimSpace(result.Text)
+	}
+	if len(message) > maxFailureMessage {
+		message = message[:maxFailureMessage] + "..."
+	}
+
+	return &core.TestFailure{
+		Suite:     suite.Name,
+		Classname: c.Classname,
+		Name:      c.Name,
+		Status:    c.Status(),
+		Message:   message,
+	}
+}
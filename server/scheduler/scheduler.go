@@ -2,16 +2,22 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/bleenco/abstruse/internal/requestid"
 	pb "github.com/bleenco/abstruse/pb"
-	"github.com/bleenco/abstruse/pkg/gitscm"
+	"github.com/bleenco/abstruse/pkg/envvar"
 	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/parser"
+	"github.com/bleenco/abstruse/server/pipeline"
 	"github.com/bleenco/abstruse/server/ws"
 	"github.com/drone/go-scm/scm"
 	"github.com/logrusorgru/aurora"
@@ -23,20 +29,39 @@ func New(
 	workers core.WorkerRegistry,
 	jobStore core.JobStore,
 	buildStore core.BuildStore,
+	notify core.NotifyService,
+	status core.StatusReporter,
+	secrets core.SecretService,
 	logger *zap.Logger,
 	ws *ws.Server,
+	config *config.Config,
 ) core.Scheduler {
 	s := &scheduler{
 		ready:      make(chan struct{}, 1),
 		interval:   time.Minute,
+		maxBuilds:  config.Scheduler.MaxRepoBuilds,
+		maxLog:     config.Scheduler.MaxLogSize,
+		failLog:    config.Scheduler.LogSizeFail,
+		pinTimeout: config.Scheduler.PinTimeout,
+		maxQueue:   config.Scheduler.MaxQueue,
+		policy:     config.Scheduler.QueuePolicy,
 		workers:    workers,
 		jobStore:   jobStore,
 		buildStore: buildStore,
+		notify:     notify,
+		status:     status,
+		secrets:    secrets,
 		logger:     logger.With(zap.String("type", "scheduler")).Sugar(),
 		pending:    make(map[uint]*jobType),
+		pipelines:  make(map[uint]*pipeline.Pipeline),
+		admitted:   make(map[uint]uint),
+		groups:     make(map[string]uint),
 		ws:         ws,
 		ctx:        context.Background(),
 	}
+	if config.Scheduler.Maintenance {
+		s.StartMaintenance("")
+	}
 	go s.run()
 	return s
 }
@@ -45,13 +70,26 @@ type scheduler struct {
 	mu         sync.Mutex
 	ready      chan struct{}
 	paused     bool
+	window     *maintenance // active maintenance, nil otherwise
 	interval   time.Duration
+	maxBuilds  int // default maximum running builds per repository
+	maxLog     int64
+	failLog    bool
+	pinTimeout time.Duration // how long jobs wait for pinned worker to connect
+	maxQueue   int           // maximum queued jobs, 0 for unlimited
+	policy     string        // queue policy applied when queue is full
 	workers    core.WorkerRegistry
 	jobStore   core.JobStore
 	buildStore core.BuildStore
+	notify     core.NotifyService
+	status     core.StatusReporter
+	secrets    core.SecretService
 	logger     *zap.SugaredLogger
 	queued     []*core.Job
 	pending    map[uint]*jobType
+	pipelines  map[uint]*pipeline.Pipeline
+	admitted   map[uint]uint   // running build ID to repository ID
+	groups     map[string]uint // concurrency group to running build ID
 	ws         *ws.Server
 	ctx        context.Context
 }
@@ -61,32 +99,48 @@ type jobType struct {
 	pb     *pb.Job
 	ctx    context.Context
 	cancel context.CancelFunc
+	status string // final status set when job is stopped
 }
 
 func (s *scheduler) Next(job *core.Job) error {
 	s.logger.Infof("scheduling job %d from build %d...", job.ID, job.BuildID)
 	s.Stop(job.ID)
-	s.mu.Lock()
-	s.queued = append(s.queued, job)
-	s.mu.Unlock()
 
 	job.Status = "queued"
+	job.Reason = ""
+	job.ImageDigest = ""
+	job.LogTruncated = false
+	job.WorkerID = ""
+	job.Environment = ""
 	job.Log = ""
+	job.QueuedAt = lib.TimeNow()
 	job.StartTime = nil
 	job.EndTime = nil
+	job.SetSteps(nil)
+	job.SetTests(nil)
+	job.SetUsage(nil)
+	job.SetAnnotations(nil)
 	if err := s.saveJob(job); err != nil {
 		s.logger.Errorf("error saving job %d: %v", job.ID, err.Error())
 	}
+
+	s.advance(job.BuildID)
+	s.mu.Lock()
+	s.queued = append(s.queued, job)
+	p := s.pipelines[job.BuildID]
+	s.mu.Unlock()
+	if p != nil && p.Blocked(job.Stage) {
+		s.stop(job.ID, pipeline.StatusSkipped)
+	}
 	s.logger.Infof("job %d scheduled", job.ID)
 
 	go func(job *core.Job) {
 		build, err := s.buildStore.Find(job.BuildID)
 		if err != nil {
 			s.logger.Errorf("error finding build %d for job %d", job.BuildID, job.ID)
+			return
 		}
-		if err := s.sendStatus(build, scm.StatePending); err != nil {
-			s.logger.Errorf("error sending status for build %d status pending", job.BuildID)
-		}
+		s.status.Report(build, scm.StatePending)
 	}(job)
 
 	s.next(s.ctx)
@@ -95,12 +149,57 @@ func (s *scheduler) Next(job *core.Job) error {
 }
 
 func (s *scheduler) Stop(id uint) (bool, error) {
+	return s.stop(id, "failing")
+}
+
+func (s *scheduler) CancelBuild(id uint) (*core.Build, error) {
+	build, err := s.buildStore.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	stopped := 0
+	for _, job := range build.Jobs {
+		if !s.active(job.ID) {
+			continue
+		}
+		stopped++
+		wg.Add(1)
+		go func(id uint) {
+			s.stop(id, core.BuildStatusCancelled)
+			wg.Done()
+		}(job.ID)
+	}
+	wg.Wait()
+	// build without queued or running jobs is already finished or
+	// cancelled, it is not updated and reported again.
+	if stopped == 0 {
+		return build, nil
+	}
+	if err := s.updateBuildTime(id); err != nil {
+		return nil, err
+	}
+	return s.buildStore.Find(id)
+}
+
+// stop removes job from the queue or stops running job and
+// saves it with provided status.
+func (s *scheduler) stop(id uint, status string) (bool, error) {
+	msg := "==> job stopped"
+	if status == core.BuildStatusCancelled {
+		msg = "==> job cancelled"
+	} else if status == pipeline.StatusSkipped {
+		msg = "==> job skipped, required stage did not pass"
+	}
+
 	if job, err := s.findJob(id); err == nil {
 		s.removeJob(id)
-		job.Status = "failing"
+		job.Status = status
 		job.EndTime = lib.TimeNow()
-		job.Log = red(fmt.Sprintf("%s\r\n", "==> job stopped"))
+		job.Log = red(fmt.Sprintf("%s\r\n", msg))
 		s.logger.Infof("job %d removed from queue", id)
+		s.release(job.BuildID)
+		s.next(s.ctx)
 		if err := s.saveJob(job); err == nil {
 			return true, nil
 		}
@@ -108,18 +207,27 @@ func (s *scheduler) Stop(id uint) (bool, error) {
 		return false, nil
 	}
 
-	if job, ok := s.pending[id]; ok {
+	s.mu.Lock()
+	job, ok := s.pending[id]
+	if ok {
+		job.status = status
+	}
+	s.mu.Unlock()
+
+	if ok {
 		job.cancel()
 
 		defer func() {
 			s.mu.Lock()
 			delete(s.pending, id)
 			s.mu.Unlock()
+			s.release(job.job.BuildID)
+			s.next(s.ctx)
 		}()
 
 		worker, err := s.getWorker(job.pb.WorkerId)
 		if err != nil {
-			job.job.Status = "failing"
+			job.job.Status = status
 			job.job.EndTime = lib.TimeNow()
 			if err := s.saveJob(job.job); err != nil {
 				s.logger.Errorf("error saving job %d: %v", job.job.ID, err.Error())
@@ -130,7 +238,7 @@ func (s *scheduler) Stop(id uint) (bool, error) {
 		stopped, _ := worker.StopJob(job.pb)
 
 		s.logger.Infof("job %d stopped", id)
-		job.job.Status = "failing"
+		job.job.Status = status
 		job.job.EndTime = lib.TimeNow()
 		if err := s.saveJob(job.job); err != nil {
 			s.logger.Errorf("error saving job %d: %v", job.job.ID, err.Error())
@@ -147,6 +255,7 @@ func (s *scheduler) RestartBuild(id uint) error {
 	if err != nil {
 		return err
 	}
+	build.QueuedAt = lib.TimeNow()
 	build.StartTime = nil
 	build.EndTime = nil
 	if err := s.buildStore.Update(build); err != nil {
@@ -188,6 +297,7 @@ func (s *scheduler) Pause() error {
 func (s *scheduler) Resume() error {
 	s.mu.Lock()
 	s.paused = false
+	s.window = nil
 	s.mu.Unlock()
 	s.next(s.ctx)
 	return nil
@@ -209,6 +319,28 @@ func (s *scheduler) JobLog(id uint) (string, error) {
 	return "", fmt.Errorf("job not running")
 }
 
+func (s *scheduler) Jobs() []*core.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*core.Job, 0, len(s.queued)+len(s.pending))
+	for _, job := range s.queued {
+		j := *job
+		jobs = append(jobs, &j)
+	}
+	for _, job := range s.pending {
+		j := *job.job
+		jobs = append(jobs, &j)
+	}
+	return jobs
+}
+
+func (s *scheduler) RunningBuilds(repoID uint) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runningBuilds(repoID)
+}
+
 func (s *scheduler) Stats() core.SchedulerStats {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -233,10 +365,24 @@ func (s *scheduler) Stats() core.SchedulerStats {
 		Workers:   workers,
 		Max:       max,
 		Running:   running,
+		MaxQueued: s.maxQueue,
 		Timestamp: time.Now(),
 	}
 }
 
+func (s *scheduler) Accept(automated bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxQueue == 0 || len(s.queued) < s.maxQueue {
+		return nil
+	}
+	if s.policy == config.QueueAutomated && !automated {
+		return nil
+	}
+	return core.ErrQueueFull
+}
+
 func (s *scheduler) process() error {
 	s.mu.Lock()
 	paused := s.paused
@@ -246,38 +392,44 @@ func (s *scheduler) process() error {
 		return fmt.Errorf("scheduler paused")
 	}
 
-	worker, err := s.findWorker()
+	s.expirePinned()
+
+	workers, err := s.findWorkers()
 	if err != nil {
 		return err
 	}
-	if worker == nil {
+	if len(workers) == 0 {
 		return nil
 	}
-	job, err := s.enqueueJob()
+	job, worker, err := s.enqueueJob(workers)
 	if err != nil || job == nil {
 		return nil
 	}
 
-	s.logger.Infof("processing job %d, sending to worker %s...", job.ID, worker.ID)
+	s.logger.With("request_id", job.RequestID).Infof("processing job %d, sending to worker %s...", job.ID, worker.ID)
 	go s.startJob(job, worker)
 
 	return nil
 }
 
 func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
+	weight := job.CapacityWeight()
 	worker.Lock()
 	worker.Running++
+	worker.Used += weight
 	worker.Unlock()
 
 	defer func() {
 		worker.Lock()
 		worker.Running--
+		worker.Used -= weight
 		worker.Unlock()
 	}()
 
 	s.removeJob(job.ID)
 
 	job.Status = "running"
+	job.WorkerID = worker.ID
 	job.Log = ""
 	job.StartTime = lib.TimeNow()
 	job.EndTime = nil
@@ -285,20 +437,25 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 		s.logger.Errorf("error saving job %d: %v", job.ID, err.Error())
 	}
 
-	var envs []*pb.EnvVariable
-	env := strings.Split(job.Env, " ")
-	for _, e := range env {
-		splitted := strings.Split(e, "=")
-		if (len(splitted) > 1) {
-			envs = append(envs, &pb.EnvVariable{
-				Key:    splitted[0],
-				Value:  splitted[1],
-				Secret: false,
-			})
+	env, err := parser.JobEnv(job)
+	if err != nil {
+		s.logger.Errorf("invalid env config of build %d, ignoring it: %v", job.BuildID, err)
+	}
+	if err := s.jobSecrets(job, env); err != nil {
+		s.logger.With("request_id", job.RequestID).Errorf("job %d failed: %v", job.ID, err)
+		job.Status = "failing"
+		job.Reason = err.Error()
+		job.Log = red(fmt.Sprintf("==> %s\r\n", job.Reason))
+		job.EndTime = lib.TimeNow()
+		if err := s.saveJob(job); err != nil {
+			s.logger.Errorf("error saving job %d: %v", job.ID, err.Error())
 		}
+		s.release(job.BuildID)
+		s.next(s.ctx)
+		return
 	}
-
-	for _, e := range job.Build.Repository.EnvVariables {
+	var envs []*pb.EnvVariable
+	for _, e := range env.Vars() {
 		envs = append(envs, &pb.EnvVariable{
 			Key:    e.Key,
 			Value:  e.Value,
@@ -306,6 +463,39 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 		})
 	}
 
+	environment := make(map[string]string)
+	for _, e := range envs {
+		if e.Secret {
+			environment[e.Key] = "**********"
+		} else {
+			environment[e.Key] = e.Value
+		}
+	}
+	if data, err := json.Marshal(environment); err == nil {
+		job.Environment = string(data)
+	}
+
+	clone, err := parser.ParseCloneConfig(job.Build.Config)
+	if err != nil {
+		s.logger.Errorf("invalid clone config of build %d, using defaults: %v", job.BuildID, err)
+		clone = parser.CloneConfig{}
+	}
+	reports, err := parser.ParseReportsConfig(job.Build.Config)
+	if err != nil {
+		s.logger.Errorf("invalid reports config of build %d, ignoring it: %v", job.BuildID, err)
+		reports = nil
+	}
+	network, err := parser.ParseNetworkConfig(job.Build.Config)
+	if err != nil {
+		s.logger.Errorf("invalid network config of build %d, using defaults: %v", job.BuildID, err)
+		network = parser.NetworkConfig{}
+	}
+	services, err := parser.ParseServicesConfig(job.Build.Config)
+	if err != nil {
+		s.logger.Errorf("invalid services config of build %d, ignoring it: %v", job.BuildID, err)
+		services = nil
+	}
+
 	j := &pb.Job{
 		Id:            uint64(job.ID),
 		BuildId:       uint64(job.BuildID),
@@ -321,6 +511,51 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 		RepoName:      job.Build.Repository.FullName,
 		Action:        pb.Job_JobStart,
 		WorkerId:      worker.ID,
+		RegistryAuth:  job.Build.Repository.RegistryAuth,
+		Resources: &pb.Resources{
+			Cpus:      job.CPUs,
+			Memory:    job.Memory,
+			PidsLimit: job.PidsLimit,
+		},
+		Clone: &pb.CloneOptions{
+			Depth:      int32(clone.CloneDepth()),
+			Submodules: int32(clone.SubmoduleDepth()),
+			Tags:       clone.TagMode(),
+			Merge:      strings.HasSuffix(job.Build.Ref, "/merge"),
+		},
+		Network: &pb.NetworkOptions{
+			Mode:       network.Mode,
+			ExtraHosts: network.ExtraHosts,
+		},
+	}
+	if auth, err := job.Build.Repository.CloneCredentials(); err != nil {
+		s.logger.Errorf("invalid clone credentials of repository %d, using provider token: %v", job.Build.Repository.ID, err)
+	} else if auth != nil {
+		j.Clone.Auth = &pb.CloneAuth{
+			Type:       auth.Type,
+			Url:        job.Build.Repository.CloneSSH,
+			Username:   auth.Username,
+			Token:      auth.Token,
+			Key:        auth.Key,
+			Passphrase: auth.Passphrase,
+		}
+	}
+	j.MaxLogSize = s.maxLog
+	j.FailOnLogLimit = s.failLog
+	j.Reports = reports
+	for _, svc := range services {
+		ports := make([]int32, len(svc.Ports))
+		for i, port := range svc.Ports {
+			ports[i] = int32(port)
+		}
+		j.Services = append(j.Services, &pb.ServiceOptions{
+			Name:        svc.Name,
+			Image:       svc.Image,
+			Env:         svc.Env,
+			Ports:       ports,
+			Healthcheck: svc.Healthcheck,
+			Wait:        svc.Wait,
+		})
 	}
 
 	s.mu.Lock()
@@ -329,22 +564,23 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 		timeout = 3600
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	s.pending[job.ID] = &jobType{job: job, pb: j, ctx: ctx, cancel: cancel}
+	ctx = requestid.OutgoingContext(ctx, job.RequestID)
+	jt := &jobType{job: job, pb: j, ctx: ctx, cancel: cancel}
+	s.pending[job.ID] = jt
 	s.mu.Unlock()
 
 	go func(job *core.Job) {
 		build, err := s.buildStore.Find(job.BuildID)
 		if err != nil {
 			s.logger.Errorf("error finding build %d for job %d", job.BuildID, job.ID)
+			return
 		}
-		if err := s.sendStatus(build, scm.StateRunning); err != nil {
-			s.logger.Errorf("error sending status for build %d status running", job.BuildID)
-		}
+		s.status.Report(build, scm.StateRunning)
 	}(job)
 
 	s.next(s.ctx)
 
-	j, err := worker.StartJob(ctx, j)
+	j, err = worker.StartJob(ctx, j)
 	if err != nil {
 		s.logger.Errorf("job %d errored: %v", job.ID, err.Error())
 		job.Log = strings.Join(j.GetLog(), "")
@@ -356,15 +592,38 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 		} else {
 			l = red(fmt.Sprintf("\r\n==> %s\r\n", err.Error()))
 		}
+		s.mu.Lock()
+		status := jt.status
+		s.mu.Unlock()
+		if status == core.BuildStatusCancelled {
+			l = red(fmt.Sprintf("\r\n%s\r\n", "==> job cancelled"))
+		}
 		job.Log = job.Log + l
 		worker.WS.Broadcast((fmt.Sprintf("/subs/logs/%d", job.ID)), map[string]interface{}{
 			"id":  job.ID,
 			"log": l,
 		})
 		job.Status = "failing"
+		job.Reason = j.GetReason()
+		job.ImageDigest = j.GetImageDigest()
+		job.LogTruncated = j.GetLogTruncated()
+		job.SetSteps(jobSteps(j.GetSteps()))
+		job.SetTests(s.testReport(job, j.GetReportFiles()))
+		job.SetUsage(jobUsage(j.GetUsage()))
+		job.SetAnnotations(s.annotations(job))
+		if status != "" {
+			job.Status = status
+		}
 	} else {
 		job.Status = j.GetStatus()
+		job.Reason = j.GetReason()
+		job.ImageDigest = j.GetImageDigest()
+		job.LogTruncated = j.GetLogTruncated()
 		job.Log = strings.Join(j.GetLog(), "")
+		job.SetSteps(jobSteps(j.GetSteps()))
+		job.SetTests(s.testReport(job, j.GetReportFiles()))
+		job.SetUsage(jobUsage(j.GetUsage()))
+		job.SetAnnotations(s.annotations(job))
 	}
 
 	job.EndTime = lib.TimeNow()
@@ -375,10 +634,59 @@ func (s *scheduler) startJob(job *core.Job, worker *core.Worker) {
 	s.mu.Lock()
 	delete(s.pending, job.ID)
 	s.mu.Unlock()
+	s.release(job.BuildID)
 
 	s.next(s.ctx)
 }
 
+// jobSecrets fetches secrets of the build from secret providers and
+// sets them as secret variables of job environment, overriding other
+// variables. Error is returned when any secret cannot be fetched.
+func (s *scheduler) jobSecrets(job *core.Job, env *envvar.Env) error {
+	secrets, err := parser.ParseSecretsConfig(job.Build.Config)
+	if err != nil {
+		return fmt.Errorf("invalid secrets config: %v", err)
+	}
+	for _, secret := range secrets {
+		value, err := s.secrets.Fetch(s.ctx, secret.Ref())
+		if err != nil {
+			return fmt.Errorf("cannot fetch secret %s: %v", secret.Name, err)
+		}
+		env.Put(envvar.Var{Key: secret.Name, Value: value, Secret: true})
+	}
+	return nil
+}
+
+// jobSteps converts step timings reported by worker.
+func jobSteps(timings []*pb.StepTiming) []*core.JobStep {
+	var steps []*core.JobStep
+	for _, t := range timings {
+		start, end := time.Unix(0, t.GetStartTime()*int64(time.Millisecond)), time.Unix(0, t.GetEndTime()*int64(time.Millisecond))
+		steps = append(steps, &core.JobStep{
+			Name:      t.GetName(),
+			StartTime: start,
+			EndTime:   end,
+			Duration:  end.Sub(start).Milliseconds(),
+			Skipped:   t.GetSkipped(),
+		})
+	}
+	return steps
+}
+
+// jobUsage converts resource usage reported by worker.
+func jobUsage(usage *pb.ResourceUsage) *core.JobUsage {
+	if usage == nil || usage.GetSamples() == 0 {
+		return nil
+	}
+	return &core.JobUsage{
+		CPUPeak:    usage.GetCpuPeak(),
+		CPUAvg:     usage.GetCpuAvg(),
+		MemoryPeak: usage.GetMemoryPeak(),
+		MemoryAvg:  usage.GetMemoryAvg(),
+		Samples:    int(usage.GetSamples()),
+	}
+}
+
 func (s *scheduler) next(ctx context.Context) {
 	select {
 	case s.ready <- struct{}{}:
@@ -386,16 +694,169 @@ func (s *scheduler) next(ctx context.Context) {
 	}
 }
 
-func (s *scheduler) enqueueJob() (*core.Job, error) {
+// enqueueJob removes and returns first queued job which is ready and
+// fits into free worker capacity together with the worker, lighter jobs
+// queued later can start while heavier jobs wait for enough capacity.
+// Jobs pinned to worker wait for that worker.
+func (s *scheduler) enqueueJob(workers []freeWorker) (*core.Job, *core.Worker, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if len(s.queued) > 0 {
-		job := s.queued[0]
-		s.queued = s.queued[1:]
-		return job, nil
+	for i, job := range s.queued {
+		if p, ok := s.pipelines[job.BuildID]; ok && !p.Ready(job.Stage) {
+			continue
+		}
+		worker := pickWorker(workers, job)
+		if worker == nil {
+			continue
+		}
+		if !s.admit(job) {
+			continue
+		}
+		s.queued = append(s.queued[:i], s.queued[i+1:]...)
+		return job, worker, nil
+	}
+	return nil, nil, fmt.Errorf("no jobs queued")
+}
+
+// pickWorker returns worker job is pinned to or worker of job
+// architecture with the most free capacity, nil when job does not fit
+// into its free capacity. Workers must be ordered by free capacity.
+func pickWorker(workers []freeWorker, job *core.Job) *core.Worker {
+	pin := pinnedWorker(job)
+	for _, w := range workers {
+		if pin != "" && w.worker.ID != pin {
+			continue
+		}
+		if !w.worker.Host.SupportsArch(job.Arch) {
+			continue
+		}
+		if job.CapacityWeight() > w.free {
+			return nil
+		}
+		return w.worker
+	}
+	return nil
+}
+
+// pinnedWorker returns ID of worker job is pinned to, empty when job
+// can run on any worker.
+func pinnedWorker(job *core.Job) string {
+	if job.Build == nil {
+		return ""
+	}
+	return job.Build.Worker
+}
+
+// expirePinned fails queued jobs pinned to worker which is not connected,
+// or requiring architecture no connected worker supports, when they
+// waited for longer than pin timeout.
+func (s *scheduler) expirePinned() {
+	if s.pinTimeout <= 0 {
+		return
+	}
+	workers, err := s.workers.List()
+	if err != nil {
+		return
+	}
+	connected := make(map[string]bool)
+	archs := make(map[string]bool)
+	for _, w := range workers {
+		connected[w.ID] = true
+		for _, arch := range w.Host.Archs {
+			archs[arch] = true
+		}
+	}
+
+	s.mu.Lock()
+	var expired []*core.Job
+	for _, job := range s.queued {
+		pin := pinnedWorker(job)
+		if (pin == "" || connected[pin]) && (job.Arch == "" || archs[job.Arch]) {
+			continue
+		}
+		if job.QueuedAt == nil || time.Since(*job.QueuedAt) < s.pinTimeout {
+			continue
+		}
+		expired = append(expired, job)
+	}
+	s.mu.Unlock()
+
+	for _, job := range expired {
+		s.removeJob(job.ID)
+		job.Status = "failing"
+		if pin := pinnedWorker(job); pin != "" && !connected[pin] {
+			job.Reason = fmt.Sprintf("worker %s not connected within %s", pin, s.pinTimeout)
+		} else {
+			job.Reason = fmt.Sprintf("no worker with architecture %s connected within %s", job.Arch, s.pinTimeout)
+		}
+		job.EndTime = lib.TimeNow()
+		job.Log = red(fmt.Sprintf("==> %s\r\n", job.Reason))
+		s.logger.Infof("job %d removed from queue, %s", job.ID, job.Reason)
+		s.release(job.BuildID)
+		if err := s.saveJob(job); err != nil {
+			s.logger.Errorf("error saving job %d: %v", job.ID, err.Error())
+		}
+	}
+}
+
+// admit reports whether job can start considering limit of running builds
+// per repository and concurrency group of the build, and marks its build
+// as running. Must be called with lock held.
+func (s *scheduler) admit(job *core.Job) bool {
+	if _, ok := s.admitted[job.BuildID]; ok || job.Build == nil {
+		return true
+	}
+	max := s.maxBuilds
+	if job.Build.Repository != nil && job.Build.Repository.MaxBuilds > 0 {
+		max = job.Build.Repository.MaxBuilds
+	}
+	if max > 0 && s.runningBuilds(job.Build.RepositoryID) >= max {
+		return false
 	}
-	return nil, fmt.Errorf("no jobs queued")
+	group := job.Build.Concurrency
+	if group != "" {
+		if _, ok := s.groups[group]; ok {
+			return false
+		}
+		s.groups[group] = job.BuildID
+	}
+	s.admitted[job.BuildID] = job.Build.RepositoryID
+	return true
+}
+
+// release frees build slot of the repository and concurrency group when
+// build has no more queued or running jobs, so next queued build can start.
+func (s *scheduler) release(buildID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, job := range s.queued {
+		if job.BuildID == buildID {
+			return
+		}
+	}
+	for _, job := range s.pending {
+		if job.job.BuildID == buildID {
+			return
+		}
+	}
+	delete(s.admitted, buildID)
+	for group, id := range s.groups {
+		if id == buildID {
+			delete(s.groups, group)
+		}
+	}
+}
+
+func (s *scheduler) runningBuilds(repoID uint) int {
+	var count int
+	for _, id := range s.admitted {
+		if id == repoID {
+			count++
+		}
+	}
+	return count
 }
 
 func (s *scheduler) findJob(id uint) (*core.Job, error) {
@@ -411,6 +872,17 @@ func (s *scheduler) findJob(id uint) (*core.Job, error) {
 	return nil, fmt.Errorf("job not found")
 }
 
+// active returns true when job is queued or running.
+func (s *scheduler) active(id uint) bool {
+	if _, err := s.findJob(id); err == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.pending[id]
+	return ok
+}
+
 func (s *scheduler) removeJob(id uint) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -423,7 +895,15 @@ func (s *scheduler) removeJob(id uint) {
 	}
 }
 
-func (s *scheduler) findWorker() (*core.Worker, error) {
+// freeWorker is worker together with its free capacity.
+type freeWorker struct {
+	worker *core.Worker
+	free   int
+}
+
+// findWorkers returns workers which can run more jobs in parallel and
+// are not draining, ordered by free capacity, the most free first.
+func (s *scheduler) findWorkers() ([]freeWorker, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -432,18 +912,18 @@ func (s *scheduler) findWorker() (*core.Worker, error) {
 		return nil, err
 	}
 
-	var worker *core.Worker
-	var c int
+	var free []freeWorker
 	for _, w := range workers {
 		w.Lock()
-		diff := w.Max - w.Running
-		if diff > c {
-			worker, c = w, diff
+		c := w.Capacity - w.Used
+		if w.Running < w.Max && c > 0 && !w.Draining {
+			free = append(free, freeWorker{worker: w, free: c})
 		}
 		w.Unlock()
 	}
+	sort.SliceStable(free, func(i, j int) bool { return free[i].free > free[j].free })
 
-	return worker, nil
+	return free, nil
 }
 
 func (s *scheduler) getWorker(id string) (*core.Worker, error) {
@@ -461,7 +941,7 @@ func (s *scheduler) getWorker(id string) (*core.Worker, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("worker not found")
+	return nil, core.ErrWorkerNotFound
 }
 
 func (s *scheduler) saveJob(job *core.Job) error {
@@ -469,7 +949,42 @@ func (s *scheduler) saveJob(job *core.Job) error {
 		s.logger.Errorf("error saving job %d: %v", job.ID, err.Error())
 	}
 	go s.broadcastJobStatus(job)
-	return s.updateBuildTime(job.BuildID)
+	if err := s.updateBuildTime(job.BuildID); err != nil {
+		return err
+	}
+	if job.Status != "queued" {
+		s.advance(job.BuildID)
+	}
+	return nil
+}
+
+// advance refreshes pipeline of the build so queued jobs are started
+// once stages they need have passed and skips queued jobs of stages
+// that will never run because of failed dependencies.
+func (s *scheduler) advance(buildID uint) {
+	build, err := s.buildStore.Find(buildID)
+	if err != nil {
+		return
+	}
+	p, err := build.Pipeline()
+
+	s.mu.Lock()
+	if err != nil || p.Done() {
+		delete(s.pipelines, buildID)
+	} else {
+		s.pipelines[buildID] = p
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+	for _, job := range build.Jobs {
+		if job.Status == "queued" && p.Blocked(job.Stage) {
+			s.stop(job.ID, pipeline.StatusSkipped)
+		}
+	}
+	s.next(s.ctx)
 }
 
 func (s *scheduler) broadcastJobStatus(job *core.Job) {
@@ -519,6 +1034,11 @@ func (s *scheduler) updateBuildTime(id uint) error {
 			s.logger.Errorf("error saving build %d: %v", build.ID, err.Error())
 			return err
 		}
+		if !alldone {
+			// jobs or stages reported separately change state as
+			// jobs finish.
+			s.status.Report(build, scm.StateRunning)
+		}
 	}
 
 	if alldone && endTime != nil {
@@ -538,41 +1058,20 @@ func (s *scheduler) updateBuildTime(id uint) error {
 		var status scm.State
 		if success {
 			status = scm.StateSuccess
+		} else if build.Status() == core.BuildStatusCancelled {
+			status = scm.StateCanceled
 		} else {
-			status = scm.StateError
+			status = scm.StateFailure
 		}
-		if err := s.sendStatus(build, status); err != nil {
-			return err
+		s.status.Report(build, status)
+		if status != scm.StateCanceled {
+			s.notify.Notify(build)
 		}
 	}
 
 	return nil
 }
 
-func (s *scheduler) sendStatus(build *core.Build, status scm.State) error {
-	scm, err := gitscm.New(
-		context.Background(),
-		build.Repository.Provider.Name,
-		build.Repository.Provider.URL,
-		build.Repository.Provider.AccessToken,
-	)
-	if err != nil {
-		return err
-	}
-
-	if err := scm.CreateStatus(
-		build.Repository.FullName,
-		build.Commit,
-		fmt.Sprintf("%s/builds/%d", build.Repository.Provider.Host, build.ID),
-		status,
-	); err != nil {
-		s.logger.Errorf("error sending build status to scm provider: %v", err.Error())
-		return err
-	}
-
-	return nil
-}
-
 func (s *scheduler) run() error {
 	s.logger.Infof("starting scheduler loop")
 	for {
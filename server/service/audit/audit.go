@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"go.uber.org/zap"
+)
+
+// New returns new AuditService instance which appends audit events as
+// JSON lines to audit log file and optionally stores them in database.
+// File is opened in append only mode and never rotated or truncated by
+// the server, so it can be shipped independently of the application log.
+func New(config *config.Config, events core.AuditStore, logger *zap.Logger) (core.AuditService, error) {
+	s := &auditService{logger: logger.With(zap.String("type", "audit")).Sugar()}
+	if config.Audit == nil {
+		return s, nil
+	}
+	if config.Audit.DB {
+		s.events = events
+	}
+	if config.Audit.Filename != "" {
+		if err := os.MkdirAll(filepath.Dir(config.Audit.Filename), 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(config.Audit.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+	}
+	return s, nil
+}
+
+type auditService struct {
+	mu     sync.Mutex
+	file   *os.File
+	events core.AuditStore
+	logger *zap.SugaredLogger
+}
+
+func (s *auditService) Record(event core.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	if s.events != nil {
+		if err := s.events.Create(&event); err != nil {
+			s.logger.Errorf("error storing audit event %s: %v", event.Action, err)
+		}
+	}
+
+	if s.file != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			s.logger.Errorf("error encoding audit event %s: %v", event.Action, err)
+		} else {
+			s.mu.Lock()
+			_, err = s.file.Write(append(line, '\n'))
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Errorf("error writing audit event %s: %v", event.Action, err)
+			}
+		}
+	}
+}
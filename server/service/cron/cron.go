@@ -0,0 +1,132 @@
+package cron
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/cron"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+	"go.uber.org/zap"
+)
+
+// interval defines how often due crons are checked.
+const interval = 30 * time.Second
+
+// New returns new CronService instance.
+func New(
+	crons core.CronStore,
+	builds core.BuildStore,
+	scheduler core.Scheduler,
+	ws *ws.Server,
+	logger *zap.Logger,
+) core.CronService {
+	s := &cronService{
+		crons:     crons,
+		builds:    builds,
+		scheduler: scheduler,
+		ws:        ws,
+		logger:    logger.With(zap.String("type", "cron")).Sugar(),
+	}
+	go s.run()
+	return s
+}
+
+type cronService struct {
+	crons     core.CronStore
+	builds    core.BuildStore
+	scheduler core.Scheduler
+	ws        *ws.Server
+	logger    *zap.SugaredLogger
+}
+
+func (s *cronService) Create(c *core.Cron) error {
+	next, err := nextRun(c, time.Now())
+	if err != nil {
+		return err
+	}
+	c.NextRun = &next
+	return s.crons.Create(c)
+}
+
+// run checks for due crons on every interval. Next run time is persisted,
+// so crons missed while server was down are triggered once on startup.
+func (s *cronService) run() {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.trigger(time.Now())
+		<-ticker.C
+	}
+}
+
+func (s *cronService) trigger(now time.Time) {
+	crons, err := s.crons.ListDue(now)
+	if err != nil {
+		s.logger.Errorf("error listing due crons: %v", err)
+		return
+	}
+
+	for _, c := range crons {
+		next, err := nextRun(c, now)
+		if err != nil {
+			s.logger.Errorf("cron %d: %v", c.ID, err)
+			continue
+		}
+		// claim cron before triggering so it is never fired twice,
+		// even when multiple server instances share the database.
+		claimed, err := s.crons.Claim(c, next)
+		if err != nil {
+			s.logger.Errorf("error claiming cron %d: %v", c.ID, err)
+			continue
+		}
+		if !claimed || c.Repository == nil {
+			continue
+		}
+		if err := s.build(c); err != nil {
+			s.logger.Errorf("error triggering build for cron %d: %v", c.ID, err)
+			continue
+		}
+		s.logger.Infof("triggered build for cron %d on repository %d, next run at %s", c.ID, c.RepositoryID, next)
+	}
+}
+
+func (s *cronService) build(c *core.Cron) error {
+	if err := s.scheduler.Accept(true); err != nil {
+		return err
+	}
+
+	jobs, id, err := s.builds.TriggerBuild(core.TriggerBuildOpts{
+		ID:     c.RepositoryID,
+		Branch: c.Branch,
+		UserID: c.Repository.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if err := s.scheduler.Next(job); err != nil {
+			return err
+		}
+	}
+
+	if build, err := s.builds.Find(id); err == nil {
+		s.ws.App.Broadcast("/subs/builds", map[string]interface{}{"build": build})
+	}
+
+	return nil
+}
+
+func nextRun(c *core.Cron, now time.Time) (time.Time, error) {
+	schedule, err := cron.Parse(c.Spec, c.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return next, fmt.Errorf("cron expression %s never runs", c.Spec)
+	}
+	return next.UTC(), nil
+}
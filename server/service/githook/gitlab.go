@@ -0,0 +1,72 @@
+package githook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// draftPrefixes are merge request title prefixes GitLab uses to mark
+// draft merge requests.
+var draftPrefixes = []string{"draft:", "[draft]", "(draft)", "wip:", "[wip]"}
+
+// gitlabMergeRequest contains merge request hook fields not mapped by go-scm.
+type gitlabMergeRequest struct {
+	ObjectAttributes struct {
+		Title          string `json:"title"`
+		Action         string `json:"action"`
+		OldRev         string `json:"oldrev"`
+		Draft          bool   `json:"draft"`
+		WorkInProgress bool   `json:"work_in_progress"`
+	} `json:"object_attributes"`
+	Changes struct {
+		Title struct {
+			Previous string `json:"previous"`
+			Current  string `json:"current"`
+		} `json:"title"`
+		Draft struct {
+			Previous bool `json:"previous"`
+			Current  bool `json:"current"`
+		} `json:"draft"`
+	} `json:"changes"`
+}
+
+func parseGitlabMergeRequest(body []byte) (*gitlabMergeRequest, error) {
+	mr := &gitlabMergeRequest{}
+	if err := json.Unmarshal(body, mr); err != nil {
+		return nil, err
+	}
+	return mr, nil
+}
+
+// draft returns true if merge request is marked as draft.
+func (mr *gitlabMergeRequest) draft() bool {
+	attrs := mr.ObjectAttributes
+	return attrs.Draft || attrs.WorkInProgress || draftTitle(attrs.Title)
+}
+
+// readied returns true when update marks draft merge request as ready.
+func (mr *gitlabMergeRequest) readied() bool {
+	changes := mr.Changes
+	if changes.Draft.Previous && !changes.Draft.Current {
+		return true
+	}
+	return draftTitle(changes.Title.Previous) && !draftTitle(changes.Title.Current)
+}
+
+// commitless returns true for update events which do not push new commits,
+// like description or label changes. Marking draft as ready is not
+// commitless so the merge request is built once ready.
+func (mr *gitlabMergeRequest) commitless() bool {
+	attrs := mr.ObjectAttributes
+	return attrs.Action == "update" && attrs.OldRev == "" && !mr.readied()
+}
+
+func draftTitle(title string) bool {
+	title = strings.ToLower(strings.TrimSpace(title))
+	for _, prefix := range draftPrefixes {
+		if strings.HasPrefix(title, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,9 @@
 package githook
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"path"
 	"strings"
@@ -14,28 +16,60 @@ const emptyCommit = "0000000000000000000000000000000000000000"
 
 // NewParser returns a new GitHookParser.
 func NewParser(client *scm.Client) core.GitHookParser {
-	return &parser{client}
+	return &parser{client: client}
+}
+
+// NewReplayParser returns a new GitHookParser which does not verify
+// signature of deliveries, used to replay deliveries which were verified
+// when received.
+func NewReplayParser(client *scm.Client) core.GitHookParser {
+	return &parser{client: client, trusted: true}
 }
 
 type parser struct {
-	client *scm.Client
+	client  *scm.Client
+	trusted bool
 }
 
 func (p *parser) Parse(req *http.Request, secretFunc func(string) *core.Repository) (*core.GitHook, *core.Repository, error) {
-	fn := func(webhook scm.Webhook) (string, error) {
-		if webhook == nil {
-			return "", scm.ErrUnknownEvent
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// delivery is verified with each secret of the repository in turn,
+	// so both secrets are accepted while secret is being rotated.
+	var payload scm.Webhook
+	for i := 0; ; i++ {
+		var more bool
+		fn := func(webhook scm.Webhook) (string, error) {
+			if webhook == nil {
+				return "", scm.ErrUnknownEvent
+			}
+			repo := webhook.Repository()
+			fullname := fmt.Sprintf("%s/%s", repo.Namespace, repo.Name)
+			r := secretFunc(fullname)
+			if r == nil {
+				return "", fmt.Errorf("cannot find repository")
+			}
+			if p.trusted {
+				return "", nil
+			}
+			secrets := r.WebhookSecrets()
+			if i >= len(secrets) {
+				return "", fmt.Errorf("cannot find repository")
+			}
+			more = i+1 < len(secrets)
+			return secrets[i], nil
 		}
-		repo := webhook.Repository()
-		fullname := fmt.Sprintf("%s/%s", repo.Namespace, repo.Name)
-		r := secretFunc(fullname)
-		if r == nil || r.Provider.Secret == "" {
-			return "", fmt.Errorf("cannot find repository")
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		payload, err = p.client.Webhooks.Parse(req, fn)
+		if err == scm.ErrSignatureInvalid && more {
+			continue
 		}
-		return r.Provider.Secret, nil
+		break
 	}
-
-	payload, err := p.client.Webhooks.Parse(req, fn)
 	if err == scm.ErrUnknownEvent {
 		return nil, nil, nil
 	}
@@ -49,7 +83,7 @@ func (p *parser) Parse(req *http.Request, secretFunc func(string) *core.Reposito
 	case *scm.TagHook:
 		return p.parseTagHook(h)
 	case *scm.PullRequestHook:
-		return p.parsePRHook(h)
+		return p.parsePRHook(h, body)
 	case *scm.BranchHook:
 		return p.parseBranchHook(h)
 	default:
@@ -179,11 +213,23 @@ func (p *parser) parseTagHook(h *scm.TagHook) (*core.GitHook, *core.Repository,
 	return githook, repo, nil
 }
 
-func (p *parser) parsePRHook(h *scm.PullRequestHook) (*core.GitHook, *core.Repository, error) {
-	if h.Action == scm.ActionClose {
+func (p *parser) parsePRHook(h *scm.PullRequestHook, body []byte) (*core.GitHook, *core.Repository, error) {
+	if h.Action == scm.ActionClose || h.Action == scm.ActionMerge {
 		return nil, nil, nil
 	}
 
+	var draft bool
+	if p.client.Driver == scm.DriverGitlab {
+		mr, err := parseGitlabMergeRequest(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mr.commitless() {
+			return nil, nil, nil
+		}
+		draft = mr.draft()
+	}
+
 	githook := &core.GitHook{
 		Event:        core.EventPullRequest,
 		Action:       h.Action.String(),
@@ -208,6 +254,7 @@ func (p *parser) parsePRHook(h *scm.PullRequestHook) (*core.GitHook, *core.Repos
 		PrNumber:     h.PullRequest.Number,
 		PrTitle:      h.PullRequest.Title,
 		PrBody:       h.PullRequest.Body,
+		Draft:        draft,
 	}
 	repo := &core.Repository{
 		UID:       h.Repo.ID,
@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// Discord is a notifier that posts build notifications to the
+// Discord webhook.
+type Discord struct{}
+
+// NewDiscord returns a new Discord notifier.
+func NewDiscord() *Discord {
+	return &Discord{}
+}
+
+// Name returns notifier name.
+func (d *Discord) Name() string {
+	return "discord"
+}
+
+// Notify posts formatted build status message to the Discord webhook
+// configured in repository notification settings.
+func (d *Discord) Notify(n *core.Notification) error {
+	repo := n.Build.Repository
+	if repo == nil || repo.Notify.DiscordURL == "" || !repo.Notify.ShouldNotify(n.Event) {
+		return nil
+	}
+
+	color := 0x48bb78
+	if n.Build.Status() == core.BuildStatusFailing {
+		color = 0xe74c3c
+	}
+
+	payload := map[string]interface{}{
+		"embeds": []map[string]interface{}{
+			{
+				"title": fmt.Sprintf("Build #%d %s", n.Build.ID, title(n.Event)),
+				"url":   n.URL,
+				"color": color,
+				"fields": []map[string]interface{}{
+					{"name": "Repository", "value": repo.FullName, "inline": true},
+					{"name": "Branch", "value": n.Build.Branch, "inline": true},
+					{"name": "Commit", "value": shortSHA(n.Build.Commit), "inline": true},
+					{"name": "Author", "value": n.Build.AuthorName, "inline": true},
+				},
+			},
+		},
+	}
+
+	return postJSON(repo.Notify.DiscordURL, payload)
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+var emailTemplates = map[string]*template.Template{
+	core.NotifyEventFailed: template.Must(template.New("failed").Parse(
+		`Build #{{.Build.ID}} of {{.Build.Repository.FullName}} ({{.Build.Branch}}) failed.
+
+Commit:  {{.Build.Commit}}
+Author:  {{.Build.AuthorName}} <{{.Build.AuthorEmail}}>
+Message: {{.Build.CommitMessage}}
+
+{{.URL}}
+`)),
+	core.NotifyEventFixed: template.Must(template.New("fixed").Parse(
+		`Build #{{.Build.ID}} of {{.Build.Repository.FullName}} ({{.Build.Branch}}) was fixed.
+
+Commit:  {{.Build.Commit}}
+Author:  {{.Build.AuthorName}} <{{.Build.AuthorEmail}}>
+Message: {{.Build.CommitMessage}}
+
+{{.URL}}
+`)),
+	core.NotifyEventStillFailing: template.Must(template.New("still_failing").Parse(
+		`Build #{{.Build.ID}} of {{.Build.Repository.FullName}} ({{.Build.Branch}}) is still failing.
+
+Commit:  {{.Build.Commit}}
+Author:  {{.Build.AuthorName}} <{{.Build.AuthorEmail}}>
+Message: {{.Build.CommitMessage}}
+
+{{.URL}}
+`)),
+}
+
+var emailSubjects = map[string]string{
+	core.NotifyEventFailed:       "Failed",
+	core.NotifyEventFixed:        "Fixed",
+	core.NotifyEventStillFailing: "Still Failing",
+}
+
+// Email is a notifier that sends build notifications via SMTP.
+type Email struct {
+	config *config.SMTP
+}
+
+// NewEmail returns a new Email notifier.
+func NewEmail(config *config.SMTP) *Email {
+	return &Email{config}
+}
+
+// Name returns notifier name.
+func (e *Email) Name() string {
+	return "email"
+}
+
+// Notify sends email notification to repository recipients and
+// optionally to commit author.
+func (e *Email) Notify(n *core.Notification) error {
+	tmpl, ok := emailTemplates[n.Event]
+	if !ok || n.Build.Repository == nil {
+		return nil
+	}
+
+	recipients := recipients(n.Build)
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, n); err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] %s: build #%d (%s)", n.Build.Repository.FullName, emailSubjects[n.Event], n.Build.ID, n.Build.Branch)
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n%s",
+		e.config.From,
+		strings.Join(recipients, ", "),
+		subject,
+		body.String(),
+	)
+
+	var auth smtp.Auth
+	if e.config.Username != "" {
+		auth = smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+	}
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+
+	return smtp.SendMail(addr, auth, e.config.From, recipients, []byte(msg))
+}
+
+func recipients(build *core.Build) []string {
+	var emails []string
+	for _, email := range strings.Split(build.Repository.Notify.Emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if build.Repository.Notify.Author && build.AuthorEmail != "" && !lib.Include(emails, build.AuthorEmail) {
+		emails = append(emails, build.AuthorEmail)
+	}
+	return emails
+}
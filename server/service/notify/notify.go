@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"go.uber.org/zap"
+)
+
+// queueSize is the maximum number of finished builds waiting
+// to be processed before new notifications are dropped.
+const queueSize = 100
+
+// New returns a new NotifyService.
+func New(config *config.Config, logger *zap.Logger, builds core.BuildStore) core.NotifyService {
+	s := &notifyService{
+		builds: builds,
+		logger: logger.With(zap.String("type", "notify")).Sugar(),
+		queue:  make(chan *core.Build, queueSize),
+		notifiers: []core.Notifier{
+			NewSlack(),
+			NewDiscord(),
+		},
+	}
+	if t, err := config.Transport(); err != nil {
+		s.logger.Errorf("error configuring notification transport: %v", err)
+	} else {
+		httpClient.Transport = t
+	}
+	if config.SMTP != nil && config.SMTP.Host != "" {
+		s.notifiers = append(s.notifiers, NewEmail(config.SMTP))
+	}
+	go s.run()
+	return s
+}
+
+type notifyService struct {
+	builds    core.BuildStore
+	logger    *zap.SugaredLogger
+	notifiers []core.Notifier
+	queue     chan *core.Build
+}
+
+func (s *notifyService) Notify(build *core.Build) {
+	select {
+	case s.queue <- build:
+	default:
+		s.logger.Errorf("notification queue full, dropping notifications for build %d", build.ID)
+	}
+}
+
+func (s *notifyService) run() {
+	for build := range s.queue {
+		if len(s.notifiers) == 0 {
+			continue
+		}
+
+		n := &core.Notification{
+			Event: s.event(build),
+			Build: build,
+			URL:   fmt.Sprintf("%s/builds/%d", build.Repository.Provider.ServerURL(), build.ID),
+		}
+
+		for _, notifier := range s.notifiers {
+			go func(notifier core.Notifier, build *core.Build) {
+				if err := notifier.Notify(n); err != nil {
+					s.logger.Errorf("error sending %s notification for build %d: %v", notifier.Name(), build.ID, err)
+				}
+			}(notifier, build)
+		}
+	}
+}
+
+// event compares build status with previous build on the same
+// branch and returns notification event.
+func (s *notifyService) event(build *core.Build) string {
+	prevFailing := false
+	if prev, err := s.builds.FindPrevious(build); err == nil {
+		prevFailing = prev.Status() == core.BuildStatusFailing
+	}
+
+	if build.Status() == core.BuildStatusFailing {
+		if prevFailing {
+			return core.NotifyEventStillFailing
+		}
+		return core.NotifyEventFailed
+	}
+	if prevFailing {
+		return core.NotifyEventFixed
+	}
+	return core.NotifyEventPassed
+}
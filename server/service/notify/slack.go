@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// Slack is a notifier that posts build notifications to the
+// Slack incoming webhook.
+type Slack struct{}
+
+// NewSlack returns a new Slack notifier.
+func NewSlack() *Slack {
+	return &Slack{}
+}
+
+// Name returns notifier name.
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Notify posts formatted build status message to the Slack webhook
+// configured in repository notification settings.
+func (s *Slack) Notify(n *core.Notification) error {
+	repo := n.Build.Repository
+	if repo == nil || repo.Notify.SlackURL == "" || !repo.Notify.ShouldNotify(n.Event) {
+		return nil
+	}
+
+	color := "good"
+	if n.Build.Status() == core.BuildStatusFailing {
+		color = "danger"
+	}
+
+	payload := map[string]interface{}{
+		"attachments": []map[string]interface{}{
+			{
+				"fallback":   fmt.Sprintf("Build #%d of %s (%s) %s", n.Build.ID, repo.FullName, n.Build.Branch, title(n.Event)),
+				"color":      color,
+				"title":      fmt.Sprintf("Build #%d %s", n.Build.ID, title(n.Event)),
+				"title_link": n.URL,
+				"fields": []map[string]interface{}{
+					{"title": "Repository", "value": repo.FullName, "short": true},
+					{"title": "Branch", "value": n.Build.Branch, "short": true},
+					{"title": "Commit", "value": shortSHA(n.Build.Commit), "short": true},
+					{"title": "Author", "value": n.Build.AuthorName, "short": true},
+				},
+			},
+		},
+	}
+
+	return postJSON(repo.Notify.SlackURL, payload)
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
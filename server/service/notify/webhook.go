@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jpillora/backoff"
+)
+
+// webhookAttempts is the number of times webhook post is attempted
+// before giving up.
+const webhookAttempts = 3
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON sends JSON encoded payload to the webhook URL and retries
+// failed requests with exponential backoff.
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	b := &backoff.Backoff{
+		Min:    time.Second,
+		Max:    10 * time.Second,
+		Jitter: true,
+	}
+
+	for {
+		err = post(url, body)
+		if err == nil || int(b.Attempt())+1 >= webhookAttempts {
+			return err
+		}
+		time.Sleep(b.Duration())
+	}
+}
+
+func post(url string, body []byte) error {
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// title returns human readable notification title.
+func title(event string) string {
+	switch event {
+	case core.NotifyEventPassed:
+		return "passed"
+	case core.NotifyEventFailed:
+		return "failed"
+	case core.NotifyEventFixed:
+		return "was fixed"
+	case core.NotifyEventStillFailing:
+		return "is still failing"
+	default:
+		return event
+	}
+}
@@ -0,0 +1,354 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	jwt "github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+)
+
+// keysInterval is minimal time between reloads of provider keys when
+// ID token is signed with unknown key, e.g. after key rotation.
+const keysInterval = time.Minute
+
+// New returns OpenID Connect provider single sign-on is configured with,
+// nil is returned when it is not enabled.
+func New(config *config.Config, logger *zap.Logger) core.OIDCProvider {
+	if config.Auth == nil || config.Auth.OIDC == nil || config.Auth.OIDC.Issuer == "" {
+		return nil
+	}
+	log := logger.With(zap.String("type", "oidc")).Sugar()
+
+	t, err := config.Transport()
+	if err != nil {
+		log.Errorf("error configuring oidc provider: %v", err)
+		return nil
+	}
+	var baseURL string
+	if config.HTTP != nil {
+		baseURL = config.HTTP.BaseURL
+	}
+	return NewProvider(config.Auth.OIDC, baseURL, t)
+}
+
+// NewProvider returns OpenID Connect provider discovered from issuer of
+// the config. Redirect URL defaults to callback endpoint under baseURL.
+func NewProvider(cfg *config.OIDC, baseURL string, transport http.RoundTripper) core.OIDCProvider {
+	p := &provider{
+		config: *cfg,
+		client: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}
+	if p.config.RedirectURL == "" {
+		p.config.RedirectURL = strings.TrimSuffix(baseURL, "/") + "/api/v1/auth/oidc/callback"
+	}
+	if len(p.config.Scopes) == 0 {
+		p.config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if p.config.GroupsClaim == "" {
+		p.config.GroupsClaim = "groups"
+	}
+	if p.config.Name == "" {
+		p.config.Name = "SSO"
+	}
+	return p
+}
+
+type provider struct {
+	config config.OIDC
+	client *http.Client
+
+	mu         sync.Mutex
+	discovery  *discovery
+	keys       map[string]interface{} // public keys by key ID
+	keysLoaded time.Time
+}
+
+// discovery is OpenID Provider metadata.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse is response of token endpoint.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (p *provider) Name() string {
+	return p.config.Name
+}
+
+func (p *provider) AuthURL(ctx context.Context, state, nonce string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.scopes(), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code, nonce string) (*core.OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	var resp tokenResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil && res.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("invalid oidc token response: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || resp.Error != "" {
+		return nil, fmt.Errorf("oidc token request failed: %s", tokenError(res.StatusCode, resp))
+	}
+	if resp.IDToken == "" {
+		return nil, fmt.Errorf("oidc token response has no id_token")
+	}
+	return p.verify(ctx, d, resp.IDToken, nonce)
+}
+
+// verify checks signature, issuer, audience, expiry and nonce of ID
+// token and returns identity from its claims.
+func (p *provider) verify(ctx context.Context, d *discovery, idToken, nonce string) (*core.OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %v", err)
+	}
+
+	if !claims.VerifyIssuer(d.Issuer, true) {
+		return nil, fmt.Errorf("invalid id token: unexpected issuer %v", claims["iss"])
+	}
+	if !audience(claims["aud"], p.config.ClientID) {
+		return nil, fmt.Errorf("invalid id token: client is not in audience")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("invalid id token: missing expiry")
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, fmt.Errorf("invalid id token: nonce does not match")
+	}
+
+	identity := &core.OIDCIdentity{
+		Subject: str(claims["sub"]),
+		Email:   str(claims["email"]),
+		Name:    str(claims["name"]),
+		Groups:  groups(lookup(claims, p.config.GroupsClaim)),
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("id token has no email claim, request email scope")
+	}
+	// users are matched by email, so email must be verified by provider,
+	// some providers encode the claim as string.
+	if v := claims["email_verified"]; v != true && v != "true" {
+		return nil, fmt.Errorf("email %s is not verified by identity provider", identity.Email)
+	}
+	if identity.Name == "" {
+		identity.Name = str(claims["preferred_username"])
+	}
+	return identity, nil
+}
+
+// scopes returns requested scopes, openid scope is always included.
+func (p *provider) scopes() []string {
+	for _, s := range p.config.Scopes {
+		if s == "openid" {
+			return p.config.Scopes
+		}
+	}
+	return append([]string{"openid"}, p.config.Scopes...)
+}
+
+// discover returns provider metadata, it is fetched on first use and
+// again after failure.
+func (p *provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	issuer := strings.TrimSuffix(p.config.Issuer, "/")
+	d := &discovery{}
+	if err := p.get(ctx, issuer+"/.well-known/openid-configuration", d); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %v", err)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery failed: issuer %s does not match %s", d.Issuer, p.config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery failed: missing endpoints")
+	}
+	p.discovery = d
+	return d, nil
+}
+
+// key returns public key ID token is signed with, keys are reloaded
+// when key is not known, at most once per keysInterval.
+func (p *provider) key(ctx context.Context, d *discovery, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.findKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysLoaded) < keysInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set jwks
+	if err := p.get(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("cannot load signing keys: %v", err)
+	}
+	p.keys, p.keysLoaded = set.publicKeys(), time.Now()
+
+	if key, ok := p.findKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// findKey returns key by ID, single key is used when token has no key ID.
+func (p *provider) findKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// get fetches JSON document from URL.
+func (p *provider) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, u)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// tokenError returns error message of failed token request.
+func tokenError(status int, resp tokenResponse) string {
+	if resp.ErrorDescription != "" {
+		return fmt.Sprintf("%s: %s", resp.Error, resp.ErrorDescription)
+	}
+	if resp.Error != "" {
+		return resp.Error
+	}
+	return fmt.Sprintf("unexpected status %d", status)
+}
+
+// audience reports whether client ID is in aud claim, which is string
+// or list of strings.
+func audience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookup returns claim by name, dots separate nested claims, e.g.
+// realm_access.roles of Keycloak.
+func lookup(claims map[string]interface{}, name string) interface{} {
+	if v, ok := claims[name]; ok {
+		return v
+	}
+	parts := strings.Split(name, ".")
+	var v interface{} = claims
+	for _, part := range parts {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[part]
+	}
+	return v
+}
+
+// groups returns groups from claim which is list or single string.
+func groups(claim interface{}) []string {
+	switch claim := claim.(type) {
+	case string:
+		return []string{claim}
+	case []interface{}:
+		var list []string
+		for _, g := range claim {
+			if s, ok := g.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
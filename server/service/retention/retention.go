@@ -0,0 +1,76 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+	"go.uber.org/zap"
+)
+
+// interval defines how often expired builds are deleted.
+const interval = time.Hour
+
+// batch is maximum number of builds deleted in single transaction.
+const batch = 100
+
+// New returns new RetentionService instance which periodically deletes
+// builds of repositories outside of their retention policy.
+func New(repos core.RepositoryStore, builds core.BuildStore, logger *zap.Logger) core.RetentionService {
+	s := &retentionService{
+		repos:  repos,
+		builds: builds,
+		logger: logger.With(zap.String("type", "retention")).Sugar(),
+	}
+	go s.run()
+	return s
+}
+
+type retentionService struct {
+	repos  core.RepositoryStore
+	builds core.BuildStore
+	logger *zap.SugaredLogger
+}
+
+func (s *retentionService) Prune(repo *core.Repository) (int, error) {
+	now := time.Now()
+	var total int
+	for {
+		n, err := s.builds.Prune(repo, now, batch)
+		total += n
+		if err != nil || n < batch {
+			return total, err
+		}
+	}
+}
+
+func (s *retentionService) run() {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.prune()
+		<-ticker.C
+	}
+}
+
+func (s *retentionService) prune() {
+	repos, _, err := s.repos.List(core.RepositoryFilter{})
+	if err != nil {
+		s.logger.Errorf("error listing repositories: %v", err)
+		return
+	}
+
+	for i := range repos {
+		repo := &repos[i]
+		if !repo.Retention.Enabled() {
+			continue
+		}
+		n, err := s.Prune(repo)
+		if err != nil {
+			s.logger.Errorf("error deleting expired builds of repository %d: %v", repo.ID, err)
+		}
+		if n > 0 {
+			s.logger.Infof("deleted %d expired builds of repository %s", n, repo.FullName)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"go.uber.org/zap"
+)
+
+// New returns a new SecretService with providers which are configured.
+func New(config *config.Config, logger *zap.Logger) core.SecretService {
+	s := &secretService{providers: make(map[string]core.SecretProvider)}
+	log := logger.With(zap.String("type", "secret")).Sugar()
+
+	if config.Vault != nil && config.Vault.Addr != "" {
+		if t, err := config.Transport(); err != nil {
+			log.Errorf("error configuring vault secret provider: %v", err)
+		} else {
+			s.add(NewVault(config.Vault, t))
+		}
+	}
+	return s
+}
+
+type secretService struct {
+	providers map[string]core.SecretProvider
+}
+
+func (s *secretService) Fetch(ctx context.Context, ref core.SecretRef) (string, error) {
+	name := ref.Provider
+	if name == "" {
+		name = core.SecretProviderVault
+	}
+	provider, ok := s.providers[name]
+	if !ok {
+		return "", fmt.Errorf("secret provider %s not configured", name)
+	}
+	return provider.Fetch(ctx, ref.Path, ref.Key)
+}
+
+func (s *secretService) add(provider core.SecretProvider) {
+	s.providers[provider.Name()] = provider
+}
@@ -0,0 +1,215 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// tokenMargin is how long before expiry AppRole token is renewed.
+const tokenMargin = 30 * time.Second
+
+// NewVault returns HashiCorp Vault secret provider reading secrets
+// from KV secrets engine with HTTP API.
+func NewVault(cfg *config.Vault, transport http.RoundTripper) core.SecretProvider {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	v := &vault{
+		config: *cfg,
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}
+	if v.config.Mount == "" {
+		v.config.Mount = "secret"
+	}
+	if v.config.KVVersion == 0 {
+		v.config.KVVersion = 2
+	}
+	if v.config.Auth == "" || v.config.Auth == config.VaultAuthToken {
+		v.token = v.config.Token
+	}
+	return v
+}
+
+type vault struct {
+	config config.Vault
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time // zero when token does not expire
+}
+
+// LeaseFetcher is implemented by secret providers which return lease
+// duration of secrets, so values can be read again before it expires.
+type LeaseFetcher interface {
+	// FetchLease returns value of key in secret at path and lease
+	// duration of the secret, zero when secret has no lease.
+	FetchLease(ctx context.Context, path, key string) (string, time.Duration, error)
+}
+
+// vaultResponse is response of Vault API.
+type vaultResponse struct {
+	Data          json.RawMessage `json:"data"`
+	Auth          *vaultAuth      `json:"auth"`
+	LeaseDuration int             `json:"lease_duration"` // in seconds
+	Errors        []string        `json:"errors"`
+}
+
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"` // in seconds
+}
+
+func (v *vault) Name() string {
+	return core.SecretProviderVault
+}
+
+func (v *vault) Fetch(ctx context.Context, path, key string) (string, error) {
+	value, _, err := v.FetchLease(ctx, path, key)
+	return value, err
+}
+
+// FetchLease returns value of key in secret at path. KV version 1
+// secrets have lease set to their ttl, version 2 secrets have none.
+func (v *vault) FetchLease(ctx context.Context, path, key string) (string, time.Duration, error) {
+	token, err := v.login(ctx)
+	if err != nil {
+		return "", 0, fmt.Errorf("vault login failed: %v", err)
+	}
+
+	path = strings.Trim(path, "/")
+	endpoint := fmt.Sprintf("%s/%s", strings.Trim(v.config.Mount, "/"), path)
+	if v.config.KVVersion == 2 {
+		endpoint = fmt.Sprintf("%s/data/%s", strings.Trim(v.config.Mount, "/"), path)
+	}
+	resp, status, err := v.do(ctx, http.MethodGet, endpoint, token, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("cannot read vault secret %s: %v", path, err)
+	}
+	if status == http.StatusForbidden && v.config.Auth == config.VaultAuthAppRole {
+		v.mu.Lock()
+		v.token = ""
+		v.mu.Unlock()
+	}
+	if status == http.StatusNotFound {
+		return "", 0, fmt.Errorf("vault secret %s not found", path)
+	}
+	if status != http.StatusOK {
+		return "", 0, fmt.Errorf("cannot read vault secret %s: %s", path, vaultError(status, resp))
+	}
+
+	data := resp.Data
+	if v.config.KVVersion == 2 {
+		var kv struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &kv); err != nil {
+			return "", 0, fmt.Errorf("invalid vault secret %s: %v", path, err)
+		}
+		data = kv.Data
+	}
+	if len(data) == 0 || string(data) == "null" {
+		return "", 0, fmt.Errorf("vault secret %s not found", path)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return "", 0, fmt.Errorf("invalid vault secret %s: %v", path, err)
+	}
+	value, ok := values[key]
+	if !ok || value == nil {
+		return "", 0, fmt.Errorf("key %s not found in vault secret %s", key, path)
+	}
+	lease := time.Duration(resp.LeaseDuration) * time.Second
+	if s, ok := value.(string); ok {
+		return s, lease, nil
+	}
+	return fmt.Sprintf("%v", value), lease, nil
+}
+
+// login returns token requests are authenticated with, AppRole token
+// is requested when it is not set or is about to expire.
+func (v *vault) login(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.config.Auth != config.VaultAuthAppRole {
+		return v.token, nil
+	}
+	if v.token != "" && (v.expires.IsZero() || time.Now().Before(v.expires)) {
+		return v.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"role_id":   v.config.RoleID,
+		"secret_id": v.config.SecretID,
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, status, err := v.do(ctx, http.MethodPost, "auth/approle/login", "", body)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("%s", vaultError(status, resp))
+	}
+
+	v.token, v.expires = resp.Auth.ClientToken, time.Time{}
+	if lease := time.Duration(resp.Auth.LeaseDuration) * time.Second; lease > 0 {
+		v.expires = time.Now().Add(lease - tokenMargin)
+	}
+	return v.token, nil
+}
+
+// do sends request to Vault API and returns decoded response with
+// status code.
+func (v *vault) do(ctx context.Context, method, endpoint, token string, body []byte) (*vaultResponse, int, error) {
+	u, err := url.Parse(strings.TrimSuffix(v.config.Addr, "/") + "/v1/" + endpoint)
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	resp := &vaultResponse{}
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil && res.StatusCode == http.StatusOK {
+		return nil, res.StatusCode, err
+	}
+	return resp, res.StatusCode, nil
+}
+
+// vaultError returns error message of failed Vault API request.
+func vaultError(status int, resp *vaultResponse) string {
+	if resp != nil && len(resp.Errors) > 0 {
+		return strings.Join(resp.Errors, ", ")
+	}
+	return fmt.Sprintf("unexpected status %d", status)
+}
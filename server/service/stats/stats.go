@@ -22,6 +22,7 @@ type statsService struct {
 	ws        *ws.Server
 	history   []core.Usage
 	stats     []core.SchedulerStats
+	queue     [3]int // queued, pending and running jobs last broadcasted
 	scheduler core.Scheduler
 }
 
@@ -77,4 +78,14 @@ func (s *statsService) handleUsage() {
 		"running": stats.Running,
 	}
 	s.ws.App.Broadcast(sub, event)
+
+	if queue := [3]int{stats.Queued, stats.Pending, stats.Running}; queue != s.queue {
+		s.queue = queue
+		s.ws.App.Broadcast("/subs/queue", map[string]interface{}{
+			"queued":  stats.Queued,
+			"pending": stats.Pending,
+			"running": stats.Running,
+			"max":     stats.Max,
+		})
+	}
 }
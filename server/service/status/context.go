@@ -0,0 +1,91 @@
+package status
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/parser"
+	"github.com/bleenco/abstruse/server/pipeline"
+	"github.com/drone/go-scm/scm"
+)
+
+// buildStatuses returns statuses reported for the build as set in its
+// config, single status with state when build is reported as whole or
+// status of each context derived from statuses of its jobs.
+func buildStatuses(build *core.Build, state scm.State) ([]commitStatus, error) {
+	config, err := parser.ParseStatusConfig(build.Config)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := config.Template()
+	if err != nil {
+		return nil, err
+	}
+	if config.Per != parser.StatusPerJob && config.Per != parser.StatusPerStage {
+		context, err := parser.ExecuteContext(tmpl, parser.StatusContext{})
+		if err != nil {
+			return nil, err
+		}
+		return []commitStatus{{context: context, state: state}}, nil
+	}
+
+	var contexts []string
+	jobs := make(map[string][]string) // job statuses by context
+	index := make(map[string]int)     // jobs seen by stage
+	for _, job := range build.Jobs {
+		index[job.Stage]++
+		context, err := parser.ExecuteContext(tmpl, parser.StatusContext{
+			Stage: job.Stage,
+			Index: index[job.Stage],
+			Env:   job.Env,
+			Image: job.Image,
+			Arch:  job.Arch,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := jobs[context]; !ok {
+			contexts = append(contexts, context)
+		}
+		jobs[context] = append(jobs[context], job.Status)
+	}
+
+	var statuses []commitStatus
+	for _, context := range contexts {
+		statuses = append(statuses, commitStatus{context: context, state: jobsState(jobs[context])})
+	}
+	return statuses, nil
+}
+
+// jobsState returns state of jobs reported together, pending or
+// running until all jobs finished, then failure when any job failed or
+// finished with unknown status, cancelled when any job was cancelled or
+// skipped and success otherwise.
+func jobsState(statuses []string) scm.State {
+	var running, queued, failing, cancelled bool
+	for _, status := range statuses {
+		switch status {
+		case pipeline.StatusRunning:
+			running = true
+		case pipeline.StatusQueued:
+			queued = true
+		case pipeline.StatusFailing:
+			failing = true
+		case pipeline.StatusCancelled, pipeline.StatusSkipped:
+			cancelled = true
+		case pipeline.StatusPassing:
+		default:
+			failing = true
+		}
+	}
+	switch {
+	case running:
+		return scm.StateRunning
+	case queued:
+		return scm.StatePending
+	case failing:
+		return scm.StateFailure
+	case cancelled:
+		return scm.StateCanceled
+	default:
+		return scm.StateSuccess
+	}
+}
@@ -0,0 +1,230 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/gitscm"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/parser"
+	"github.com/drone/go-scm/scm"
+	"github.com/jpillora/backoff"
+	"go.uber.org/zap"
+)
+
+// maxAttempts is the number of times status is attempted to be
+// sent on transient errors.
+const maxAttempts = 3
+
+// sentTTL is how long last sent statuses of the build are kept.
+const sentTTL = 24 * time.Hour
+
+// New returns a new StatusReporter.
+func New(logger *zap.Logger) core.StatusReporter {
+	return &reporter{
+		logger:  logger.With(zap.String("type", "status")).Sugar(),
+		limited: make(map[uint]time.Time),
+		sent:    make(map[uint]*sentStatuses),
+	}
+}
+
+type reporter struct {
+	mu      sync.Mutex
+	logger  *zap.SugaredLogger
+	limited map[uint]time.Time
+	sent    map[uint]*sentStatuses // by build ID
+}
+
+// commitStatus is status of single context reported for the build.
+type commitStatus struct {
+	context string
+	state   scm.State
+}
+
+// sentStatuses holds last reported state of each context of the build.
+// Statuses are sent one at a time, so they reach provider in order
+// they were reported in.
+type sentStatuses struct {
+	sync.Mutex // held while sending
+	states     map[string]scm.State
+	updated    time.Time
+}
+
+// Report records statuses of the build which changed since last report
+// and sends them in the background.
+func (r *reporter) Report(build *core.Build, state scm.State) {
+	if build == nil || build.Repository == nil {
+		return
+	}
+
+	statuses, err := buildStatuses(build, state)
+	if err != nil {
+		r.logger.Errorf("invalid status config of build %d, reporting single status: %v", build.ID, err)
+		statuses = []commitStatus{{context: parser.DefaultBuildContext, state: state}}
+	}
+	sent, changed := r.changed(build.ID, statuses)
+	if len(changed) == 0 {
+		return
+	}
+	go r.report(build, sent, changed)
+}
+
+func (r *reporter) report(build *core.Build, sent *sentStatuses, statuses []commitStatus) {
+	sent.Lock()
+	defer sent.Unlock()
+
+	provider := build.Repository.Provider
+	if until, ok := r.rateLimited(provider.ID); ok {
+		r.logger.Warnf("skipping statuses for build %d, provider %s rate limited until %s", build.ID, provider.Name, until.Format(time.RFC3339))
+		r.forget(sent, statuses)
+		return
+	}
+
+	client, err := gitscm.New(context.Background(), provider.Name, provider.URL, provider.AccessToken)
+	if err != nil {
+		r.logger.Errorf("error sending status for build %d: %v", build.ID, err)
+		r.forget(sent, statuses)
+		return
+	}
+
+	target := fmt.Sprintf("%s/builds/%d", provider.ServerURL(), build.ID)
+	for i, status := range statuses {
+		if !r.latest(sent, status) {
+			// newer state of the context was reported meanwhile.
+			continue
+		}
+		if !r.send(client, build, target, status) {
+			r.forget(sent, statuses[i:])
+			return
+		}
+	}
+}
+
+// send sends status of the context, false is returned when status
+// could not be sent.
+func (r *reporter) send(client gitscm.SCM, build *core.Build, target string, status commitStatus) bool {
+	provider := build.Repository.Provider
+	state := status.state
+	b := &backoff.Backoff{Min: 2 * time.Second, Max: 30 * time.Second, Jitter: true}
+
+	for {
+		res, err := client.CreateStatus(build.Repository.FullName, build.Commit, target, status.context, state)
+		if err == nil {
+			r.logger.Debugf("status %s of %s for build %d sent to %s", state, status.context, build.ID, provider.Name)
+			return true
+		}
+
+		switch {
+		case res != nil && res.Status == http.StatusUnauthorized:
+			r.logger.Errorf("error sending status for build %d: %s access token is invalid or expired", build.ID, provider.Name)
+			return false
+		case res != nil && isRateLimited(res):
+			until := resetTime(res)
+			r.setRateLimited(provider.ID, until)
+			r.logger.Warnf("error sending status for build %d: %s rate limit exceeded until %s", build.ID, provider.Name, until.Format(time.RFC3339))
+			return false
+		case res != nil && res.Status < http.StatusInternalServerError:
+			r.logger.Errorf("error sending status for build %d: %v", build.ID, err)
+			return false
+		}
+
+		if int(b.Attempt())+1 >= maxAttempts {
+			r.logger.Errorf("error sending status for build %d after %d attempts: %v", build.ID, maxAttempts, err)
+			return false
+		}
+		time.Sleep(b.Duration())
+	}
+}
+
+// changed returns sent statuses of the build and statuses which differ
+// from them, changed statuses are recorded as sent. Statuses of builds
+// which were not reported for a while are forgotten.
+func (r *reporter) changed(id uint, statuses []commitStatus) (*sentStatuses, []commitStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for buildID, sent := range r.sent {
+		if now.Sub(sent.updated) > sentTTL {
+			delete(r.sent, buildID)
+		}
+	}
+
+	sent, ok := r.sent[id]
+	if !ok {
+		sent = &sentStatuses{states: make(map[string]scm.State)}
+		r.sent[id] = sent
+	}
+	sent.updated = now
+
+	var changed []commitStatus
+	for _, status := range statuses {
+		if sent.states[status.context] != status.state {
+			changed = append(changed, status)
+			sent.states[status.context] = status.state
+		}
+	}
+	return sent, changed
+}
+
+// latest returns true when status is the last state reported for its
+// context.
+func (r *reporter) latest(sent *sentStatuses, status commitStatus) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sent.states[status.context] == status.state
+}
+
+// forget removes statuses which were not sent from sent statuses, so
+// they are sent again with next report unless newer state was reported.
+func (r *reporter) forget(sent *sentStatuses, statuses []commitStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range statuses {
+		if sent.states[status.context] == status.state {
+			delete(sent.states, status.context)
+		}
+	}
+}
+
+func (r *reporter) rateLimited(id uint) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, ok := r.limited[id]
+	if !ok {
+		return until, false
+	}
+	if time.Now().After(until) {
+		delete(r.limited, id)
+		return until, false
+	}
+	return until, true
+}
+
+func (r *reporter) setRateLimited(id uint, until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limited[id] = until
+}
+
+func isRateLimited(res *scm.Response) bool {
+	if res.Status == http.StatusTooManyRequests {
+		return true
+	}
+	return res.Status == http.StatusForbidden && res.Rate.Limit > 0 && res.Rate.Remaining == 0
+}
+
+func resetTime(res *scm.Response) time.Time {
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if res.Rate.Reset > 0 {
+		return time.Unix(res.Rate.Reset, 0)
+	}
+	return time.Now().Add(time.Minute)
+}
@@ -0,0 +1,58 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// New returns a new APIKeyStore.
+func New(db *gorm.DB) core.APIKeyStore {
+	return apiKeyStore{db}
+}
+
+type apiKeyStore struct {
+	db *gorm.DB
+}
+
+func (s apiKeyStore) Find(id uint) (*core.APIKey, error) {
+	key := &core.APIKey{}
+	err := s.db.Where("id = ?", id).First(&key).Error
+	return key, err
+}
+
+func (s apiKeyStore) FindKey(plain string) (*core.APIKey, error) {
+	key := &core.APIKey{}
+	err := s.db.Preload("User").Where("hash = ?", hash(plain)).First(&key).Error
+	return key, err
+}
+
+func (s apiKeyStore) List() ([]*core.APIKey, error) {
+	var keys []*core.APIKey
+	err := s.db.Preload("User").Order("id desc").Find(&keys).Error
+	return keys, err
+}
+
+func (s apiKeyStore) Create(key *core.APIKey) (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	plain := core.APIKeyPrefix + hex.EncodeToString(buf)
+	key.Prefix = plain[:len(core.APIKeyPrefix)+6]
+	key.Hash = hash(plain)
+
+	return plain, s.db.Create(key).Error
+}
+
+func (s apiKeyStore) Delete(key *core.APIKey) error {
+	return s.db.Delete(key).Error
+}
+
+func hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
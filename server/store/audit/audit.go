@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// defaultLimit is number of events listed when limit is not set.
+const defaultLimit = 100
+
+// New returns a new AuditStore.
+func New(db *gorm.DB) core.AuditStore {
+	return auditStore{db}
+}
+
+type auditStore struct {
+	db *gorm.DB
+}
+
+func (s auditStore) Create(event *core.AuditEvent) error {
+	return s.db.Create(event).Error
+}
+
+func (s auditStore) List(filter core.AuditFilter) ([]*core.AuditEvent, error) {
+	var events []*core.AuditEvent
+	db := s.db
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.ActorID != 0 {
+		db = db.Where("actor_id = ?", filter.ActorID)
+	}
+	if !filter.From.IsZero() {
+		db = db.Where("time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		db = db.Where("time < ?", filter.To)
+	}
+	if filter.Cursor != 0 {
+		db = db.Where("id < ?", filter.Cursor)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	err := db.Order("id desc").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -4,23 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/bleenco/abstruse/pkg/gitscm"
+	"github.com/bleenco/abstruse/pkg/imagepolicy"
 	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/config"
 	"github.com/bleenco/abstruse/server/core"
 	"github.com/bleenco/abstruse/server/parser"
+	"github.com/bleenco/abstruse/server/store"
 	"github.com/jinzhu/gorm"
 )
 
 // New returns a new BuildStore
-func New(db *gorm.DB, repos core.RepositoryStore, jobs core.JobStore) core.BuildStore {
-	return buildStore{db, repos, jobs}
+func New(db *gorm.DB, repos core.RepositoryStore, jobs core.JobStore, config *config.Config) core.BuildStore {
+	return buildStore{db, repos, jobs, config.Images.Policy()}
 }
 
 type buildStore struct {
-	db    *gorm.DB
-	repos core.RepositoryStore
-	jobs  core.JobStore
+	db     *gorm.DB
+	repos  core.RepositoryStore
+	jobs   core.JobStore
+	images imagepolicy.Policy
 }
 
 func (s buildStore) Find(id uint) (*core.Build, error) {
@@ -36,6 +42,9 @@ func (s buildStore) FindUser(id, userID uint) (*core.Build, error) {
 		return &build, err
 	}
 	build.Repository.Perms = s.repos.GetPermissions(build.RepositoryID, userID)
+	if p, err := build.Pipeline(); err == nil {
+		build.Stages = p.Stages()
+	}
 	return &build, err
 }
 
@@ -55,28 +64,36 @@ func (s buildStore) FindStatus(token, branch string) (string, error) {
 		return core.BuildStatusUnknown, err
 	}
 
-	running, failing := false, false
-	for _, job := range build.Jobs {
-		if job.Status == "running" {
-			running = true
-		}
-		if job.Status == "failing" {
-			failing = true
-		}
-	}
+	return build.Status(), nil
+}
 
-	if running {
-		return core.BuildStatusRunning, nil
-	}
-	if failing {
-		return core.BuildStatusFailing, nil
-	}
-	return core.BuildStatusPassing, nil
+func (s buildStore) FindPrevious(build *core.Build) (*core.Build, error) {
+	prev := &core.Build{}
+	err := s.db.Preload("Jobs").
+		Where("repository_id = ? AND branch = ? AND pr = ? AND id < ? AND end_time IS NOT NULL", build.RepositoryID, build.Branch, build.PR, build.ID).
+		Last(&prev).Error
+	return prev, err
+}
+
+func (s buildStore) FindLatest(repoID uint, branch string) (*core.Build, error) {
+	build := &core.Build{}
+	err := s.db.Preload("Jobs").
+		Where("repository_id = ? AND branch = ? AND pr = ? AND end_time IS NOT NULL", repoID, branch, 0).
+		Last(&build).Error
+	return build, err
+}
+
+func (s buildStore) FindUnfinished(build *core.Build) ([]*core.Build, error) {
+	var builds []*core.Build
+	err := s.db.
+		Where("repository_id = ? AND ref = ? AND id < ? AND end_time IS NULL", build.RepositoryID, build.Ref, build.ID).
+		Find(&builds).Error
+	return builds, err
 }
 
-func (s buildStore) List(filters core.BuildFilter) ([]*core.Build, error) {
+func (s buildStore) List(ctx context.Context, filters core.BuildFilter) ([]*core.Build, error) {
 	var builds []*core.Build
-	db := s.db
+	db := store.WithContext(ctx, store.Read(s.db))
 
 	db = db.Preload("Jobs").Preload("Repository")
 	db = db.Joins("LEFT JOIN repositories ON repositories.id = builds.repository_id").
@@ -84,23 +101,45 @@ func (s buildStore) List(filters core.BuildFilter) ([]*core.Build, error) {
 		Joins("LEFT JOIN teams ON teams.id = permissions.team_id").
 		Joins("LEFT JOIN team_users ON team_users.team_id = teams.id")
 
-	if filters.RepositoryID > 0 || filters.Kind != "latest" {
-		if filters.RepositoryID > 0 {
-			db = db.Where("builds.repository_id = ?", uint(filters.RepositoryID))
-		}
+	if filters.RepositoryID > 0 {
+		db = db.Where("builds.repository_id = ?", uint(filters.RepositoryID))
+	}
+	if filters.Kind == "pull-requests" {
+		db = db.Where("builds.pr != ?", 0)
+	} else if filters.Kind == "commits" || filters.Kind == "branches" {
+		db = db.Where("builds.pr = ?", 0)
+	}
 
-		if filters.Kind == "pull-requests" {
-			db = db.Where("builds.pr != ?", 0)
-		} else if filters.Kind == "commits" || filters.Kind == "branches" {
-			db = db.Where("builds.pr = ?", 0)
-		}
+	db = db.Where("(repositories.user_id = ? OR (team_users.user_id = ? AND permissions.read = ?))", filters.UserID, filters.UserID, true)
 
-		db = db.Where(db.Where("repositories.user_id = ?", filters.UserID).Or("team_users.user_id = ? AND permissions.read = ?", filters.UserID, true))
-	} else {
-		db = db.Where("repositories.user_id = ?", filters.UserID).Or("team_users.user_id = ? AND permissions.read = ?", filters.UserID, true)
+	if filters.Branch != "" {
+		db = db.Where("builds.branch = ?", filters.Branch)
+	}
+	if !filters.From.IsZero() {
+		db = db.Where("builds.created_at >= ?", filters.From)
+	}
+	if !filters.To.IsZero() {
+		db = db.Where("builds.created_at <= ?", filters.To)
+	}
+
+	switch filters.Status {
+	case core.BuildStatusRunning:
+		db = db.Where("EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id AND jobs.status = ?)", core.BuildStatusRunning)
+	case core.BuildStatusFailing:
+		db = db.Where("EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id AND jobs.status = ?)", core.BuildStatusFailing).
+			Where("NOT EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id AND jobs.status = ?)", core.BuildStatusRunning)
+	case core.BuildStatusPassing:
+		db = db.Where("EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id)").
+			Where("NOT EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id AND jobs.status IN (?))", []string{core.BuildStatusRunning, core.BuildStatusFailing})
 	}
 
-	err := db.Order("builds.created_at desc").Group("builds.id").Limit(filters.Limit).Offset(filters.Offset).Find(&builds).Error
+	if filters.Cursor > 0 {
+		db = db.Where("builds.id < ?", filters.Cursor)
+	} else if filters.Offset > 0 {
+		db = db.Offset(filters.Offset)
+	}
+
+	err := db.Order("builds.id desc").Group("builds.id").Limit(filters.Limit).Find(&builds).Error
 
 	for i, build := range builds {
 		builds[i].Repository.Perms = s.repos.GetPermissions(build.RepositoryID, filters.UserID)
@@ -114,13 +153,108 @@ func (s buildStore) Create(build *core.Build) error {
 }
 
 func (s buildStore) Update(build *core.Build) error {
-	return s.db.Model(build).Updates(map[string]interface{}{"start_time": build.StartTime, "end_time": build.EndTime}).Error
+	return s.db.Model(build).Updates(map[string]interface{}{
+		"queued_at":  build.QueuedAt,
+		"start_time": build.StartTime,
+		"end_time":   build.EndTime,
+	}).Error
 }
 
 func (s buildStore) Delete(build *core.Build) error {
 	return s.db.Delete(build).Error
 }
 
+func (s buildStore) Usage(ctx context.Context) ([]*core.StorageUsage, error) {
+	length := "OCTET_LENGTH"
+	if s.db.Dialect().GetName() == "mssql" {
+		length = "DATALENGTH"
+	}
+
+	rows, err := store.WithContext(ctx, store.Read(s.db)).Table("builds").
+		Select(fmt.Sprintf("builds.repository_id, repositories.full_name, COUNT(DISTINCT builds.id), COUNT(jobs.id), "+
+			"COALESCE(SUM(%[1]s(jobs.log)), 0), COALESCE(SUM(%[1]s(jobs.test_results)), 0), MIN(builds.created_at)", length)).
+		Joins("JOIN repositories ON repositories.id = builds.repository_id").
+		Joins("LEFT JOIN jobs ON jobs.build_id = builds.id AND jobs.deleted_at IS NULL").
+		Where("builds.deleted_at IS NULL").
+		Group("builds.repository_id, repositories.full_name").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []*core.StorageUsage
+	for rows.Next() {
+		var u core.StorageUsage
+		if err := rows.Scan(&u.RepositoryID, &u.FullName, &u.Builds, &u.Jobs, &u.LogBytes, &u.TestReportBytes, &u.OldestBuild); err != nil {
+			return nil, err
+		}
+		usage = append(usage, &u)
+	}
+	return usage, rows.Err()
+}
+
+func (s buildStore) Prune(repo *core.Repository, now time.Time, limit int) (int, error) {
+	policy := repo.Retention
+	if !policy.Enabled() {
+		return 0, nil
+	}
+
+	tx := s.db.Begin()
+	// builds and jobs are deleted permanently, including soft deleted ones.
+	db := tx.Unscoped().Model(&core.Build{}).Where("repository_id = ? AND end_time IS NOT NULL", repo.ID)
+	if policy.Builds > 0 {
+		var keep []uint
+		err := tx.Model(&core.Build{}).Where("repository_id = ?", repo.ID).
+			Order("id desc").Limit(policy.Builds).Pluck("id", &keep).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if len(keep) > 0 {
+			db = db.Where("id NOT IN (?)", keep)
+		}
+	}
+	if policy.Days > 0 {
+		db = db.Where("created_at < ?", now.AddDate(0, 0, -policy.Days))
+	}
+	if policy.KeepLastGreen {
+		var green []uint
+		err := tx.Model(&core.Build{}).
+			Where("repository_id = ? AND branch = ? AND pr = ? AND end_time IS NOT NULL", repo.ID, repo.DefaultBranch, 0).
+			Where("EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id)").
+			Where("NOT EXISTS (SELECT 1 FROM jobs WHERE jobs.build_id = builds.id AND jobs.status <> ?)", core.BuildStatusPassing).
+			Order("id desc").Limit(1).Pluck("id", &green).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if len(green) > 0 {
+			db = db.Where("id <> ?", green[0])
+		}
+	}
+
+	var ids []uint
+	if err := db.Order("id").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if len(ids) == 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	if err := tx.Unscoped().Where("build_id IN (?)", ids).Delete(&core.Job{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Unscoped().Where("id IN (?)", ids).Delete(&core.Build{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return len(ids), tx.Commit().Error
+}
+
 func (s buildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]*core.Job, uint, error) {
 	scm, err := gitscm.New(context.Background(), repo.Provider.Name, repo.Provider.URL, repo.Provider.AccessToken)
 	if err != nil {
@@ -168,10 +302,13 @@ func (s buildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]
 		CommitterEmail:  base.SenderEmail,
 		CommitterAvatar: base.SenderAvatar,
 		RepositoryID:    repo.ID,
+		QueuedAt:        lib.TimeNow(),
 		StartTime:       lib.TimeNow(),
 	}
 
 	parser := parser.NewConfigParser(string(content.Data), base.Target, parser.GenerateGlobalEnv(build))
+	parser.Ref, parser.PR = build.Ref, build.PR != 0
+	parser.Images = s.images
 	pjobs, err := parser.Parse()
 	if err != nil {
 		return nil, 0, err
@@ -180,6 +317,8 @@ func (s buildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]
 	if !parser.ShouldBuild() {
 		return nil, 0, fmt.Errorf("branch %s is ignored or not marked to build in config", base.Target)
 	}
+	build.Worker = parser.Parsed.Worker
+	build.Concurrency = parser.Parsed.Concurrency
 
 	if err := s.Create(build); err != nil {
 		return nil, 0, err
@@ -193,11 +332,17 @@ func (s buildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]
 		}
 
 		job := &core.Job{
-			Image:    j.Image,
-			Commands: string(commands),
-			Env:      j.Title,
-			Stage:    j.Stage,
-			BuildID:  build.ID,
+			Image:     j.Image,
+			Commands:  string(commands),
+			Env:       j.Title,
+			Stage:     j.Stage,
+			Needs:     strings.Join(j.Needs, ","),
+			CPUs:      j.CPUs,
+			Memory:    j.Memory,
+			PidsLimit: j.PidsLimit,
+			Weight:    j.Weight,
+			Arch:      j.Arch,
+			BuildID:   build.ID,
 		}
 		if err := s.jobs.Create(job); err != nil {
 			return nil, 0, err
@@ -213,14 +358,14 @@ func (s buildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]
 	return jobs, build.ID, nil
 }
 
-func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, error) {
+func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, uint, error) {
 	repo, err := s.repos.Find(opts.ID, opts.UserID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	scm, err := gitscm.New(context.Background(), repo.Provider.Name, repo.Provider.URL, repo.Provider.AccessToken)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	build := &core.Build{}
@@ -231,13 +376,13 @@ func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, error
 
 	if branch == "" {
 		branch = repo.DefaultBranch
-		build.Branch = branch
 	}
+	build.Branch = branch
 
 	ref, err := scm.FindBranch(repo.FullName, branch)
 	if err != nil {
 		fmt.Println("branch", repo.DefaultBranch)
-		return nil, err
+		return nil, 0, err
 	}
 	build.Ref = ref.Path
 
@@ -245,7 +390,7 @@ func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, error
 		commit, err := scm.LastCommit(repo.FullName, branch)
 		if err != nil {
 			fmt.Println("last commit")
-			return nil, err
+			return nil, 0, err
 		}
 		sha = commit.Sha
 
@@ -263,7 +408,7 @@ func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, error
 		commit, err := scm.FindCommit(repo.FullName, sha)
 		if err != nil {
 			fmt.Println("find commit")
-			return nil, err
+			return nil, 0, err
 		}
 
 		build.Commit = commit.Sha
@@ -281,54 +426,154 @@ func (s buildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, error
 	if content == "" {
 		raw, err := scm.FindContent(repo.FullName, sha, ".abstruse.yml")
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		content = string(raw.Data)
 	}
 
 	build.Config = content
 
+	if len(opts.Env) > 0 {
+		env, err := json.Marshal(opts.Env)
+		if err != nil {
+			return nil, 0, err
+		}
+		build.Env = string(env)
+	}
+
 	parser := parser.NewConfigParser(content, branch, parser.GenerateGlobalEnv(build))
+	parser.Ref, parser.PR = build.Ref, build.PR != 0
+	parser.Images = s.images
 	pjobs, err := parser.Parse()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	if !parser.ShouldBuild() {
-		return nil, fmt.Errorf("branch %s is ignored or not marked to build in config", branch)
+		return nil, 0, fmt.Errorf("branch %s is ignored or not marked to build in config", branch)
 	}
 
+	build.Worker = parser.Parsed.Worker
+	build.Concurrency = parser.Parsed.Concurrency
+	if opts.Worker != "" {
+		build.Worker = opts.Worker
+	}
 	build.RepositoryID = repo.ID
+	build.QueuedAt = lib.TimeNow()
 	build.StartTime = lib.TimeNow()
 
 	if err := s.Create(build); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	var jobs []*core.Job
 	for _, j := range pjobs {
 		commands, err := json.Marshal(j.Commands)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		job := &core.Job{
-			Image:    j.Image,
-			Commands: string(commands),
-			Env:      j.Title,
-			Stage:    j.Stage,
-			BuildID:  build.ID,
+			Image:     j.Image,
+			Commands:  string(commands),
+			Env:       j.Title,
+			Stage:     j.Stage,
+			Needs:     strings.Join(j.Needs, ","),
+			CPUs:      j.CPUs,
+			Memory:    j.Memory,
+			PidsLimit: j.PidsLimit,
+			Weight:    j.Weight,
+			Arch:      j.Arch,
+			BuildID:   build.ID,
 		}
 		if err := s.jobs.Create(job); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		job, err = s.jobs.Find(job.ID)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, build.ID, nil
+}
+
+func (s buildStore) Rebuild(id uint) ([]*core.Job, uint, error) {
+	orig, err := s.Find(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	build := &core.Build{
+		Branch:          orig.Branch,
+		Ref:             orig.Ref,
+		Commit:          orig.Commit,
+		CommitMessage:   orig.CommitMessage,
+		PR:              orig.PR,
+		PRTitle:         orig.PRTitle,
+		PRBody:          orig.PRBody,
+		Config:          orig.Config,
+		Env:             orig.Env,
+		AuthorLogin:     orig.AuthorLogin,
+		AuthorName:      orig.AuthorName,
+		AuthorEmail:     orig.AuthorEmail,
+		AuthorAvatar:    orig.AuthorAvatar,
+		CommitterLogin:  orig.CommitterLogin,
+		CommitterName:   orig.CommitterName,
+		CommitterEmail:  orig.CommitterEmail,
+		CommitterAvatar: orig.CommitterAvatar,
+		RepositoryID:    orig.RepositoryID,
+		ParentID:        orig.ID,
+		Worker:          orig.Worker,
+		Concurrency:     orig.Concurrency,
+		QueuedAt:        lib.TimeNow(),
+		StartTime:       lib.TimeNow(),
+	}
+
+	parser := parser.NewConfigParser(build.Config, build.Branch, parser.GenerateGlobalEnv(build))
+	parser.Ref, parser.PR = build.Ref, build.PR != 0
+	parser.Images = s.images
+	pjobs, err := parser.Parse()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.Create(build); err != nil {
+		return nil, 0, err
+	}
+
+	var jobs []*core.Job
+	for _, j := range pjobs {
+		commands, err := json.Marshal(j.Commands)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		job := &core.Job{
+			Image:     j.Image,
+			Commands:  string(commands),
+			Env:       j.Title,
+			Stage:     j.Stage,
+			Needs:     strings.Join(j.Needs, ","),
+			CPUs:      j.CPUs,
+			Memory:    j.Memory,
+			PidsLimit: j.PidsLimit,
+			Weight:    j.Weight,
+			Arch:      j.Arch,
+			BuildID:   build.ID,
+		}
+		if err := s.jobs.Create(job); err != nil {
+			return nil, 0, err
+		}
+		job, err = s.jobs.Find(job.ID)
+		if err != nil {
+			return nil, 0, err
 		}
 
 		jobs = append(jobs, job)
 	}
 
-	return jobs, nil
+	return jobs, build.ID, nil
 }
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WithContext returns database handle which runs queries with context,
+// queries in flight are cancelled by the driver when context is done, like
+// when HTTP client disconnects or request times out. Handle shares
+// connection pool with db and should be used for single operation only.
+func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return db
+	}
+	conn, err := gorm.Open(db.Dialect().GetName(), &contextDB{ctx: ctx, db: sqlDB})
+	if err != nil {
+		return db
+	}
+	return conn
+}
+
+// contextDB implements gorm.SQLCommon running statements with context.
+type contextDB struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func (c *contextDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c *contextDB) Prepare(query string) (*sql.Stmt, error) {
+	return c.db.PrepareContext(c.ctx, query)
+}
+
+func (c *contextDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c *contextDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c *contextDB) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, nil)
+}
+
+func (c *contextDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return c.db.BeginTx(ctx, opts)
+}
@@ -0,0 +1,61 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// New returns a new CronStore.
+func New(db *gorm.DB) core.CronStore {
+	return cronStore{db}
+}
+
+type cronStore struct {
+	db *gorm.DB
+}
+
+func (s cronStore) Find(id uint) (*core.Cron, error) {
+	var cron core.Cron
+	err := s.db.Where("id = ?", id).First(&cron).Error
+	return &cron, err
+}
+
+func (s cronStore) List(id uint) ([]*core.Cron, error) {
+	var crons []*core.Cron
+	err := s.db.Where("repository_id = ?", id).Order("id asc").Find(&crons).Error
+	return crons, err
+}
+
+func (s cronStore) ListDue(t time.Time) ([]*core.Cron, error) {
+	var crons []*core.Cron
+	err := s.db.Where("next_run <= ?", t).Preload("Repository").Find(&crons).Error
+	return crons, err
+}
+
+func (s cronStore) Create(cron *core.Cron) error {
+	return s.db.Create(cron).Error
+}
+
+func (s cronStore) Claim(cron *core.Cron, next time.Time) (bool, error) {
+	if cron.NextRun == nil {
+		return false, nil
+	}
+	now := lib.TimeNow()
+	db := s.db.Model(&core.Cron{}).Where("id = ? AND next_run = ?", cron.ID, *cron.NextRun).
+		Updates(map[string]interface{}{"next_run": next, "last_run": now})
+	if db.Error != nil {
+		return false, db.Error
+	}
+	if db.RowsAffected == 0 {
+		return false, nil
+	}
+	cron.LastRun, cron.NextRun = now, &next
+	return true, nil
+}
+
+func (s cronStore) Delete(cron *core.Cron) error {
+	return s.db.Delete(cron).Error
+}
@@ -0,0 +1,78 @@
+package delivery
+
+import (
+	"fmt"
+
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// keep is number of recent deliveries kept per repository, deliveries
+// which matched no repository are kept together.
+const keep = 50
+
+// defaultLimit is number of deliveries listed when limit is not set.
+const defaultLimit = 20
+
+// New returns a new HookDeliveryStore.
+func New(db *gorm.DB) core.HookDeliveryStore {
+	return deliveryStore{db}
+}
+
+type deliveryStore struct {
+	db *gorm.DB
+}
+
+func (s deliveryStore) Find(id uint) (*core.HookDelivery, error) {
+	var delivery core.HookDelivery
+	if s.db.Where("id = ?", id).First(&delivery).RecordNotFound() {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	return &delivery, nil
+}
+
+func (s deliveryStore) List(filter core.HookDeliveryFilter) ([]*core.HookDelivery, error) {
+	var deliveries []*core.HookDelivery
+	db := s.db.Select("id, repository_id, event, verified, result, reason, build_id, replay_of, created_at")
+	if filter.RepositoryID != 0 {
+		db = db.Where("repository_id = ?", filter.RepositoryID)
+	}
+	if filter.Result != "" {
+		db = db.Where("result = ?", filter.Result)
+	}
+	if filter.Cursor != 0 {
+		db = db.Where("id < ?", filter.Cursor)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	err := db.Order("id desc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (s deliveryStore) Update(delivery *core.HookDelivery) error {
+	if delivery.ID == 0 {
+		return fmt.Errorf("delivery not saved")
+	}
+	return s.db.Model(delivery).Updates(map[string]interface{}{
+		"result":   delivery.Result,
+		"reason":   delivery.Reason,
+		"build_id": delivery.BuildID,
+	}).Error
+}
+
+func (s deliveryStore) Create(delivery *core.HookDelivery) error {
+	if err := s.db.Create(delivery).Error; err != nil {
+		return err
+	}
+
+	var old []uint
+	err := s.db.Model(&core.HookDelivery{}).
+		Where("repository_id = ?", delivery.RepositoryID).
+		Order("id desc").Offset(keep).Pluck("id", &old).Error
+	if err != nil || len(old) == 0 {
+		return err
+	}
+	return s.db.Where("id IN (?)", old).Delete(core.HookDelivery{}).Error
+}
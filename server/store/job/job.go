@@ -1,9 +1,12 @@
 package job
 
 import (
+	"context"
+	"strings"
 	"time"
 
 	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/store"
 	"github.com/jinzhu/gorm"
 )
 
@@ -45,6 +48,47 @@ func (s jobStore) List(from, to time.Time) ([]*core.Job, error) {
 	return jobs, err
 }
 
+func (s jobStore) Search(ctx context.Context, filter core.LogSearchFilter) ([]*core.LogMatch, error) {
+	var matches []*core.LogMatch
+	db := store.WithContext(ctx, store.Read(s.db)).Table("jobs").
+		Select("jobs.id, jobs.build_id, builds.repository_id, builds.branch, jobs.log").
+		Joins("JOIN builds ON builds.id = jobs.build_id").
+		Joins("JOIN repositories ON repositories.id = builds.repository_id").
+		Where("jobs.log LIKE ? ESCAPE '!'", "%"+escapeLike(filter.Query)+"%").
+		Where("(repositories.user_id = ? OR EXISTS (SELECT 1 FROM permissions JOIN team_users ON team_users.team_id = permissions.team_id WHERE permissions.repository_id = repositories.id AND permissions.read = ? AND team_users.user_id = ?))", filter.UserID, true, filter.UserID)
+
+	if filter.RepositoryID > 0 {
+		db = db.Where("builds.repository_id = ?", filter.RepositoryID)
+	}
+	if !filter.From.IsZero() {
+		db = db.Where("jobs.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		db = db.Where("jobs.created_at <= ?", filter.To)
+	}
+	if filter.Cursor > 0 {
+		db = db.Where("jobs.id < ?", filter.Cursor)
+	}
+
+	rows, err := db.Order("jobs.id desc").Limit(filter.Limit).Rows()
+	if err != nil {
+		return matches, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var match core.LogMatch
+		var log string
+		if err := rows.Scan(&match.JobID, &match.BuildID, &match.RepositoryID, &match.Branch, &log); err != nil {
+			return matches, err
+		}
+		match.Snippets = snippets(log, filter.Query)
+		matches = append(matches, &match)
+	}
+
+	return matches, rows.Err()
+}
+
 func (s jobStore) Create(job *core.Job) error {
 	return s.db.Create(job).Error
 }
@@ -57,13 +101,56 @@ func (s jobStore) Update(job *core.Job) error {
 	}
 
 	return s.db.Model(job).Updates(map[string]interface{}{
-		"status":     job.Status,
-		"start_time": job.StartTime,
-		"end_time":   job.EndTime,
-		"log":        job.Log,
+		"status":        job.Status,
+		"queued_at":     job.QueuedAt,
+		"start_time":    job.StartTime,
+		"end_time":      job.EndTime,
+		"log":           job.Log,
+		"reason":        job.Reason,
+		"image_digest":  job.ImageDigest,
+		"log_truncated": job.LogTruncated,
+		"worker_id":     job.WorkerID,
+		"environment":   job.Environment,
+		"step_timings":  job.StepTimings,
+		"test_results":  job.TestResults,
+		"usage_stats":   job.UsageStats,
+		"notes":         job.Notes,
 	}).Error
 }
 
 func (s jobStore) Delete(job *core.Job) error {
 	return s.db.Delete(job).Error
 }
+
+// maxSnippets is the maximum number of matching lines returned per job.
+const maxSnippets = 3
+
+// snippetContext is the number of characters shown around the match.
+const snippetContext = 80
+
+// snippets returns lines from log that contain query.
+func snippets(log, query string) []string {
+	var result []string
+	for _, line := range strings.Split(log, "\n") {
+		i := strings.Index(line, query)
+		if i == -1 {
+			continue
+		}
+		start, end := i-snippetContext, i+len(query)+snippetContext
+		if start < 0 {
+			start = 0
+		}
+		if end > len(line) {
+			end = len(line)
+		}
+		result = append(result, strings.TrimRight(line[start:end], "\r"))
+		if len(result) == maxSnippets {
+			break
+		}
+	}
+	return result
+}
+
+func escapeLike(str string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(str)
+}
@@ -0,0 +1,92 @@
+package login
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// New returns a new LoginAttemptStore.
+func New(db *gorm.DB) core.LoginAttemptStore {
+	return loginAttemptStore{db}
+}
+
+type loginAttemptStore struct {
+	db *gorm.DB
+}
+
+func (s loginAttemptStore) Find(email string) (*core.LoginAttempt, error) {
+	var attempt core.LoginAttempt
+	err := s.db.Where("email = ?", normalize(email)).First(&attempt).Error
+	return &attempt, err
+}
+
+func (s loginAttemptStore) Fail(email string, max int, window, duration time.Duration) (*core.LoginAttempt, error) {
+	var attempt core.LoginAttempt
+	email, now := normalize(email), time.Now()
+
+	tx := s.db.Begin()
+	query, args := insertAttempt(tx.Dialect().GetName(), email, now)
+	if err := tx.Exec(query, args...).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Set("gorm:query_option", lockOption(tx.Dialect().GetName())).Where("email = ?", email).First(&attempt).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if now.Sub(attempt.LastFailure) > window {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	if max > 0 && attempt.Failures >= max {
+		lockedUntil := now.Add(duration)
+		attempt.LockedUntil = &lockedUntil
+		attempt.Failures = 0
+	}
+
+	if err := tx.Save(&attempt).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &attempt, tx.Commit().Error
+}
+
+func (s loginAttemptStore) Reset(email string) error {
+	return s.db.Where("email = ?", normalize(email)).Delete(core.LoginAttempt{}).Error
+}
+
+// insertAttempt returns query inserting login attempt without failures
+// unless one exists, so concurrent first failures of the same email do
+// not violate unique index.
+func insertAttempt(dialect, email string, now time.Time) (string, []interface{}) {
+	switch dialect {
+	case "postgres":
+		return "INSERT INTO login_attempts (email, failures, last_failure) VALUES (?, 0, ?) ON CONFLICT (email) DO NOTHING",
+			[]interface{}{email, now}
+	case "mssql":
+		return "INSERT INTO login_attempts (email, failures, last_failure) SELECT ?, 0, ? WHERE NOT EXISTS " +
+				"(SELECT 1 FROM login_attempts WITH (UPDLOCK, HOLDLOCK) WHERE email = ?)",
+			[]interface{}{email, now, email}
+	default:
+		return "INSERT INTO login_attempts (email, failures, last_failure) VALUES (?, 0, ?) ON DUPLICATE KEY UPDATE email = email",
+			[]interface{}{email, now}
+	}
+}
+
+// lockOption returns query option locking selected login attempt until
+// transaction ends, mssql row is locked by insertAttempt.
+func lockOption(dialect string) string {
+	if dialect == "mssql" {
+		return ""
+	}
+	return "FOR UPDATE"
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// initial creates schema of all models. Databases created before
+// versioned migrations are brought up to date by this migration.
+var initial = Migration{
+	Version: 1,
+	Name:    "initial",
+	Up: func(db *gorm.DB) error {
+		err := db.AutoMigrate(
+			core.User{},
+			core.Team{},
+			core.Permission{},
+			core.Repository{},
+			core.EnvVariable{},
+			core.Provider{},
+			core.Job{},
+			core.Build{},
+			core.APIKey{},
+			core.Cron{},
+		).Error
+		if err != nil {
+			return err
+		}
+		if err := db.Model(&core.Build{}).AddIndex("idx_builds_repository_branch", "repository_id", "branch").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&core.Build{}).AddIndex("idx_builds_created_at", "created_at").Error; err != nil {
+			return err
+		}
+		return db.Model(&core.Job{}).AddIndex("idx_jobs_build_status", "build_id", "status").Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.DropTableIfExists(
+			core.Cron{},
+			core.APIKey{},
+			core.Job{},
+			core.Build{},
+			core.Provider{},
+			core.EnvVariable{},
+			core.Repository{},
+			core.Permission{},
+			core.Team{},
+			core.User{},
+			"team_users",
+		).Error
+	},
+}
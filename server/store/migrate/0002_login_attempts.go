@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// loginAttempts creates table tracking failed login attempts.
+var loginAttempts = Migration{
+	Version: 2,
+	Name:    "login_attempts",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.LoginAttempt{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.DropTableIfExists(core.LoginAttempt{}).Error
+	},
+}
@@ -0,0 +1,24 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// timings adds queued time to builds and jobs and step timings to jobs.
+var timings = Migration{
+	Version: 3,
+	Name:    "timings",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Build{}, core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		if err := db.Model(&core.Job{}).DropColumn("step_timings").Error; err != nil {
+			return err
+		}
+		if err := db.Model(&core.Job{}).DropColumn("queued_at").Error; err != nil {
+			return err
+		}
+		return db.Model(&core.Build{}).DropColumn("queued_at").Error
+	},
+}
@@ -0,0 +1,21 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// autoCancel adds auto cancel settings to repositories.
+var autoCancel = Migration{
+	Version: 4,
+	Name:    "auto_cancel",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		if err := db.Model(&core.Repository{}).DropColumn("autocancel_releases").Error; err != nil {
+			return err
+		}
+		return db.Model(&core.Repository{}).DropColumn("autocancel_enabled").Error
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// publicBadge adds public badge flag to repositories.
+var publicBadge = Migration{
+	Version: 5,
+	Name:    "public_badge",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Repository{}).DropColumn("public_badge").Error
+	},
+}
@@ -0,0 +1,23 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// retention adds build retention policy to repositories.
+var retention = Migration{
+	Version: 6,
+	Name:    "retention",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		for _, column := range []string{"retention_builds", "retention_days", "retention_keep_last_green"} {
+			if err := db.Model(&core.Repository{}).DropColumn(column).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
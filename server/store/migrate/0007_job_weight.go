@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// jobWeight adds worker capacity weight to jobs.
+var jobWeight = Migration{
+	Version: 7,
+	Name:    "job_weight",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Job{}).DropColumn("weight").Error
+	},
+}
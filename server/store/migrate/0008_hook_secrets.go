@@ -0,0 +1,21 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// hookSecrets adds webhook secrets of repositories.
+var hookSecrets = Migration{
+	Version: 8,
+	Name:    "hook_secrets",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		if err := db.Model(&core.Repository{}).DropColumn("hook_secret").Error; err != nil {
+			return err
+		}
+		return db.Model(&core.Repository{}).DropColumn("hook_secret_alt").Error
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// logTruncated adds flag of jobs whose log exceeded maximum size.
+var logTruncated = Migration{
+	Version: 9,
+	Name:    "log_truncated",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Job{}).DropColumn("log_truncated").Error
+	},
+}
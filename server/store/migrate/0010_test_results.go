@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// testResults adds results parsed from test reports to jobs.
+var testResults = Migration{
+	Version: 10,
+	Name:    "test_results",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Job{}).DropColumn("test_results").Error
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// buildWorker adds worker builds can be pinned to.
+var buildWorker = Migration{
+	Version: 11,
+	Name:    "build_worker",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Build{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Build{}).DropColumn("worker").Error
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// auditEvents creates table of audit events.
+var auditEvents = Migration{
+	Version: 12,
+	Name:    "audit_events",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.AuditEvent{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.DropTableIfExists(core.AuditEvent{}).Error
+	},
+}
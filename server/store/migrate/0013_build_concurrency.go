@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// buildConcurrency adds concurrency group of builds.
+var buildConcurrency = Migration{
+	Version: 13,
+	Name:    "build_concurrency",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Build{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Build{}).DropColumn("concurrency").Error
+	},
+}
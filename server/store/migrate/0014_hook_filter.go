@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// hookFilter adds webhook filters to repositories and table of webhooks
+// which did not trigger build.
+var hookFilter = Migration{
+	Version: 14,
+	Name:    "hook_filter",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}, core.SkippedBuild{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		if err := db.DropTableIfExists(core.SkippedBuild{}).Error; err != nil {
+			return err
+		}
+		for _, column := range []string{"filter_branches", "filter_ignore_branches", "filter_paths", "filter_ignore_paths"} {
+			if err := db.Model(&core.Repository{}).DropColumn(column).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// jobArch adds architecture of workers jobs run on.
+var jobArch = Migration{
+	Version: 15,
+	Name:    "job_arch",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Job{}).DropColumn("arch").Error
+	},
+}
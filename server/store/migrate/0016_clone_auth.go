@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// cloneAuth adds clone credentials of repositories.
+var cloneAuth = Migration{
+	Version: 16,
+	Name:    "clone_auth",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Repository{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Repository{}).DropColumn("clone_auth").Error
+	},
+}
@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// jobUsage adds resource usage of jobs.
+var jobUsage = Migration{
+	Version: 17,
+	Name:    "job_usage",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.Job{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.Model(&core.Job{}).DropColumn("usage_stats").Error
+	},
+}
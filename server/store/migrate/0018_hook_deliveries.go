@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// hookDeliveries creates table of webhook deliveries.
+var hookDeliveries = Migration{
+	Version: 18,
+	Name:    "hook_deliveries",
+	Up: func(db *gorm.DB) error {
+		return db.AutoMigrate(core.HookDelivery{}).Error
+	},
+	Down: func(db *gorm.DB) error {
+		return db.DropTableIfExists(core.HookDelivery{}).Error
+	},
+}
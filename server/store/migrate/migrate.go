@@ -0,0 +1,182 @@
+// Package migrate implements versioned database schema migrations.
+//
+// Every schema change is a new Migration registered in migrations list
+// with next version number, defined in its own file named after version.
+// Migrations are applied in order and recorded in schema_migrations table.
+package migrate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration defines schema change with its rollback.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(*gorm.DB) error
+	Down    func(*gorm.DB) error
+}
+
+// Status represents migration with time it was applied at,
+// AppliedAt is nil for pending migrations.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration is record of applied migration.
+type schemaMigration struct {
+	Version   int `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations lists all migrations ordered by version.
+var migrations = []Migration{
+	initial,
+	loginAttempts,
+	timings,
+	autoCancel,
+	publicBadge,
+	retention,
+	jobWeight,
+	hookSecrets,
+	logTruncated,
+	testResults,
+	buildWorker,
+	auditEvents,
+	buildConcurrency,
+	hookFilter,
+	jobArch,
+	cloneAuth,
+	jobUsage,
+	hookDeliveries,
+	jobAnnotations,
+}
+
+// Latest returns schema version expected by this binary.
+func Latest() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Version returns current schema version of the database.
+func Version(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(schemaMigration{}).Error; err != nil {
+		return 0, err
+	}
+	var m schemaMigration
+	err := db.Order("version desc").First(&m).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, nil
+	}
+	return m.Version, err
+}
+
+// Check returns error when database schema is newer than this binary
+// expects, running older binary could corrupt data. Returns number of
+// pending migrations otherwise.
+func Check(db *gorm.DB) (int, error) {
+	version, err := Version(db)
+	if err != nil {
+		return 0, err
+	}
+	if version > Latest() {
+		return 0, fmt.Errorf("database schema version %d is newer than supported version %d, upgrade abstruse", version, Latest())
+	}
+	var pending int
+	for _, m := range migrations {
+		if m.Version > version {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations and returns applied ones.
+func Up(db *gorm.DB) ([]Migration, error) {
+	if _, err := Check(db); err != nil {
+		return nil, err
+	}
+	version, err := Version(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Up(db); err != nil {
+			return applied, fmt.Errorf("migration %d %s failed: %v", m.Version, m.Name, err)
+		}
+		record := schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		if err := db.Create(&record).Error; err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// Down rolls back last steps applied migrations and returns rolled back ones.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if _, err := Check(db); err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		m := migrations[i]
+		var record schemaMigration
+		if err := db.Where("version = ?", m.Version).First(&record).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				continue
+			}
+			return rolledBack, err
+		}
+		if err := m.Down(db); err != nil {
+			return rolledBack, fmt.Errorf("rollback of migration %d %s failed: %v", m.Version, m.Name, err)
+		}
+		if err := db.Delete(&record).Error; err != nil {
+			return rolledBack, err
+		}
+		rolledBack = append(rolledBack, m)
+	}
+	return rolledBack, nil
+}
+
+// List returns all migrations with their status.
+func List(db *gorm.DB) ([]Status, error) {
+	if _, err := Version(db); err != nil {
+		return nil, err
+	}
+	var records []schemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	for _, r := range records {
+		applied[r.Version] = r.AppliedAt
+	}
+
+	var list []Status
+	for _, m := range migrations {
+		s := Status{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			s.AppliedAt = &t
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
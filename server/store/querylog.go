@@ -0,0 +1,83 @@
+package store
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+const queryStartKey = "abstruse:query_start"
+
+var (
+	literalRe    = regexp.MustCompile(`'(?:[^']|'')*'`)
+	queryLogOnce sync.Once
+)
+
+// queryLog logs SQL statements executed by gorm. Query parameters are
+// never logged and string literals in statements are redacted, so
+// passwords and tokens do not end up in logs.
+type queryLog struct {
+	log       *zap.SugaredLogger
+	all       bool          // log every query at debug level
+	threshold time.Duration // warn about queries taking longer, 0 disables
+}
+
+// logQueries registers gorm callbacks logging executed queries when
+// query logging or slow query warnings are enabled. Callbacks are
+// registered on default callbacks shared by all connections, so queries
+// of connections opened with context are logged too.
+func logQueries(cfg *config.DB, logger *zap.Logger) {
+	if !cfg.LogQueries && cfg.SlowQueryThreshold <= 0 {
+		return
+	}
+	q := &queryLog{
+		log:       logger.With(zap.String("type", "db")).Sugar(),
+		all:       cfg.LogQueries,
+		threshold: cfg.SlowQueryThreshold,
+	}
+	queryLogOnce.Do(func() {
+		q.register(gorm.DefaultCallback)
+	})
+}
+
+func (q *queryLog) register(cb *gorm.Callback) {
+	cb.Create().Before("gorm:create").Register("abstruse:create_start", q.start)
+	cb.Create().After("gorm:create").Register("abstruse:create_log", q.end)
+	cb.Update().Before("gorm:update").Register("abstruse:update_start", q.start)
+	cb.Update().After("gorm:update").Register("abstruse:update_log", q.end)
+	cb.Delete().Before("gorm:delete").Register("abstruse:delete_start", q.start)
+	cb.Delete().After("gorm:delete").Register("abstruse:delete_log", q.end)
+	cb.Query().Before("gorm:query").Register("abstruse:query_start", q.start)
+	cb.Query().After("gorm:query").Register("abstruse:query_log", q.end)
+	cb.RowQuery().Before("gorm:row_query").Register("abstruse:row_query_start", q.start)
+	cb.RowQuery().After("gorm:row_query").Register("abstruse:row_query_log", q.end)
+}
+
+func (q *queryLog) start(scope *gorm.Scope) {
+	scope.InstanceSet(queryStartKey, time.Now())
+}
+
+func (q *queryLog) end(scope *gorm.Scope) {
+	v, ok := scope.InstanceGet(queryStartKey)
+	if !ok || scope.SQL == "" {
+		return
+	}
+	start, ok := v.(time.Time)
+	if !ok {
+		return
+	}
+
+	elapsed := time.Since(start)
+	query := literalRe.ReplaceAllString(scope.SQL, "'?'")
+	if q.threshold > 0 && elapsed >= q.threshold {
+		q.log.Warnf("slow query took %s (%d rows): %s", elapsed, scope.DB().RowsAffected, query)
+		return
+	}
+	if q.all {
+		q.log.Debugf("query took %s (%d rows): %s", elapsed, scope.DB().RowsAffected, query)
+	}
+}
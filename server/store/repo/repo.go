@@ -58,6 +58,12 @@ func (s repositoryStore) FindToken(token string) (*core.Repository, error) {
 	return &repo, err
 }
 
+func (s repositoryStore) FindName(name string) (*core.Repository, error) {
+	var repo core.Repository
+	err := s.db.Where("full_name = ?", name).First(&repo).Error
+	return &repo, err
+}
+
 func (s repositoryStore) List(filters core.RepositoryFilter) ([]core.Repository, int, error) {
 	var repos []core.Repository
 	var count int
@@ -125,6 +131,108 @@ func (s repositoryStore) SetActive(id uint, active bool) error {
 	return s.db.Model(&repo).Update("active", active).Error
 }
 
+func (s repositoryStore) SetNotifications(id uint, notify core.Notifications) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Updates(map[string]interface{}{
+		"notify_emails":      notify.Emails,
+		"notify_author":      notify.Author,
+		"notify_slack_url":   notify.SlackURL,
+		"notify_discord_url": notify.DiscordURL,
+		"notify_on":          notify.On,
+	}).Error
+}
+
+func (s repositoryStore) SetRegistryAuth(id uint, auth string) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Update("registry_auth", auth).Error
+}
+
+func (s repositoryStore) SetCloneAuth(id uint, auth string) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Update("clone_auth", auth).Error
+}
+
+func (s repositoryStore) SetMaxBuilds(id uint, max int) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Update("max_builds", max).Error
+}
+
+func (s repositoryStore) SetAutoCancel(id uint, autoCancel core.AutoCancel) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Updates(map[string]interface{}{
+		"autocancel_enabled":  autoCancel.Enabled,
+		"autocancel_releases": autoCancel.Releases,
+	}).Error
+}
+
+func (s repositoryStore) SetHookFilter(id uint, filter core.HookFilter) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Updates(map[string]interface{}{
+		"filter_branches":        filter.Branches,
+		"filter_ignore_branches": filter.IgnoreBranches,
+		"filter_paths":           filter.Paths,
+		"filter_ignore_paths":    filter.IgnorePaths,
+	}).Error
+}
+
+func (s repositoryStore) SetPublicBadge(id uint, public bool) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Update("public_badge", public).Error
+}
+
+func (s repositoryStore) SetRetention(id uint, retention core.Retention) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Updates(map[string]interface{}{
+		"retention_builds":          retention.Builds,
+		"retention_days":            retention.Days,
+		"retention_keep_last_green": retention.KeepLastGreen,
+	}).Error
+}
+
+func (s repositoryStore) SetHookSecrets(id uint, secret, alt string) error {
+	var repo core.Repository
+	if s.db.Where("id = ?", id).First(&repo).RecordNotFound() {
+		return fmt.Errorf("repository not found")
+	}
+
+	return s.db.Model(&repo).Updates(map[string]interface{}{
+		"hook_secret":     secret,
+		"hook_secret_alt": alt,
+	}).Error
+}
+
 func (s repositoryStore) GetPermissions(id, userID uint) core.Perms {
 	perms := core.Perms{Read: false, Write: false, Exec: false}
 
@@ -133,16 +241,17 @@ func (s repositoryStore) GetPermissions(id, userID uint) core.Perms {
 	if err != nil {
 		return perms
 	}
-	if user.Role == "admin" {
+	if user.Role == core.RoleAdmin {
 		return core.Perms{Read: true, Write: true, Exec: true}
 	}
+	readOnly := user.Role == core.RoleViewer
 
 	var repo core.Repository
 	if err := s.db.Where("id = ?", id).First(&repo).Error; err == nil {
 		if repo.UserID == userID {
 			perms.Read = true
-			perms.Write = true
-			perms.Exec = true
+			perms.Write = !readOnly
+			perms.Exec = !readOnly
 			return perms
 		}
 	}
@@ -173,6 +282,10 @@ func (s repositoryStore) GetPermissions(id, userID uint) core.Perms {
 		}
 	}
 
+	if readOnly {
+		w, x = false, false
+	}
+
 	return core.Perms{Read: r, Write: w, Exec: x}
 }
 
@@ -214,8 +327,8 @@ func (s repositoryStore) CreateHook(id, userID uint, data gitscm.HookForm) error
 		return nil
 	}
 
-	target := fmt.Sprintf("%s/webhooks", repo.Provider.Host)
-	_, err = gitscm.CreateHook(repo.FullName, target, repo.Provider.Name, repo.Provider.Secret, data)
+	target := fmt.Sprintf("%s/webhooks", repo.Provider.ServerURL())
+	_, err = gitscm.CreateHook(repo.FullName, target, repo.Provider.Name, repo.WebhookSecret(), data)
 	return err
 }
 
@@ -251,7 +364,8 @@ func filterHooks(hooks []*scm.Hook, provider core.Provider) []*scm.Hook {
 
 	for _, hook := range hooks {
 		url, _ := url.Parse(hook.Target)
-		if strings.HasPrefix(hook.Target, provider.Host) && strings.HasSuffix(url.Path, "/webhooks") {
+		local := strings.HasPrefix(hook.Target, provider.Host) || strings.HasPrefix(hook.Target, provider.ServerURL())
+		if local && strings.HasSuffix(url.Path, "/webhooks") {
 			webhooks = append(webhooks, hook)
 		}
 	}
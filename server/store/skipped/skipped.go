@@ -0,0 +1,39 @@
+package skipped
+
+import (
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/jinzhu/gorm"
+)
+
+// keep is number of recent skipped builds kept per repository.
+const keep = 100
+
+// New returns a new SkippedBuildStore.
+func New(db *gorm.DB) core.SkippedBuildStore {
+	return skippedBuildStore{db}
+}
+
+type skippedBuildStore struct {
+	db *gorm.DB
+}
+
+func (s skippedBuildStore) Create(skipped *core.SkippedBuild) error {
+	if err := s.db.Create(skipped).Error; err != nil {
+		return err
+	}
+
+	var old []uint
+	err := s.db.Model(&core.SkippedBuild{}).
+		Where("repository_id = ?", skipped.RepositoryID).
+		Order("id desc").Offset(keep).Pluck("id", &old).Error
+	if err != nil || len(old) == 0 {
+		return err
+	}
+	return s.db.Where("id IN (?)", old).Delete(core.SkippedBuild{}).Error
+}
+
+func (s skippedBuildStore) List(repoID uint, limit int) ([]*core.SkippedBuild, error) {
+	var skipped []*core.SkippedBuild
+	err := s.db.Where("repository_id = ?", repoID).Order("id desc").Limit(limit).Find(&skipped).Error
+	return skipped, err
+}
@@ -3,20 +3,24 @@ package store
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/bleenco/abstruse/server/config"
-	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/store/migrate"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mssql"    // mssql driver
 	_ "github.com/jinzhu/gorm/dialects/mysql"    // mysql driver
 	_ "github.com/jinzhu/gorm/dialects/postgres" // postres driver
 	"github.com/jpillora/backoff"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 var db *gorm.DB
+var replica *gorm.DB
 var b = &backoff.Backoff{
 	Min:    5 * time.Second,
 	Max:    10 * time.Second,
@@ -25,10 +29,47 @@ var b = &backoff.Backoff{
 
 // New returns new database instance.
 func New(config *config.Config, logger *zap.Logger) (*gorm.DB, error) {
-	connect(config.DB, logger)
+	logQueries(config.DB, logger)
+	if err := connect(config.DB, logger); err != nil {
+		return nil, err
+	}
+	connectReplica(config.DB, logger)
 	return instance()
 }
 
+// Read returns database handle for read-only queries which tolerate
+// stale data, like listing builds or searching logs. Queries are routed
+// to read replica when configured and to primary db otherwise.
+func Read(db *gorm.DB) *gorm.DB {
+	if replica != nil {
+		return replica
+	}
+	return db
+}
+
+// Open opens database connection without applying migrations.
+func Open(cfg *config.DB) (*gorm.DB, error) {
+	if err := check(cfg); err != nil {
+		return nil, err
+	}
+	return gorm.Open(cfg.Driver, connString(cfg, true))
+}
+
+// OpenChecked opens database connection and checks schema version the
+// same way as New, pending migrations are applied only when auto migrate
+// is enabled.
+func OpenChecked(cfg *config.DB, logger *zap.Logger) (*gorm.DB, error) {
+	conn, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateSchema(conn, cfg, logger.With(zap.String("type", "db")).Sugar()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 // instance returns db connection.
 func instance() (*gorm.DB, error) {
 	if db == nil {
@@ -37,8 +78,9 @@ func instance() (*gorm.DB, error) {
 	return db, nil
 }
 
-// connect connects to database.
-func connect(cfg *config.DB, logger *zap.Logger) {
+// connect connects to database. Error is returned when database schema
+// is not compatible with this version, connection errors are retried.
+func connect(cfg *config.DB, logger *zap.Logger) error {
 	log := logger.With(zap.String("type", "db")).Sugar()
 
 	if err := check(cfg); err != nil {
@@ -51,24 +93,85 @@ func connect(cfg *config.DB, logger *zap.Logger) {
 		if err != nil {
 			log.Errorf("database connection issue: %v", err)
 		} else {
-			conn.AutoMigrate(
-				core.User{},
-				core.Team{},
-				core.Permission{},
-				core.Repository{},
-				core.EnvVariable{},
-				core.Provider{},
-				core.Job{},
-				core.Build{},
-			)
+			if err := migrateSchema(conn, cfg, log); err != nil {
+				conn.Close()
+				return err
+			}
 			db = conn
 			log.Debugf("succesfully connected to database")
 		}
 	}
+
+	return nil
+}
+
+// connectReplica connects to read replica when configured, queries are
+// sent to primary database when replica cannot be opened.
+func connectReplica(cfg *config.DB, logger *zap.Logger) {
+	rcfg, ok := replicaConfig(cfg)
+	if !ok {
+		return
+	}
+	log := logger.With(zap.String("type", "db")).Sugar()
+
+	conn, err := gorm.Open(rcfg.Driver, connString(rcfg, true))
+	if err != nil {
+		log.Errorf("read replica connection issue, using primary database: %v", err)
+		return
+	}
+	replica = conn
+	log.Debugf("succesfully connected to read replica %s", rcfg.Host)
+}
+
+// replicaConfig returns connection config of read replica, unset values
+// are taken from primary database.
+func replicaConfig(cfg *config.DB) (*config.DB, bool) {
+	r := cfg.Replica
+	if r.Host == "" {
+		return nil, false
+	}
+	rcfg := *cfg
+	rcfg.Host = r.Host
+	if r.Port != 0 {
+		rcfg.Port = r.Port
+	}
+	if r.Name != "" {
+		rcfg.Name = r.Name
+	}
+	if r.User != "" {
+		rcfg.User = r.User
+	}
+	if r.Password != "" {
+		rcfg.Password = r.Password
+	}
+	return &rcfg, true
+}
+
+// migrateSchema checks schema version of the database and applies pending
+// migrations when automatic migrations are enabled.
+func migrateSchema(conn *gorm.DB, cfg *config.DB, log *zap.SugaredLogger) error {
+	pending, err := migrate.Check(conn)
+	if err != nil {
+		return err
+	}
+	if pending == 0 {
+		return nil
+	}
+	if !cfg.AutoMigrate {
+		return fmt.Errorf("database schema has %d pending migrations, run `abstruse migrate up`", pending)
+	}
+	applied, err := migrate.Up(conn)
+	for _, m := range applied {
+		log.Infof("applied migration %d %s", m.Version, m.Name)
+	}
+	return err
 }
 
 // Close closes database connection.
 func Close() error {
+	if replica != nil {
+		replica.Close()
+	}
 	return db.Close()
 }
 
@@ -104,6 +207,25 @@ func connString(cfg *config.DB, useDB bool) string {
 	}
 }
 
+// CheckDSN checks connection string built from config can be parsed by
+// the database driver, without connecting to the database.
+func CheckDSN(cfg *config.DB) error {
+	dsn := connString(cfg, true)
+	switch strings.ToLower(cfg.Driver) {
+	case "mysql", "mariadb":
+		_, err := mysql.ParseDSN(dsn)
+		return err
+	case "mssql":
+		_, err := url.Parse(dsn)
+		return err
+	case "postgres", "postgresql":
+		_, err := pq.NewConnector(dsn)
+		return err
+	default:
+		return nil
+	}
+}
+
 func check(cfg *config.DB) error {
 	switch strings.ToLower(cfg.Driver) {
 	case "mysql", "mariadb":
@@ -143,7 +265,10 @@ func reconnectLoop(cfg *config.DB, logger *zap.Logger) {
 			dur := b.Duration()
 			logger.Sugar().Debugf("reconnecting to database in %v...", dur)
 			time.Sleep(dur)
-			connect(cfg, logger)
+			if err := connect(cfg, logger); err != nil {
+				logger.Sugar().Errorf("database schema issue: %v", err)
+				break
+			}
 			if db != nil {
 				b.Reset()
 				break
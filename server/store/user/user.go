@@ -2,6 +2,7 @@ package user
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bleenco/abstruse/internal/auth"
 	"github.com/bleenco/abstruse/server/core"
@@ -10,7 +11,9 @@ import (
 
 // New returns a new UserStore.
 func New(db *gorm.DB) core.UserStore {
-	return userStore{db}
+	s := userStore{db}
+	s.loadRevoked()
+	return s
 }
 
 type userStore struct {
@@ -78,10 +81,40 @@ func (s userStore) Login(email, password string) bool {
 		return false
 	}
 
-	return auth.CheckPasswordHash(password, user.Password)
+	if !auth.CheckPasswordHash(password, user.Password) {
+		return false
+	}
+
+	if auth.NeedsRehash(user.Password) {
+		if hash, err := auth.HashPassword(auth.Password{Password: password}); err == nil {
+			s.db.Model(user).Update("password", hash)
+		}
+	}
+
+	return true
 }
 
 func (s userStore) AdminExists() bool {
 	var user core.User
-	return !s.db.Where("role = ?", "admin").First(&user).RecordNotFound()
+	return !s.db.Where("role = ?", core.RoleAdmin).First(&user).RecordNotFound()
+}
+
+func (s userStore) RevokeTokens(id uint) error {
+	now := time.Now()
+	if err := s.db.Model(&core.User{}).Where("id = ?", id).Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	auth.RevokeUserTokens(id, now)
+	return nil
+}
+
+// loadRevoked loads token revocations from the datastore.
+func (s userStore) loadRevoked() {
+	var users []*core.User
+	if err := s.db.Where("revoked_at IS NOT NULL").Find(&users).Error; err != nil {
+		return
+	}
+	for _, user := range users {
+		auth.RevokeUserTokens(user.ID, *user.RevokedAt)
+	}
 }
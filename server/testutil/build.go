@@ -0,0 +1,215 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// BuildStore is in-memory core.BuildStore. Builds are returned with
+// their jobs from job store. Triggering builds needs build config
+// parsing and is not supported, builds and jobs are created directly.
+type BuildStore struct {
+	mu     sync.Mutex
+	builds map[uint]*core.Build
+	nextID uint
+	repos  *RepositoryStore
+	jobs   *JobStore
+}
+
+// NewBuildStore returns empty in-memory build store.
+func NewBuildStore(repos *RepositoryStore, jobs *JobStore) *BuildStore {
+	return &BuildStore{builds: make(map[uint]*core.Build), repos: repos, jobs: jobs}
+}
+
+func (s *BuildStore) Find(id uint) (*core.Build, error) {
+	s.mu.Lock()
+	build, ok := s.builds[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("build %d not found", id)
+	}
+	return s.load(build), nil
+}
+
+// FindUser returns build by ID, permissions of the user are not checked.
+func (s *BuildStore) FindUser(id, userID uint) (*core.Build, error) {
+	return s.Find(id)
+}
+
+func (s *BuildStore) FindStatus(token, branch string) (string, error) {
+	repo, err := s.repos.FindToken(token)
+	if err != nil {
+		return core.BuildStatusUnknown, fmt.Errorf("repository not found")
+	}
+	if branch == "" {
+		branch = repo.DefaultBranch
+	}
+	build := s.last(func(b *core.Build) bool {
+		return b.PR == 0 && b.RepositoryID == repo.ID && b.Branch == branch
+	})
+	if build == nil {
+		return core.BuildStatusUnknown, fmt.Errorf("build not found")
+	}
+	return build.Status(), nil
+}
+
+func (s *BuildStore) FindPrevious(build *core.Build) (*core.Build, error) {
+	prev := s.last(func(b *core.Build) bool {
+		return b.ID < build.ID && b.RepositoryID == build.RepositoryID && b.Branch == build.Branch && b.EndTime != nil
+	})
+	if prev == nil {
+		return nil, fmt.Errorf("build not found")
+	}
+	return prev, nil
+}
+
+func (s *BuildStore) FindLatest(repoID uint, branch string) (*core.Build, error) {
+	build := s.last(func(b *core.Build) bool {
+		return b.PR == 0 && b.RepositoryID == repoID && b.Branch == branch && b.EndTime != nil
+	})
+	if build == nil {
+		return nil, fmt.Errorf("build not found")
+	}
+	return build, nil
+}
+
+func (s *BuildStore) FindUnfinished(build *core.Build) ([]*core.Build, error) {
+	return s.filter(func(b *core.Build) bool {
+		return b.ID < build.ID && b.RepositoryID == build.RepositoryID && b.Ref == build.Ref && b.EndTime == nil
+	}), nil
+}
+
+// List returns builds newest first, filtered by repository, branch,
+// cursor and status.
+func (s *BuildStore) List(ctx context.Context, filters core.BuildFilter) ([]*core.Build, error) {
+	list := s.filter(func(b *core.Build) bool {
+		if filters.Cursor != 0 && b.ID >= filters.Cursor {
+			return false
+		}
+		if filters.RepositoryID != 0 && b.RepositoryID != uint(filters.RepositoryID) {
+			return false
+		}
+		return filters.Branch == "" || b.Branch == filters.Branch
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+
+	if filters.Status != "" {
+		var filtered []*core.Build
+		for _, build := range list {
+			if build.Status() == filters.Status {
+				filtered = append(filtered, build)
+			}
+		}
+		list = filtered
+	}
+	if filters.Offset > len(list) {
+		return nil, nil
+	}
+	list = list[filters.Offset:]
+	if filters.Limit > 0 && filters.Limit < len(list) {
+		list = list[:filters.Limit]
+	}
+	return list, nil
+}
+
+func (s *BuildStore) Create(build *core.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	build.ID = s.nextID
+	build.CreatedAt, build.UpdatedAt = time.Now(), time.Now()
+	if build.Ref == "" {
+		build.Ref = "refs/heads/master"
+	}
+	b := *build
+	b.Jobs, b.Repository = nil, nil
+	s.builds[build.ID] = &b
+	return nil
+}
+
+func (s *BuildStore) Update(build *core.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.builds[build.ID]; !ok {
+		return fmt.Errorf("build %d not found", build.ID)
+	}
+	build.UpdatedAt = time.Now()
+	b := *build
+	b.Jobs, b.Repository = nil, nil
+	s.builds[build.ID] = &b
+	return nil
+}
+
+func (s *BuildStore) Delete(build *core.Build) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.builds, build.ID)
+	return nil
+}
+
+func (s *BuildStore) TriggerBuild(opts core.TriggerBuildOpts) ([]*core.Job, uint, error) {
+	return nil, 0, ErrNotSupported
+}
+
+func (s *BuildStore) Rebuild(id uint) ([]*core.Job, uint, error) {
+	return nil, 0, ErrNotSupported
+}
+
+func (s *BuildStore) Prune(repo *core.Repository, now time.Time, limit int) (int, error) {
+	return 0, ErrNotSupported
+}
+
+func (s *BuildStore) GenerateBuild(repo *core.Repository, base *core.GitHook) ([]*core.Job, uint, error) {
+	return nil, 0, ErrNotSupported
+}
+
+func (s *BuildStore) Usage(ctx context.Context) ([]*core.StorageUsage, error) {
+	return nil, ErrNotSupported
+}
+
+// load returns copy of the build with its jobs and repository.
+func (s *BuildStore) load(build *core.Build) *core.Build {
+	b := *build
+	b.Jobs = s.jobs.build(b.ID)
+	if repo, err := s.repos.Find(b.RepositoryID, 0); err == nil {
+		b.Repository = &repo
+	}
+	b.AfterFind()
+	return &b
+}
+
+// filter returns builds matching fn ordered by ID.
+func (s *BuildStore) filter(fn func(*core.Build) bool) []*core.Build {
+	s.mu.Lock()
+	var matched []*core.Build
+	for _, build := range s.builds {
+		if fn(build) {
+			matched = append(matched, build)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+	list := make([]*core.Build, len(matched))
+	for i, build := range matched {
+		list[i] = s.load(build)
+	}
+	return list
+}
+
+// last returns build with highest ID matching fn, nil when none does.
+func (s *BuildStore) last(fn func(*core.Build) bool) *core.Build {
+	list := s.filter(fn)
+	if len(list) == 0 {
+		return nil
+	}
+	return list[len(list)-1]
+}
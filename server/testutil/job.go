@@ -0,0 +1,112 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// JobStore is in-memory core.JobStore.
+type JobStore struct {
+	mu     sync.Mutex
+	jobs   map[uint]*core.Job
+	nextID uint
+}
+
+// NewJobStore returns empty in-memory job store.
+func NewJobStore() *JobStore {
+	return &JobStore{jobs: make(map[uint]*core.Job)}
+}
+
+func (s *JobStore) Find(id uint) (*core.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job %d not found", id)
+	}
+	j := *job
+	return &j, nil
+}
+
+// FindUser returns job by ID, permissions of the user are not checked.
+func (s *JobStore) FindUser(id, userID uint) (*core.Job, error) {
+	return s.Find(id)
+}
+
+func (s *JobStore) List(from, to time.Time) ([]*core.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*core.Job
+	for _, job := range s.jobs {
+		if job.CreatedAt.Before(from) || job.CreatedAt.After(to) {
+			continue
+		}
+		j := *job
+		list = append(list, &j)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *JobStore) Search(ctx context.Context, filter core.LogSearchFilter) ([]*core.LogMatch, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *JobStore) Create(job *core.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	job.ID = s.nextID
+	job.CreatedAt, job.UpdatedAt = time.Now(), time.Now()
+	if job.Status == "" {
+		job.Status = "queued"
+	}
+	j := *job
+	s.jobs[job.ID] = &j
+	return nil
+}
+
+func (s *JobStore) Update(job *core.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.ID]; !ok {
+		return fmt.Errorf("job %d not found", job.ID)
+	}
+	job.UpdatedAt = time.Now()
+	j := *job
+	s.jobs[job.ID] = &j
+	return nil
+}
+
+func (s *JobStore) Delete(job *core.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+// build returns jobs of the build.
+func (s *JobStore) build(id uint) []*core.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []*core.Job
+	for _, job := range s.jobs {
+		if job.BuildID == id {
+			j := *job
+			list = append(list, &j)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
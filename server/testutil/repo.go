@@ -0,0 +1,221 @@
+package testutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/gitscm"
+	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/drone/go-scm/scm"
+)
+
+// RepositoryStore is in-memory core.RepositoryStore. All users have full
+// permissions to all repositories. Webhooks are managed with provider
+// APIs and are not supported.
+type RepositoryStore struct {
+	mu     sync.Mutex
+	repos  map[uint]*core.Repository
+	nextID uint
+}
+
+// NewRepositoryStore returns empty in-memory repository store.
+func NewRepositoryStore() *RepositoryStore {
+	return &RepositoryStore{repos: make(map[uint]*core.Repository)}
+}
+
+func (s *RepositoryStore) Find(id, userID uint) (core.Repository, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, ok := s.repos[id]
+	if !ok {
+		return core.Repository{}, fmt.Errorf("repository %d not found", id)
+	}
+	r := *repo
+	r.Perms = core.Perms{Read: true, Write: true, Exec: true}
+	return r, nil
+}
+
+func (s *RepositoryStore) FindUID(uid string) (core.Repository, error) {
+	repo := s.find(func(r *core.Repository) bool { return r.UID == uid })
+	if repo == nil {
+		return core.Repository{}, fmt.Errorf("repository %s not found", uid)
+	}
+	return *repo, nil
+}
+
+func (s *RepositoryStore) FindClone(clone string) (core.Repository, error) {
+	repo := s.find(func(r *core.Repository) bool { return r.Clone == clone })
+	if repo == nil {
+		return core.Repository{}, fmt.Errorf("repository %s not found", clone)
+	}
+	return *repo, nil
+}
+
+func (s *RepositoryStore) FindToken(token string) (*core.Repository, error) {
+	repo := s.find(func(r *core.Repository) bool { return r.Token == token })
+	if repo == nil {
+		return nil, fmt.Errorf("repository not found")
+	}
+	return repo, nil
+}
+
+func (s *RepositoryStore) FindName(name string) (*core.Repository, error) {
+	repo := s.find(func(r *core.Repository) bool { return r.FullName == name })
+	if repo == nil {
+		return nil, fmt.Errorf("repository %s not found", name)
+	}
+	return repo, nil
+}
+
+func (s *RepositoryStore) List(filters core.RepositoryFilter) ([]core.Repository, int, error) {
+	s.mu.Lock()
+	var list []core.Repository
+	for _, repo := range s.repos {
+		if filters.Keyword == "" || strings.Contains(strings.ToLower(repo.FullName), strings.ToLower(filters.Keyword)) {
+			r := *repo
+			r.Perms = core.Perms{Read: true, Write: true, Exec: true}
+			list = append(list, r)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].FullName < list[j].FullName })
+	count := len(list)
+	if filters.Offset > len(list) {
+		return nil, count, nil
+	}
+	list = list[filters.Offset:]
+	if filters.Limit > 0 && filters.Limit < len(list) {
+		list = list[:filters.Limit]
+	}
+	return list, count, nil
+}
+
+func (s *RepositoryStore) Create(repo core.Repository) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	repo.ID = s.nextID
+	repo.Token, repo.Timeout = lib.RandomString(), 3600
+	repo.CreatedAt, repo.UpdatedAt = time.Now(), time.Now()
+	s.repos[repo.ID] = &repo
+	return nil
+}
+
+func (s *RepositoryStore) Update(repo core.Repository) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.repos[repo.ID]; !ok {
+		return fmt.Errorf("repository %d not found", repo.ID)
+	}
+	repo.UpdatedAt = time.Now()
+	s.repos[repo.ID] = &repo
+	return nil
+}
+
+func (s *RepositoryStore) CreateOrUpdate(repo core.Repository) error {
+	if r, err := s.FindUID(repo.UID); err == nil {
+		repo.ID, repo.Token, repo.Active = r.ID, r.Token, r.Active
+		return s.Update(repo)
+	}
+	return s.Create(repo)
+}
+
+func (s *RepositoryStore) Delete(repo core.Repository) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.repos, repo.ID)
+	return nil
+}
+
+func (s *RepositoryStore) GetPermissions(id, userID uint) core.Perms {
+	return core.Perms{Read: true, Write: true, Exec: true}
+}
+
+func (s *RepositoryStore) SetActive(id uint, active bool) error {
+	return s.set(id, func(r *core.Repository) { r.Active = active })
+}
+
+func (s *RepositoryStore) ListHooks(id, userID uint) ([]*scm.Hook, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *RepositoryStore) CreateHook(id, userID uint, data gitscm.HookForm) error {
+	return ErrNotSupported
+}
+
+func (s *RepositoryStore) DeleteHooks(id, userID uint) error {
+	return ErrNotSupported
+}
+
+func (s *RepositoryStore) SetNotifications(id uint, notify core.Notifications) error {
+	return s.set(id, func(r *core.Repository) { r.Notify = notify })
+}
+
+func (s *RepositoryStore) SetRegistryAuth(id uint, auth string) error {
+	return s.set(id, func(r *core.Repository) { r.RegistryAuth = auth })
+}
+
+func (s *RepositoryStore) SetCloneAuth(id uint, auth string) error {
+	return s.set(id, func(r *core.Repository) { r.CloneAuth = auth })
+}
+
+func (s *RepositoryStore) SetMaxBuilds(id uint, max int) error {
+	return s.set(id, func(r *core.Repository) { r.MaxBuilds = max })
+}
+
+func (s *RepositoryStore) SetAutoCancel(id uint, autoCancel core.AutoCancel) error {
+	return s.set(id, func(r *core.Repository) { r.AutoCancel = autoCancel })
+}
+
+func (s *RepositoryStore) SetPublicBadge(id uint, public bool) error {
+	return s.set(id, func(r *core.Repository) { r.PublicBadge = public })
+}
+
+func (s *RepositoryStore) SetRetention(id uint, retention core.Retention) error {
+	return s.set(id, func(r *core.Repository) { r.Retention = retention })
+}
+
+func (s *RepositoryStore) SetHookFilter(id uint, filter core.HookFilter) error {
+	return s.set(id, func(r *core.Repository) { r.HookFilter = filter })
+}
+
+func (s *RepositoryStore) SetHookSecrets(id uint, secret, alt string) error {
+	return s.set(id, func(r *core.Repository) { r.HookSecret, r.HookSecretAlt = secret, alt })
+}
+
+// find returns copy of first repository matching fn, nil when none does.
+func (s *RepositoryStore) find(fn func(*core.Repository) bool) *core.Repository {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, repo := range s.repos {
+		if fn(repo) {
+			r := *repo
+			return &r
+		}
+	}
+	return nil
+}
+
+// set updates repository with fn.
+func (s *RepositoryStore) set(id uint, fn func(*core.Repository)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	repo, ok := s.repos[id]
+	if !ok {
+		return fmt.Errorf("repository %d not found", id)
+	}
+	fn(repo)
+	repo.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package testutil provides in-memory implementations of core stores
+// and registry and test server wiring them into API router, so handler
+// and scheduler tests run without database, Docker or workers.
+package testutil
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/api"
+	"github.com/bleenco/abstruse/server/config"
+	"github.com/bleenco/abstruse/server/core"
+	"github.com/bleenco/abstruse/server/ws"
+	"go.uber.org/zap"
+)
+
+// ErrNotSupported is returned by methods of in-memory stores which need
+// database queries, build config parsing or provider APIs.
+var ErrNotSupported = errors.New("not supported by in-memory store")
+
+// jwtSecret is secret test tokens are signed with.
+const jwtSecret = "abstruse-test-secret"
+
+var (
+	_ core.UserStore       = (*UserStore)(nil)
+	_ core.BuildStore      = (*BuildStore)(nil)
+	_ core.JobStore        = (*JobStore)(nil)
+	_ core.RepositoryStore = (*RepositoryStore)(nil)
+	_ core.WorkerRegistry  = (*WorkerRegistry)(nil)
+	_ core.AuditService    = (*AuditRecorder)(nil)
+)
+
+// TestServer is HTTP test server serving API router backed by in-memory
+// stores. Stores can be filled before requests are sent and inspected
+// after. Dependencies without in-memory implementation are nil, routes
+// using them panic.
+type TestServer struct {
+	*httptest.Server
+	Config  *config.Config
+	Users   *UserStore
+	Builds  *BuildStore
+	Jobs    *JobStore
+	Repos   *RepositoryStore
+	Workers *WorkerRegistry
+	Audit   *AuditRecorder
+}
+
+// NewTestServer starts test server, it is closed when test finishes.
+func NewTestServer(t *testing.T) *TestServer {
+	t.Helper()
+	if err := auth.Init(jwtSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		HTTP:      &config.HTTP{UploadDir: t.TempDir()},
+		Auth:      &config.Auth{JWTSecret: jwtSecret},
+		Websocket: &config.WebSocket{Addr: "127.0.0.1:0"},
+		RateLimit: &config.RateLimit{},
+	}
+	jobs, repos := NewJobStore(), NewRepositoryStore()
+	s := &TestServer{
+		Config:  cfg,
+		Users:   NewUserStore(),
+		Builds:  NewBuildStore(repos, jobs),
+		Jobs:    jobs,
+		Repos:   repos,
+		Workers: NewWorkerRegistry(),
+		Audit:   &AuditRecorder{},
+	}
+
+	router := api.New(
+		cfg,
+		ws.New(cfg, zap.NewNop()),
+		s.Users,
+		nil, // teams
+		nil, // permissions
+		nil, // providers
+		s.Builds,
+		s.Jobs,
+		s.Repos,
+		nil, // env variables
+		s.Workers,
+		nil, // scheduler
+		nil, // stats
+		nil, // api keys
+		nil, // crons
+		nil, // cron
+		nil, // login attempts
+		nil, // audit events
+		nil, // skipped builds
+		nil, // hook deliveries
+		s.Audit,
+		nil, // oidc
+	)
+	s.Server = httptest.NewServer(router.Handler())
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Token returns access token of the user.
+func (s *TestServer) Token(t *testing.T, user *core.User) string {
+	t.Helper()
+	token, err := auth.JWT.CreateJWT(user.Claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
@@ -0,0 +1,158 @@
+package testutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// UserStore is in-memory core.UserStore, passwords are hashed like in
+// database store.
+type UserStore struct {
+	mu     sync.Mutex
+	users  map[uint]*core.User
+	nextID uint
+}
+
+// NewUserStore returns empty in-memory user store.
+func NewUserStore() *UserStore {
+	return &UserStore{users: make(map[uint]*core.User)}
+}
+
+func (s *UserStore) Find(id uint) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user %d not found", id)
+	}
+	u := *user
+	return &u, nil
+}
+
+func (s *UserStore) FindEmail(email string) (*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s not found", email)
+}
+
+func (s *UserStore) List() ([]*core.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*core.User, 0, len(s.users))
+	for _, user := range s.users {
+		u := *user
+		list = append(list, &u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (s *UserStore) Create(user *core.User) error {
+	hash, err := auth.HashPassword(auth.Password{Password: user.Password})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return fmt.Errorf("user %s already exists", user.Email)
+		}
+	}
+	s.nextID++
+	user.ID, user.Password = s.nextID, hash
+	user.CreatedAt, user.UpdatedAt = time.Now(), time.Now()
+	u := *user
+	s.users[user.ID] = &u
+	return nil
+}
+
+func (s *UserStore) Update(user *core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return fmt.Errorf("user %d not found", user.ID)
+	}
+	user.UpdatedAt = time.Now()
+	u := *user
+	s.users[user.ID] = &u
+	return nil
+}
+
+func (s *UserStore) UpdatePassword(id uint, curr, password string) error {
+	user, err := s.Find(id)
+	if err != nil {
+		return err
+	}
+	if !auth.CheckPasswordHash(curr, user.Password) {
+		return fmt.Errorf("invalid current password")
+	}
+	hash, err := auth.HashPassword(auth.Password{Password: password})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[id].Password = hash
+	return nil
+}
+
+func (s *UserStore) Delete(user *core.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, user.ID)
+	return nil
+}
+
+func (s *UserStore) Login(email, password string) bool {
+	user, err := s.FindEmail(email)
+	if err != nil {
+		return false
+	}
+	return auth.CheckPasswordHash(password, user.Password)
+}
+
+func (s *UserStore) AdminExists() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Role == core.RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *UserStore) RevokeTokens(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return fmt.Errorf("user %d not found", id)
+	}
+	now := time.Now()
+	user.RevokedAt = &now
+	auth.RevokeUserTokens(id, now)
+	return nil
+}
@@ -0,0 +1,80 @@
+package testutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bleenco/abstruse/server/core"
+)
+
+// WorkerRegistry is in-memory core.WorkerRegistry, workers added to it
+// are not connected.
+type WorkerRegistry struct {
+	mu      sync.Mutex
+	workers map[string]*core.Worker
+}
+
+// NewWorkerRegistry returns empty worker registry.
+func NewWorkerRegistry() *WorkerRegistry {
+	return &WorkerRegistry{workers: make(map[string]*core.Worker)}
+}
+
+func (r *WorkerRegistry) Add(worker *core.Worker) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workers[worker.ID]; ok {
+		return fmt.Errorf("worker %s already exists", worker.ID)
+	}
+	r.workers[worker.ID] = worker
+	return nil
+}
+
+func (r *WorkerRegistry) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.workers[id]; !ok {
+		return fmt.Errorf("worker %s not found", id)
+	}
+	delete(r.workers, id)
+	return nil
+}
+
+func (r *WorkerRegistry) List() ([]*core.Worker, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]*core.Worker, 0, len(r.workers))
+	for _, worker := range r.workers {
+		list = append(list, worker)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// AuditRecorder is core.AuditService which keeps recorded events.
+type AuditRecorder struct {
+	mu     sync.Mutex
+	events []core.AuditEvent
+}
+
+func (r *AuditRecorder) Record(event core.AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	r.events = append(r.events, event)
+}
+
+// Events returns recorded events in order.
+func (r *AuditRecorder) Events() []core.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]core.AuditEvent(nil), r.events...)
+}
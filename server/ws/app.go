@@ -14,12 +14,14 @@ type App struct {
 	mu      sync.RWMutex
 	logger  *zap.SugaredLogger
 	Clients []*Client
+	Events  *Events
 }
 
 // NewApp inits new app instance and returns it.
 func NewApp(logger *zap.SugaredLogger) *App {
 	return &App{
 		logger: logger,
+		Events: NewEvents(),
 	}
 }
 
@@ -56,8 +58,15 @@ func (a *App) Remove(client *Client) {
 	}
 }
 
-// Broadcast sends socket event to all subscribers.
+// Broadcast sends socket event to all subscribers and publishes it on
+// the event stream.
 func (a *App) Broadcast(sub string, data map[string]interface{}) {
+	if typ, ok := eventTypes[sub]; ok {
+		if err := a.Events.Publish(typ, data); err != nil {
+			a.logger.Debugf("error publishing event: %s\n", err.Error())
+		}
+	}
+
 	var clients []*Client
 
 	for _, c := range a.Clients {
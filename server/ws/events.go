@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// eventHistory is number of recent events kept for resuming streams.
+const eventHistory = 1000
+
+// eventTypes maps subscriptions to types of events published on the
+// event stream, other subscriptions are not streamed.
+var eventTypes = map[string]string{
+	"/subs/builds":         "build",
+	"/subs/jobs":           "job",
+	"/subs/queue":          "queue",
+	"/subs/workers_add":    "worker_connected",
+	"/subs/workers_delete": "worker_disconnected",
+}
+
+// Event is event published on the event stream.
+type Event struct {
+	ID      uint64
+	Type    string
+	Data    map[string]interface{}
+	Payload []byte // JSON encoded data
+}
+
+// Events keeps recent events and delivers published events to
+// subscribers of the event stream.
+type Events struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []Event
+	subs    map[chan Event]struct{}
+}
+
+// NewEvents returns new event stream.
+func NewEvents() *Events {
+	return &Events{
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish assigns id to event and delivers it to subscribers.
+// Subscribers not keeping up are unsubscribed and their channel is
+// closed, so they can resume from the last received event.
+func (e *Events) Publish(typ string, data map[string]interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.seq++
+	event := Event{ID: e.seq, Type: typ, Data: data, Payload: payload}
+	e.history = append(e.history, event)
+	if len(e.history) > eventHistory {
+		e.history = e.history[len(e.history)-eventHistory:]
+	}
+
+	for ch := range e.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(e.subs, ch)
+			close(ch)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe returns events published after event with id last and
+// channel of new events. When events after last are no longer kept,
+// ok is false and no missed events are returned.
+func (e *Events) Subscribe(last uint64) (missed []Event, ch chan Event, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch = make(chan Event, 64)
+	e.subs[ch] = struct{}{}
+
+	ok = true
+	if last > 0 {
+		if last > e.seq || (len(e.history) > 0 && last < e.history[0].ID-1) {
+			return nil, ch, false
+		}
+		for _, event := range e.history {
+			if event.ID > last {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	return missed, ch, ok
+}
+
+// Unsubscribe stops delivering events to the channel.
+func (e *Events) Unsubscribe(ch chan Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.subs[ch]; ok {
+		delete(e.subs, ch)
+		close(ch)
+	}
+}
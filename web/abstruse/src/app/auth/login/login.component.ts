@@ -5,6 +5,8 @@ import { AuthService } from '../shared/auth.service';
 import { finalize } from 'rxjs/operators';
 import { SetupService } from 'src/app/setup/shared/setup.service';
 import { Router } from '@angular/router';
+import { environment } from 'src/environments/environment';
+import { OIDCStatus } from '../shared/auth.model';
 
 @UntilDestroy()
 @Component({
@@ -18,6 +20,8 @@ export class LoginComponent implements OnInit {
   loginForm!: FormGroup;
   isLoading = false;
   submitted = false;
+  oidc: OIDCStatus = { enabled: false };
+  oidcLoginURL = `${environment.apiURL}auth/oidc/login`;
 
   constructor(
     private fromBuilder: FormBuilder,
@@ -29,6 +33,23 @@ export class LoginComponent implements OnInit {
   }
 
   ngOnInit(): void {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (params.has('token')) {
+      this.auth.login(params.get('token') as string);
+      return;
+    }
+    if (params.has('error')) {
+      this.error = params.get('error') as string;
+    }
+
+    this.auth
+      .oidc()
+      .pipe(untilDestroyed(this))
+      .subscribe(
+        resp => (this.oidc = resp),
+        () => (this.oidc = { enabled: false })
+      );
+
     this.setup
       .ready()
       .then(ready => (!!ready ? (this.displayForm = true) : this.router.navigate(['/setup'])))
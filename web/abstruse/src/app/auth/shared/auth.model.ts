@@ -18,3 +18,8 @@ export interface UserData {
 export interface TokenResponse {
   token: string;
 }
+
+export interface OIDCStatus {
+  enabled: boolean;
+  name?: string;
+}
@@ -2,7 +2,7 @@ import { Injectable } from '@angular/core';
 import { HttpClient } from '@angular/common/http';
 import { Router } from '@angular/router';
 import { Observable, BehaviorSubject } from 'rxjs';
-import { AUTH_TOKEN_KEY, Login, UserData, TokenResponse } from './auth.model';
+import { AUTH_TOKEN_KEY, Login, UserData, TokenResponse, OIDCStatus } from './auth.model';
 import { CookieService } from 'ngx-cookie-service';
 import jwtDecode from 'jwt-decode';
 
@@ -56,4 +56,8 @@ export class AuthService {
   authenticate(data: Login): Observable<TokenResponse> {
     return this.http.post<TokenResponse>('/auth/login', data);
   }
+
+  oidc(): Observable<OIDCStatus> {
+    return this.http.get<OIDCStatus>('/auth/oidc');
+  }
 }
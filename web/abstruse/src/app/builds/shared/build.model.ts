@@ -80,6 +80,13 @@ export class Build {
       return 'passing';
     }
 
+    if (
+      this.jobs.find(job => job.status === 'cancelled') &&
+      !this.jobs.find(job => job.status === 'queued')
+    ) {
+      return 'cancelled';
+    }
+
     return 'queued';
   }
 
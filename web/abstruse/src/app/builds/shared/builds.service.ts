@@ -37,8 +37,8 @@ export class BuildsService {
       params = params.append('repoID', String(data.repoID));
     }
     return this.http
-      .get<Build[]>('/builds', { params })
-      .pipe(map(d => (data && d.length ? d.map(generateBuildModel) : [])));
+      .get<{ data: Build[]; next_cursor?: string }>('/builds', { params })
+      .pipe(map(d => (d && d.data && d.data.length ? d.data.map(generateBuildModel) : [])));
   }
 
   findBuild(id: number): Observable<Build> {
@@ -23,7 +23,7 @@ export class ErrorInterceptor implements HttpInterceptor {
     if (response.status === 504) {
       this.router.navigate(['/gateway-timeout']);
     }
-    throw response.error;
+    throw response.error && response.error.error ? response.error.error : response.error;
   }
 }
 
@@ -24,7 +24,8 @@ export class UserModalComponent implements OnInit {
 
   roleList = [
     { value: 'admin', placeholder: 'Admin' },
-    { value: 'user', placeholder: 'User' }
+    { value: 'maintainer', placeholder: 'Maintainer' },
+    { value: 'viewer', placeholder: 'Viewer' }
   ];
 
   files: UploadFile[] = [];
@@ -135,7 +136,7 @@ export class UserModalComponent implements OnInit {
       id: [(this.user && this.user.id) || null, []],
       email: [(this.user && this.user.email) || null, [Validators.required, Validators.email]],
       name: [(this.user && this.user.name) || null, [Validators.required]],
-      role: [(this.user && this.user.role) || 'user', [Validators.required]],
+      role: [(this.user && this.user.role) || 'maintainer', [Validators.required]],
       avatar: [
         (this.user && this.user.avatar) || '/assets/images/avatars/avatar_7.svg',
         [Validators.required]
@@ -9,6 +9,7 @@ import (
 	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/server/api/render"
 	"github.com/bleenco/abstruse/worker/config"
+	"github.com/bleenco/abstruse/worker/docker"
 	"github.com/bleenco/abstruse/worker/http"
 	"go.uber.org/zap"
 )
@@ -31,7 +32,7 @@ func NewApp(config *config.Config, logger *zap.Logger) (*App, error) {
 	if err != nil {
 		return nil, err
 	}
-	client, err := http.NewClient(config.Server.Addr, token)
+	client, err := http.NewClient(config.Server.Addr, token, config.Proxy.Transport())
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +47,15 @@ func (a *App) Run() error {
 	errch := make(chan error, 1)
 	quitch := make(chan error, 1)
 
+	if a.Config.Docker != nil && a.Config.Docker.Cleanup {
+		containers, err := docker.Cleanup(a.Config.ID)
+		if err != nil {
+			a.Logger.Errorf("error cleaning up orphaned build containers: %v", err)
+		} else if containers > 0 {
+			a.Logger.Infof("removed %d orphaned build containers", containers)
+		}
+	}
+
 	go func() {
 		if err := a.API.Run(); err != nil {
 			quitch <- err
@@ -106,5 +116,5 @@ func (a *App) sendAuthRequest() error {
 		return err
 	}
 
-	return fmt.Errorf("error connecting to abstruse server: %s", r.Message)
+	return fmt.Errorf("error connecting to abstruse server: %s", r.Error.Message)
 }
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/bleenco/abstruse/pb"
+)
+
+const (
+	// maxLogChunks is number of recent log chunks kept per job.
+	maxLogChunks = 4096
+
+	// logRetention is how long log chunks are kept after job finished,
+	// so server can request chunks missing at the end of the log.
+	logRetention = time.Minute
+)
+
+// logBuffer numbers log chunks of the job and keeps recent chunks,
+// so chunks server did not receive can be sent again.
+type logBuffer struct {
+	mu     sync.Mutex
+	seq    uint64
+	chunks []*pb.JobResp
+}
+
+// add returns log chunk with next sequence number and keeps it.
+func (b *logBuffer) add(job *pb.Job, content []byte) *pb.JobResp {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	chunk := &pb.JobResp{
+		Id:      job.GetId(),
+		BuildId: job.GetBuildId(),
+		Content: content,
+		Type:    pb.JobResp_Log,
+		Seq:     b.seq,
+	}
+	b.chunks = append(b.chunks, chunk)
+	if len(b.chunks) > maxLogChunks {
+		b.chunks = b.chunks[len(b.chunks)-maxLogChunks:]
+	}
+	return chunk
+}
+
+// last returns sequence number of the last chunk.
+func (b *logBuffer) last() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// get returns kept chunks with sequence numbers from from to to.
+func (b *logBuffer) get(from, to uint64) []*pb.JobResp {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var chunks []*pb.JobResp
+	for _, chunk := range b.chunks {
+		if chunk.Seq >= from && chunk.Seq <= to {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
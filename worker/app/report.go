@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pb "github.com/bleenco/abstruse/pb"
+)
+
+const (
+	// maxReportFiles is number of test report files collected per job.
+	maxReportFiles = 20
+
+	// maxReportSize is size of the largest test report file collected.
+	maxReportSize = 1 << 20
+)
+
+// collectReports returns test report files matching patterns relative
+// to job workspace dir and notes about reports which were not collected.
+func collectReports(dir string, patterns []string) ([]*pb.ReportFile, []string) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var files []*pb.ReportFile
+	var notes []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(pattern)))
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("invalid test report path %s: %v", pattern, err))
+			continue
+		}
+		if len(matches) == 0 {
+			notes = append(notes, fmt.Sprintf("no test reports found matching %s", pattern))
+		}
+		for _, match := range matches {
+			// reports are written by build, so links may point
+			// outside of workspace.
+			real, err := filepath.EvalSymlinks(match)
+			if err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(root, real)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				notes = append(notes, fmt.Sprintf("test report %s is outside of workspace", pattern))
+				continue
+			}
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+
+			info, err := os.Stat(real)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if info.Size() > maxReportSize {
+				notes = append(notes, fmt.Sprintf("test report %s exceeds %d bytes", filepath.ToSlash(rel), maxReportSize))
+				continue
+			}
+			if len(files) == maxReportFiles {
+				notes = append(notes, fmt.Sprintf("more than %d test reports found, ignoring %s", maxReportFiles, filepath.ToSlash(rel)))
+				continue
+			}
+			data, err := ioutil.ReadFile(real)
+			if err != nil {
+				notes = append(notes, err.Error())
+				continue
+			}
+			files = append(files, &pb.ReportFile{Path: filepath.ToSlash(rel), Content: data})
+		}
+	}
+	return files, notes
+}
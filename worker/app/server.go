@@ -4,16 +4,22 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/bleenco/abstruse/internal/requestid"
+	"github.com/bleenco/abstruse/internal/rpc"
 	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/pkg/envvar"
 	"github.com/bleenco/abstruse/pkg/fs"
 	"github.com/bleenco/abstruse/pkg/stats"
+	"github.com/bleenco/abstruse/pkg/step"
 	"github.com/bleenco/abstruse/worker/config"
 	"github.com/bleenco/abstruse/worker/docker"
 	"github.com/bleenco/abstruse/worker/git"
@@ -37,6 +43,8 @@ type Server struct {
 	app      *App
 	logger   *zap.SugaredLogger
 	jobs     map[uint64]*pb.Job
+	logs     map[uint64]*logBuffer
+	draining bool // server starts no new jobs on worker
 	errch    chan error
 }
 
@@ -49,6 +57,7 @@ func NewServer(config *config.Config, logger *zap.Logger, app *App) *Server {
 		app:    app,
 		logger: logger.With(zap.String("type", "server")).Sugar(),
 		jobs:   make(map[uint64]*pb.Job),
+		logs:   make(map[uint64]*logBuffer),
 		errch:  make(chan error),
 	}
 }
@@ -71,6 +80,7 @@ func (s *Server) Run() error {
 	})
 
 	grpcOpts = append(grpcOpts, grpc.Creds(creds))
+	grpcOpts = append(grpcOpts, rpc.ServerOptions(rpcOptions(s.config.GRPC))...)
 	grpcOpts = append(grpcOpts, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
 		s.unaryInterceptor,
 		grpc_recovery.UnaryServerInterceptor(),
@@ -93,6 +103,13 @@ func (s *Server) Connect(ctx context.Context, in *empty.Empty) (*pb.HostInfo, er
 	if err != nil {
 		return nil, err
 	}
+	capacity := s.config.Scheduler.Capacity
+	if capacity == 0 {
+		capacity = s.config.Scheduler.MaxParallel
+	}
+	s.mu.Lock()
+	draining := s.draining
+	s.mu.Unlock()
 
 	return &pb.HostInfo{
 		Id:                   s.id,
@@ -111,6 +128,10 @@ func (s *Server) Connect(ctx context.Context, in *empty.Empty) (*pb.HostInfo, er
 		VirtualizationRole:   info.VirtualizationRole,
 		HostID:               info.HostID,
 		MaxParallel:          uint64(s.config.Scheduler.MaxParallel),
+		Labels:               s.config.Labels,
+		Capacity:             uint64(capacity),
+		Archs:                s.config.Archs,
+		Draining:             draining,
 	}, nil
 }
 
@@ -159,7 +180,11 @@ func (s *Server) Usage(stream pb.API_UsageServer) error {
 // StartJob gRPC method.
 func (s *Server) StartJob(job *pb.Job, stream pb.API_StartJobServer) error {
 	name := fmt.Sprintf("abstruse-job-%d", job.GetId())
-	s.logger.Infof("starting job %d with name %s", job.Id, name)
+	logger := s.logger
+	if id := requestid.FromIncomingContext(stream.Context()); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	logger.Infof("starting job %d with name %s", job.Id, name)
 
 	s.mu.Lock()
 	if _, ok := s.jobs[job.Id]; ok {
@@ -178,41 +203,98 @@ func (s *Server) StartJob(job *pb.Job, stream pb.API_StartJobServer) error {
 		s.mu.Unlock()
 	}()
 
+	var commands []step.Step
+	if err := json.Unmarshal([]byte(job.Commands), &commands); err != nil {
+		return err
+	}
+	secrets := resolveStepEnv(job, commands)
+
+	logs := &logBuffer{}
+	s.mu.Lock()
+	s.logs[job.Id] = logs
+	s.mu.Unlock()
+	defer time.AfterFunc(logRetention, func() {
+		s.mu.Lock()
+		if s.logs[job.Id] == logs {
+			delete(s.logs, job.Id)
+		}
+		s.mu.Unlock()
+	})
+
+	// stream is not safe for concurrent sends and log chunks are
+	// sent from their own goroutine.
+	var sendmu sync.Mutex
+	send := func(resp *pb.JobResp) error {
+		sendmu.Lock()
+		defer sendmu.Unlock()
+		return stream.Send(resp)
+	}
+
 	logch := make(chan []byte, 1024)
+	flushed := make(chan struct{})
+	// truncated is set when log reached maximum size, output after
+	// that is discarded but still read so container is not blocked.
+	var truncated bool
 
 	go func(job *pb.Job) {
+		defer close(flushed)
+		var failed bool
+		var size int64
 		for output := range logch {
+			if truncated {
+				continue
+			}
 			out := string(output)
 
-			for _, e := range job.Env {
-				if e.Secret && strings.Contains(out, e.Value) {
-					out = strings.ReplaceAll(out, e.Value, "**********")
+			for _, secret := range secrets {
+				if strings.Contains(out, secret) {
+					out = strings.ReplaceAll(out, secret, "**********")
 				}
 			}
 
-			log := &pb.JobResp{Id: job.GetId(), Content: []byte(out), Type: pb.JobResp_Log}
-			if err := stream.Send(log); err != nil {
-				break
+			if max := job.GetMaxLogSize(); max > 0 && size+int64(len(out)) > max {
+				out = truncateLog(out, int(max-size)) + red(fmt.Sprintf("\r\n==> Log truncated at %d bytes\r\n", max))
+				truncated = true
+				send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Metadata, LogTruncated: true})
+				if job.GetFailOnLogLimit() {
+					docker.StopContainer(name)
+				}
+			}
+			size += int64(len(out))
+
+			// chunks are numbered and kept even when sending fails,
+			// so server can request them again.
+			chunk := logs.add(job, []byte(out))
+			if failed {
+				continue
+			}
+			if err := send(chunk); err != nil {
+				failed = true
 			}
 		}
 	}(job)
 
 	logch <- []byte(yellow(fmt.Sprintf("==> Starting job %d in %s...\r\n", job.GetId(), name)))
 
-	image := job.Image
+	policy := s.config.Images.Policy()
+	image := policy.Image(job.Image)
+	if image == "" {
+		return fmt.Errorf("image not specified")
+	}
+	if err := policy.Check(image); err != nil {
+		return err
+	}
 
-	var env []string
+	// proxy variables go first so job can override them.
+	env := s.config.Proxy.Env()
 	for _, e := range job.Env {
 		env = append(env, fmt.Sprintf("%s=%s", e.Key, e.Value))
 	}
 
-	var cmds []string
-	if err := json.Unmarshal([]byte(job.Commands), &cmds); err != nil {
-		return err
-	}
-	var commands [][]string
-	for _, c := range cmds {
-		commands = append(commands, strings.Split(c, " "))
+	env = append(env, docker.BuildCacheEnv()...)
+
+	for i := range commands {
+		commands[i].Run = docker.WithBuildCache(commands[i].Run, job.GetRepoName())
 	}
 
 	logch <- []byte(yellow(fmt.Sprintf("==> Creating temp directory to mount volume... ")))
@@ -223,18 +305,46 @@ func (s *Server) StartJob(job *pb.Job, stream pb.API_StartJobServer) error {
 	defer os.RemoveAll(dir)
 	logch <- []byte(yellow(fmt.Sprintf("done\r\n")))
 
-	logch <- []byte(yellow(fmt.Sprintf("==> Cloning repository %s ref: %s sha: %s... ", job.GetUrl(), job.GetRef(), job.GetCommitSHA())))
-	if err := git.CloneRepository(job.GetUrl(), job.GetRef(), job.GetCommitSHA(), job.GetProviderToken(), dir); err != nil {
+	timing := func(name string, start, end time.Time, skipped bool) {
+		send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Timing, Step: &pb.StepTiming{
+			Name:      name,
+			StartTime: start.UnixNano() / int64(time.Millisecond),
+			EndTime:   end.UnixNano() / int64(time.Millisecond),
+			Skipped:   skipped,
+		}})
+	}
+
+	url, creds := cloneAuth(job)
+	if creds.Key != "" && !git.KnownHosts() {
+		logch <- []byte(yellow("==> No known_hosts file on worker, host key of repository is not verified\r\n"))
+	}
+	logch <- []byte(yellow(fmt.Sprintf("==> Cloning repository %s ref: %s sha: %s... ", url, job.GetRef(), job.GetCommitSHA())))
+	start := time.Now()
+	if err := git.CloneRepository(url, job.GetRef(), job.GetCommitSHA(), dir, creds, cloneOptions(job.GetClone())); err != nil {
 		return err
 	}
+	timing("clone", start, time.Now(), false)
 	logch <- []byte(yellow(fmt.Sprintf("done\r\n")))
 
 	logch <- []byte(yellow(fmt.Sprintf("==> Pulling image %s... ", image)))
-	if err := docker.PullImage(image, s.config.Registry); err != nil {
+	start = time.Now()
+	auth := docker.NewRegistryAuth()
+	if job.GetRegistryAuth() != "" {
+		if err := auth.Load([]byte(job.GetRegistryAuth())); err != nil {
+			logch <- []byte(err.Error())
+		}
+	}
+	if err := docker.PullImage(image, s.config.Registry, docker.Auths(auth)); err != nil {
 		logch <- []byte(err.Error())
 	} else {
 		logch <- []byte(yellow(fmt.Sprintf("done\r\n")))
 	}
+	timing("pull image", start, time.Now(), false)
+
+	if digest, err := docker.ImageDigest(image); err == nil {
+		logch <- []byte(yellow(fmt.Sprintf("==> Using image %s\r\n", digest)))
+		send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Metadata, ImageDigest: digest})
+	}
 
 	ok := true
 	s.mu.Lock()
@@ -245,15 +355,84 @@ func (s *Server) StartJob(job *pb.Job, stream pb.API_StartJobServer) error {
 		return nil
 	}
 
-	logch <- []byte(yellow(fmt.Sprintf("==> Starting container %s...\r\n", name)))
-	if err := docker.RunContainer(name, image, commands, env, dir, logch); err != nil {
-		stream.Send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Done, Status: pb.JobResp_StatusFailing})
-		s.logger.Infof("job %d with name %s done with status failing", job.Id, name)
+	resources, err := docker.NewResources(s.config.Resources, job.GetResources())
+	if err != nil {
+		return err
+	}
+
+	network, err := docker.NewNetwork(job.GetNetwork())
+	if err != nil {
+		return err
+	}
+
+	vars := jobEnv(job)
+	services := docker.NewServices(job.GetServices(), func(value string) string {
+		value, _ = vars.Expand(value)
+		return value
+	})
+	for _, svc := range services {
+		if err := policy.Check(svc.Image); err != nil {
+			return err
+		}
+		logch <- []byte(yellow(fmt.Sprintf("==> Pulling service image %s... ", svc.Image)))
+		if err := docker.PullImage(svc.Image, s.config.Registry, docker.Auths(auth)); err != nil {
+			logch <- []byte(err.Error())
+		} else {
+			logch <- []byte(yellow(fmt.Sprintf("done\r\n")))
+		}
+	}
+	running, network, err := docker.StartServices(name, services, network, docker.Labels(s.id, job.GetBuildId(), job.GetId()), logch)
+	defer running.Stop()
+	if err != nil {
+		return err
+	}
+
+	logch <- []byte(yellow(fmt.Sprintf("==> Starting container %s (%s, network %s)...\r\n", name, resources, network)))
+	usage, err := docker.RunContainer(name, image, commands, env, dir, docker.Labels(s.id, job.GetBuildId(), job.GetId()), resources, network, logch, timing)
+	<-flushed
+
+	if usage.Samples > 0 {
+		send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Metadata, Usage: &pb.ResourceUsage{
+			CpuPeak:    usage.CPUPeak,
+			CpuAvg:     usage.CPUAvg,
+			MemoryPeak: usage.MemoryPeak,
+			MemoryAvg:  usage.MemoryAvg,
+			Samples:    int32(usage.Samples),
+		}})
+	}
+
+	// test reports are collected whether job passed or not, log is
+	// flushed at this point so notes are added as chunks directly.
+	reports, notes := collectReports(dir, job.GetReports())
+	for _, note := range notes {
+		if !truncated {
+			send(logs.add(job, []byte(yellow(fmt.Sprintf("==> %s\r\n", note)))))
+		}
+	}
+	for _, report := range reports {
+		send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Report, Report: report})
+	}
+
+	if err != nil {
+		var reason string
+		if errors.Is(err, docker.ErrOutOfMemory) {
+			reason = err.Error()
+		} else if truncated && job.GetFailOnLogLimit() {
+			reason = logLimitReason(job)
+		}
+		send(&pb.JobResp{Id: job.GetId(), BuildId: job.GetBuildId(), Type: pb.JobResp_Done, Status: pb.JobResp_StatusFailing, Reason: reason, Seq: logs.last()})
+		logger.Infof("job %d with name %s done with status failing", job.Id, name)
 		return err
 	}
 
-	stream.Send(&pb.JobResp{Id: job.GetId(), Type: pb.JobResp_Done, Status: pb.JobResp_StatusPassing})
-	s.logger.Infof("job %d with name %s done with status success", job.Id, name)
+	if truncated && job.GetFailOnLogLimit() {
+		// container finished before it was stopped.
+		send(&pb.JobResp{Id: job.GetId(), BuildId: job.GetBuildId(), Type: pb.JobResp_Done, Status: pb.JobResp_StatusFailing, Reason: logLimitReason(job), Seq: logs.last()})
+		logger.Infof("job %d with name %s done with status failing", job.Id, name)
+		return nil
+	}
+	send(&pb.JobResp{Id: job.GetId(), BuildId: job.GetBuildId(), Type: pb.JobResp_Done, Status: pb.JobResp_StatusPassing, Seq: logs.last()})
+	logger.Infof("job %d with name %s done with status success", job.Id, name)
 
 	return nil
 }
@@ -280,10 +459,146 @@ func (s *Server) StopJob(ctx context.Context, job *pb.Job) (*pb.JobStopResp, err
 	return &pb.JobStopResp{Stopped: false}, nil
 }
 
+// ResendLog gRPC method sends kept log chunks of the job again.
+func (s *Server) ResendLog(r *pb.LogRange, stream pb.API_ResendLogServer) error {
+	s.mu.Lock()
+	logs, ok := s.logs[r.GetJobId()]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("log of job %d not found", r.GetJobId())
+	}
+
+	for _, chunk := range logs.get(r.GetFrom(), r.GetTo()) {
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drain gRPC method, worker keeps drain state so it is restored when
+// worker reconnects to server.
+func (s *Server) Drain(ctx context.Context, in *pb.DrainRequest) (*empty.Empty, error) {
+	s.mu.Lock()
+	s.draining = in.GetDraining()
+	running := len(s.jobs)
+	s.mu.Unlock()
+
+	if in.GetDraining() {
+		s.logger.Infof("worker draining, waiting for %d running jobs to finish", running)
+	} else {
+		s.logger.Infof("worker drain lifted")
+	}
+	return &empty.Empty{}, nil
+}
+
 func (s *Server) Error() chan error {
 	return s.errch
 }
 
+// rpcOptions returns gRPC options from config, defaults are used when
+// config is not set.
+func rpcOptions(cfg *config.GRPC) rpc.Options {
+	if cfg == nil {
+		return rpc.Options{}
+	}
+	opts := rpc.Options{
+		MaxRecvMsgSize: cfg.MaxRecvMsgSize,
+		MaxSendMsgSize: cfg.MaxSendMsgSize,
+	}
+	if ka := cfg.Keepalive; ka != nil {
+		opts.KeepaliveTime = ka.Time
+		opts.KeepaliveTimeout = ka.Timeout
+	}
+	return opts
+}
+
+// resolveStepEnv resolves env of commands on top of job environment
+// and returns values of secret variables, which are masked in logs.
+func resolveStepEnv(job *pb.Job, commands []step.Step) []string {
+	env := jobEnv(job)
+
+	seen := make(map[string]bool)
+	var secrets []string
+	collect := func(vars []envvar.Var) {
+		for _, v := range vars {
+			if v.Secret && v.Value != "" && !seen[v.Value] {
+				seen[v.Value] = true
+				secrets = append(secrets, v.Value)
+			}
+		}
+	}
+	collect(env.Vars())
+
+	for i := range commands {
+		if len(commands[i].Env) == 0 {
+			continue
+		}
+		stepEnv := env.Clone()
+		stepEnv.SetAll(commands[i].Env, false)
+		commands[i].Env = stepEnv.Pairs()
+		collect(stepEnv.Vars())
+	}
+	return secrets
+}
+
+// jobEnv returns resolved environment of the job.
+func jobEnv(job *pb.Job) *envvar.Env {
+	env := envvar.New()
+	for _, e := range job.GetEnv() {
+		env.Put(envvar.Var{Key: e.Key, Value: e.Value, Secret: e.Secret})
+	}
+	return env
+}
+
+// cloneOptions returns clone options of the job, defaults are used for
+// jobs from servers not sending them.
+func cloneOptions(clone *pb.CloneOptions) git.CloneOptions {
+	if clone == nil {
+		return git.DefaultCloneOptions
+	}
+	return git.CloneOptions{
+		Depth:      int(clone.GetDepth()),
+		Submodules: int(clone.GetSubmodules()),
+		Tags:       clone.GetTags(),
+		Merge:      clone.GetMerge(),
+	}
+}
+
+// cloneAuth returns URL and credentials repository is cloned with, SSH
+// deploy key or HTTPS token of the repository, provider token otherwise.
+func cloneAuth(job *pb.Job) (string, git.Auth) {
+	auth := job.GetClone().GetAuth()
+	switch {
+	case auth.GetType() == "ssh" && auth.GetUrl() != "":
+		return auth.GetUrl(), git.Auth{Username: auth.GetUsername(), Key: auth.GetKey(), Passphrase: auth.GetPassphrase()}
+	case auth.GetType() == "token":
+		return job.GetUrl(), git.Auth{Username: auth.GetUsername(), Token: auth.GetToken()}
+	default:
+		return job.GetUrl(), git.Auth{Token: job.GetProviderToken()}
+	}
+}
+
+// truncateLog returns at most n bytes of log, without splitting
+// multibyte character.
+func truncateLog(log string, n int) string {
+	if n >= len(log) {
+		return log
+	}
+	for n > 0 && !utf8.RuneStart(log[n]) {
+		n--
+	}
+	return log[:n]
+}
+
+func logLimitReason(job *pb.Job) string {
+	return fmt.Sprintf("log exceeded maximum size of %d bytes", job.GetMaxLogSize())
+}
+
 func yellow(str string) string {
 	return aurora.Bold(aurora.Yellow(str)).String()
 }
+
+func red(str string) string {
+	return aurora.Bold(aurora.Red(str)).String()
+}
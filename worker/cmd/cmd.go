@@ -1,19 +1,25 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bleenco/abstruse/internal/auth"
+	"github.com/bleenco/abstruse/internal/rpc"
 	"github.com/bleenco/abstruse/internal/version"
-	"github.com/bleenco/abstruse/pkg/fs"
+	"github.com/bleenco/abstruse/pkg/configfile"
 	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/pkg/tlsutil"
 	"github.com/bleenco/abstruse/worker/app"
 	"github.com/bleenco/abstruse/worker/config"
 	"github.com/bleenco/abstruse/worker/docker"
+	"github.com/bleenco/abstruse/worker/git"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -21,20 +27,19 @@ import (
 )
 
 var (
-	cfgFile string
-	rootCmd = &cobra.Command{
-		Use:   "abstruse-worker",
-		Short: "Abstruse CI Worker Node",
-		Run: func(cmd *cobra.Command, args []string) {
+	cfgFile    string
+	cfgNoWrite bool
+	rootCmd    = &cobra.Command{
+		Use:           "abstruse-worker",
+		Short:         "Abstruse CI Worker Node",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			app, err := CreateApp()
 			if err != nil {
-				fatal(err)
-			}
-
-			if err := app.run(); err != nil {
-				fatal(err)
+				return err
 			}
-			os.Exit(0)
+			return app.run()
 		},
 	}
 	versionCmd = &cobra.Command{
@@ -42,7 +47,6 @@ var (
 		Short: "Print the version number and build info",
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println(version.GenerateBuildVersionString())
-			os.Exit(0)
 		},
 	}
 )
@@ -73,7 +77,8 @@ func (a application) run() error {
 	return <-errch
 }
 
-// Execute executes the root command.
+// Execute executes the root command, errors are returned to the caller
+// which is responsible for exiting the process.
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -82,115 +87,172 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 	cobra.OnInitialize(initDefaults)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/abstruse/abstruse-worker.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file in JSON, YAML or TOML format (default is $HOME/abstruse/abstruse-worker.json)")
+	rootCmd.PersistentFlags().BoolVar(&cfgNoWrite, "no-write-config", false, "do not create config file when missing, run with defaults, environment variables and flags (default is $ABSTRUSE_NO_WRITE_CONFIG)")
 	rootCmd.PersistentFlags().String("id", lib.RandomString(), "worker node ID")
+	rootCmd.PersistentFlags().String("datadir", "", "data directory root relative paths of logs and certificates are resolved to (default is config file directory)")
+	rootCmd.PersistentFlags().StringToString("labels", nil, "worker node labels reported to server, e.g. region=eu,gpu=true")
+	rootCmd.PersistentFlags().StringSlice("archs", []string{}, "architectures of jobs worker runs, e.g. amd64,arm64 with emulation (default is container runtime architecture)")
 	rootCmd.PersistentFlags().String("server-addr", "http://localhost", "abstruse server API address")
 	rootCmd.PersistentFlags().String("grpc-addr", "0.0.0.0:3330", "gRPC server listen address")
-	rootCmd.PersistentFlags().String("tls-cert", "cert-worker.pem", "path to SSL certificate file")
-	rootCmd.PersistentFlags().String("tls-key", "key-worker.pem", "path to SSL private key file")
+	rootCmd.PersistentFlags().Int("grpc-maxrecvmsgsize", rpc.DefaultMaxMsgSize, "maximum size of received gRPC message in bytes")
+	rootCmd.PersistentFlags().Int("grpc-maxsendmsgsize", rpc.DefaultMaxMsgSize, "maximum size of sent gRPC message in bytes")
+	rootCmd.PersistentFlags().Duration("grpc-keepalive-time", 30*time.Second, "interval of gRPC keepalive pings on idle connections (0 disables)")
+	rootCmd.PersistentFlags().Duration("grpc-keepalive-timeout", 10*time.Second, "time to wait for gRPC keepalive ping response before closing connection")
+	rootCmd.PersistentFlags().String("tls-cert", "certs/cert-worker.pem", "path to SSL certificate file")
+	rootCmd.PersistentFlags().String("tls-key", "certs/key-worker.pem", "path to SSL private key file")
 	rootCmd.PersistentFlags().Int("scheduler-maxparallel", 5, "scheduler max parallel option defines how many jobs can run in parallel")
+	rootCmd.PersistentFlags().Int("scheduler-capacity", 0, "total weight of jobs running in parallel, jobs weigh 1 unless set in build config (0 for maxparallel)")
 	rootCmd.PersistentFlags().String("auth-jwtsecret", lib.RandomString(), "JWT authentication secret key")
 	rootCmd.PersistentFlags().String("registry-addr", "https://registry-1.docker.io", "docker image registry server addr")
 	rootCmd.PersistentFlags().String("registry-username", "", "docker image registry username")
 	rootCmd.PersistentFlags().String("registry-password", "", "docker image registry password")
+	rootCmd.PersistentFlags().String("registry-authconfig", "", "path to docker config.json file with credentials for multiple registries")
+	rootCmd.PersistentFlags().Float64("resources-cpus", 0, "number of CPUs available to each build container (0 for unlimited)")
+	rootCmd.PersistentFlags().String("resources-memory", "", "memory limit of each build container, e.g. 512m or 2g (unlimited when empty)")
+	rootCmd.PersistentFlags().Int64("resources-pidslimit", 0, "maximum number of processes in each build container (0 for unlimited)")
+	rootCmd.PersistentFlags().String("images-default", "", "build image used when job does not specify image")
+	rootCmd.PersistentFlags().StringSlice("images-allow", []string{}, "patterns of build images allowed to run, e.g. golang,ghcr.io/org/ (all allowed when empty)")
+	rootCmd.PersistentFlags().StringSlice("images-deny", []string{}, "patterns of build images denied to run")
+	rootCmd.PersistentFlags().Bool("docker-cleanup", true, "remove orphaned build containers and networks on startup")
+	rootCmd.PersistentFlags().String("docker-runtime", "docker", "container runtime (available options: docker, podman)")
+	rootCmd.PersistentFlags().String("docker-host", "", "container runtime API socket path or URL (defaults to DOCKER_HOST or podman socket)")
+	rootCmd.PersistentFlags().Bool("docker-buildcache", false, "enable BuildKit with registry layer cache for docker build commands in builds")
+	rootCmd.PersistentFlags().String("docker-buildcache-ref", "", "registry reference BuildKit layer cache is imported from and exported to, e.g. registry.example.com/cache")
+	rootCmd.PersistentFlags().Bool("docker-allowhostnetwork", false, "allow builds to run containers in host network")
+	rootCmd.PersistentFlags().String("proxy-http", "", "proxy for server, git http requests and build containers (default is $HTTP_PROXY)")
+	rootCmd.PersistentFlags().String("proxy-https", "", "proxy for server, git https requests and build containers (default is $HTTPS_PROXY)")
+	rootCmd.PersistentFlags().String("proxy-noproxy", "", "comma separated hosts requested without proxy (default is $NO_PROXY)")
 	rootCmd.PersistentFlags().String("logger-level", "info", "logging level (available options: debug, info, warn, error, panic, fatal)")
 	rootCmd.PersistentFlags().Bool("logger-stdout", true, "print logs to stdout")
-	rootCmd.PersistentFlags().String("logger-filename", "abstruse-worker.log", "log filename")
+	rootCmd.PersistentFlags().String("logger-filename", "logs/abstruse-worker.log", "log filename")
 	rootCmd.PersistentFlags().Int("logger-max-size", 500, "maximum log file size (in MB)")
 	rootCmd.PersistentFlags().Int("logger-max-backups", 3, "maximum log file backups")
 	rootCmd.PersistentFlags().Int("logger-max-age", 3, "maximum log age")
+	rootCmd.PersistentFlags().String("logger-timeformat", "", "log timestamp format: rfc3339, rfc3339nano, iso8601, epoch, epochmillis or Go time layout (default is encoder default)")
 }
 
 func initDefaults() {
 	viper.BindPFlag("grpc.addr", rootCmd.PersistentFlags().Lookup("grpc-addr"))
+	viper.BindPFlag("grpc.maxrecvmsgsize", rootCmd.PersistentFlags().Lookup("grpc-maxrecvmsgsize"))
+	viper.BindPFlag("grpc.maxsendmsgsize", rootCmd.PersistentFlags().Lookup("grpc-maxsendmsgsize"))
+	viper.BindPFlag("grpc.keepalive.time", rootCmd.PersistentFlags().Lookup("grpc-keepalive-time"))
+	viper.BindPFlag("grpc.keepalive.timeout", rootCmd.PersistentFlags().Lookup("grpc-keepalive-timeout"))
 	viper.BindPFlag("id", rootCmd.PersistentFlags().Lookup("id"))
+	viper.BindPFlag("datadir", rootCmd.PersistentFlags().Lookup("datadir"))
+	viper.BindPFlag("labels", rootCmd.PersistentFlags().Lookup("labels"))
+	viper.BindPFlag("archs", rootCmd.PersistentFlags().Lookup("archs"))
 	viper.BindPFlag("server.addr", rootCmd.PersistentFlags().Lookup("server-addr"))
 	viper.BindPFlag("tls.cert", rootCmd.PersistentFlags().Lookup("tls-cert"))
 	viper.BindPFlag("tls.key", rootCmd.PersistentFlags().Lookup("tls-key"))
 	viper.BindPFlag("scheduler.maxparallel", rootCmd.PersistentFlags().Lookup("scheduler-maxparallel"))
+	viper.BindPFlag("scheduler.capacity", rootCmd.PersistentFlags().Lookup("scheduler-capacity"))
 	viper.BindPFlag("auth.jwtsecret", rootCmd.PersistentFlags().Lookup("auth-jwtsecret"))
 	viper.BindPFlag("registry.addr", rootCmd.PersistentFlags().Lookup("registry-addr"))
 	viper.BindPFlag("registry.username", rootCmd.PersistentFlags().Lookup("registry-username"))
 	viper.BindPFlag("registry.password", rootCmd.PersistentFlags().Lookup("registry-password"))
+	viper.BindPFlag("registry.authconfig", rootCmd.PersistentFlags().Lookup("registry-authconfig"))
+	viper.BindPFlag("resources.cpus", rootCmd.PersistentFlags().Lookup("resources-cpus"))
+	viper.BindPFlag("resources.memory", rootCmd.PersistentFlags().Lookup("resources-memory"))
+	viper.BindPFlag("resources.pidslimit", rootCmd.PersistentFlags().Lookup("resources-pidslimit"))
+	viper.BindPFlag("images.default", rootCmd.PersistentFlags().Lookup("images-default"))
+	viper.BindPFlag("images.allow", rootCmd.PersistentFlags().Lookup("images-allow"))
+	viper.BindPFlag("images.deny", rootCmd.PersistentFlags().Lookup("images-deny"))
+	viper.BindPFlag("docker.cleanup", rootCmd.PersistentFlags().Lookup("docker-cleanup"))
+	viper.BindPFlag("docker.runtime", rootCmd.PersistentFlags().Lookup("docker-runtime"))
+	viper.BindPFlag("docker.host", rootCmd.PersistentFlags().Lookup("docker-host"))
+	viper.BindPFlag("docker.buildcache", rootCmd.PersistentFlags().Lookup("docker-buildcache"))
+	viper.BindPFlag("docker.buildcacheref", rootCmd.PersistentFlags().Lookup("docker-buildcache-ref"))
+	viper.BindPFlag("docker.allowhostnetwork", rootCmd.PersistentFlags().Lookup("docker-allowhostnetwork"))
+	viper.BindPFlag("proxy.http", rootCmd.PersistentFlags().Lookup("proxy-http"))
+	viper.BindPFlag("proxy.https", rootCmd.PersistentFlags().Lookup("proxy-https"))
+	viper.BindPFlag("proxy.noproxy", rootCmd.PersistentFlags().Lookup("proxy-noproxy"))
 	viper.BindPFlag("logger.level", rootCmd.PersistentFlags().Lookup("logger-level"))
 	viper.BindPFlag("logger.stdout", rootCmd.PersistentFlags().Lookup("logger-stdout"))
 	viper.BindPFlag("logger.filename", rootCmd.PersistentFlags().Lookup("logger-filename"))
 	viper.BindPFlag("logger.maxsize", rootCmd.PersistentFlags().Lookup("logger-max-size"))
 	viper.BindPFlag("logger.maxbackups", rootCmd.PersistentFlags().Lookup("logger-max-backups"))
 	viper.BindPFlag("logger.maxage", rootCmd.PersistentFlags().Lookup("logger-max-age"))
+	viper.BindPFlag("logger.timeformat", rootCmd.PersistentFlags().Lookup("logger-timeformat"))
 }
 
-func newConfig() *config.Config {
+func newConfig() (*config.Config, error) {
 	var cfg *config.Config
 
 	if cfgFile == "" {
 		home, err := homedir.Dir()
 		if err != nil {
-			fatal(err)
+			return nil, err
 		}
-		cfgFile = filepath.Join(home, "abstruse", "abstruse-worker.json")
+		cfgFile = configfile.Default(filepath.Join(home, "abstruse"), "abstruse-worker")
 	}
 
 	viper.SetConfigFile(cfgFile)
-	viper.SetConfigType("json")
+	viper.SetConfigType(configfile.Format(cfgFile))
 	viper.SetEnvPrefix("abstruse")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
 
 	cfgFileUsed := viper.ConfigFileUsed()
 
-	if !fs.Exists(cfgFileUsed) {
-		if !fs.Exists(filepath.Dir(cfgFileUsed)) {
-			if err := fs.MakeDir(filepath.Dir(cfgFileUsed)); err != nil {
-				fatal(err)
-			}
-		}
+	if !cfgNoWrite {
+		cfgNoWrite, _ = strconv.ParseBool(os.Getenv("ABSTRUSE_NO_WRITE_CONFIG"))
+	}
 
-		if err := viper.SafeWriteConfigAs(cfgFileUsed); err != nil {
-			fatal(err)
+	if !cfgNoWrite {
+		if err := configfile.Create(cfgFileUsed, viper.AllSettings()); err != nil {
+			return nil, err
 		}
 	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		fatal(err)
+	data, err := configfile.Read(cfgFileUsed)
+	if err != nil && !(cfgNoWrite && errors.Is(err, configfile.ErrNotExist)) {
+		return nil, err
 	}
 
-	if err := viper.Unmarshal(&cfg); err != nil {
-		fatal(err)
+	if data != nil {
+		if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+			return nil, &configfile.FileError{Path: cfgFileUsed, Err: configfile.ErrMalformed, Cause: err}
+		}
 	}
 
-	if !strings.HasPrefix(cfg.Logger.Filename, "/") {
-		cfg.Logger.Filename = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), cfg.Logger.Filename)
+	if err := viper.Unmarshal(&cfg, configfile.DecodeHook(nil)); err != nil {
+		return nil, err
 	}
 
-	if !strings.HasPrefix(cfg.TLS.Cert, "/") {
-		cfg.TLS.Cert = filepath.Join(filepath.Dir(cfgFileUsed), cfg.TLS.Cert)
+	cfg.DataDir = configfile.DataDir(cfgFileUsed, cfg.DataDir)
+	cfg.Logger.Filename = configfile.Resolve(cfg.DataDir, cfg.Logger.Filename)
+	cfg.TLS.Cert = configfile.Resolve(cfg.DataDir, cfg.TLS.Cert)
+	cfg.TLS.Key = configfile.Resolve(cfg.DataDir, cfg.TLS.Key)
+
+	if err := auth.Init(viper.GetString("auth.jwtsecret")); err != nil {
+		return nil, err
 	}
 
-	if !strings.HasPrefix(cfg.TLS.Key, "/") {
-		cfg.TLS.Key = filepath.Join(filepath.Dir(cfgFileUsed), cfg.TLS.Key)
+	if err := tlsutil.CheckAndGenerateCert(cfg.TLS.Cert, cfg.TLS.Key); err != nil {
+		return nil, err
 	}
 
-	auth.Init(viper.GetString("auth.jwtsecret"))
+	if err := docker.Init(cfg.Registry, cfg.Docker); err != nil {
+		return nil, err
+	}
 
-	cert, key := cfg.TLS.Cert, cfg.TLS.Key
-	if !strings.HasPrefix(cert, "/") {
-		cert = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), cert)
+	if len(cfg.Archs) == 0 {
+		cfg.Archs = []string{docker.Arch()}
 	}
-	if !strings.HasPrefix(key, "/") {
-		key = filepath.Join(filepath.Dir(viper.ConfigFileUsed()), key)
+	for i, arch := range cfg.Archs {
+		cfg.Archs[i] = lib.NormalizeArch(arch)
 	}
 
-	if err := tlsutil.CheckAndGenerateCert(cert, key); err != nil {
-		fatal(err)
+	if _, err := docker.NewResources(cfg.Resources, nil); err != nil {
+		return nil, err
 	}
 
-	docker.Init(cfg.Registry)
+	if err := cfg.Images.Policy().Validate(); err != nil {
+		return nil, err
+	}
 
-	return cfg
-}
+	git.SetTransport(cfg.Proxy.Transport())
 
-func fatal(msg interface{}) {
-	fmt.Println(msg)
-	os.Exit(1)
+	return cfg, nil
 }
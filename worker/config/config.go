@@ -1,16 +1,30 @@
 package config
 
+import (
+	"time"
+
+	"github.com/bleenco/abstruse/pkg/imagepolicy"
+	"github.com/bleenco/abstruse/pkg/proxy"
+)
+
 type (
 	// Config holds data about worker configuration.
 	Config struct {
-		ID        string     `json:"id"`
-		Server    *Server    `json:"server"`
-		TLS       *TLS       `json:"tls"`
-		GRPC      *GRPC      `json:"grpc"`
-		Scheduler *Scheduler `json:"scheduler"`
-		Auth      *Auth      `json:"auth"`
-		Registry  *Registry  `json:"registry"`
-		Logger    *Logger    `json:"logger"`
+		ID        string            `json:"id"`
+		DataDir   string            `json:"datadir"` // root of relative paths, defaults to config file directory
+		Labels    map[string]string `json:"labels"`
+		Archs     []string          `json:"archs"` // detected from container runtime when empty
+		Server    *Server           `json:"server"`
+		TLS       *TLS              `json:"tls"`
+		GRPC      *GRPC             `json:"grpc"`
+		Scheduler *Scheduler        `json:"scheduler"`
+		Auth      *Auth             `json:"auth"`
+		Registry  *Registry         `json:"registry"`
+		Resources *Resources        `json:"resources"`
+		Images    *Images           `json:"images"`
+		Docker    *Docker           `json:"docker"`
+		Proxy     *Proxy            `json:"proxy"`
+		Logger    *Logger           `json:"logger"`
 	}
 
 	// Server configuration.
@@ -26,12 +40,22 @@ type (
 
 	// GRPC configuration.
 	GRPC struct {
-		Addr string `json:"addr"`
+		Addr           string     `json:"addr"`
+		MaxRecvMsgSize int        `json:"maxrecvmsgsize"`
+		MaxSendMsgSize int        `json:"maxsendmsgsize"`
+		Keepalive      *Keepalive `json:"keepalive"`
+	}
+
+	// Keepalive defines gRPC keepalive pings, 0 time disables pings.
+	Keepalive struct {
+		Time    time.Duration `json:"time"`
+		Timeout time.Duration `json:"timeout"`
 	}
 
 	// Scheduler configuration.
 	Scheduler struct {
 		MaxParallel int `json:"maxparallel"`
+		Capacity    int `json:"capacity"` // total weight of running jobs, 0 for maxparallel
 	}
 
 	// Auth authentication config.
@@ -41,11 +65,43 @@ type (
 
 	// Registry docker image registry configuration.
 	Registry struct {
-		Addr     string `json:"addr"`
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Addr       string `json:"addr"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+		AuthConfig string `json:"authconfig"`
+	}
+
+	// Resources build container resource limits configuration.
+	Resources struct {
+		CPUs      float64 `json:"cpus"`
+		Memory    string  `json:"memory"`
+		PidsLimit int64   `json:"pidslimit"`
 	}
 
+	// Images build image policy configuration, patterns are described
+	// in imagepolicy package.
+	Images struct {
+		Default string   `json:"default"` // used when job does not specify image
+		Allow   []string `json:"allow"`   // all images allowed when empty
+		Deny    []string `json:"deny"`
+	}
+
+	// Docker container runtime configuration.
+	Docker struct {
+		Runtime string `json:"runtime"`
+		Host    string `json:"host"`
+		Cleanup bool   `json:"cleanup"`
+
+		BuildCache    bool   `json:"buildcache"`
+		BuildCacheRef string `json:"buildcacheref"`
+
+		AllowHostNetwork bool `json:"allowhostnetwork"` // jobs can run in host network
+	}
+
+	// Proxy of outbound HTTP requests and build containers, values
+	// not set are read from environment.
+	Proxy = proxy.Config
+
 	// Logger config.
 	Logger struct {
 		Filename   string `json:"filename"`
@@ -54,5 +110,15 @@ type (
 		MaxAge     int    `json:"maxage"`
 		Level      string `json:"level"`
 		Stdout     bool   `json:"stdout"`
+		TimeFormat string `json:"timeformat"` // see lib.TimeEncoder
 	}
 )
+
+// Policy returns image policy, all images are allowed when images
+// config is not set.
+func (i *Images) Policy() imagepolicy.Policy {
+	if i == nil {
+		return imagepolicy.Policy{}
+	}
+	return imagepolicy.Policy{Default: i.Default, Allow: i.Allow, Deny: i.Deny}
+}
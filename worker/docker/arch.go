@@ -0,0 +1,19 @@
+package docker
+
+import (
+	"context"
+	goruntime "runtime"
+
+	"github.com/bleenco/abstruse/pkg/lib"
+)
+
+// Arch returns architecture of the container runtime host, architecture
+// of the worker when runtime is not reachable.
+func Arch() string {
+	if cli, err := newClient(); err == nil {
+		if info, err := cli.Info(context.Background()); err == nil && info.Architecture != "" {
+			return lib.NormalizeArch(info.Architecture)
+		}
+	}
+	return goruntime.GOARCH
+}
@@ -0,0 +1,148 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+)
+
+// defaultRegistry is the hostname used for images without registry prefix.
+const defaultRegistry = "docker.io"
+
+// dockerConfig is the subset of Docker's config.json holding registry credentials.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// RegistryAuth holds docker registry credentials keyed by registry hostname.
+// Encoded credentials are cached so they are computed only once per host.
+type RegistryAuth struct {
+	mu    sync.Mutex
+	creds map[string]types.AuthConfig
+	cache map[string]string
+}
+
+// NewRegistryAuth returns new empty RegistryAuth.
+func NewRegistryAuth() *RegistryAuth {
+	return &RegistryAuth{
+		creds: make(map[string]types.AuthConfig),
+		cache: make(map[string]string),
+	}
+}
+
+// Add sets credentials for registry host.
+func (r *RegistryAuth) Add(host, username, password string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = normalizeRegistry(host)
+	r.creds[host] = types.AuthConfig{Username: username, Password: password, ServerAddress: host}
+	delete(r.cache, host)
+}
+
+// Load adds credentials from data in Docker's config.json format.
+func (r *RegistryAuth) Load(data []byte) error {
+	var config dockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("invalid registry auth config")
+	}
+	for host, auth := range config.Auths {
+		username, password := auth.Username, auth.Password
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return fmt.Errorf("invalid auth for registry %s", host)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid auth for registry %s", host)
+			}
+			username, password = parts[0], parts[1]
+		}
+		r.Add(host, username, password)
+	}
+	return nil
+}
+
+// LoadFile adds credentials from Docker config.json file.
+func (r *RegistryAuth) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return r.Load(data)
+}
+
+// Merge returns new RegistryAuth containing credentials from r and other,
+// where credentials from other take precedence.
+func (r *RegistryAuth) Merge(other *RegistryAuth) *RegistryAuth {
+	merged := NewRegistryAuth()
+	for _, auth := range []*RegistryAuth{r, other} {
+		if auth == nil {
+			continue
+		}
+		auth.mu.Lock()
+		for host, creds := range auth.creds {
+			merged.creds[host] = creds
+		}
+		auth.mu.Unlock()
+	}
+	return merged
+}
+
+// Encoded returns base64 encoded credentials for registry of the image
+// or empty string if no credentials are configured for that registry.
+func (r *RegistryAuth) Encoded(image string) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host := registryHost(image)
+	if auth, ok := r.cache[host]; ok {
+		return auth
+	}
+	creds, ok := r.creds[host]
+	if !ok {
+		return ""
+	}
+	authJSON, _ := json.Marshal(creds)
+	auth := base64.URLEncoding.EncodeToString(authJSON)
+	r.cache[host] = auth
+	return auth
+}
+
+// registryHost returns registry hostname of the image reference.
+func registryHost(image string) string {
+	i := strings.Index(image, "/")
+	if i == -1 {
+		return defaultRegistry
+	}
+	host := image[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return defaultRegistry
+	}
+	return normalizeRegistry(host)
+}
+
+// normalizeRegistry strips scheme and path from registry address and maps
+// Docker Hub aliases to defaultRegistry.
+func normalizeRegistry(addr string) string {
+	addr = strings.TrimPrefix(strings.TrimPrefix(addr, "https://"), "http://")
+	if i := strings.Index(addr, "/"); i != -1 {
+		addr = addr[:i]
+	}
+	switch addr {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return defaultRegistry
+	}
+	return addr
+}
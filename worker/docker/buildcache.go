@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bleenco/abstruse/worker/config"
+)
+
+// buildCacheRef is registry reference BuildKit layer cache is imported
+// from and exported to, build cache is disabled when empty.
+var buildCacheRef string
+
+// buildCommand matches docker image build commands and captures
+// arguments following the build subcommand.
+var buildCommand = regexp.MustCompile(`^\s*docker\s+(?:buildx\s+build|image\s+build|build)(\s.*)?$`)
+
+// invalidTagChars matches characters not allowed in image tag.
+var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+func initBuildCache(config *config.Docker) error {
+	buildCacheRef = ""
+	if config == nil || !config.BuildCache {
+		return nil
+	}
+	if runtime != RuntimeDocker {
+		return fmt.Errorf("build cache is supported only with %s runtime", RuntimeDocker)
+	}
+	if config.BuildCacheRef == "" {
+		return fmt.Errorf("build cache enabled but cache registry reference is not set")
+	}
+	buildCacheRef = config.BuildCacheRef
+	return nil
+}
+
+// BuildCacheEnv returns environment variables enabling BuildKit in build
+// container when build cache is enabled.
+func BuildCacheEnv() []string {
+	if buildCacheRef == "" {
+		return nil
+	}
+	return []string{"DOCKER_BUILDKIT=1"}
+}
+
+// WithBuildCache rewrites docker build command to BuildKit build importing
+// and exporting layer cache from the cache registry. Cache reference
+// without tag is tagged with repository name so repositories do not
+// share cache. Other commands are returned unchanged.
+func WithBuildCache(command, repo string) string {
+	if buildCacheRef == "" {
+		return command
+	}
+	match := buildCommand.FindStringSubmatch(command)
+	if match == nil {
+		return command
+	}
+	args := match[1]
+	ref := cacheRef(buildCacheRef, repo)
+
+	flags := []string{
+		fmt.Sprintf("--cache-from type=registry,ref=%s", ref),
+		fmt.Sprintf("--cache-to type=registry,ref=%s,mode=max", ref),
+	}
+	if !strings.Contains(args, "--load") && !strings.Contains(args, "--push") && !strings.Contains(args, "--output") {
+		flags = append(flags, "--load")
+	}
+	return fmt.Sprintf("docker buildx build %s%s", strings.Join(flags, " "), args)
+}
+
+// cacheRef returns cache reference for repository.
+func cacheRef(ref, repo string) string {
+	name := ref[strings.LastIndex(ref, "/")+1:]
+	if strings.Contains(name, ":") || strings.Contains(name, "@") || repo == "" {
+		return ref
+	}
+	tag := strings.Trim(invalidTagChars.ReplaceAllString(repo, "-"), "-.")
+	if tag == "" {
+		return ref
+	}
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return fmt.Sprintf("%s:%s", ref, tag)
+}
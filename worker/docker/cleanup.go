@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Labels set on containers and networks created by the worker.
+const (
+	LabelPrefix = "io.abstruse."
+	LabelWorker = LabelPrefix + "worker"
+	LabelBuild  = LabelPrefix + "build"
+	LabelJob    = LabelPrefix + "job"
+)
+
+// Labels returns labels identifying build container of the job
+// running on the worker.
+func Labels(workerID string, buildID, jobID uint64) map[string]string {
+	return map[string]string{
+		LabelWorker: workerID,
+		LabelBuild:  fmt.Sprintf("%d", buildID),
+		LabelJob:    fmt.Sprintf("%d", jobID),
+	}
+}
+
+// Cleanup removes build containers and networks created by the worker that
+// are not tracked anymore, e.g. after the worker was not shut down gracefully.
+// Only resources labeled with workerID are removed.
+func Cleanup(workerID string) (containers int, err error) {
+	ctx := context.Background()
+	cli, err := newClient()
+	if err != nil {
+		return 0, err
+	}
+
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", LabelWorker, workerID))
+
+	list, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range list {
+		if _, ok := c.Labels[LabelBuild]; !ok {
+			continue
+		}
+		if err := removeContainer(cli, c.ID, true); err != nil {
+			return containers, err
+		}
+		containers++
+	}
+
+	// networks created for services of jobs.
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+	if err != nil {
+		return containers, err
+	}
+	for _, n := range networks {
+		if _, ok := n.Labels[LabelBuild]; !ok {
+			continue
+		}
+		if err := cli.NetworkRemove(ctx, n.ID); err != nil {
+			return containers, err
+		}
+	}
+
+	return containers, nil
+}
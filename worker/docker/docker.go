@@ -4,90 +4,136 @@ import (
 	"context"
 	"fmt"
 	"path"
-	"strings"
+	"time"
 
 	"github.com/bleenco/abstruse/pkg/lib"
+	"github.com/bleenco/abstruse/pkg/step"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 )
 
-// RunContainer runs container.
-func RunContainer(name, image string, commands [][]string, env []string, dir string, logch chan<- []byte) error {
+// StepFunc is called with start and end time of each command, skipped
+// commands are reported with zero duration.
+type StepFunc func(name string, start, end time.Time, skipped bool)
+
+// RunContainer runs container with specified labels and resource limits.
+// Commands after failed command run only when their condition allows it,
+// job fails with exit code of the first failed command. Env of command
+// is set on top of container env.
+// Container is attached to the network, host entries are added to its
+// /etc/hosts.
+// ErrOutOfMemory is returned when container exceeded memory limit.
+// Resource usage of the container is sampled while commands run.
+func RunContainer(name, image string, commands []step.Step, env []string, dir string, labels map[string]string, resources Resources, network Network, logch chan<- []byte, step StepFunc) (usage Usage, err error) {
 	ctx := context.Background()
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
-		return err
+		return usage, err
 	}
 	defer close(logch)
 
-	resp, err := createContainer(cli, name, image, dir, []string{"/bin/bash"}, env)
+	resp, err := createContainer(cli, name, image, dir, []string{"/bin/bash"}, env, labels, resources, network)
 	if err != nil {
 		logch <- []byte(err.Error())
-		return err
+		return usage, err
 	}
 	if !isContainerRunning(cli, resp.ID) {
 		if err := startContainer(cli, resp.ID); err != nil {
-			resp, err = createContainer(cli, name, image, dir, []string{"/bin/sh"}, env)
+			resp, err = createContainer(cli, name, image, dir, []string{"/bin/sh"}, env, labels, resources, network)
 			if err != nil {
 				logch <- []byte(err.Error())
-				return err
+				return usage, err
 			}
 		}
 	}
 	defer cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{Force: true})
 
+	sampler := sampleUsage(cli, resp.ID)
+	defer func() { usage = sampler.stop() }()
+
 	logch <- []byte(yellow(fmt.Sprintf("==> Starting build...\r\n")))
 
 	exitCode := 0
 	containerID := resp.ID
 
 	for _, command := range commands {
-		if !isContainerRunning(cli, containerID) {
-			if err := startContainer(cli, containerID); err != nil {
-				logch <- []byte(err.Error())
-				return err
+		cmd := command.Run
+		if !command.Runs(exitCode != 0) {
+			logch <- []byte(yellow("\r==> " + cmd + " (skipped)\n\r"))
+			if step != nil {
+				now := time.Now()
+				step(cmd, now, now, true)
 			}
+			continue
 		}
-		str := yellow("\r==> " + strings.Join(command, " ") + "\n\r")
+		str := yellow("\r==> " + cmd + "\n\r")
 		logch <- []byte(str)
-		command = []string{"bash", "-ci", strings.Join(command, " ")}
-		conn, execID, err := exec(cli, containerID, command, env)
-		if err != nil {
-			logch <- []byte(err.Error())
-			return err
-		}
-		for {
-			buf := make([]byte, 4096)
-			n, err := conn.Reader.Read(buf)
-			if err != nil {
-				conn.Close()
-				break
-			}
-			logch <- buf[:n]
+		start := time.Now()
+		cmdEnv := append(append([]string(nil), env...), command.Env...)
+		code, err := runCommand(cli, containerID, command.Command(), cmdEnv, logch)
+		for attempt := 1; err == nil && code != 0 && attempt <= command.Retries; attempt++ {
+			logch <- []byte(yellow(fmt.Sprintf("\r==> %s failed with exit code %d, retrying %d/%d\n\r", cmd, code, attempt, command.Retries)))
+			time.Sleep(command.Wait(attempt))
+			code, err = runCommand(cli, containerID, command.Command(), cmdEnv, logch)
 		}
-		inspect, err := cli.ContainerExecInspect(ctx, execID)
 		if err != nil {
 			logch <- []byte(err.Error())
-			return err
+			return usage, err
 		}
-		exitCode = inspect.ExitCode
-		if exitCode != 0 {
-			break
+		if step != nil {
+			step(cmd, start, time.Now(), false)
+		}
+		if exitCode == 0 {
+			exitCode = code
 		}
 	}
 
+	if exitCode != 0 && isOOMKilled(cli, containerID, exitCode, resources) {
+		logch <- []byte(red("\r\n==> Container exceeded memory limit (out of memory)\r\n"))
+		return usage, ErrOutOfMemory
+	}
+
 	logch <- []byte(genExitMessage(exitCode))
 	if exitCode == 0 {
-		return nil
+		return usage, nil
+	}
+	return usage, fmt.Errorf("errored: %d", exitCode)
+}
+
+// runCommand runs command in the container, started when it is not
+// running, and streams its output to log channel. Returns exit code of
+// the command.
+func runCommand(cli *client.Client, id string, cmd, env []string, logch chan<- []byte) (int, error) {
+	if !isContainerRunning(cli, id) {
+		if err := startContainer(cli, id); err != nil {
+			return 0, err
+		}
+	}
+	conn, execID, err := exec(cli, id, cmd, env)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		buf := make([]byte, 4096)
+		n, err := conn.Reader.Read(buf)
+		if err != nil {
+			conn.Close()
+			break
+		}
+		logch <- buf[:n]
 	}
-	return fmt.Errorf("errored: %d", exitCode)
+	inspect, err := cli.ContainerExecInspect(context.Background(), execID)
+	if err != nil {
+		return 0, err
+	}
+	return inspect.ExitCode, nil
 }
 
 // StopContainer stops the container.
 func StopContainer(name string) error {
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -129,7 +175,7 @@ func exec(cli *client.Client, id string, cmd, env []string) (types.HijackedRespo
 
 // ContainerExists finds container by name and if exists returns id.
 func ContainerExists(name string) (string, bool) {
-	cli, _ := client.NewEnvClient()
+	cli, _ := newClient()
 
 	containers, err := cli.ContainerList(context.Background(), types.ContainerListOptions{All: true})
 	if err != nil {
@@ -159,7 +205,7 @@ func startContainer(cli *client.Client, id string) error {
 }
 
 // CreateContainer creates new Docker container.
-func createContainer(cli *client.Client, name, image, dir string, cmd []string, env []string) (container.ContainerCreateCreatedBody, error) {
+func createContainer(cli *client.Client, name, image, dir string, cmd []string, env []string, labels map[string]string, resources Resources, network Network) (container.ContainerCreateCreatedBody, error) {
 	if id, exists := ContainerExists(name); exists {
 		if err := cli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true}); err != nil {
 			return container.ContainerCreateCreatedBody{}, err
@@ -167,8 +213,14 @@ func createContainer(cli *client.Client, name, image, dir string, cmd []string,
 	}
 
 	mounts := []mount.Mount{
-		{Type: mount.TypeBind, Source: path.Join(dir), Target: "/build"},
-		{Type: mount.TypeBind, Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"},
+		{Type: mount.TypeBind, Source: path.Join(dir), Target: step.Workspace},
+	}
+	if socket := socketPath(); socket != "" {
+		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: socket, Target: dockerSocket})
+	}
+
+	if runtime == RuntimePodman {
+		image = qualifiedImage(image)
 	}
 
 	return cli.ContainerCreate(context.Background(), &container.Config{
@@ -176,12 +228,25 @@ func createContainer(cli *client.Client, name, image, dir string, cmd []string,
 		Cmd:        cmd,
 		Tty:        true,
 		Env:        env,
-		WorkingDir: "/build",
+		WorkingDir: step.Workspace,
+		Labels:     labels,
 	}, &container.HostConfig{
-		Mounts: mounts,
+		Mounts:      mounts,
+		NetworkMode: network.networkMode(),
+		ExtraHosts:  network.ExtraHosts,
+		Resources:   resources.hostConfig(),
 	}, nil, name)
 }
 
+// isOOMKilled returns true if container or its process was killed because
+// of exceeding memory limit.
+func isOOMKilled(cli *client.Client, id string, exitCode int, resources Resources) bool {
+	if data, err := inspectContainer(cli, id); err == nil && data.State != nil && data.State.OOMKilled {
+		return true
+	}
+	return resources.Memory > 0 && exitCode == oomExitCode
+}
+
 // IsContainerRunning returns true if container is running.
 func isContainerRunning(cli *client.Client, id string) bool {
 	containers, err := listRunningContainers(cli)
@@ -15,7 +15,6 @@ import (
 
 	"github.com/bleenco/abstruse/worker/config"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
 )
 
@@ -27,7 +26,7 @@ type ImageBuildOutput struct {
 // BuildImage builds the docker image.
 func BuildImage(tags []string, dockerFile string) (types.ImageBuildResponse, error) {
 	ctx := context.Background()
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
@@ -71,7 +70,7 @@ func BuildImage(tags []string, dockerFile string) (types.ImageBuildResponse, err
 // PushImage pushes image to the registry.
 func PushImage(tag string) (io.ReadCloser, error) {
 	ctx := context.Background()
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
@@ -84,26 +83,19 @@ func PushImage(tag string) (io.ReadCloser, error) {
 	return cli.ImagePush(ctx, tag, types.ImagePushOptions{RegistryAuth: auth})
 }
 
-// PullImage pulls image from the registry.
-func PullImage(image string, config *config.Registry) error {
+// PullImage pulls image from the registry using credentials from auth
+// matching the image registry host.
+func PullImage(image string, config *config.Registry, auth *RegistryAuth) error {
 	ctx := context.Background()
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
 
-	opts := types.ImagePullOptions{}
-
-	if cfg.Username != "" && cfg.Password != "" {
-		authConfig := types.AuthConfig{Username: cfg.Username, Password: cfg.Password}
-		authJSON, _ := json.Marshal(authConfig)
-		opts.RegistryAuth = base64.URLEncoding.EncodeToString(authJSON)
-	}
-
 	if config.Addr != "" && !strings.Contains(config.Addr, "docker.io") {
-		pimage := fmt.Sprintf("%s/%s", config.Addr, image)
+		pimage := fmt.Sprintf("%s/%s", normalizeRegistry(config.Addr), image)
 
-		out, err := cli.ImagePull(ctx, pimage, opts)
+		out, err := cli.ImagePull(ctx, pimage, types.ImagePullOptions{RegistryAuth: auth.Encoded(pimage)})
 		if err == nil {
 			defer out.Close()
 			ioutil.ReadAll(out)
@@ -111,26 +103,39 @@ func PullImage(image string, config *config.Registry) error {
 		}
 	}
 
-	if !strings.Contains(image, "/") && !strings.HasPrefix(image, "docker.io") {
-		image = fmt.Sprintf("docker.io/library/%s", image)
-	} else if strings.Contains(image, "/") && !strings.HasPrefix(image, "docker.io") {
-		image = fmt.Sprintf("docker.io/%s", image)
+	image = qualifiedImage(image)
+	out, err := cli.ImagePull(ctx, image, types.ImagePullOptions{RegistryAuth: auth.Encoded(image)})
+	if err != nil {
+		return err
 	}
+	defer out.Close()
+	_, err = ioutil.ReadAll(out)
+	return err
+}
 
-	out, err := cli.ImagePull(ctx, image, opts)
-	if err == nil {
-		defer out.Close()
-		if _, rerr := ioutil.ReadAll(out); rerr != nil {
-			return rerr
-		}
+// ImageDigest returns repository digest of the local image, or image ID
+// when image has no repository digest (e.g. it was built locally).
+func ImageDigest(image string) (string, error) {
+	cli, err := newClient()
+	if err != nil {
+		return "", err
 	}
-
-	return nil
+	if runtime == RuntimePodman {
+		image = qualifiedImage(image)
+	}
+	inspect, _, err := cli.ImageInspectWithRaw(context.Background(), image)
+	if err != nil {
+		return "", err
+	}
+	if len(inspect.RepoDigests) > 0 {
+		return inspect.RepoDigests[0], nil
+	}
+	return inspect.ID, nil
 }
 
 // ListImages returns all images.
 func ListImages() []types.ImageSummary {
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
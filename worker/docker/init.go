@@ -3,10 +3,32 @@ package docker
 import "github.com/bleenco/abstruse/worker/config"
 
 var (
-	cfg *config.Registry
+	cfg   *config.Registry
+	auths *RegistryAuth
 )
 
 // Init initializes global variables
-func Init(config *config.Registry) {
+func Init(config *config.Registry, runtimeConfig *config.Docker) error {
+	if err := initRuntime(runtimeConfig); err != nil {
+		return err
+	}
+	if err := initBuildCache(runtimeConfig); err != nil {
+		return err
+	}
+	initNetwork(runtimeConfig)
 	cfg = config
+	auths = NewRegistryAuth()
+	if cfg.Username != "" && cfg.Password != "" {
+		auths.Add(cfg.Addr, cfg.Username, cfg.Password)
+	}
+	if cfg.AuthConfig != "" {
+		return auths.LoadFile(cfg.AuthConfig)
+	}
+	return nil
+}
+
+// Auths returns registry credentials configured on the worker merged with
+// credentials from job, which take precedence.
+func Auths(job *RegistryAuth) *RegistryAuth {
+	return auths.Merge(job)
 }
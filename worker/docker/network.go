@@ -0,0 +1,47 @@
+package docker
+
+import (
+	"fmt"
+
+	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/worker/config"
+	"github.com/docker/docker/api/types/container"
+)
+
+// allowHostNetwork allows jobs to run containers in host network.
+var allowHostNetwork bool
+
+// Network defines network of build container, default network of the
+// runtime is used when mode is empty.
+type Network struct {
+	Mode       string
+	ExtraHosts []string // host:ip entries added to /etc/hosts
+}
+
+func initNetwork(config *config.Docker) {
+	if config != nil {
+		allowHostNetwork = config.AllowHostNetwork
+	}
+}
+
+// NewNetwork returns network of build container set on the job, host
+// network is used only when allowed on the worker.
+func NewNetwork(job *pb.NetworkOptions) (Network, error) {
+	n := Network{Mode: job.GetMode(), ExtraHosts: job.GetExtraHosts()}
+	if container.NetworkMode(n.Mode).IsHost() && !allowHostNetwork {
+		return n, fmt.Errorf("host network is not allowed on this worker")
+	}
+	return n, nil
+}
+
+// String returns network mode.
+func (n Network) String() string {
+	if n.Mode == "" {
+		return defaultNetwork()
+	}
+	return n.Mode
+}
+
+func (n Network) networkMode() container.NetworkMode {
+	return container.NetworkMode(n.String())
+}
@@ -0,0 +1,80 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/bleenco/abstruse/pb"
+	"github.com/bleenco/abstruse/worker/config"
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+)
+
+// ErrOutOfMemory is returned when build container exceeded its memory limit.
+var ErrOutOfMemory = errors.New("out of memory")
+
+// oomExitCode is exit code of process killed with SIGKILL.
+const oomExitCode = 137
+
+// Resources defines build container resource limits. Zero values mean no limit.
+type Resources struct {
+	CPUs      float64
+	Memory    int64
+	PidsLimit int64
+}
+
+// NewResources returns resource limits from worker configuration overridden
+// with limits set on the job.
+func NewResources(config *config.Resources, job *pb.Resources) (Resources, error) {
+	var res Resources
+	if config != nil {
+		res.CPUs, res.PidsLimit = config.CPUs, config.PidsLimit
+		if config.Memory != "" {
+			memory, err := units.RAMInBytes(config.Memory)
+			if err != nil {
+				return res, fmt.Errorf("invalid memory limit %s", config.Memory)
+			}
+			res.Memory = memory
+		}
+	}
+	if job.GetCpus() > 0 {
+		res.CPUs = job.GetCpus()
+	}
+	if job.GetMemory() > 0 {
+		res.Memory = job.GetMemory()
+	}
+	if job.GetPidsLimit() > 0 {
+		res.PidsLimit = job.GetPidsLimit()
+	}
+	if res.CPUs < 0 || res.Memory < 0 || res.PidsLimit < 0 {
+		return res, fmt.Errorf("invalid resource limits")
+	}
+	return res, nil
+}
+
+// String returns human readable resource limits.
+func (r Resources) String() string {
+	cpus, memory, pids := "unlimited", "unlimited", "unlimited"
+	if r.CPUs > 0 {
+		cpus = fmt.Sprintf("%g", r.CPUs)
+	}
+	if r.Memory > 0 {
+		memory = units.BytesSize(float64(r.Memory))
+	}
+	if r.PidsLimit > 0 {
+		pids = fmt.Sprintf("%d", r.PidsLimit)
+	}
+	return fmt.Sprintf("cpus: %s, memory: %s, pids: %s", cpus, memory, pids)
+}
+
+func (r Resources) hostConfig() container.Resources {
+	res := container.Resources{
+		NanoCPUs:  int64(r.CPUs * 1e9),
+		Memory:    r.Memory,
+		PidsLimit: r.PidsLimit,
+	}
+	if r.Memory > 0 {
+		res.MemorySwap = r.Memory // disable swap so limit is enforced
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bleenco/abstruse/worker/config"
+	"github.com/docker/docker/client"
+)
+
+// Supported container runtimes.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// dockerSocket is the default Docker daemon socket path.
+const dockerSocket = "/var/run/docker.sock"
+
+var (
+	runtime = RuntimeDocker
+	host    string
+)
+
+// initRuntime sets container runtime and daemon host used by the client.
+// When host is not set Docker runtime uses DOCKER_HOST environment and
+// Podman runtime uses its default rootful or rootless socket.
+func initRuntime(config *config.Docker) error {
+	if config == nil {
+		return nil
+	}
+	switch config.Runtime {
+	case "", RuntimeDocker:
+		runtime = RuntimeDocker
+	case RuntimePodman:
+		runtime = RuntimePodman
+	default:
+		return fmt.Errorf("unsupported container runtime %s", config.Runtime)
+	}
+	host = config.Host
+	if host != "" && !strings.Contains(host, "://") {
+		host = "unix://" + host
+	}
+	if host == "" && runtime == RuntimePodman {
+		host = podmanSocket()
+	}
+	return nil
+}
+
+// newClient returns client connected to the configured container runtime.
+func newClient() (*client.Client, error) {
+	if host == "" {
+		return client.NewEnvClient()
+	}
+	version := os.Getenv("DOCKER_API_VERSION")
+	if version == "" {
+		version = client.DefaultVersion
+	}
+	return client.NewClient(host, version, nil, nil)
+}
+
+// podmanSocket returns default Podman API socket, rootless socket is used
+// when worker is not running as root.
+func podmanSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Getuid() != 0 {
+		return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+	}
+	return "unix:///run/podman/podman.sock"
+}
+
+// socketPath returns path of the runtime socket on the host which is mounted
+// into build containers, or empty string when daemon is not reachable
+// through unix socket.
+func socketPath() string {
+	addr := host
+	if addr == "" {
+		addr = os.Getenv("DOCKER_HOST")
+	}
+	if addr == "" {
+		return dockerSocket
+	}
+	if strings.HasPrefix(addr, "unix://") {
+		return strings.TrimPrefix(addr, "unix://")
+	}
+	return ""
+}
+
+// defaultNetwork returns name of the default network build containers are
+// attached to, Podman names its default bridge network differently.
+func defaultNetwork() string {
+	if runtime == RuntimePodman {
+		return "podman"
+	}
+	return "bridge"
+}
+
+// qualifiedImage returns fully qualified image reference. Podman does not
+// resolve short names the same way Docker does, so images without registry
+// are expanded to Docker Hub references.
+func qualifiedImage(image string) string {
+	if registryHost(image) != defaultRegistry || strings.HasPrefix(image, "docker.io") {
+		return image
+	}
+	if !strings.Contains(image, "/") {
+		return fmt.Sprintf("docker.io/library/%s", image)
+	}
+	return fmt.Sprintf("docker.io/%s", image)
+}
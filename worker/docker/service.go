@@ -0,0 +1,215 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/bleenco/abstruse/pb"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+// serviceWaitTimeout is how long build waits for service to become healthy.
+const serviceWaitTimeout = 5 * time.Minute
+
+// Service defines service container started before build container.
+type Service struct {
+	Name        string
+	Image       string
+	Env         []string
+	Ports       []int
+	Healthcheck string
+	Wait        bool
+}
+
+// NewServices returns service containers of the job, env of services is
+// resolved with expand.
+func NewServices(job []*pb.ServiceOptions, expand func(string) string) []Service {
+	var services []Service
+	for _, svc := range job {
+		s := Service{
+			Name:        svc.GetName(),
+			Image:       svc.GetImage(),
+			Healthcheck: svc.GetHealthcheck(),
+			Wait:        svc.GetWait(),
+		}
+		for _, pair := range svc.GetEnv() {
+			if i := strings.Index(pair, "="); i > 0 {
+				s.Env = append(s.Env, pair[:i+1]+expand(pair[i+1:]))
+			}
+		}
+		for _, port := range svc.GetPorts() {
+			s.Ports = append(s.Ports, int(port))
+		}
+		services = append(services, s)
+	}
+	return services
+}
+
+// Services are running service containers of the job.
+type Services struct {
+	cli        *client.Client
+	network    string // created for the job, removed with services
+	containers []string
+}
+
+// StartServices starts service containers of the job before build
+// container. When job uses default network, network for the job is
+// created, so services are reachable by their names. Returns network
+// build container is attached to. Output of services is discarded,
+// last lines are written to the log when service fails to start.
+// Services which were started have to be stopped also on error.
+func StartServices(name string, services []Service, net Network, labels map[string]string, logch chan<- []byte) (*Services, Network, error) {
+	s := &Services{}
+	if len(services) == 0 {
+		return s, net, nil
+	}
+	ctx := context.Background()
+
+	cli, err := newClient()
+	if err != nil {
+		return s, net, err
+	}
+	s.cli = cli
+
+	switch net.Mode {
+	case "":
+		resp, err := cli.NetworkCreate(ctx, name, types.NetworkCreate{CheckDuplicate: true, Labels: labels})
+		if err != nil {
+			return s, net, err
+		}
+		s.network = resp.ID
+		net.Mode = name
+	case "bridge", "host", "none", defaultNetwork():
+		return s, net, fmt.Errorf("services require default or named network, not %s", net.Mode)
+	}
+
+	for _, svc := range services {
+		logch <- []byte(yellow(fmt.Sprintf("==> Starting service %s (%s)... ", svc.Name, svc.Image)))
+		id, err := s.start(name, svc, net, labels)
+		if err != nil {
+			logch <- []byte(red(err.Error() + "\r\n"))
+			return s, net, err
+		}
+		if !svc.Wait {
+			logch <- []byte(yellow("done\r\n"))
+			continue
+		}
+		logch <- []byte(yellow("waiting... "))
+		if err := waitHealthy(cli, id); err != nil {
+			logch <- []byte(red(err.Error() + "\r\n"))
+			logch <- serviceLogs(cli, id)
+			return s, net, fmt.Errorf("service %s: %v", svc.Name, err)
+		}
+		logch <- []byte(yellow("done\r\n"))
+	}
+
+	return s, net, nil
+}
+
+// Stop removes service containers and network created for the job.
+func (s *Services) Stop() {
+	if s == nil || s.cli == nil {
+		return
+	}
+	for _, id := range s.containers {
+		removeContainer(s.cli, id, true)
+	}
+	if s.network != "" {
+		s.cli.NetworkRemove(context.Background(), s.network)
+	}
+}
+
+func (s *Services) start(name string, svc Service, net Network, labels map[string]string) (string, error) {
+	ctx := context.Background()
+	containerName := fmt.Sprintf("%s-%s", name, svc.Name)
+	if id, exists := ContainerExists(containerName); exists {
+		if err := removeContainer(s.cli, id, true); err != nil {
+			return "", err
+		}
+	}
+
+	image := svc.Image
+	if runtime == RuntimePodman {
+		image = qualifiedImage(image)
+	}
+	config := &container.Config{
+		Image:    image,
+		Env:      svc.Env,
+		Labels:   labels,
+		Hostname: svc.Name,
+	}
+	if len(svc.Ports) > 0 {
+		config.ExposedPorts = make(nat.PortSet)
+		for _, port := range svc.Ports {
+			config.ExposedPorts[nat.Port(fmt.Sprintf("%d/tcp", port))] = struct{}{}
+		}
+	}
+	if svc.Healthcheck != "" {
+		config.Healthcheck = &container.HealthConfig{
+			Test:     []string{"CMD-SHELL", svc.Healthcheck},
+			Interval: 2 * time.Second,
+			Timeout:  10 * time.Second,
+			Retries:  int(serviceWaitTimeout / (2 * time.Second)),
+		}
+	}
+
+	resp, err := s.cli.ContainerCreate(ctx, config, &container.HostConfig{
+		NetworkMode: net.networkMode(),
+		ExtraHosts:  net.ExtraHosts,
+	}, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			net.Mode: {Aliases: []string{svc.Name}},
+		},
+	}, containerName)
+	if err != nil {
+		return "", err
+	}
+	s.containers = append(s.containers, resp.ID)
+
+	return resp.ID, startContainer(s.cli, resp.ID)
+}
+
+// waitHealthy waits until container is healthy, containers without
+// healthcheck are considered healthy once running.
+func waitHealthy(cli *client.Client, id string) error {
+	deadline := time.Now().Add(serviceWaitTimeout)
+	for {
+		data, err := inspectContainer(cli, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case data.State == nil:
+		case !data.State.Running:
+			return fmt.Errorf("exited with code %d", data.State.ExitCode)
+		case data.State.Health == nil || data.State.Health.Status == "healthy":
+			return nil
+		case data.State.Health.Status == "unhealthy":
+			return fmt.Errorf("unhealthy")
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s", serviceWaitTimeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// serviceLogs returns last lines of service container output.
+func serviceLogs(cli *client.Client, id string) []byte {
+	out, err := cli.ContainerLogs(context.Background(), id, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Tail: "20"})
+	if err != nil {
+		return nil
+	}
+	defer out.Close()
+	var buf bytes.Buffer
+	stdcopy.StdCopy(&buf, &buf, out)
+	return bytes.ReplaceAll(buf.Bytes(), []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	goruntime "runtime"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// usageInterval is how often resource usage of job container is sampled.
+const usageInterval = 5 * time.Second
+
+// Usage is resource usage of job container sampled while commands ran,
+// CPU is in number of CPUs and memory in bytes.
+type Usage struct {
+	CPUPeak    float64
+	CPUAvg     float64
+	MemoryPeak int64
+	MemoryAvg  int64
+	Samples    int
+}
+
+// usageSampler samples container stats in background, failed samples
+// are skipped so sampling never affects the job.
+type usageSampler struct {
+	mu      sync.Mutex
+	usage   Usage
+	cpuSum  float64
+	cpus    int // CPU samples, first sample has no previous CPU time
+	memSum  int64
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+func sampleUsage(cli *client.Client, id string) *usageSampler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &usageSampler{cancel: cancel, stopped: make(chan struct{})}
+
+	go func() {
+		defer close(s.stopped)
+		ticker := time.NewTicker(usageInterval)
+		defer ticker.Stop()
+		var prevTotal, prevSystem uint64
+		for {
+			if stats, err := containerStats(ctx, cli, id); err == nil {
+				total, system := stats.CPUStats.CPUUsage.TotalUsage, stats.CPUStats.SystemUsage
+				cpu := -1.0
+				if prevSystem > 0 && system > prevSystem && total >= prevTotal {
+					cpus := len(stats.CPUStats.CPUUsage.PercpuUsage)
+					if cpus == 0 {
+						cpus = goruntime.NumCPU()
+					}
+					cpu = float64(total-prevTotal) / float64(system-prevSystem) * float64(cpus)
+				}
+				prevTotal, prevSystem = total, system
+				s.add(cpu, memoryUsage(stats.MemoryStats))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// stop stops sampling and returns sampled usage.
+func (s *usageSampler) stop() Usage {
+	s.cancel()
+	<-s.stopped
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage := s.usage
+	if s.cpus > 0 {
+		usage.CPUAvg = s.cpuSum / float64(s.cpus)
+	}
+	if usage.Samples > 0 {
+		usage.MemoryAvg = s.memSum / int64(usage.Samples)
+	}
+	return usage
+}
+
+func (s *usageSampler) add(cpu float64, memory int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage.Samples++
+	s.memSum += memory
+	if memory > s.usage.MemoryPeak {
+		s.usage.MemoryPeak = memory
+	}
+	if cpu < 0 {
+		return
+	}
+	s.cpus++
+	s.cpuSum += cpu
+	if cpu > s.usage.CPUPeak {
+		s.usage.CPUPeak = cpu
+	}
+}
+
+func containerStats(ctx context.Context, cli *client.Client, id string) (types.StatsJSON, error) {
+	var stats types.StatsJSON
+	ctx, cancel := context.WithTimeout(ctx, usageInterval)
+	defer cancel()
+	resp, err := cli.ContainerStats(ctx, id, false)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+// memoryUsage returns memory used by container without page cache,
+// like docker stats reports it.
+func memoryUsage(mem types.MemoryStats) int64 {
+	usage := mem.Usage
+	for _, key := range []string{"inactive_file", "cache"} {
+		if cache, ok := mem.Stats[key]; ok && cache < usage {
+			usage -= cache
+			break
+		}
+	}
+	return int64(usage)
+}
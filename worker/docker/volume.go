@@ -6,14 +6,13 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/volume"
-	"github.com/docker/docker/client"
 )
 
 func createVolume(name string) types.Volume {
 	if volume, ok := findVolume(name); ok {
 		return *volume
 	}
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
@@ -26,7 +25,7 @@ func createVolume(name string) types.Volume {
 
 func removeVolume(name string) error {
 	if volume, ok := findVolume(name); ok {
-		cli, err := client.NewEnvClient()
+		cli, err := newClient()
 		if err != nil {
 			panic(err)
 		}
@@ -36,7 +35,7 @@ func removeVolume(name string) error {
 }
 
 func findVolume(name string) (*types.Volume, bool) {
-	cli, err := client.NewEnvClient()
+	cli, err := newClient()
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,49 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// Auth defines credentials repository and its submodules are cloned
+// with. Credentials are kept in memory and never written to disk.
+type Auth struct {
+	Username   string // HTTPS username, git for SSH when empty
+	Token      string // HTTPS token or password
+	Key        string // PEM encoded SSH private key, cloned over SSH when set
+	Passphrase string // of encrypted SSH private key
+}
+
+// KnownHosts returns true when SSH host keys can be verified against
+// known_hosts file, $SSH_KNOWN_HOSTS or ~/.ssh/known_hosts.
+func KnownHosts() bool {
+	_, err := gitssh.NewKnownHostsCallback()
+	return err == nil
+}
+
+func (a Auth) method() (transport.AuthMethod, error) {
+	if a.Key == "" {
+		username := a.Username
+		if username == "" {
+			username = "user"
+		}
+		return &http.BasicAuth{Username: username, Password: a.Token}, nil
+	}
+
+	username := a.Username
+	if username == "" {
+		username = "git"
+	}
+	keys, err := gitssh.NewPublicKeys(username, []byte(a.Key), a.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if callback, err := gitssh.NewKnownHostsCallback(); err == nil {
+		keys.HostKeyCallback = callback
+	} else {
+		keys.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	return keys, nil
+}
@@ -1,26 +1,68 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 )
 
-// CloneRepository clones repository contents to specified path.
-func CloneRepository(url, ref, commit, token, dir string) error {
-	auth := &http.BasicAuth{
-		Username: "user",
-		Password: token,
+// Tag fetching modes.
+const (
+	TagsFollowing = "following"
+	TagsAll       = "all"
+	TagsNone      = "none"
+)
+
+// CloneOptions defines how repository is cloned.
+type CloneOptions struct {
+	Depth      int    // 0 for full history
+	Submodules int    // submodule recursion depth, 0 disables submodules
+	Tags       string // following, all or none
+	Merge      bool   // check out tip of ref (simulated merge commit) instead of commit
+}
+
+// DefaultCloneOptions are used when job does not specify clone options.
+var DefaultCloneOptions = CloneOptions{
+	Depth:      50,
+	Submodules: int(git.DefaultSubmoduleRecursionDepth),
+	Tags:       TagsFollowing,
+}
+
+// CloneRepository clones repository contents to specified path and checks
+// out the commit, or the tip of ref when opts.Merge is set. When the commit is not part of shallow history the
+// repository is cloned again with full history.
+func CloneRepository(url, ref, commit, dir string, auth Auth, opts CloneOptions) error {
+	err := cloneRepository(url, ref, commit, dir, auth, opts)
+	if err == nil || opts.Depth == 0 || !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	opts.Depth = 0
+	return cloneRepository(url, ref, commit, dir, auth, opts)
+}
+
+func cloneRepository(url, ref, commit, dir string, credentials Auth, opts CloneOptions) error {
+	auth, err := credentials.method()
+	if err != nil {
+		return err
 	}
 
 	r, err := git.PlainClone(dir, false, &git.CloneOptions{
-		URL:               url,
-		Auth:              auth,
-		Depth:             50,
-		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		URL:        url,
+		Auth:       auth,
+		Depth:      opts.Depth,
+		Tags:       tagMode(opts.Tags),
+		NoCheckout: true,
 	})
 	if err != nil {
 		return err
@@ -32,12 +74,15 @@ func CloneRepository(url, ref, commit, token, dir string) error {
 	}
 
 	if reference.Name().String() != ref {
-		if err := r.Fetch(&git.FetchOptions{
+		err := r.Fetch(&git.FetchOptions{
 			RefSpecs: []config.RefSpec{
-				config.RefSpec(fmt.Sprintf("%s:%s", ref, ref)),
+				config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref)),
 			},
-			Auth: auth,
-		}); err != nil {
+			Depth: opts.Depth,
+			Tags:  tagMode(opts.Tags),
+			Auth:  auth,
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return err
 		}
 	}
@@ -53,11 +98,35 @@ func CloneRepository(url, ref, commit, token, dir string) error {
 		return err
 	}
 
-	if err := w.Checkout(&git.CheckoutOptions{
-		Hash: plumbing.NewHash(commit),
-	}); err != nil {
+	if !opts.Merge {
+		if err := w.Checkout(&git.CheckoutOptions{
+			Hash: plumbing.NewHash(commit),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if opts.Submodules == 0 {
+		return nil
+	}
+	submodules, err := w.Submodules()
+	if err != nil {
 		return err
 	}
+	return submodules.Update(&git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.SubmoduleRescursivity(opts.Submodules),
+		Auth:              auth,
+	})
+}
 
-	return nil
+func tagMode(tags string) git.TagMode {
+	switch tags {
+	case TagsAll:
+		return git.AllTags
+	case TagsNone:
+		return git.NoTags
+	default:
+		return git.TagFollowing
+	}
 }
@@ -1 +1,16 @@
 package git
+
+import (
+	nethttp "net/http"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// SetTransport sets transport repositories are cloned with over
+// HTTP(S), e.g. to clone through proxy.
+func SetTransport(t nethttp.RoundTripper) {
+	c := http.NewClient(&nethttp.Client{Transport: t})
+	client.InstallProtocol("http", c)
+	client.InstallProtocol("https", c)
+}
@@ -35,8 +35,9 @@ type (
 	}
 )
 
-// NewClient returns new HTTP Client instance.
-func NewClient(uri, token string) (*Client, error) {
+// NewClient returns new HTTP Client instance, requests are sent with
+// rt transport or http.DefaultTransport when nil.
+func NewClient(uri, token string, rt http.RoundTripper) (*Client, error) {
 	base, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
@@ -48,7 +49,7 @@ func NewClient(uri, token string) (*Client, error) {
 	client := &Client{
 		BaseURL: base,
 		Client: &http.Client{
-			Transport: &TokenAuth{Token: token},
+			Transport: &TokenAuth{Base: rt, Token: token},
 		},
 	}
 
@@ -79,9 +80,9 @@ func (c *Client) Do(ctx context.Context, method, path string, in, out interface{
 
 	// if an error is encountered, unmarshal and return the error response.
 	if res.Status > 300 {
-		err := new(Error)
-		json.NewDecoder(res.Body).Decode(err)
-		return res, err
+		var resp errorResponse
+		json.NewDecoder(res.Body).Decode(&resp)
+		return res, &resp.Error
 	}
 
 	return res, json.NewDecoder(res.Body).Decode(out)
@@ -136,9 +137,19 @@ func newResponse(r *http.Response) *Response {
 
 // Error represents response error.
 type Error struct {
-	Message string `json:"message"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// errorResponse is envelope errors are sent in by the API.
+type errorResponse struct {
+	Error Error `json:"error"`
 }
 
 func (e *Error) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
 	return e.Message
 }
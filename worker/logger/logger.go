@@ -3,6 +3,7 @@ package logger
 import (
 	"os"
 
+	"github.com/bleenco/abstruse/pkg/lib"
 	"github.com/bleenco/abstruse/worker/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -28,11 +29,15 @@ func New(config *config.Config) (*zap.Logger, error) {
 	})
 	cw := zapcore.Lock(os.Stdout)
 	cores := make([]zapcore.Core, 0, 2)
-	je := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	jc, cc := zap.NewProductionEncoderConfig(), zap.NewDevelopmentEncoderConfig()
+	if enc := lib.TimeEncoder(cfg.TimeFormat, nil); enc != nil {
+		jc.EncodeTime, cc.EncodeTime = enc, enc
+	}
+	je := zapcore.NewJSONEncoder(jc)
 	cores = append(cores, zapcore.NewCore(je, fw, level))
 
 	if cfg.Stdout {
-		ce := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+		ce := zapcore.NewConsoleEncoder(cc)
 		cores = append(cores, zapcore.NewCore(ce, cw, level))
 	}
 